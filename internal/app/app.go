@@ -3,20 +3,27 @@ package app
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
 	"maps"
+	"os"
+	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea/v2"
+	"github.com/charmbracelet/crush/internal/checkpoint"
 	"github.com/charmbracelet/crush/internal/config"
 	"github.com/charmbracelet/crush/internal/csync"
 	"github.com/charmbracelet/crush/internal/db"
 	"github.com/charmbracelet/crush/internal/format"
 	"github.com/charmbracelet/crush/internal/history"
+	"github.com/charmbracelet/crush/internal/hooks"
 	"github.com/charmbracelet/crush/internal/llm/agent"
+	"github.com/charmbracelet/crush/internal/llm/tools"
 	"github.com/charmbracelet/crush/internal/log"
 	"github.com/charmbracelet/crush/internal/pubsub"
 
@@ -24,14 +31,21 @@ import (
 	"github.com/charmbracelet/crush/internal/message"
 	"github.com/charmbracelet/crush/internal/permission"
 	"github.com/charmbracelet/crush/internal/session"
+	"github.com/charmbracelet/crush/internal/shell"
+	"github.com/charmbracelet/crush/internal/todo"
 )
 
 type App struct {
 	Sessions    session.Service
 	Messages    message.Service
 	History     history.Service
+	Todos       todo.Service
+	Shells      *shell.SessionShells
+	Jobs        *shell.JobManager
 	Permissions permission.Service
 
+	// CoderAgent runs each session with the agent selected for it, the
+	// coder by default.
 	CoderAgent agent.Service
 
 	LSPClients map[string]*lsp.Client
@@ -64,12 +78,20 @@ func New(ctx context.Context, conn *sql.DB, cfg *config.Config) (*App, error) {
 	if cfg.Permissions != nil && cfg.Permissions.AllowedTools != nil {
 		allowedTools = cfg.Permissions.AllowedTools
 	}
+	policy, err := permission.NewPolicy(cfg.WorkingDir(), cfg.Permissions)
+	if err != nil {
+		return nil, fmt.Errorf("invalid permission rules: %w", err)
+	}
+	grants := permission.NewGrantStore(cfg.Options.DataDirectory, filepath.Dir(config.GlobalConfigData()))
 
 	app := &App{
 		Sessions:    sessions,
 		Messages:    messages,
 		History:     files,
-		Permissions: permission.NewPermissionService(cfg.WorkingDir(), skipPermissionsRequests, allowedTools),
+		Todos:       todo.NewService(q),
+		Shells:      shell.NewSessionShells(cfg.WorkingDir(), sessions),
+		Jobs:        shell.NewJobManager(),
+		Permissions: permission.NewPermissionService(cfg.WorkingDir(), skipPermissionsRequests, allowedTools, policy, grants),
 		LSPClients:  make(map[string]*lsp.Client),
 
 		globalCtx: ctx,
@@ -83,6 +105,14 @@ func New(ctx context.Context, conn *sql.DB, cfg *config.Config) (*App, error) {
 		tuiWG:           &sync.WaitGroup{},
 	}
 
+	if cfg.Sandbox.Enabled {
+		app.Shells.SetSandbox(shell.NewSandbox(cfg.WorkingDir(), cfg.Sandbox.WritablePaths, cfg.Sandbox.AllowNetwork))
+	}
+
+	if len(cfg.Hooks.PermissionRequest) > 0 {
+		app.Permissions.SetRequestHook(app.permissionRequestHook)
+	}
+
 	app.setupEvents()
 
 	// Initialize LSP clients in the background.
@@ -99,19 +129,101 @@ func New(ctx context.Context, conn *sql.DB, cfg *config.Config) (*App, error) {
 	return app, nil
 }
 
+// permissionRequestHook runs the permission request hooks of the
+// configuration, denying the requests they block.
+func (app *App) permissionRequestHook(req permission.PermissionRequest) error {
+	input, err := json.Marshal(req.Params)
+	if err != nil {
+		slog.Error("Failed to encode permission request parameters", "error", err)
+	}
+	result := hooks.Run(app.globalCtx, app.config.Hooks.PermissionRequest, hooks.Input{
+		Event:       hooks.PermissionRequest,
+		SessionID:   req.SessionID,
+		WorkingDir:  app.config.WorkingDir(),
+		ToolName:    req.ToolName,
+		ToolInput:   input,
+		Action:      req.Action,
+		Path:        req.Path,
+		Description: req.Description,
+	})
+	if result.Blocked {
+		return &permission.DeniedError{Reason: result.Message}
+	}
+	return nil
+}
+
 // Config returns the application configuration.
 func (app *App) Config() *config.Config {
 	return app.config
 }
 
+// DeniedToolCallsError is returned by non-interactive runs in which tool
+// calls were denied, so scripts can tell them apart from runs that failed.
+type DeniedToolCallsError struct {
+	Denials []permission.PermissionNotification
+	// Stopped is set when the run was stopped by a tool call needing
+	// approval.
+	Stopped bool
+}
+
+func (e *DeniedToolCallsError) Error() string {
+	var sb strings.Builder
+	if e.Stopped {
+		sb.WriteString("run stopped because a tool call needed approval")
+	} else {
+		fmt.Fprintf(&sb, "%d tool calls were denied", len(e.Denials))
+	}
+	for _, denial := range e.Denials {
+		fmt.Fprintf(&sb, "\n- %s: %s", denial.ToolName, denial.Reason)
+	}
+	return sb.String()
+}
+
+// ExitCode is the exit status of the run: 2 when tool calls were denied, and
+// 3 when the run was stopped.
+func (e *DeniedToolCallsError) ExitCode() int {
+	if e.Stopped {
+		return 3
+	}
+	return 2
+}
+
+// RunOptions configures a non-interactive run.
+type RunOptions struct {
+	// Quiet hides the spinner.
+	Quiet bool
+	// PermissionMode handles the tool calls needing approval.
+	PermissionMode permission.Mode
+	// OutputFormat is the format of what's printed to stdout. Defaults to
+	// text.
+	OutputFormat format.OutputFormat
+	// SessionID is the session to append the run to. A new session is
+	// created when empty.
+	SessionID string
+	// Agent is the agent the session is run with from now on. The agent
+	// the session was run with is kept when empty.
+	Agent string
+	// Plan switches the session in or out of plan mode, where the agent
+	// only plans the work, which is carried out by a later run out of it. The
+	// mode the session was in is kept when nil.
+	Plan *bool
+}
+
 // RunNonInteractive handles the execution flow when a prompt is provided via
 // CLI flag.
-func (app *App) RunNonInteractive(ctx context.Context, prompt string, quiet bool) error {
+func (app *App) RunNonInteractive(ctx context.Context, prompt string, opts RunOptions) error {
 	slog.Info("Running in non-interactive mode")
 
+	if _, ok := app.config.Agents[opts.Agent]; opts.Agent != "" && !ok {
+		return fmt.Errorf("agent %s not found", opts.Agent)
+	}
+
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
+	jsonOutput := opts.OutputFormat == format.JSON || opts.OutputFormat == format.StreamJSON
+	quiet := opts.Quiet || jsonOutput
+
 	// Start spinner if not in quiet mode.
 	var spinner *format.Spinner
 	if !quiet {
@@ -128,32 +240,49 @@ func (app *App) RunNonInteractive(ctx context.Context, prompt string, quiet bool
 	}
 	defer stopSpinner()
 
-	const maxPromptLengthForTitle = 100
-	titlePrefix := "Non-interactive: "
-	var titleSuffix string
+	sess, err := app.runSession(ctx, prompt, opts.SessionID)
+	if err != nil {
+		return err
+	}
+	if sess, err = app.applyRunOptions(ctx, sess, opts); err != nil {
+		return err
+	}
 
-	if len(prompt) > maxPromptLengthForTitle {
-		titleSuffix = prompt[:maxPromptLengthForTitle] + "..."
-	} else {
-		titleSuffix = prompt
+	// Nobody is around to answer permission requests, so they're handled
+	// according to the mode.
+	app.Permissions.SetSessionMode(sess.ID, opts.PermissionMode)
+
+	var events *runEventWriter
+	if jsonOutput {
+		events = newRunEventWriter(os.Stdout, opts.OutputFormat == format.StreamJSON, sess.ID)
+		modelType := config.SelectedModelTypeLarge
+		if agentCfg, ok := app.config.Agents[sess.AgentID]; ok {
+			modelType = agentCfg.Model
+		}
+		events.init(app.config.Models[modelType].Model, app.config.Models[modelType].Provider)
 	}
-	title := titlePrefix + titleSuffix
 
-	sess, err := app.Sessions.Create(ctx, title)
-	if err != nil {
-		return fmt.Errorf("failed to create session for non-interactive mode: %w", err)
+	var denials []permission.PermissionNotification
+	notifications := app.Permissions.SubscribeNotifications(ctx)
+	handleNotification := func(event pubsub.Event[permission.PermissionNotification]) {
+		if event.Payload.Denied && event.Payload.Reason != "" {
+			denials = append(denials, event.Payload)
+		}
+		if events != nil {
+			events.permission(event.Payload)
+		}
 	}
-	slog.Info("Created session for non-interactive run", "session_id", sess.ID)
 
-	// Automatically approve all permission requests for this non-interactive session
-	app.Permissions.AutoApproveSession(sess.ID)
+	messageEvents := app.Messages.Subscribe(ctx)
+	sessionEvents := app.Sessions.Subscribe(ctx)
+	agentEvents := app.CoderAgent.Subscribe(ctx)
+	start := time.Now()
 
 	done, err := app.CoderAgent.Run(ctx, sess.ID, prompt)
 	if err != nil {
 		return fmt.Errorf("failed to start agent processing stream: %w", err)
 	}
 
-	messageEvents := app.Messages.Subscribe(ctx)
 	readBts := 0
 
 	for {
@@ -161,26 +290,83 @@ func (app *App) RunNonInteractive(ctx context.Context, prompt string, quiet bool
 		case result := <-done:
 			stopSpinner()
 
+			// Pick up the events that weren't handled yet.
+			for pending := true; pending; {
+				select {
+				case event := <-notifications:
+					handleNotification(event)
+				case event := <-agentEvents:
+					if events != nil {
+						events.toolOutput(event.Payload)
+					}
+				case event := <-messageEvents:
+					if events != nil {
+						events.message(event.Payload)
+					}
+				default:
+					pending = false
+				}
+			}
+
+			var runErr error
+			cancelled := false
 			if result.Error != nil {
 				if errors.Is(result.Error, context.Canceled) || errors.Is(result.Error, agent.ErrRequestCancelled) {
 					slog.Info("Non-interactive: agent processing cancelled", "session_id", sess.ID)
-					return nil
+					cancelled = true
+				} else {
+					runErr = fmt.Errorf("agent processing failed: %w", result.Error)
+				}
+			} else {
+				stopped := result.Message.FinishReason() == message.FinishReasonPermissionDenied
+				if stopped || len(denials) > 0 {
+					runErr = &DeniedToolCallsError{Denials: denials, Stopped: stopped}
 				}
-				return fmt.Errorf("agent processing failed: %w", result.Error)
 			}
 
-			msgContent := result.Message.Content().String()
-			if len(msgContent) < readBts {
-				slog.Error("Non-interactive: message content is shorter than read bytes", "message_length", len(msgContent), "read_bytes", readBts)
-				return fmt.Errorf("message content is shorter than read bytes: %d < %d", len(msgContent), readBts)
+			if events != nil {
+				events.message(result.Message)
+				final := RunEvent{
+					Result:       result.Message.Content().String(),
+					FinishReason: result.Message.FinishReason(),
+					Denials:      denials,
+					IsError:      runErr != nil || cancelled,
+					DurationMS:   time.Since(start).Milliseconds(),
+				}
+				if runErr != nil {
+					final.Error = runErr.Error()
+				} else if cancelled {
+					final.Error = "request cancelled"
+				}
+				if updated, err := app.Sessions.Get(ctx, sess.ID); err == nil {
+					usage := sessionUsage(updated)
+					final.Usage = &usage
+				}
+				if plan, ok, err := app.PendingPlan(ctx, sess.ID); err == nil && ok {
+					final.Plan = &plan
+				}
+				events.result(final)
+			} else if result.Error == nil {
+				msgContent := result.Message.Content().String()
+				if len(msgContent) < readBts {
+					slog.Error("Non-interactive: message content is shorter than read bytes", "message_length", len(msgContent), "read_bytes", readBts)
+					return fmt.Errorf("message content is shorter than read bytes: %d < %d", len(msgContent), readBts)
+				}
+				fmt.Println(msgContent[readBts:])
+				if plan, ok, err := app.PendingPlan(ctx, sess.ID); err == nil && ok {
+					fmt.Printf("\n%s\n", plan.Markdown())
+				}
 			}
-			fmt.Println(msgContent[readBts:])
 
 			slog.Info("Non-interactive: run completed", "session_id", sess.ID)
-			return nil
+			return runErr
 
 		case event := <-messageEvents:
 			msg := event.Payload
+			if events != nil {
+				events.message(msg)
+				continue
+			}
 			if msg.SessionID == sess.ID && msg.Role == message.Assistant && len(msg.Parts) > 0 {
 				stopSpinner()
 				part := msg.Content().String()[readBts:]
@@ -188,6 +374,19 @@ func (app *App) RunNonInteractive(ctx context.Context, prompt string, quiet bool
 				readBts += len(part)
 			}
 
+		case event := <-sessionEvents:
+			if events != nil {
+				events.session(event.Payload)
+			}
+
+		case event := <-agentEvents:
+			if events != nil {
+				events.toolOutput(event.Payload)
+			}
+
+		case event := <-notifications:
+			handleNotification(event)
+
 		case <-ctx.Done():
 			stopSpinner()
 			return ctx.Err()
@@ -195,10 +394,160 @@ func (app *App) RunNonInteractive(ctx context.Context, prompt string, quiet bool
 	}
 }
 
+// runSession returns the session with the given ID, or a new one titled after
+// the prompt when the ID is empty.
+func (app *App) runSession(ctx context.Context, prompt, sessionID string) (session.Session, error) {
+	if sessionID != "" {
+		sess, err := app.Sessions.Get(ctx, sessionID)
+		if err != nil {
+			return session.Session{}, fmt.Errorf("failed to get session %s: %w", sessionID, err)
+		}
+		slog.Info("Resuming session for non-interactive run", "session_id", sess.ID)
+		return sess, nil
+	}
+
+	const maxPromptLengthForTitle = 100
+	titlePrefix := "Non-interactive: "
+	var titleSuffix string
+
+	if len(prompt) > maxPromptLengthForTitle {
+		titleSuffix = prompt[:maxPromptLengthForTitle] + "..."
+	} else {
+		titleSuffix = prompt
+	}
+	title := titlePrefix + titleSuffix
+
+	sess, err := app.Sessions.Create(ctx, title)
+	if err != nil {
+		return session.Session{}, fmt.Errorf("failed to create session for non-interactive mode: %w", err)
+	}
+	slog.Info("Created session for non-interactive run", "session_id", sess.ID)
+	return sess, nil
+}
+
 func (app *App) UpdateAgentModel() error {
 	return app.CoderAgent.UpdateModel()
 }
 
+// Retry runs the last turn of a session again with the current model, with
+// the same prompt and attachments. The discarded attempt is kept in a child
+// session, which is returned.
+func (app *App) Retry(ctx context.Context, sessionID string) (session.Session, error) {
+	if app.CoderAgent == nil {
+		return session.Session{}, fmt.Errorf("coder agent is not initialized")
+	}
+	if app.CoderAgent.IsSessionBusy(sessionID) {
+		return session.Session{}, fmt.Errorf("session %s is busy", sessionID)
+	}
+	services := checkpoint.Services{Sessions: app.Sessions, Messages: app.Messages, History: app.History}
+	attempt, err := checkpoint.DiscardLastTurn(ctx, services, sessionID)
+	if err != nil {
+		return session.Session{}, err
+	}
+	var attachments []message.Attachment
+	for _, bc := range attempt.Prompt.BinaryContent() {
+		attachments = append(attachments, message.Attachment{
+			FilePath: bc.Path,
+			FileName: filepath.Base(bc.Path),
+			MimeType: bc.MIMEType,
+			Content:  bc.Data,
+		})
+	}
+	if _, err := app.CoderAgent.Run(ctx, sessionID, attempt.Prompt.Content().Text, attachments...); err != nil {
+		return session.Session{}, err
+	}
+	return attempt.Session, nil
+}
+
+// applyRunOptions sets the agent and the plan mode the session is run with,
+// when they're given.
+func (app *App) applyRunOptions(ctx context.Context, sess session.Session, opts RunOptions) (session.Session, error) {
+	var err error
+	if opts.Agent != "" && opts.Agent != sess.AgentID {
+		sess.AgentID = opts.Agent
+		if sess, err = app.Sessions.Save(ctx, sess); err != nil {
+			return session.Session{}, fmt.Errorf("failed to set the agent of the session: %w", err)
+		}
+	}
+	if opts.Plan != nil && *opts.Plan != sess.PlanMode {
+		if sess, err = app.SetPlanMode(ctx, sess.ID, *opts.Plan); err != nil {
+			return session.Session{}, fmt.Errorf("failed to set the plan mode of the session: %w", err)
+		}
+	}
+	return sess, nil
+}
+
+// SetPlanMode switches the session in or out of plan mode, where the agent
+// only plans the work with read-only tools until the plan is approved.
+func (app *App) SetPlanMode(ctx context.Context, sessionID string, planMode bool) (session.Session, error) {
+	if app.CoderAgent != nil && app.CoderAgent.IsSessionBusy(sessionID) {
+		return session.Session{}, fmt.Errorf("session %s is busy", sessionID)
+	}
+	sess, err := app.Sessions.Get(ctx, sessionID)
+	if err != nil {
+		return session.Session{}, fmt.Errorf("failed to get session: %w", err)
+	}
+	if sess.PlanMode == planMode {
+		return sess, nil
+	}
+	sess.PlanMode = planMode
+	return app.Sessions.Save(ctx, sess)
+}
+
+// PendingPlan returns the plan waiting for approval in the session, the one
+// submitted in its last turn if it's still in plan mode.
+func (app *App) PendingPlan(ctx context.Context, sessionID string) (tools.PlanParams, bool, error) {
+	sess, err := app.Sessions.Get(ctx, sessionID)
+	if err != nil {
+		return tools.PlanParams{}, false, fmt.Errorf("failed to get session: %w", err)
+	}
+	if !sess.PlanMode {
+		return tools.PlanParams{}, false, nil
+	}
+	msgs, err := app.Messages.List(ctx, sessionID)
+	if err != nil {
+		return tools.PlanParams{}, false, fmt.Errorf("failed to list messages: %w", err)
+	}
+	plan, ok := agent.PendingPlan(msgs)
+	return plan, ok, nil
+}
+
+// ApprovePlan takes the session out of plan mode and has the agent carry out
+// the plan with all of its tools.
+func (app *App) ApprovePlan(ctx context.Context, sessionID string, plan tools.PlanParams) error {
+	if _, err := app.SetPlanMode(ctx, sessionID, false); err != nil {
+		return err
+	}
+	_, err := app.CoderAgent.Run(ctx, sessionID, ExecutePlanPrompt(plan))
+	return err
+}
+
+// ExecutePlanPrompt returns the prompt asking the agent to carry out the
+// plan.
+func ExecutePlanPrompt(plan tools.PlanParams) string {
+	return "The plan is approved, carry it out:\n\n" + plan.Markdown()
+}
+
+// Rewind rewinds a session to its checkpoint, and moves the shell of the
+// session back to the working directory it was in at the checkpoint, or to
+// the working directory of the app when it's gone.
+func (app *App) Rewind(ctx context.Context, c checkpoint.Checkpoint, force bool) error {
+	if app.CoderAgent != nil && app.CoderAgent.IsSessionBusy(c.SessionID) {
+		return fmt.Errorf("session %s is busy", c.SessionID)
+	}
+	services := checkpoint.Services{Sessions: app.Sessions, Messages: app.Messages, History: app.History}
+	if err := checkpoint.Rewind(ctx, services, c, force); err != nil {
+		return err
+	}
+	sh := app.Shells.Get(ctx, c.SessionID)
+	if c.WorkingDir == "" || sh.SetWorkingDir(c.WorkingDir) != nil {
+		if err := sh.SetWorkingDir(app.config.WorkingDir()); err != nil {
+			return fmt.Errorf("failed to reset the shell working directory: %w", err)
+		}
+	}
+	return app.Shells.Save(ctx, c.SessionID)
+}
+
 func (app *App) setupEvents() {
 	ctx, cancel := context.WithCancel(app.globalCtx)
 	app.eventsCtx = ctx
@@ -207,6 +556,8 @@ func (app *App) setupEvents() {
 	setupSubscriber(ctx, app.serviceEventsWG, "permissions", app.Permissions.Subscribe, app.events)
 	setupSubscriber(ctx, app.serviceEventsWG, "permissions-notifications", app.Permissions.SubscribeNotifications, app.events)
 	setupSubscriber(ctx, app.serviceEventsWG, "history", app.History.Subscribe, app.events)
+	setupSubscriber(ctx, app.serviceEventsWG, "todos", app.Todos.Subscribe, app.events)
+	setupSubscriber(ctx, app.serviceEventsWG, "jobs", app.Jobs.Subscribe, app.events)
 	setupSubscriber(ctx, app.serviceEventsWG, "mcp", agent.SubscribeMCPEvents, app.events)
 	setupSubscriber(ctx, app.serviceEventsWG, "lsp", SubscribeLSPEvents, app.events)
 	cleanupFunc := func() {
@@ -252,18 +603,16 @@ func setupSubscriber[T any](
 }
 
 func (app *App) InitCoderAgent() error {
-	coderAgentCfg := app.config.Agents["coder"]
-	if coderAgentCfg.ID == "" {
-		return fmt.Errorf("coder agent configuration is missing")
-	}
 	var err error
-	app.CoderAgent, err = agent.NewAgent(
+	app.CoderAgent, err = agent.NewCoordinator(
 		app.globalCtx,
-		coderAgentCfg,
 		app.Permissions,
 		app.Sessions,
 		app.Messages,
 		app.History,
+		app.Todos,
+		app.Shells,
+		app.Jobs,
 		app.LSPClients,
 	)
 	if err != nil {
@@ -315,6 +664,9 @@ func (app *App) Shutdown() {
 		app.CoderAgent.CancelAll()
 	}
 
+	// Kill the commands still running in the background.
+	app.Jobs.KillAll()
+
 	for cancel := range app.watcherCancelFuncs.Seq() {
 		cancel()
 	}
@@ -0,0 +1,48 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/db"
+	"github.com/charmbracelet/crush/internal/session"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyRunOptions(t *testing.T) {
+	t.Parallel()
+
+	newPlanSession := func(t *testing.T) (*App, session.Session) {
+		t.Helper()
+		conn, err := db.Connect(t.Context(), t.TempDir())
+		require.NoError(t, err)
+		t.Cleanup(func() { conn.Close() })
+		app := &App{Sessions: session.NewService(db.New(conn))}
+		sess, err := app.Sessions.Create(t.Context(), "Plan the work")
+		require.NoError(t, err)
+		sess, err = app.SetPlanMode(t.Context(), sess.ID, true)
+		require.NoError(t, err)
+		return app, sess
+	}
+
+	t.Run("resuming keeps plan mode", func(t *testing.T) {
+		t.Parallel()
+
+		app, sess := newPlanSession(t)
+		sess, err := app.applyRunOptions(t.Context(), sess, RunOptions{})
+		require.NoError(t, err)
+		require.True(t, sess.PlanMode)
+		sess, err = app.Sessions.Get(t.Context(), sess.ID)
+		require.NoError(t, err)
+		require.True(t, sess.PlanMode)
+	})
+
+	t.Run("resuming with plan off leaves plan mode", func(t *testing.T) {
+		t.Parallel()
+
+		app, sess := newPlanSession(t)
+		plan := false
+		sess, err := app.applyRunOptions(t.Context(), sess, RunOptions{Plan: &plan})
+		require.NoError(t, err)
+		require.False(t, sess.PlanMode)
+	})
+}
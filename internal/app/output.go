@@ -0,0 +1,213 @@
+package app
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+
+	"github.com/charmbracelet/crush/internal/llm/agent"
+	"github.com/charmbracelet/crush/internal/llm/tools"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/charmbracelet/crush/internal/permission"
+	"github.com/charmbracelet/crush/internal/session"
+)
+
+// RunEventType is the type of the events printed by non-interactive runs
+// with JSON output.
+type RunEventType string
+
+const (
+	RunEventInit       RunEventType = "init"
+	RunEventText       RunEventType = "text"
+	RunEventReasoning  RunEventType = "reasoning"
+	RunEventToolUse    RunEventType = "tool_use"
+	RunEventToolCall   RunEventType = "tool_call"
+	RunEventToolOutput RunEventType = "tool_output"
+	RunEventToolResult RunEventType = "tool_result"
+	RunEventPermission RunEventType = "permission"
+	RunEventUsage      RunEventType = "usage"
+	RunEventResult     RunEventType = "result"
+)
+
+// RunEvent is a single line of the output of non-interactive runs with
+// stream-json output. Only the fields relevant to the type are set.
+type RunEvent struct {
+	Type      RunEventType `json:"type"`
+	SessionID string       `json:"session_id,omitempty"`
+
+	// init
+	Model    string `json:"model,omitempty"`
+	Provider string `json:"provider,omitempty"`
+
+	// text and reasoning, and tool_output for the delta
+	MessageID string `json:"message_id,omitempty"`
+	Delta     string `json:"delta,omitempty"`
+
+	// tool_use, tool_call, tool_output, tool_result and permission
+	ToolCallID string `json:"tool_call_id,omitempty"`
+	ToolName   string `json:"tool_name,omitempty"`
+	Input      string `json:"input,omitempty"`
+	Content    string `json:"content,omitempty"`
+	Metadata   string `json:"metadata,omitempty"`
+	Granted    bool   `json:"granted,omitempty"`
+	Denied     bool   `json:"denied,omitempty"`
+	Reason     string `json:"reason,omitempty"`
+
+	// usage and result
+	Usage *RunUsage `json:"usage,omitempty"`
+
+	// result
+	Result       string                              `json:"result,omitempty"`
+	FinishReason message.FinishReason                `json:"finish_reason,omitempty"`
+	Denials      []permission.PermissionNotification `json:"denials,omitempty"`
+	IsError      bool                                `json:"is_error,omitempty"`
+	Error        string                              `json:"error,omitempty"`
+	DurationMS   int64                               `json:"duration_ms,omitempty"`
+	// Plan is the plan submitted for approval in plan mode.
+	Plan *tools.PlanParams `json:"plan,omitempty"`
+}
+
+// RunUsage is the token usage and cost of the session of a run.
+type RunUsage struct {
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	Cost             float64 `json:"cost"`
+}
+
+func sessionUsage(sess session.Session) RunUsage {
+	return RunUsage{
+		PromptTokens:     sess.PromptTokens,
+		CompletionTokens: sess.CompletionTokens,
+		Cost:             sess.Cost,
+	}
+}
+
+// runEventWriter turns the updates of the session of a run into events. When
+// streaming, every event is written as it happens, otherwise only the result
+// is.
+type runEventWriter struct {
+	enc       *json.Encoder
+	stream    bool
+	sessionID string
+
+	text        map[string]int // length of the text written, per message
+	reasoning   map[string]int // length of the reasoning written, per message
+	toolUses    map[string]bool // tool calls started
+	toolCalls   map[string]bool // tool calls with their whole input
+	toolResults map[string]bool
+	usage       RunUsage
+}
+
+func newRunEventWriter(w io.Writer, stream bool, sessionID string) *runEventWriter {
+	return &runEventWriter{
+		enc:         json.NewEncoder(w),
+		stream:      stream,
+		sessionID:   sessionID,
+		text:        make(map[string]int),
+		reasoning:   make(map[string]int),
+		toolUses:    make(map[string]bool),
+		toolCalls:   make(map[string]bool),
+		toolResults: make(map[string]bool),
+	}
+}
+
+func (w *runEventWriter) write(event RunEvent) {
+	if !w.stream && event.Type != RunEventResult {
+		return
+	}
+	event.SessionID = w.sessionID
+	if err := w.enc.Encode(event); err != nil {
+		slog.Error("Failed to write run event", "type", event.Type, "error", err)
+	}
+}
+
+func (w *runEventWriter) init(model, provider string) {
+	w.write(RunEvent{Type: RunEventInit, Model: model, Provider: provider})
+}
+
+// message writes what's new in the given message since it was last seen.
+func (w *runEventWriter) message(msg message.Message) {
+	if msg.SessionID != w.sessionID {
+		return
+	}
+	switch msg.Role {
+	case message.Assistant:
+		if reasoning := msg.ReasoningContent().Thinking; len(reasoning) > w.reasoning[msg.ID] {
+			w.write(RunEvent{Type: RunEventReasoning, MessageID: msg.ID, Delta: reasoning[w.reasoning[msg.ID]:]})
+			w.reasoning[msg.ID] = len(reasoning)
+		}
+		if text := msg.Content().Text; len(text) > w.text[msg.ID] {
+			w.write(RunEvent{Type: RunEventText, MessageID: msg.ID, Delta: text[w.text[msg.ID]:]})
+			w.text[msg.ID] = len(text)
+		}
+		for _, call := range msg.ToolCalls() {
+			// Calls are reported as soon as they start, while the model is
+			// still writing their input, and again once it's complete.
+			if !w.toolUses[call.ID] {
+				w.toolUses[call.ID] = true
+				w.write(RunEvent{Type: RunEventToolUse, MessageID: msg.ID, ToolCallID: call.ID, ToolName: call.Name})
+			}
+			if !call.Finished || w.toolCalls[call.ID] {
+				continue
+			}
+			w.toolCalls[call.ID] = true
+			w.write(RunEvent{Type: RunEventToolCall, MessageID: msg.ID, ToolCallID: call.ID, ToolName: call.Name, Input: call.Input})
+		}
+	case message.Tool:
+		for _, result := range msg.ToolResults() {
+			if w.toolResults[result.ToolCallID] {
+				continue
+			}
+			w.toolResults[result.ToolCallID] = true
+			w.write(RunEvent{
+				Type:       RunEventToolResult,
+				MessageID:  msg.ID,
+				ToolCallID: result.ToolCallID,
+				ToolName:   result.Name,
+				Content:    result.Content,
+				Metadata:   result.Metadata,
+				IsError:    result.IsError,
+			})
+		}
+	}
+}
+
+// toolOutput writes the output printed by a running tool call.
+func (w *runEventWriter) toolOutput(event agent.AgentEvent) {
+	if event.Type != agent.AgentEventTypeToolOutput || event.SessionID != w.sessionID {
+		return
+	}
+	w.write(RunEvent{Type: RunEventToolOutput, ToolCallID: event.ToolCallID, Delta: event.Output})
+}
+
+// permission writes the outcome of a permission request. Notifications of
+// pending requests are skipped.
+func (w *runEventWriter) permission(n permission.PermissionNotification) {
+	if !n.Granted && !n.Denied {
+		return
+	}
+	w.write(RunEvent{
+		Type:       RunEventPermission,
+		ToolCallID: n.ToolCallID,
+		ToolName:   n.ToolName,
+		Granted:    n.Granted,
+		Denied:     n.Denied,
+		Reason:     n.Reason,
+	})
+}
+
+// session writes the usage of the session when it changed.
+func (w *runEventWriter) session(sess session.Session) {
+	if sess.ID != w.sessionID {
+		return
+	}
+	if usage := sessionUsage(sess); usage != w.usage {
+		w.usage = usage
+		w.write(RunEvent{Type: RunEventUsage, Usage: &usage})
+	}
+}
+
+func (w *runEventWriter) result(event RunEvent) {
+	event.Type = RunEventResult
+	w.write(event)
+}
@@ -0,0 +1,97 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/llm/agent"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/charmbracelet/crush/internal/permission"
+	"github.com/charmbracelet/crush/internal/session"
+	"github.com/stretchr/testify/require"
+)
+
+func decodeRunEvents(t *testing.T, buf *bytes.Buffer) []RunEvent {
+	t.Helper()
+	var events []RunEvent
+	dec := json.NewDecoder(buf)
+	for dec.More() {
+		var event RunEvent
+		require.NoError(t, dec.Decode(&event))
+		events = append(events, event)
+	}
+	return events
+}
+
+func TestRunEventWriter(t *testing.T) {
+	t.Parallel()
+
+	t.Run("stream", func(t *testing.T) {
+		t.Parallel()
+
+		var buf bytes.Buffer
+		w := newRunEventWriter(&buf, true, "session")
+		w.init("model", "provider")
+
+		msg := message.Message{ID: "assistant", Role: message.Assistant, SessionID: "session"}
+		msg.AppendReasoningContent("Thinking")
+		msg.AppendContent("Hello")
+		w.message(msg)
+		msg.AppendContent(", world")
+		msg.AddToolCall(message.ToolCall{ID: "call", Name: "view", Input: `{"file_path":"main.go"}`})
+		w.message(msg)
+		msg.FinishToolCall("call")
+		w.message(msg)
+		w.message(msg)
+
+		// Messages of other sessions, like the ones of sub-agents, are skipped.
+		w.message(message.Message{ID: "other", Role: message.Assistant, SessionID: "other", Parts: []message.ContentPart{message.TextContent{Text: "Hidden"}}})
+
+		w.permission(permission.PermissionNotification{ToolCallID: "call"})
+		w.permission(permission.PermissionNotification{ToolCallID: "call", ToolName: "view", Granted: true})
+		w.toolOutput(agent.AgentEvent{Type: agent.AgentEventTypeToolOutput, SessionID: "session", ToolCallID: "call", Output: "building\n"})
+		w.toolOutput(agent.AgentEvent{Type: agent.AgentEventTypeToolOutput, SessionID: "other", ToolCallID: "nested", Output: "hidden\n"})
+
+		result := message.Message{ID: "result", Role: message.Tool, SessionID: "session"}
+		result.AddToolResult(message.ToolResult{ToolCallID: "call", Name: "view", Content: "package main"})
+		w.message(result)
+		w.message(result)
+
+		w.session(session.Session{ID: "session", PromptTokens: 10, CompletionTokens: 5, Cost: 0.01})
+		w.session(session.Session{ID: "session", PromptTokens: 10, CompletionTokens: 5, Cost: 0.01})
+		w.result(RunEvent{Result: "Hello, world", FinishReason: message.FinishReasonEndTurn})
+
+		usage := RunUsage{PromptTokens: 10, CompletionTokens: 5, Cost: 0.01}
+		require.Equal(t, []RunEvent{
+			{Type: RunEventInit, SessionID: "session", Model: "model", Provider: "provider"},
+			{Type: RunEventReasoning, SessionID: "session", MessageID: "assistant", Delta: "Thinking"},
+			{Type: RunEventText, SessionID: "session", MessageID: "assistant", Delta: "Hello"},
+			{Type: RunEventText, SessionID: "session", MessageID: "assistant", Delta: ", world"},
+			{Type: RunEventToolUse, SessionID: "session", MessageID: "assistant", ToolCallID: "call", ToolName: "view"},
+			{Type: RunEventToolCall, SessionID: "session", MessageID: "assistant", ToolCallID: "call", ToolName: "view", Input: `{"file_path":"main.go"}`},
+			{Type: RunEventPermission, SessionID: "session", ToolCallID: "call", ToolName: "view", Granted: true},
+			{Type: RunEventToolOutput, SessionID: "session", ToolCallID: "call", Delta: "building\n"},
+			{Type: RunEventToolResult, SessionID: "session", MessageID: "result", ToolCallID: "call", ToolName: "view", Content: "package main"},
+			{Type: RunEventUsage, SessionID: "session", Usage: &usage},
+			{Type: RunEventResult, SessionID: "session", Result: "Hello, world", FinishReason: message.FinishReasonEndTurn},
+		}, decodeRunEvents(t, &buf))
+	})
+
+	t.Run("result only", func(t *testing.T) {
+		t.Parallel()
+
+		var buf bytes.Buffer
+		w := newRunEventWriter(&buf, false, "session")
+		w.init("model", "provider")
+		msg := message.Message{ID: "assistant", Role: message.Assistant, SessionID: "session"}
+		msg.AppendContent("Hello")
+		w.message(msg)
+		w.permission(permission.PermissionNotification{ToolCallID: "call", ToolName: "bash", Denied: true, Reason: "denied"})
+		w.result(RunEvent{Result: "Hello", IsError: true, Error: "denied"})
+
+		require.Equal(t, []RunEvent{
+			{Type: RunEventResult, SessionID: "session", Result: "Hello", IsError: true, Error: "denied"},
+		}, decodeRunEvents(t, &buf))
+	})
+}
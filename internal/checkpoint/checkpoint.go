@@ -0,0 +1,172 @@
+// Package checkpoint rewinds sessions to the state they were in before one of
+// their user messages, both the conversation and the files, and forks them
+// into new sessions from any of their messages.
+package checkpoint
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/charmbracelet/crush/internal/history"
+	"github.com/charmbracelet/crush/internal/llm/tools"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/charmbracelet/crush/internal/session"
+)
+
+// Services are the services sessions are rewound with.
+type Services struct {
+	Sessions session.Service
+	Messages message.Service
+	History  history.Service
+}
+
+// Checkpoint is the state of a session right before one of its user
+// messages.
+type Checkpoint struct {
+	SessionID string
+	// Message is the user message the session is rewound to before.
+	Message message.Message
+	// Messages are the messages removed by the rewind, the user message and
+	// every message after it.
+	Messages []message.Message
+	// Reverts are the files restored by the rewind.
+	Reverts []history.Revert
+	// WorkingDir is the working directory the shell of the session was in,
+	// empty when no command had run yet.
+	WorkingDir string
+}
+
+// Conflicts returns the files modified outside of crush since the agent last
+// wrote them.
+func (c Checkpoint) Conflicts() []history.Revert {
+	var conflicts []history.Revert
+	for _, r := range c.Reverts {
+		if r.Conflict {
+			conflicts = append(conflicts, r)
+		}
+	}
+	return conflicts
+}
+
+// Plan returns the checkpoint of the session before the given user message.
+func Plan(ctx context.Context, s Services, sessionID, messageID string) (Checkpoint, error) {
+	msgs, idx, err := findMessage(ctx, s, sessionID, messageID)
+	if err != nil {
+		return Checkpoint{}, err
+	}
+	if msgs[idx].Role != message.User {
+		return Checkpoint{}, fmt.Errorf("can only rewind to before a user message")
+	}
+	reverts, err := history.PlanSessionRevert(ctx, s.History, sessionID, msgs[idx].Seq)
+	if err != nil {
+		return Checkpoint{}, err
+	}
+	return Checkpoint{
+		SessionID:  sessionID,
+		Message:    msgs[idx],
+		Messages:   msgs[idx:],
+		Reverts:    reverts,
+		WorkingDir: workingDir(msgs[:idx]),
+	}, nil
+}
+
+// workingDir returns the working directory the last command of the messages
+// ran in.
+func workingDir(msgs []message.Message) string {
+	for _, msg := range slices.Backward(msgs) {
+		for _, result := range slices.Backward(msg.ToolResults()) {
+			if result.Name != tools.BashToolName || result.Metadata == "" {
+				continue
+			}
+			var metadata tools.BashResponseMetadata
+			if err := json.Unmarshal([]byte(result.Metadata), &metadata); err == nil && metadata.WorkingDirectory != "" {
+				return metadata.WorkingDirectory
+			}
+		}
+	}
+	return ""
+}
+
+// Rewind restores the files of the checkpoint and removes its messages from
+// the session. Unless forced, nothing is done when any of the files has a
+// conflict.
+func Rewind(ctx context.Context, s Services, c Checkpoint, force bool) error {
+	if err := history.ApplyReverts(ctx, s.History, c.SessionID, c.Reverts, force); err != nil {
+		return err
+	}
+
+	return removeMessages(ctx, s, c.SessionID, c.Messages)
+}
+
+// Attempt is the last turn of a session, set aside to retry it.
+type Attempt struct {
+	// Prompt is the user message that started the turn, to send again.
+	Prompt message.Message
+	// Session is the session the attempt is kept in, a child of the
+	// session with all of its messages.
+	Session session.Session
+}
+
+// DiscardLastTurn removes the last turn from the session, the last user
+// message and every message after it, so that it can be retried. The turn is
+// kept in a child session, listed under the session, where it can still be
+// viewed. Files are left as they are.
+func DiscardLastTurn(ctx context.Context, s Services, sessionID string) (Attempt, error) {
+	msgs, err := s.Messages.List(ctx, sessionID)
+	if err != nil {
+		return Attempt{}, fmt.Errorf("failed to list messages: %w", err)
+	}
+	idx := -1
+	for i, msg := range slices.Backward(msgs) {
+		if msg.Role == message.User {
+			idx = i
+			break
+		}
+	}
+	if idx == -1 {
+		return Attempt{}, fmt.Errorf("no turn to retry in session %s", sessionID)
+	}
+
+	orig, err := s.Sessions.Get(ctx, sessionID)
+	if err != nil {
+		return Attempt{}, fmt.Errorf("failed to get session: %w", err)
+	}
+	attempt, err := s.Sessions.CreateAttemptSession(ctx, orig.ID, orig.Title+" (attempt)")
+	if err != nil {
+		return Attempt{}, fmt.Errorf("failed to create session: %w", err)
+	}
+	attempt, err = copyConversation(ctx, s, orig, attempt, msgs, nil)
+	if err != nil {
+		return Attempt{}, err
+	}
+	if err := removeMessages(ctx, s, sessionID, msgs[idx:]); err != nil {
+		return Attempt{}, err
+	}
+	return Attempt{Prompt: msgs[idx], Session: attempt}, nil
+}
+
+// removeMessages deletes the messages from the session.
+func removeMessages(ctx context.Context, s Services, sessionID string, msgs []message.Message) error {
+	removed := make(map[string]bool, len(msgs))
+	for _, msg := range slices.Backward(msgs) {
+		if err := s.Messages.Delete(ctx, msg.ID); err != nil {
+			return fmt.Errorf("failed to delete message %s: %w", msg.ID, err)
+		}
+		removed[msg.ID] = true
+	}
+
+	sess, err := s.Sessions.Get(ctx, sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to get session: %w", err)
+	}
+	if removed[sess.SummaryMessageID] {
+		// The conversation starts over from the beginning again.
+		sess.SummaryMessageID = ""
+		if _, err := s.Sessions.Save(ctx, sess); err != nil {
+			return fmt.Errorf("failed to save session: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,304 @@
+package checkpoint
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/db"
+	"github.com/charmbracelet/crush/internal/history"
+	"github.com/charmbracelet/crush/internal/llm/tools"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/charmbracelet/crush/internal/session"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRewind(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	conn, err := db.Connect(ctx, t.TempDir())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	q := db.New(conn)
+	s := Services{
+		Sessions: session.NewService(q),
+		Messages: message.NewService(q),
+		History:  history.NewService(q, conn),
+	}
+
+	sess, err := s.Sessions.Create(ctx, "Rewind")
+	require.NoError(t, err)
+	first, err := s.Messages.Create(ctx, sess.ID, message.CreateMessageParams{
+		Role:  message.User,
+		Parts: []message.ContentPart{message.TextContent{Text: "Create main.go"}},
+	})
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "main.go")
+	_, err = s.History.CreateMissing(ctx, sess.ID, path)
+	require.NoError(t, err)
+	_, err = s.History.CreateVersion(ctx, sess.ID, path, "package main")
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, []byte("package main"), 0o644))
+	_, err = s.Messages.Create(ctx, sess.ID, message.CreateMessageParams{
+		Role: message.Tool,
+		Parts: []message.ContentPart{message.ToolResult{
+			ToolCallID: "call",
+			Name:       tools.BashToolName,
+			Metadata:   `{"working_directory":"/src/cmd"}`,
+		}},
+	})
+	require.NoError(t, err)
+
+	second, err := s.Messages.Create(ctx, sess.ID, message.CreateMessageParams{
+		Role:  message.User,
+		Parts: []message.ContentPart{message.TextContent{Text: "Add a main function"}},
+	})
+	require.NoError(t, err)
+	answer, err := s.Messages.Create(ctx, sess.ID, message.CreateMessageParams{
+		Role:  message.Assistant,
+		Parts: []message.ContentPart{message.TextContent{Text: "Done."}},
+	})
+	require.NoError(t, err)
+
+	_, err = Plan(ctx, s, sess.ID, answer.ID)
+	require.ErrorContains(t, err, "user message")
+
+	// Rewinding to before the second message keeps the file, even though
+	// everything was created within the same second.
+	c, err := Plan(ctx, s, sess.ID, second.ID)
+	require.NoError(t, err)
+	require.Equal(t, second.ID, c.Message.ID)
+	require.Equal(t, []string{second.ID, answer.ID}, messageIDs(c.Messages))
+	require.Empty(t, c.Reverts)
+	require.Equal(t, "/src/cmd", c.WorkingDir)
+
+	// Rewinding to before the first message removes everything.
+	c, err = Plan(ctx, s, sess.ID, first.ID)
+	require.NoError(t, err)
+	require.Len(t, c.Messages, 4)
+	require.Empty(t, c.WorkingDir)
+	require.Len(t, c.Reverts, 1)
+	require.True(t, c.Reverts[0].Delete)
+	require.Empty(t, c.Conflicts())
+
+	require.NoError(t, Rewind(ctx, s, c, false))
+	require.NoFileExists(t, path)
+	msgs, err := s.Messages.List(ctx, sess.ID)
+	require.NoError(t, err)
+	require.Empty(t, msgs)
+}
+
+func TestFork(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	conn, err := db.Connect(ctx, t.TempDir())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	q := db.New(conn)
+	s := Services{
+		Sessions: session.NewService(q),
+		Messages: message.NewService(q),
+		History:  history.NewService(q, conn),
+	}
+
+	sess, err := s.Sessions.Create(ctx, "Parser")
+	require.NoError(t, err)
+	sess.AgentID = "reviewer"
+	sess.PlanMode = true
+	sess, err = s.Sessions.Save(ctx, sess)
+	require.NoError(t, err)
+	prompt, err := s.Messages.Create(ctx, sess.ID, message.CreateMessageParams{
+		Role:  message.User,
+		Parts: []message.ContentPart{message.TextContent{Text: "Find the parser"}},
+	})
+	require.NoError(t, err)
+	call, err := s.Messages.Create(ctx, sess.ID, message.CreateMessageParams{
+		Role:  message.Assistant,
+		Parts: []message.ContentPart{message.ToolCall{ID: "call", Name: "glob", Input: `{"pattern":"parser*"}`, Finished: true}},
+	})
+	require.NoError(t, err)
+	_, err = s.Messages.Create(ctx, sess.ID, message.CreateMessageParams{
+		Role:  message.Tool,
+		Parts: []message.ContentPart{message.ToolResult{ToolCallID: "call", Name: "glob", Content: "parser.go"}},
+	})
+	require.NoError(t, err)
+	_, err = s.Messages.Create(ctx, sess.ID, message.CreateMessageParams{
+		Role:  message.Assistant,
+		Parts: []message.ContentPart{message.TextContent{Text: "It's in parser.go."}},
+	})
+	require.NoError(t, err)
+
+	// Forking from a tool call keeps its result.
+	forked, err := Fork(ctx, s, sess.ID, call.ID)
+	require.NoError(t, err)
+	require.Equal(t, sess.ID, forked.ForkedFromSessionID)
+	require.Equal(t, "Parser (fork)", forked.Title)
+	require.Equal(t, "reviewer", forked.AgentID)
+	require.True(t, forked.PlanMode)
+	require.EqualValues(t, 3, forked.MessageCount)
+	msgs, err := s.Messages.List(ctx, forked.ID)
+	require.NoError(t, err)
+	require.Len(t, msgs, 3)
+	require.Equal(t, message.Tool, msgs[2].Role)
+	require.Equal(t, "parser.go", msgs[2].ToolResults()[0].Content)
+
+	edited, err := ForkBefore(ctx, s, sess.ID, prompt.ID)
+	require.NoError(t, err)
+	require.Zero(t, edited.MessageCount)
+	_, err = ForkBefore(ctx, s, sess.ID, call.ID)
+	require.ErrorContains(t, err, "user message")
+
+	// The original session is left untouched.
+	msgs, err = s.Messages.List(ctx, sess.ID)
+	require.NoError(t, err)
+	require.Len(t, msgs, 4)
+}
+
+func TestRewindFork(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	conn, err := db.Connect(ctx, t.TempDir())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	q := db.New(conn)
+	s := Services{
+		Sessions: session.NewService(q),
+		Messages: message.NewService(q),
+		History:  history.NewService(q, conn),
+	}
+
+	sess, err := s.Sessions.Create(ctx, "Main")
+	require.NoError(t, err)
+	_, err = s.Messages.Create(ctx, sess.ID, message.CreateMessageParams{
+		Role:      message.User,
+		Parts:     []message.ContentPart{message.TextContent{Text: "Create main.go"}},
+		CreatedAt: 1000,
+	})
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "main.go")
+	_, err = s.History.Create(ctx, sess.ID, path, "")
+	require.NoError(t, err)
+	_, err = s.History.CreateVersion(ctx, sess.ID, path, "package main")
+	require.NoError(t, err)
+	_, err = s.Messages.Create(ctx, sess.ID, message.CreateMessageParams{
+		Role:      message.User,
+		Parts:     []message.ContentPart{message.TextContent{Text: "Add a main function"}},
+		CreatedAt: 2000,
+	})
+	require.NoError(t, err)
+	_, err = s.History.CreateVersion(ctx, sess.ID, path, "package main\n\nfunc main() {}")
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, []byte("package main\n\nfunc main() {}"), 0o644))
+	answer, err := s.Messages.Create(ctx, sess.ID, message.CreateMessageParams{
+		Role:      message.Assistant,
+		Parts:     []message.ContentPart{message.TextContent{Text: "Done."}},
+		CreatedAt: 2001,
+	})
+	require.NoError(t, err)
+
+	// The fork keeps the times of the messages and file versions.
+	forked, err := Fork(ctx, s, sess.ID, answer.ID)
+	require.NoError(t, err)
+	msgs, err := s.Messages.List(ctx, forked.ID)
+	require.NoError(t, err)
+	require.Len(t, msgs, 3)
+	for i, createdAt := range []int64{1000, 2000, 2001} {
+		require.Equal(t, createdAt, msgs[i].CreatedAt)
+	}
+	files, err := s.History.ListBySession(ctx, sess.ID)
+	require.NoError(t, err)
+	forkedFiles, err := s.History.ListBySession(ctx, forked.ID)
+	require.NoError(t, err)
+	require.Len(t, forkedFiles, len(files))
+	for i, f := range files {
+		require.Equal(t, f.Version, forkedFiles[i].Version)
+		require.Equal(t, f.CreatedAt, forkedFiles[i].CreatedAt)
+		require.Equal(t, f.Content, forkedFiles[i].Content)
+	}
+
+	// Rewinding the fork to before the second message only undoes the
+	// changes made since.
+	c, err := Plan(ctx, s, forked.ID, msgs[1].ID)
+	require.NoError(t, err)
+	require.Len(t, c.Reverts, 1)
+	require.False(t, c.Reverts[0].Delete)
+	require.Equal(t, "package main", c.Reverts[0].Target)
+	require.NoError(t, Rewind(ctx, s, c, false))
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "package main", string(content))
+}
+
+func TestDiscardLastTurn(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	conn, err := db.Connect(ctx, t.TempDir())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	q := db.New(conn)
+	s := Services{
+		Sessions: session.NewService(q),
+		Messages: message.NewService(q),
+		History:  history.NewService(q, conn),
+	}
+
+	sess, err := s.Sessions.Create(ctx, "Retry")
+	require.NoError(t, err)
+	_, err = DiscardLastTurn(ctx, s, sess.ID)
+	require.ErrorContains(t, err, "no turn to retry")
+
+	for _, text := range []string{"Hi", "Hello!", "Fix the bug"} {
+		role := message.User
+		if text == "Hello!" {
+			role = message.Assistant
+		}
+		_, err = s.Messages.Create(ctx, sess.ID, message.CreateMessageParams{
+			Role:  role,
+			Parts: []message.ContentPart{message.TextContent{Text: text}},
+		})
+		require.NoError(t, err)
+	}
+	_, err = s.Messages.Create(ctx, sess.ID, message.CreateMessageParams{
+		Role: message.Assistant,
+		Parts: []message.ContentPart{
+			message.Finish{Reason: message.FinishReasonError, Message: "Provider error"},
+		},
+	})
+	require.NoError(t, err)
+
+	attempt, err := DiscardLastTurn(ctx, s, sess.ID)
+	require.NoError(t, err)
+	require.Equal(t, "Fix the bug", attempt.Prompt.Content().Text)
+	require.Equal(t, "Retry (attempt)", attempt.Session.Title)
+	require.Equal(t, sess.ID, attempt.Session.ParentSessionID)
+	require.Equal(t, sess.ID, attempt.Session.ForkedFromSessionID)
+
+	// The attempt is kept with the session, out of the list of sessions.
+	require.True(t, attempt.Session.IsAttempt())
+	sessions, err := s.Sessions.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	sessions, err = s.Sessions.ListAll(ctx)
+	require.NoError(t, err)
+	require.Len(t, sessions, 2)
+
+	msgs, err := s.Messages.List(ctx, sess.ID)
+	require.NoError(t, err)
+	require.Len(t, msgs, 2)
+	msgs, err = s.Messages.List(ctx, attempt.Session.ID)
+	require.NoError(t, err)
+	require.Len(t, msgs, 4)
+	require.Equal(t, message.FinishReasonError, msgs[3].FinishReason())
+}
+
+func messageIDs(msgs []message.Message) []string {
+	ids := make([]string, len(msgs))
+	for i, msg := range msgs {
+		ids[i] = msg.ID
+	}
+	return ids
+}
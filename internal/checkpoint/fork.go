@@ -0,0 +1,123 @@
+package checkpoint
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/charmbracelet/crush/internal/history"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/charmbracelet/crush/internal/session"
+)
+
+// Fork creates a session with the messages of the session up to and
+// including the given one, along with the results of its tool calls. The
+// original session is left untouched.
+func Fork(ctx context.Context, s Services, sessionID, messageID string) (session.Session, error) {
+	msgs, idx, err := findMessage(ctx, s, sessionID, messageID)
+	if err != nil {
+		return session.Session{}, err
+	}
+	end := idx + 1
+	for end < len(msgs) && msgs[end].Role == message.Tool {
+		end++
+	}
+	return fork(ctx, s, sessionID, msgs[:end], msgs[end:])
+}
+
+// ForkBefore creates a session with the messages of the session before the
+// given user message, to send another version of it. The original session is
+// left untouched.
+func ForkBefore(ctx context.Context, s Services, sessionID, messageID string) (session.Session, error) {
+	msgs, idx, err := findMessage(ctx, s, sessionID, messageID)
+	if err != nil {
+		return session.Session{}, err
+	}
+	if msgs[idx].Role != message.User {
+		return session.Session{}, fmt.Errorf("can only edit a user message")
+	}
+	return fork(ctx, s, sessionID, msgs[:idx], msgs[idx:])
+}
+
+func findMessage(ctx context.Context, s Services, sessionID, messageID string) ([]message.Message, int, error) {
+	msgs, err := s.Messages.List(ctx, sessionID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list messages: %w", err)
+	}
+	idx := slices.IndexFunc(msgs, func(m message.Message) bool { return m.ID == messageID })
+	if idx == -1 {
+		return nil, 0, fmt.Errorf("message %s not found in session %s", messageID, sessionID)
+	}
+	return msgs, idx, nil
+}
+
+// fork copies the messages into a new session forked from the session, with
+// the history of the files changed before the first of the messages left out.
+// The fork is titled after the session.
+func fork(ctx context.Context, s Services, sessionID string, msgs, rest []message.Message) (session.Session, error) {
+	orig, err := s.Sessions.Get(ctx, sessionID)
+	if err != nil {
+		return session.Session{}, fmt.Errorf("failed to get session: %w", err)
+	}
+	sess, err := s.Sessions.CreateForkSession(ctx, orig.ID, orig.Title+" (fork)")
+	if err != nil {
+		return session.Session{}, fmt.Errorf("failed to create session: %w", err)
+	}
+	return copyConversation(ctx, s, orig, sess, msgs, rest)
+}
+
+// copyConversation copies the messages of the original session into the
+// session, with the history of the files changed before the first of the
+// remaining messages. Messages and file versions are copied in the order they
+// were created, with their creation times, so the copy can be rewound like
+// the original. The session is run with the same agent in the same mode.
+func copyConversation(ctx context.Context, s Services, orig, sess session.Session, msgs, rest []message.Message) (session.Session, error) {
+	files, err := s.History.ListBySession(ctx, orig.ID)
+	if err != nil {
+		return session.Session{}, fmt.Errorf("failed to list the file history: %w", err)
+	}
+	if len(rest) > 0 {
+		files = slices.DeleteFunc(files, func(f history.File) bool { return f.Seq >= rest[0].Seq })
+	}
+
+	messageIDs := make(map[string]string, len(msgs))
+	for len(msgs) > 0 || len(files) > 0 {
+		if len(files) > 0 && (len(msgs) == 0 || files[0].Seq < msgs[0].Seq) {
+			if _, err := s.History.Copy(ctx, sess.ID, files[0]); err != nil {
+				return session.Session{}, fmt.Errorf("failed to create history of %s: %w", files[0].Path, err)
+			}
+			files = files[1:]
+			continue
+		}
+
+		m := msgs[0]
+		msg, err := s.Messages.Create(ctx, sess.ID, message.CreateMessageParams{
+			Role:      m.Role,
+			Parts:     m.Parts,
+			Model:     m.Model,
+			Provider:  m.Provider,
+			CreatedAt: m.CreatedAt,
+		})
+		if err != nil {
+			return session.Session{}, fmt.Errorf("failed to create message: %w", err)
+		}
+		// Messages are created with an extra finish part, unless they come
+		// from the assistant, so the original parts are written back as is.
+		msg.Parts = m.Parts
+		if err := s.Messages.Update(ctx, msg); err != nil {
+			return session.Session{}, fmt.Errorf("failed to update message: %w", err)
+		}
+		messageIDs[m.ID] = msg.ID
+		msgs = msgs[1:]
+	}
+
+	// The message count is kept up to date by the database.
+	sess, err = s.Sessions.Get(ctx, sess.ID)
+	if err != nil {
+		return session.Session{}, fmt.Errorf("failed to get session: %w", err)
+	}
+	sess.SummaryMessageID = messageIDs[orig.SummaryMessageID]
+	sess.AgentID = orig.AgentID
+	sess.PlanMode = orig.PlanMode
+	return s.Sessions.Save(ctx, sess)
+}
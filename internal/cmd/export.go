@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+
+	"github.com/charmbracelet/crush/internal/export"
+	"github.com/spf13/cobra"
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export <session>",
+	Short: "Export a session",
+	Long: `Export a session as a Markdown transcript, a self-contained HTML page, or a
+JSON bundle that can be imported into another project with crush import.`,
+	Example: `
+# Print a session as Markdown
+crush export 2f1c3f0e-6d3b-4a8e-9c1b-5b8f4a6d7e21
+
+# Save a session as an HTML page
+crush export 2f1c3f0e-6d3b-4a8e-9c1b-5b8f4a6d7e21 --format html -o session.html
+
+# Move a session to another project
+crush export 2f1c3f0e-6d3b-4a8e-9c1b-5b8f4a6d7e21 --format json -o session.json
+crush import -c ../other-project session.json
+  `,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		value, _ := cmd.Flags().GetString("format")
+		format := export.Format(value)
+		if !slices.Contains(export.Formats, format) {
+			return fmt.Errorf("invalid export format %q, must be one of markdown, html or json", value)
+		}
+
+		store, err := openSessionStore(cmd)
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+
+		sess, err := store.get(cmd, args[0])
+		if err != nil {
+			return err
+		}
+		bundle, err := export.Load(cmd.Context(), store.services(), sess.ID, store.workingDir)
+		if err != nil {
+			return err
+		}
+
+		var out io.Writer = cmd.OutOrStdout()
+		if output, _ := cmd.Flags().GetString("output"); output != "" {
+			f, err := os.Create(output)
+			if err != nil {
+				return fmt.Errorf("failed to create %s: %w", output, err)
+			}
+			defer f.Close()
+			out = f
+		}
+		return bundle.Write(out, format)
+	},
+}
+
+var importCmd = &cobra.Command{
+	Use:   "import <file>",
+	Short: "Import a session",
+	Long: `Import a session exported with crush export --format json, including the
+history of the files it changed and its child sessions. The imported sessions
+get new IDs, and the paths of files in the project the session was exported
+from are moved to this one.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		f, err := os.Open(args[0])
+		if err != nil {
+			return fmt.Errorf("failed to open %s: %w", args[0], err)
+		}
+		defer f.Close()
+		bundle, err := export.Read(f)
+		if err != nil {
+			return err
+		}
+
+		store, err := openSessionStore(cmd)
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+
+		sess, err := export.Import(cmd.Context(), store.conn, bundle, store.workingDir)
+		if err != nil {
+			return fmt.Errorf("failed to import session: %w", err)
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "Imported session %s as %s.\n", bundle.Session.ID, sess.ID)
+		return nil
+	},
+}
+
+func init() {
+	exportCmd.Flags().StringP("format", "f", string(export.Markdown), "Export format: markdown, html or json")
+	exportCmd.Flags().StringP("output", "o", "", "File to write to, instead of stdout")
+	rootCmd.AddCommand(exportCmd, importCmd)
+}
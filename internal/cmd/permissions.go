@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/permission"
+	"github.com/spf13/cobra"
+)
+
+var permissionsCmd = &cobra.Command{
+	Use:   "permissions",
+	Short: "Manage remembered permissions",
+	Long: `List and revoke the permissions remembered when choosing to allow a tool
+for the session, the project, or always.`,
+}
+
+var permissionsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List remembered permissions",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := grantStore(cmd)
+		if err != nil {
+			return err
+		}
+		grants, err := store.List()
+		if err != nil {
+			return err
+		}
+		if len(grants) == 0 {
+			fmt.Fprintln(cmd.OutOrStdout(), "No remembered permissions.")
+			return nil
+		}
+
+		w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tSCOPE\tTOOL\tACTION\tPATH\tSESSION\tCREATED")
+		for _, g := range grants {
+			fmt.Fprintf(
+				w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+				g.ID, g.Scope, g.ToolName, g.Action, g.Path, g.SessionID,
+				time.Unix(g.CreatedAt, 0).Format(time.DateTime),
+			)
+		}
+		return w.Flush()
+	},
+}
+
+var permissionsRevokeCmd = &cobra.Command{
+	Use:   "revoke [id...]",
+	Short: "Revoke remembered permissions",
+	Example: `
+# Revoke a single permission
+crush permissions revoke 2f1c3f0e-6d3b-4a8e-9c1b-5b8f4a6d7e21
+
+# Revoke all the remembered permissions
+crush permissions revoke --all
+  `,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		all, _ := cmd.Flags().GetBool("all")
+		if all == (len(args) > 0) {
+			return errors.New("provide the IDs of the permissions to revoke, or --all")
+		}
+
+		store, err := grantStore(cmd)
+		if err != nil {
+			return err
+		}
+		ids := args
+		if all {
+			grants, err := store.List()
+			if err != nil {
+				return err
+			}
+			for _, g := range grants {
+				ids = append(ids, g.ID)
+			}
+		}
+		for _, id := range ids {
+			if err := store.Revoke(id); err != nil {
+				return fmt.Errorf("failed to revoke %s: %w", id, err)
+			}
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "Revoked %d permissions.\n", len(ids))
+		return nil
+	},
+}
+
+func init() {
+	permissionsRevokeCmd.Flags().Bool("all", false, "Revoke all the remembered permissions")
+	permissionsCmd.AddCommand(permissionsListCmd, permissionsRevokeCmd)
+	rootCmd.AddCommand(permissionsCmd)
+}
+
+func grantStore(cmd *cobra.Command) (*permission.GrantStore, error) {
+	cwd, err := ResolveCwd(cmd)
+	if err != nil {
+		return nil, err
+	}
+	dataDir, _ := cmd.Flags().GetString("data-dir")
+	cfg, err := config.Load(cwd, dataDir, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load configuration: %v", err)
+	}
+	return permission.NewGrantStore(cfg.Options.DataDirectory, filepath.Dir(config.GlobalConfigData())), nil
+}
@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/charmbracelet/crush/internal/history"
+	"github.com/spf13/cobra"
+)
+
+var revertCmd = &cobra.Command{
+	Use:   "revert <session>",
+	Short: "Revert the files changed by a session",
+	Long: `Restore the files changed by a session from their recorded history. By default
+every change made in the session is undone; use --after to only undo the changes
+made after a message, or --file and --version to restore a single file to one of
+its versions.
+
+A diff of each file is shown before reverting. Files modified outside of crush
+since the agent last wrote them are not reverted unless --force is given.`,
+	Example: `
+# Preview undoing every change of a session
+crush revert 2f1c3f0e-6d3b-4a8e-9c1b-5b8f4a6d7e21 --dry-run
+
+# Undo the changes made after a message
+crush revert 2f1c3f0e-6d3b-4a8e-9c1b-5b8f4a6d7e21 --after 8d0b7a4c-1e2f-4b3a-9c8d-7e6f5a4b3c2d
+
+# Restore a file to one of its versions
+crush revert 2f1c3f0e-6d3b-4a8e-9c1b-5b8f4a6d7e21 --file main.go --version 3
+  `,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		file, _ := cmd.Flags().GetString("file")
+		version, _ := cmd.Flags().GetInt64("version")
+		after, _ := cmd.Flags().GetString("after")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		force, _ := cmd.Flags().GetBool("force")
+		if file != "" && after != "" {
+			return fmt.Errorf("--file and --after can't be used together")
+		}
+		if file != "" && !cmd.Flags().Changed("version") {
+			return fmt.Errorf("--version is required with --file")
+		}
+
+		cwd, err := ResolveCwd(cmd)
+		if err != nil {
+			return err
+		}
+		store, err := openSessionStore(cmd)
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+
+		sess, err := store.get(cmd, args[0])
+		if err != nil {
+			return err
+		}
+
+		var reverts []history.Revert
+		switch {
+		case file != "":
+			if !filepath.IsAbs(file) {
+				file = filepath.Join(cwd, file)
+			}
+			revert, err := history.PlanFileRevert(cmd.Context(), store.history, sess.ID, file, version)
+			if err != nil {
+				return err
+			}
+			reverts = append(reverts, revert)
+		default:
+			var since int64
+			if after != "" {
+				msg, err := store.messages.Get(cmd.Context(), after)
+				if errors.Is(err, sql.ErrNoRows) || (err == nil && msg.SessionID != sess.ID) {
+					return fmt.Errorf("message %s not found in session %s", after, sess.ID)
+				}
+				if err != nil {
+					return fmt.Errorf("failed to get message %s: %w", after, err)
+				}
+				since = msg.Seq
+			}
+			reverts, err = history.PlanSessionRevert(cmd.Context(), store.history, sess.ID, since)
+			if err != nil {
+				return err
+			}
+		}
+
+		out := cmd.OutOrStdout()
+		if len(reverts) == 0 {
+			fmt.Fprintln(out, "Nothing to revert.")
+			return nil
+		}
+		conflicts := printReverts(out, cwd, reverts)
+		if dryRun {
+			return nil
+		}
+		if conflicts > 0 && !force {
+			return conflictsError(conflicts)
+		}
+		if err := history.ApplyReverts(cmd.Context(), store.history, sess.ID, reverts, force); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Reverted %d files.\n", len(reverts))
+		return nil
+	},
+}
+
+// printReverts previews the reverts as diffs, returning how many of them
+// conflict.
+func printReverts(w io.Writer, cwd string, reverts []history.Revert) int {
+	conflicts := 0
+	for _, r := range reverts {
+		if r.Conflict {
+			conflicts++
+			fmt.Fprintf(w, "Conflict: %s was modified since the agent last wrote it.\n", r.Path)
+		}
+		if r.Delete {
+			fmt.Fprintf(w, "Delete %s\n", r.Path)
+			continue
+		}
+		diff, _, _ := r.Diff(cwd)
+		fmt.Fprint(w, diff)
+	}
+	return conflicts
+}
+
+func conflictsError(conflicts int) error {
+	return fmt.Errorf("%d files were modified since the agent last wrote them, use --force to revert them anyway", conflicts)
+}
+
+func init() {
+	revertCmd.Flags().String("file", "", "Only revert this file")
+	revertCmd.Flags().Int64("version", 0, "Version to restore the file to, with --file")
+	revertCmd.Flags().String("after", "", "Only undo the changes made after this message")
+	revertCmd.Flags().Bool("dry-run", false, "Show the changes without reverting")
+	revertCmd.Flags().Bool("force", false, "Revert files modified outside of crush too")
+	rootCmd.AddCommand(revertCmd)
+}
@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/crush/internal/checkpoint"
+	"github.com/spf13/cobra"
+)
+
+var rewindCmd = &cobra.Command{
+	Use:   "rewind <session> <message>",
+	Short: "Rewind a session to before one of its messages",
+	Long: `Rewind a session to the state it was in right before one of its user messages:
+the message and every message after it are removed, and the files the agent
+changed since are restored from their recorded history. Message IDs are listed
+by crush sessions show.
+
+A diff of each file is shown before rewinding. Files modified outside of crush
+since the agent last wrote them are not restored unless --force is given.`,
+	Example: `
+# Preview rewinding a session
+crush rewind 2f1c3f0e-6d3b-4a8e-9c1b-5b8f4a6d7e21 8d0b7a4c-1e2f-4b3a-9c8d-7e6f5a4b3c2d --dry-run
+
+# Rewind a session and continue it with another prompt
+crush rewind 2f1c3f0e-6d3b-4a8e-9c1b-5b8f4a6d7e21 8d0b7a4c-1e2f-4b3a-9c8d-7e6f5a4b3c2d
+crush run -s 2f1c3f0e-6d3b-4a8e-9c1b-5b8f4a6d7e21 "Try again without changing the API"
+  `,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		force, _ := cmd.Flags().GetBool("force")
+
+		cwd, err := ResolveCwd(cmd)
+		if err != nil {
+			return err
+		}
+		store, err := openSessionStore(cmd)
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+
+		sess, err := store.get(cmd, args[0])
+		if err != nil {
+			return err
+		}
+		services := checkpoint.Services{Sessions: store.sessions, Messages: store.messages, History: store.history}
+		c, err := checkpoint.Plan(cmd.Context(), services, sess.ID, args[1])
+		if err != nil {
+			return err
+		}
+
+		out := cmd.OutOrStdout()
+		fmt.Fprintf(out, "Rewinding to before: %s\n", truncateTitle(strings.Join(strings.Fields(c.Message.Content().Text), " ")))
+		fmt.Fprintf(out, "%d messages will be removed and %d files restored.\n", len(c.Messages), len(c.Reverts))
+		conflicts := printReverts(out, cwd, c.Reverts)
+		if dryRun {
+			return nil
+		}
+		if conflicts > 0 && !force {
+			return conflictsError(conflicts)
+		}
+		if err := checkpoint.Rewind(cmd.Context(), services, c, force); err != nil {
+			return err
+		}
+		// The shell of the session moves back to where it was at the
+		// checkpoint, like it does in the TUI.
+		workingDir := c.WorkingDir
+		if info, err := os.Stat(workingDir); workingDir == "" || err != nil || !info.IsDir() {
+			workingDir = cwd
+		}
+		_, env, err := store.sessions.LoadShell(cmd.Context(), sess.ID)
+		if err != nil {
+			return err
+		}
+		if err := store.sessions.SaveShell(cmd.Context(), sess.ID, workingDir, env); err != nil {
+			return fmt.Errorf("failed to reset the shell working directory: %w", err)
+		}
+		fmt.Fprintln(out, "Rewound the session.")
+		return nil
+	},
+}
+
+func init() {
+	rewindCmd.Flags().Bool("dry-run", false, "Show the changes without rewinding")
+	rewindCmd.Flags().Bool("force", false, "Restore files modified outside of crush too")
+	rootCmd.AddCommand(rewindCmd)
+}
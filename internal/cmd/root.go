@@ -2,6 +2,8 @@ package cmd
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
@@ -12,6 +14,7 @@ import (
 	"github.com/charmbracelet/crush/internal/app"
 	"github.com/charmbracelet/crush/internal/config"
 	"github.com/charmbracelet/crush/internal/db"
+	"github.com/charmbracelet/crush/internal/session"
 	"github.com/charmbracelet/crush/internal/tui"
 	"github.com/charmbracelet/crush/internal/version"
 	"github.com/charmbracelet/fang"
@@ -26,6 +29,7 @@ func init() {
 
 	rootCmd.Flags().BoolP("help", "h", false, "Help")
 	rootCmd.Flags().BoolP("yolo", "y", false, "Automatically accept all permissions (dangerous mode)")
+	addSessionFlags(rootCmd)
 
 	rootCmd.AddCommand(runCmd)
 }
@@ -57,6 +61,12 @@ crush run "Explain the use of context in Go"
 
 # Run in dangerous mode (auto-accept all permissions)
 crush -y
+
+# Continue the latest session of the project
+crush --continue
+
+# Open a given session
+crush --session 2f1c3f0e-6d3b-4a8e-9c1b-5b8f4a6d7e21
   `,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		app, err := setupApp(cmd)
@@ -65,9 +75,14 @@ crush -y
 		}
 		defer app.Shutdown()
 
+		sess, err := resumeSession(cmd, app)
+		if err != nil {
+			return err
+		}
+
 		// Set up the TUI.
 		program := tea.NewProgram(
-			tui.New(app),
+			tui.New(app, sess),
 			tea.WithAltScreen(),
 			tea.WithContext(cmd.Context()),
 			tea.WithMouseCellMotion(),            // Use cell motion instead of all motion to reduce event flooding
@@ -91,6 +106,10 @@ func Execute() {
 		fang.WithVersion(version.Version),
 		fang.WithNotifySignal(os.Interrupt),
 	); err != nil {
+		var exitErr interface{ ExitCode() int }
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.ExitCode())
+		}
 		os.Exit(1)
 	}
 }
@@ -117,6 +136,7 @@ func setupApp(cmd *cobra.Command) (*app.App, error) {
 		cfg.Permissions = &config.Permissions{}
 	}
 	cfg.Permissions.SkipRequests = yolo
+	applyPermissionFlags(cmd, cfg)
 
 	if err := createDotCrushDir(cfg.Options.DataDirectory); err != nil {
 		return nil, err
@@ -137,6 +157,47 @@ func setupApp(cmd *cobra.Command) (*app.App, error) {
 	return appInstance, nil
 }
 
+func addSessionFlags(cmd *cobra.Command) {
+	cmd.Flags().StringP("session", "s", "", "ID of the session to resume")
+	cmd.Flags().Bool("continue", false, "Continue the latest session of the project")
+	cmd.MarkFlagsMutuallyExclusive("session", "continue")
+}
+
+// resumeSession returns the session picked with the --session or --continue
+// flags, or an empty session when neither is given.
+func resumeSession(cmd *cobra.Command, app *app.App) (session.Session, error) {
+	ctx := cmd.Context()
+	id, _ := cmd.Flags().GetString("session")
+	if id != "" {
+		sess, err := app.Sessions.Get(ctx, id)
+		if errors.Is(err, sql.ErrNoRows) {
+			return session.Session{}, fmt.Errorf("session %s not found", id)
+		}
+		if err != nil {
+			return session.Session{}, fmt.Errorf("failed to get session %s: %w", id, err)
+		}
+		return sess, nil
+	}
+
+	if cont, _ := cmd.Flags().GetBool("continue"); !cont {
+		return session.Session{}, nil
+	}
+	sessions, err := app.Sessions.List(ctx)
+	if err != nil {
+		return session.Session{}, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	if len(sessions) == 0 {
+		return session.Session{}, errors.New("no session to continue in this project")
+	}
+	latest := sessions[0]
+	for _, sess := range sessions[1:] {
+		if sess.UpdatedAt > latest.UpdatedAt {
+			latest = sess
+		}
+	}
+	return latest, nil
+}
+
 func MaybePrependStdin(prompt string) (string, error) {
 	if term.IsTerminal(os.Stdin.Fd()) {
 		return prompt, nil
@@ -3,8 +3,13 @@ package cmd
 import (
 	"fmt"
 	"log/slog"
+	"slices"
 	"strings"
 
+	"github.com/charmbracelet/crush/internal/app"
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/format"
+	"github.com/charmbracelet/crush/internal/permission"
 	"github.com/spf13/cobra"
 )
 
@@ -22,9 +27,58 @@ echo "What is this code doing?" | crush run
 
 # Run with quiet mode (no spinner)
 crush run -q "Generate a README for this project"
+
+# Only let the model read files
+crush run --read-only "Explain how the permission service works"
+
+# Let the model edit files, denying any other tool call needing approval
+crush run --allow-tools edit,write "Add doc comments to the exported functions"
+
+# Stop as soon as a tool call would need approval
+crush run --permission-mode fail-on-prompt "Fix the linter warnings"
+
+# Plan the work without changing anything, then carry out the plan
+crush run --plan "Add a --verbose flag to the CLI"
+crush run --continue --plan=false "Go ahead with the plan"
+
+# Continue the latest session of the project
+crush run --continue "Now add tests for it"
+
+# Run with one of the agents defined in the configuration
+crush run --agent reviewer "Review the changes in this branch"
+
+# Append to a given session
+crush run --session 2f1c3f0e-6d3b-4a8e-9c1b-5b8f4a6d7e21 "Summarize what we did"
+
+# Print the result as JSON
+crush run --output-format json "Summarize the changes in this branch"
+
+# Stream newline-delimited JSON events as the run progresses
+crush run --output-format stream-json "Add tests for the config loader"
   `,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		quiet, _ := cmd.Flags().GetBool("quiet")
+		mode, err := permissionMode(cmd)
+		if err != nil {
+			return err
+		}
+		outputFormat, _ := cmd.Flags().GetString("output-format")
+		output, err := format.Parse(outputFormat)
+		if err != nil {
+			return err
+		}
+		agentID, _ := cmd.Flags().GetString("agent")
+		opts := app.RunOptions{
+			Quiet:          quiet,
+			PermissionMode: mode,
+			OutputFormat:   output,
+			Agent:          agentID,
+		}
+		// A resumed session stays in the mode it was in, unless it's given.
+		if cmd.Flags().Changed("plan") {
+			plan, _ := cmd.Flags().GetBool("plan")
+			opts.Plan = &plan
+		}
 
 		app, err := setupApp(cmd)
 		if err != nil {
@@ -32,6 +86,12 @@ crush run -q "Generate a README for this project"
 		}
 		defer app.Shutdown()
 
+		sess, err := resumeSession(cmd, app)
+		if err != nil {
+			return err
+		}
+		opts.SessionID = sess.ID
+
 		if !app.Config().IsConfigured() {
 			return fmt.Errorf("no providers configured - please run 'crush' to set up a provider interactively")
 		}
@@ -49,10 +109,57 @@ crush run -q "Generate a README for this project"
 		}
 
 		// Run non-interactive flow using the App method
-		return app.RunNonInteractive(cmd.Context(), prompt, quiet)
+		return app.RunNonInteractive(cmd.Context(), prompt, opts)
 	},
 }
 
 func init() {
 	runCmd.Flags().BoolP("quiet", "q", false, "Hide spinner")
+	addSessionFlags(runCmd)
+	runCmd.Flags().StringSlice("allow-tools", nil, "Tools, or tool:action pairs, allowed without approval")
+	runCmd.Flags().StringSlice("deny-tools", nil, "Tools not made available to the model")
+	runCmd.Flags().Bool("read-only", false, "Deny tool calls that write files, download files or run commands that need approval")
+	runCmd.Flags().String("agent", "", "Agent to run the session with, as defined in the configuration (default coder)")
+	runCmd.Flags().Bool("plan", false, "Only plan the work with read-only tools and print the plan, without changing anything; --plan=false takes a resumed session out of plan mode")
+	runCmd.Flags().StringP("output-format", "o", string(format.Text), "Output format: text, json or stream-json")
+	runCmd.Flags().String("permission-mode", "", "How tool calls needing approval are handled: approve-all, deny-unlisted or fail-on-prompt (default approve-all, or deny-unlisted with --allow-tools or --read-only)")
+}
+
+// permissionMode returns the mode that handles the tool calls needing
+// approval in a non-interactive run.
+func permissionMode(cmd *cobra.Command) (permission.Mode, error) {
+	value, _ := cmd.Flags().GetString("permission-mode")
+	if value != "" {
+		mode := permission.Mode(value)
+		if !slices.Contains(permission.Modes, mode) {
+			return "", fmt.Errorf("invalid permission mode %q, must be one of approve-all, deny-unlisted or fail-on-prompt", value)
+		}
+		return mode, nil
+	}
+	allowTools, _ := cmd.Flags().GetStringSlice("allow-tools")
+	readOnly, _ := cmd.Flags().GetBool("read-only")
+	if len(allowTools) > 0 || readOnly {
+		return permission.ModeDenyUnlisted, nil
+	}
+	return permission.ModeApproveAll, nil
+}
+
+// applyPermissionFlags adds the permissions given on the command line to the
+// configuration. It does nothing for commands without these flags.
+func applyPermissionFlags(cmd *cobra.Command, cfg *config.Config) {
+	allowTools, _ := cmd.Flags().GetStringSlice("allow-tools")
+	denyTools, _ := cmd.Flags().GetStringSlice("deny-tools")
+	readOnly, _ := cmd.Flags().GetBool("read-only")
+
+	cfg.Permissions.AllowedTools = append(cfg.Permissions.AllowedTools, allowTools...)
+	cfg.Options.DisabledTools = append(cfg.Options.DisabledTools, denyTools...)
+	if readOnly {
+		for _, action := range []string{"write", "download", "execute"} {
+			cfg.Permissions.Deny = append(cfg.Permissions.Deny, config.PermissionRule{
+				Action:        action,
+				Reason:        "this run is read-only",
+				NeedsApproval: true,
+			})
+		}
+	}
 }
@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/permission"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadOnlyRun(t *testing.T) {
+	t.Parallel()
+
+	cmd := &cobra.Command{}
+	cmd.Flags().Bool("read-only", false, "")
+	require.NoError(t, cmd.Flags().Set("read-only", "true"))
+
+	cfg := &config.Config{Options: &config.Options{}, Permissions: &config.Permissions{}}
+	applyPermissionFlags(cmd, cfg)
+	mode, err := permissionMode(cmd)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	policy, err := permission.NewPolicy(dir, cfg.Permissions)
+	require.NoError(t, err)
+	service := permission.NewPermissionService(dir, false, cfg.Permissions.AllowedTools, policy, nil)
+	service.SetSessionMode("session", mode)
+
+	// Safe commands run without approval, so they're still allowed.
+	require.NoError(t, service.Request(permission.CreatePermissionRequest{
+		SessionID: "session",
+		ToolName:  "bash",
+		Action:    "execute",
+		Path:      dir,
+		Command:   "ls",
+		ReadOnly:  true,
+	}))
+
+	err = service.Request(permission.CreatePermissionRequest{
+		SessionID: "session",
+		ToolName:  "bash",
+		Action:    "execute",
+		Path:      dir,
+		Command:   "rm -rf build",
+	})
+	var denied *permission.DeniedError
+	require.ErrorAs(t, err, &denied)
+	require.Equal(t, "this run is read-only", denied.Reason)
+}
@@ -0,0 +1,475 @@
+package cmd
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/checkpoint"
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/db"
+	"github.com/charmbracelet/crush/internal/export"
+	"github.com/charmbracelet/crush/internal/history"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/charmbracelet/crush/internal/permission"
+	"github.com/charmbracelet/crush/internal/session"
+	"github.com/spf13/cobra"
+)
+
+var sessionsCmd = &cobra.Command{
+	Use:   "sessions",
+	Short: "Manage sessions",
+	Long:  `List, search, inspect, fork, rename and delete the sessions of the project.`,
+}
+
+var sessionsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List sessions",
+	Long: `List the sessions of the project, newest first. The sessions of the tasks
+delegated to sub-agents are listed too, with the session that delegated them
+as their parent.`,
+	Args: cobra.NoArgs,
+	RunE: withSessionStore(listSessions),
+}
+
+var sessionsSearchCmd = &cobra.Command{
+	Use:   "search <query...>",
+	Short: "Search the messages of all sessions",
+	Long: `Search the text, tool calls and tool results of the messages of all sessions.
+Messages matching all the words of the query are listed, best matches first.
+A word ending with * matches any word it's a prefix of.`,
+	Example: `
+# Find where a function was discussed
+crush sessions search parseConfig
+
+# Match any word starting with "migrat"
+crush sessions search "migrat* sqlite"
+  `,
+	Args: cobra.MinimumNArgs(1),
+	RunE: withSessionStore(searchSessions),
+}
+
+var sessionsShowCmd = &cobra.Command{
+	Use:   "show <id>",
+	Short: "Show a session and its messages",
+	Args:  cobra.ExactArgs(1),
+	RunE:  withSessionStore(showSession),
+}
+
+var sessionsDeleteCmd = &cobra.Command{
+	Use:   "delete <id>...",
+	Short: "Delete sessions and their messages",
+	Args:  cobra.MinimumNArgs(1),
+	RunE:  withSessionStore(deleteSessions),
+}
+
+var sessionsForkCmd = &cobra.Command{
+	Use:   "fork <id> [message]",
+	Short: "Fork a session into a new one",
+	Long: `Fork a session into a new one with its messages up to and including the given
+message, or all of them. With --before, the fork stops before the given user
+message, so that another version of it can be sent with crush run --session.
+The original session is left untouched.`,
+	Example: `
+# Try another approach from a message
+crush sessions fork 2f1c3f0e-6d3b-4a8e-9c1b-5b8f4a6d7e21 8d0b7a4c-1e2f-4b3a-9c8d-7e6f5a4b3c2d
+
+# Edit a prompt and send it again in a fork
+crush sessions fork 2f1c3f0e-6d3b-4a8e-9c1b-5b8f4a6d7e21 8d0b7a4c-1e2f-4b3a-9c8d-7e6f5a4b3c2d --before
+crush run -s <fork> "Add the flag, but keep the old one as an alias"
+  `,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: withSessionStore(forkSession),
+}
+
+var sessionsRenameCmd = &cobra.Command{
+	Use:   "rename <id> <title...>",
+	Short: "Rename a session",
+	Args:  cobra.MinimumNArgs(2),
+	RunE:  withSessionStore(renameSession),
+}
+
+func listSessions(cmd *cobra.Command, store *sessionStore, args []string) error {
+	sessions, err := store.sessions.ListAll(cmd.Context())
+	if err != nil {
+		return fmt.Errorf("failed to list sessions: %w", err)
+	}
+	if asJSON, _ := cmd.Flags().GetBool("json"); asJSON {
+		items := make([]sessionJSON, 0, len(sessions))
+		for _, s := range sessions {
+			items = append(items, newSessionJSON(s))
+		}
+		return writeJSON(cmd.OutOrStdout(), items)
+	}
+	if len(sessions) == 0 {
+		fmt.Fprintln(cmd.OutOrStdout(), "No sessions.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tPARENT\tTITLE\tMESSAGES\tTOKENS\tCOST\tCREATED\tUPDATED")
+	for _, s := range sessions {
+		parent := s.ParentSessionID
+		if parent == "" {
+			parent = "-"
+		}
+		fmt.Fprintf(
+			w, "%s\t%s\t%s\t%d\t%d\t$%.2f\t%s\t%s\n",
+			s.ID, parent, truncateTitle(s.Title), s.MessageCount, s.PromptTokens+s.CompletionTokens, s.Cost,
+			formatUnix(s.CreatedAt), formatUnix(s.UpdatedAt),
+		)
+	}
+	return w.Flush()
+}
+
+func searchSessions(cmd *cobra.Command, store *sessionStore, args []string) error {
+	limit, _ := cmd.Flags().GetInt("limit")
+	results, err := store.messages.Search(cmd.Context(), strings.Join(args, " "), limit)
+	if err != nil {
+		return fmt.Errorf("failed to search messages: %w", err)
+	}
+	if asJSON, _ := cmd.Flags().GetBool("json"); asJSON {
+		items := make([]searchResultJSON, 0, len(results))
+		for _, r := range results {
+			items = append(items, searchResultJSON{
+				SessionID:    r.SessionID,
+				SessionTitle: r.SessionTitle,
+				MessageID:    r.MessageID,
+				Role:         r.Role,
+				Snippet:      r.Snippet,
+				CreatedAt:    time.Unix(r.CreatedAt, 0),
+			})
+		}
+		return writeJSON(cmd.OutOrStdout(), items)
+	}
+	if len(results) == 0 {
+		fmt.Fprintln(cmd.OutOrStdout(), "No matching messages.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SESSION\tTITLE\tROLE\tCREATED\tMATCH")
+	for _, r := range results {
+		fmt.Fprintf(
+			w, "%s\t%s\t%s\t%s\t%s\n",
+			r.SessionID, truncateTitle(r.SessionTitle), r.Role, formatUnix(r.CreatedAt),
+			strings.Join(strings.Fields(r.Snippet), " "),
+		)
+	}
+	return w.Flush()
+}
+
+func showSession(cmd *cobra.Command, store *sessionStore, args []string) error {
+	s, err := store.get(cmd, args[0])
+	if err != nil {
+		return err
+	}
+	msgs, err := store.messages.List(cmd.Context(), s.ID)
+	if err != nil {
+		return fmt.Errorf("failed to list messages: %w", err)
+	}
+
+	out := cmd.OutOrStdout()
+	if asJSON, _ := cmd.Flags().GetBool("json"); asJSON {
+		item := newSessionJSON(s)
+		item.Messages = make([]messageJSON, 0, len(msgs))
+		for _, msg := range msgs {
+			item.Messages = append(item.Messages, newMessageJSON(msg))
+		}
+		return writeJSON(out, item)
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "ID:\t%s\n", s.ID)
+	fmt.Fprintf(w, "Title:\t%s\n", s.Title)
+	if s.ParentSessionID != "" {
+		fmt.Fprintf(w, "Parent session:\t%s\n", s.ParentSessionID)
+	}
+	if s.ForkedFromSessionID != "" {
+		fmt.Fprintf(w, "Forked from:\t%s\n", s.ForkedFromSessionID)
+	}
+	if s.AgentID != "" {
+		fmt.Fprintf(w, "Agent:\t%s\n", s.AgentID)
+	}
+	fmt.Fprintf(w, "Messages:\t%d\n", s.MessageCount)
+	fmt.Fprintf(w, "Tokens:\t%d prompt, %d completion\n", s.PromptTokens, s.CompletionTokens)
+	fmt.Fprintf(w, "Cost:\t$%.4f\n", s.Cost)
+	fmt.Fprintf(w, "Created:\t%s\n", formatUnix(s.CreatedAt))
+	fmt.Fprintf(w, "Updated:\t%s\n", formatUnix(s.UpdatedAt))
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	for _, msg := range msgs {
+		fmt.Fprintf(out, "\n[%s] %s %s\n", msg.Role, formatUnix(msg.CreatedAt), msg.ID)
+		if text := strings.TrimSpace(msg.Content().Text); text != "" {
+			fmt.Fprintln(out, text)
+		}
+		for _, call := range msg.ToolCalls() {
+			fmt.Fprintf(out, "-> %s %s\n", call.Name, call.Input)
+		}
+		for _, result := range msg.ToolResults() {
+			status := "ok"
+			if result.IsError {
+				status = "error"
+			}
+			fmt.Fprintf(out, "<- %s (%s, %d bytes)\n", result.Name, status, len(result.Content))
+		}
+	}
+	return nil
+}
+
+func deleteSessions(cmd *cobra.Command, store *sessionStore, args []string) error {
+	sessions := make([]session.Session, len(args))
+	for i, id := range args {
+		s, err := store.get(cmd, id)
+		if err != nil {
+			return err
+		}
+		sessions[i] = s
+	}
+	for _, s := range sessions {
+		// Child sessions are deleted with their parent, which may come first.
+		err := store.sessions.Delete(cmd.Context(), s.ID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("failed to delete session %s: %w", s.ID, err)
+		}
+	}
+	if err := pruneGrants(cmd, store); err != nil {
+		return err
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "Deleted %d sessions.\n", len(args))
+	return nil
+}
+
+// pruneGrants removes the permissions granted to sessions that were deleted,
+// along with their children.
+func pruneGrants(cmd *cobra.Command, store *sessionStore) error {
+	sessions, err := store.sessions.ListAll(cmd.Context())
+	if err != nil {
+		return fmt.Errorf("failed to list sessions: %w", err)
+	}
+	ids := make(map[string]bool, len(sessions))
+	for _, s := range sessions {
+		ids[s.ID] = true
+	}
+	if err := store.grants.PruneSessions(func(sessionID string) bool { return ids[sessionID] }); err != nil {
+		return fmt.Errorf("failed to remove the permissions of the sessions: %w", err)
+	}
+	return nil
+}
+
+func renameSession(cmd *cobra.Command, store *sessionStore, args []string) error {
+	s, err := store.get(cmd, args[0])
+	if err != nil {
+		return err
+	}
+	s.Title = strings.Join(args[1:], " ")
+	if _, err := store.sessions.Save(cmd.Context(), s); err != nil {
+		return fmt.Errorf("failed to rename session %s: %w", s.ID, err)
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "Renamed session %s to %q.\n", s.ID, s.Title)
+	return nil
+}
+
+func forkSession(cmd *cobra.Command, store *sessionStore, args []string) error {
+	before, _ := cmd.Flags().GetBool("before")
+	if before && len(args) < 2 {
+		return fmt.Errorf("--before requires a message")
+	}
+
+	s, err := store.get(cmd, args[0])
+	if err != nil {
+		return err
+	}
+	var messageID string
+	if len(args) == 2 {
+		messageID = args[1]
+	} else {
+		msgs, err := store.messages.List(cmd.Context(), s.ID)
+		if err != nil {
+			return fmt.Errorf("failed to list messages: %w", err)
+		}
+		if len(msgs) == 0 {
+			return fmt.Errorf("session %s has no messages", s.ID)
+		}
+		messageID = msgs[len(msgs)-1].ID
+	}
+
+	services := checkpoint.Services{Sessions: store.sessions, Messages: store.messages, History: store.history}
+	fork := checkpoint.Fork
+	if before {
+		fork = checkpoint.ForkBefore
+	}
+	forked, err := fork(cmd.Context(), services, s.ID, messageID)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "Forked session %s as %s.\n", s.ID, forked.ID)
+	return nil
+}
+
+func init() {
+	sessionsListCmd.Flags().Bool("json", false, "Print the sessions as JSON")
+	sessionsShowCmd.Flags().Bool("json", false, "Print the session as JSON")
+	sessionsSearchCmd.Flags().Bool("json", false, "Print the matching messages as JSON")
+	sessionsSearchCmd.Flags().Int("limit", 20, "Maximum number of matching messages")
+	sessionsForkCmd.Flags().Bool("before", false, "Stop the fork before the message, to edit it")
+	sessionsCmd.AddCommand(sessionsListCmd, sessionsSearchCmd, sessionsShowCmd, sessionsForkCmd, sessionsDeleteCmd, sessionsRenameCmd)
+	rootCmd.AddCommand(sessionsCmd)
+}
+
+// sessionStore gives access to the sessions of the project without setting up
+// the whole app.
+type sessionStore struct {
+	conn       *sql.DB
+	workingDir string
+	sessions   session.Service
+	messages   message.Service
+	history    history.Service
+	// grants are the remembered permissions, some of which are scoped to
+	// sessions.
+	grants *permission.GrantStore
+}
+
+func openSessionStore(cmd *cobra.Command) (*sessionStore, error) {
+	cwd, err := ResolveCwd(cmd)
+	if err != nil {
+		return nil, err
+	}
+	dataDir, _ := cmd.Flags().GetString("data-dir")
+	cfg, err := config.Load(cwd, dataDir, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load configuration: %v", err)
+	}
+	conn, err := db.Connect(cmd.Context(), cfg.Options.DataDirectory)
+	if err != nil {
+		return nil, err
+	}
+	q := db.New(conn)
+	return &sessionStore{
+		conn:       conn,
+		workingDir: cfg.WorkingDir(),
+		sessions:   session.NewService(q),
+		messages:   message.NewService(q),
+		history:    history.NewService(q, conn),
+		grants:     permission.NewGrantStore(cfg.Options.DataDirectory, filepath.Dir(config.GlobalConfigData())),
+	}, nil
+}
+
+// withSessionStore runs the command with the session store of the project.
+func withSessionStore(run func(cmd *cobra.Command, store *sessionStore, args []string) error) func(cmd *cobra.Command, args []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		store, err := openSessionStore(cmd)
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+		return run(cmd, store, args)
+	}
+}
+
+func (s *sessionStore) services() export.Services {
+	return export.Services{Sessions: s.sessions, Messages: s.messages, History: s.history}
+}
+
+func (s *sessionStore) Close() error {
+	return s.conn.Close()
+}
+
+func (s *sessionStore) get(cmd *cobra.Command, id string) (session.Session, error) {
+	sess, err := s.sessions.Get(cmd.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return session.Session{}, fmt.Errorf("session %s not found", id)
+	}
+	if err != nil {
+		return session.Session{}, fmt.Errorf("failed to get session %s: %w", id, err)
+	}
+	return sess, nil
+}
+
+type sessionJSON struct {
+	ID               string        `json:"id"`
+	ParentSessionID  string        `json:"parent_session_id,omitempty"`
+	ForkedFrom       string        `json:"forked_from_session_id,omitempty"`
+	AgentID          string        `json:"agent_id,omitempty"`
+	Title            string        `json:"title"`
+	MessageCount     int64         `json:"message_count"`
+	PromptTokens     int64         `json:"prompt_tokens"`
+	CompletionTokens int64         `json:"completion_tokens"`
+	Cost             float64       `json:"cost"`
+	CreatedAt        time.Time     `json:"created_at"`
+	UpdatedAt        time.Time     `json:"updated_at"`
+	Messages         []messageJSON `json:"messages,omitempty"`
+}
+
+func newSessionJSON(s session.Session) sessionJSON {
+	return sessionJSON{
+		ID:               s.ID,
+		ParentSessionID:  s.ParentSessionID,
+		ForkedFrom:       s.ForkedFromSessionID,
+		AgentID:          s.AgentID,
+		Title:            s.Title,
+		MessageCount:     s.MessageCount,
+		PromptTokens:     s.PromptTokens,
+		CompletionTokens: s.CompletionTokens,
+		Cost:             s.Cost,
+		CreatedAt:        time.Unix(s.CreatedAt, 0),
+		UpdatedAt:        time.Unix(s.UpdatedAt, 0),
+	}
+}
+
+type searchResultJSON struct {
+	SessionID    string              `json:"session_id"`
+	SessionTitle string              `json:"session_title"`
+	MessageID    string              `json:"message_id"`
+	Role         message.MessageRole `json:"role"`
+	Snippet      string              `json:"snippet"`
+	CreatedAt    time.Time           `json:"created_at"`
+}
+
+type messageJSON struct {
+	ID          string               `json:"id"`
+	Role        message.MessageRole  `json:"role"`
+	Model       string               `json:"model,omitempty"`
+	Content     string               `json:"content,omitempty"`
+	ToolCalls   []message.ToolCall   `json:"tool_calls,omitempty"`
+	ToolResults []message.ToolResult `json:"tool_results,omitempty"`
+	CreatedAt   time.Time            `json:"created_at"`
+}
+
+func newMessageJSON(msg message.Message) messageJSON {
+	return messageJSON{
+		ID:          msg.ID,
+		Role:        msg.Role,
+		Model:       msg.Model,
+		Content:     msg.Content().Text,
+		ToolCalls:   msg.ToolCalls(),
+		ToolResults: msg.ToolResults(),
+		CreatedAt:   time.Unix(msg.CreatedAt, 0),
+	}
+}
+
+func writeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func formatUnix(t int64) string {
+	return time.Unix(t, 0).Format(time.DateTime)
+}
+
+func truncateTitle(title string) string {
+	const maxTitleLength = 50
+	if len([]rune(title)) <= maxTitleLength {
+		return title
+	}
+	return string([]rune(title)[:maxTitleLength-3]) + "..."
+}
@@ -0,0 +1,190 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/db"
+	"github.com/charmbracelet/crush/internal/history"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/charmbracelet/crush/internal/permission"
+	"github.com/charmbracelet/crush/internal/session"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestSessionStore(t *testing.T) *sessionStore {
+	t.Helper()
+	conn, err := db.Connect(t.Context(), t.TempDir())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	q := db.New(conn)
+	return &sessionStore{
+		conn:     conn,
+		sessions: session.NewService(q),
+		messages: message.NewService(q),
+		history:  history.NewService(q, conn),
+		grants:   permission.NewGrantStore(t.TempDir(), t.TempDir()),
+	}
+}
+
+// runSessionsCommand runs a sessions subcommand against the store and returns
+// what it printed.
+func runSessionsCommand(t *testing.T, store *sessionStore, run func(*cobra.Command, *sessionStore, []string) error, asJSON bool, args ...string) string {
+	t.Helper()
+	var out bytes.Buffer
+	cmd := &cobra.Command{}
+	cmd.Flags().Bool("json", asJSON, "")
+	cmd.SetOut(&out)
+	cmd.SetContext(t.Context())
+	require.NoError(t, run(cmd, store, args))
+	return out.String()
+}
+
+func TestSessionsList(t *testing.T) {
+	t.Parallel()
+
+	store := newTestSessionStore(t)
+	ctx := t.Context()
+	require.Equal(t, "No sessions.\n", runSessionsCommand(t, store, listSessions, false))
+
+	parent, err := store.sessions.Create(ctx, "Add a flag")
+	require.NoError(t, err)
+	task, err := store.sessions.CreateTaskSession(ctx, "call-1", parent.ID, "New Agent Session")
+	require.NoError(t, err)
+
+	out := runSessionsCommand(t, store, listSessions, false)
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	require.Len(t, lines, 3)
+	require.Equal(t, []string{"ID", "PARENT", "TITLE", "MESSAGES", "TOKENS", "COST", "CREATED", "UPDATED"}, strings.Fields(lines[0]))
+	require.Equal(t, []string{task.ID, parent.ID, "New", "Agent", "Session"}, strings.Fields(lines[1])[:5])
+	require.Equal(t, []string{parent.ID, "-", "Add", "a", "flag"}, strings.Fields(lines[2])[:5])
+
+	var items []sessionJSON
+	require.NoError(t, json.Unmarshal([]byte(runSessionsCommand(t, store, listSessions, true)), &items))
+	require.Len(t, items, 2)
+	require.Equal(t, task.ID, items[0].ID)
+	require.Equal(t, parent.ID, items[0].ParentSessionID)
+	require.Equal(t, parent.ID, items[1].ID)
+	require.Empty(t, items[1].ParentSessionID)
+}
+
+func TestSessionsShow(t *testing.T) {
+	t.Parallel()
+
+	store := newTestSessionStore(t)
+	ctx := t.Context()
+	sess, err := store.sessions.Create(ctx, "Add a flag")
+	require.NoError(t, err)
+	_, err = store.messages.Create(ctx, sess.ID, message.CreateMessageParams{
+		Role:  message.User,
+		Parts: []message.ContentPart{message.TextContent{Text: "Add a --verbose flag"}},
+	})
+	require.NoError(t, err)
+	_, err = store.messages.Create(ctx, sess.ID, message.CreateMessageParams{
+		Role: message.Assistant,
+		Parts: []message.ContentPart{
+			message.TextContent{Text: "Let me look at the flags."},
+			message.ToolCall{ID: "call-1", Name: "view", Input: `{"file_path":"main.go"}`, Finished: true},
+		},
+	})
+	require.NoError(t, err)
+
+	out := runSessionsCommand(t, store, showSession, false, sess.ID)
+	require.Regexp(t, `Title:\s+Add a flag\n`, out)
+	require.Regexp(t, `Messages:\s+2\n`, out)
+	require.Contains(t, out, "Add a --verbose flag\n")
+	require.Contains(t, out, "Let me look at the flags.\n-> view {\"file_path\":\"main.go\"}\n")
+
+	var item sessionJSON
+	require.NoError(t, json.Unmarshal([]byte(runSessionsCommand(t, store, showSession, true, sess.ID)), &item))
+	require.Equal(t, sess.ID, item.ID)
+	require.Len(t, item.Messages, 2)
+	require.Equal(t, "Add a --verbose flag", item.Messages[0].Content)
+	require.Len(t, item.Messages[1].ToolCalls, 1)
+
+	cmd := &cobra.Command{}
+	cmd.Flags().Bool("json", false, "")
+	cmd.SetContext(ctx)
+	require.EqualError(t, showSession(cmd, store, []string{"missing"}), "session missing not found")
+}
+
+func TestSessionsRename(t *testing.T) {
+	t.Parallel()
+
+	store := newTestSessionStore(t)
+	ctx := t.Context()
+	sess, err := store.sessions.Create(ctx, "New Session")
+	require.NoError(t, err)
+
+	out := runSessionsCommand(t, store, renameSession, false, sess.ID, "Add", "a", "flag")
+	require.Equal(t, "Renamed session "+sess.ID+" to \"Add a flag\".\n", out)
+	sess, err = store.sessions.Get(ctx, sess.ID)
+	require.NoError(t, err)
+	require.Equal(t, "Add a flag", sess.Title)
+}
+
+func TestSessionsDelete(t *testing.T) {
+	t.Parallel()
+
+	store := newTestSessionStore(t)
+	ctx := t.Context()
+	first, err := store.sessions.Create(ctx, "First")
+	require.NoError(t, err)
+	second, err := store.sessions.Create(ctx, "Second")
+	require.NoError(t, err)
+	kept, err := store.sessions.Create(ctx, "Kept")
+	require.NoError(t, err)
+
+	out := runSessionsCommand(t, store, deleteSessions, false, first.ID, second.ID)
+	require.Equal(t, "Deleted 2 sessions.\n", out)
+	sessions, err := store.sessions.ListAll(ctx)
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	require.Equal(t, kept.ID, sessions[0].ID)
+
+	cmd := &cobra.Command{}
+	cmd.SetContext(ctx)
+	require.EqualError(t, deleteSessions(cmd, store, []string{first.ID}), "session "+first.ID+" not found")
+}
+
+func TestSessionsDeleteChildren(t *testing.T) {
+	t.Parallel()
+
+	store := newTestSessionStore(t)
+	ctx := t.Context()
+	parent, err := store.sessions.Create(ctx, "Parent")
+	require.NoError(t, err)
+	attempt, err := store.sessions.CreateAttemptSession(ctx, parent.ID, "Parent (attempt)")
+	require.NoError(t, err)
+	task, err := store.sessions.CreateTaskSession(ctx, "call", attempt.ID, "Task")
+	require.NoError(t, err)
+	_, err = store.messages.Create(ctx, task.ID, message.CreateMessageParams{
+		Role:  message.User,
+		Parts: []message.ContentPart{message.TextContent{Text: "Look at the parser"}},
+	})
+	require.NoError(t, err)
+	kept, err := store.sessions.Create(ctx, "Kept")
+	require.NoError(t, err)
+	_, err = store.grants.Add(permission.Grant{Scope: permission.ScopeSession, SessionID: task.ID, ToolName: "bash", Action: "execute", Path: "/project"})
+	require.NoError(t, err)
+	keptGrant, err := store.grants.Add(permission.Grant{Scope: permission.ScopeSession, SessionID: kept.ID, ToolName: "bash", Action: "execute", Path: "/project"})
+	require.NoError(t, err)
+
+	// The attempt is deleted with its parent, before its own turn comes.
+	out := runSessionsCommand(t, store, deleteSessions, false, parent.ID, attempt.ID)
+	require.Equal(t, "Deleted 2 sessions.\n", out)
+	sessions, err := store.sessions.ListAll(ctx)
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	require.Equal(t, kept.ID, sessions[0].ID)
+	msgs, err := store.messages.List(ctx, task.ID)
+	require.NoError(t, err)
+	require.Empty(t, msgs)
+	// So are the permissions granted to them.
+	grants, err := store.grants.List()
+	require.NoError(t, err)
+	require.Equal(t, []permission.Grant{keptGrant}, grants)
+}
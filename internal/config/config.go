@@ -20,6 +20,10 @@ import (
 const (
 	appName              = "crush"
 	defaultDataDirectory = ".crush"
+
+	// defaultAutoSummarizeThreshold is the fraction of the model's context
+	// window that, once used, triggers an automatic summarization.
+	defaultAutoSummarizeThreshold = 0.85
 )
 
 var defaultContextPaths = []string{
@@ -134,15 +138,66 @@ type TUIOptions struct {
 type Permissions struct {
 	AllowedTools []string `json:"allowed_tools,omitempty" jsonschema:"description=List of tools that don't require permission prompts,example=bash,example=view"` // Tools that don't require permission prompts
 	SkipRequests bool     `json:"-"`                                                                                                                              // Automatically accept all permissions (YOLO mode)
+
+	Allow []PermissionRule `json:"allow,omitempty" jsonschema:"description=Rules for tool calls that are allowed without prompting"`
+	Deny  []PermissionRule `json:"deny,omitempty" jsonschema:"description=Rules for tool calls that are always denied; deny rules take precedence over all others"`
+	Ask   []PermissionRule `json:"ask,omitempty" jsonschema:"description=Rules for tool calls that always prompt even if the tool is allowed"`
+}
+
+// PermissionRule matches tool calls for the permission policy. All the
+// fields that are set must match for the rule to apply.
+type PermissionRule struct {
+	Tool          string `json:"tool,omitempty" jsonschema:"description=Name of the tool the rule applies to; * matches any characters,example=bash,example=edit,example=mcp_*"`
+	Action        string `json:"action,omitempty" jsonschema:"description=Action of the tool the rule applies to,example=execute,example=write,example=read"`
+	Path          string `json:"path,omitempty" jsonschema:"description=Glob of the files the rule applies to; relative patterns are matched from the working directory and patterns without a slash match at any depth,example=internal/**,example=.env*,example=.git/"`
+	Command       string `json:"command,omitempty" jsonschema:"description=Pattern of the bash commands the rule applies to; * matches any characters,example=go test ./...,example=git status *"`
+	CommandRegex  string `json:"command_regex,omitempty" jsonschema:"description=Regular expression of the bash commands the rule applies to,example=^npm (run )?test"`
+	Reason        string `json:"reason,omitempty" jsonschema:"description=Explanation given to the model when the rule denies a tool call"`
+	NeedsApproval bool   `json:"needs_approval,omitempty" jsonschema:"description=Only apply the rule to tool calls that need approval; safe read-only commands like ls or git status are left out"`
+}
+
+// Hook is a shell command run around tool calls and turns of the agent.
+type Hook struct {
+	Matcher string `json:"matcher,omitempty" jsonschema:"description=Regular expression the tool name must match for the hook to run (any tool when empty),example=^(edit|multiedit|write)$"`
+	Command string `json:"command" jsonschema:"required,description=Shell command to run; it gets the event as JSON on stdin,example=gofmt -l ."`
+	Timeout int    `json:"timeout,omitempty" jsonschema:"description=Timeout of the command in seconds,default=60"`
+}
+
+// Hooks are the hooks run for each event.
+type Hooks struct {
+	PreToolUse        []Hook `json:"pre_tool_use,omitempty" jsonschema:"description=Hooks run before a tool call; exiting with code 2 blocks the call with stderr as the reason given to the model"`
+	PostToolUse       []Hook `json:"post_tool_use,omitempty" jsonschema:"description=Hooks run after a tool call; what they print is added to the result given to the model"`
+	TurnEnd           []Hook `json:"turn_end,omitempty" jsonschema:"description=Hooks run when the agent ends its turn"`
+	PermissionRequest []Hook `json:"permission_request,omitempty" jsonschema:"description=Hooks run when a tool call needs approval; exiting with code 2 denies it with stderr as the reason"`
+}
+
+// Bash configures the commands the bash tool refuses to run and the ones it
+// runs without asking for permission. Patterns are a command name followed by
+// the first arguments and the flags the command must have, where * matches
+// any characters.
+type Bash struct {
+	BannedCommands  []string `json:"banned_commands,omitempty" jsonschema:"description=Commands the bash tool refuses to run in addition to the built-in ones,example=git push,example=rm -rf"`
+	AllowedCommands []string `json:"allowed_commands,omitempty" jsonschema:"description=Commands the bash tool runs even though they are banned,example=curl http://localhost*"`
+	SafeCommands    []string `json:"safe_commands,omitempty" jsonschema:"description=Commands the bash tool runs without asking for permission in addition to the built-in ones,example=make test,example=go vet,example=npm run lint"`
+}
+
+// Sandbox restricts what the commands run by the bash tool can do. It's only
+// supported on Linux.
+type Sandbox struct {
+	Enabled       bool     `json:"enabled,omitempty" jsonschema:"description=Run bash commands in a sandbox that only lets them write to the working directory and the temporary directories (Linux only),default=false"`
+	AllowNetwork  bool     `json:"allow_network,omitempty" jsonschema:"description=Let sandboxed commands use the network,default=false"`
+	WritablePaths []string `json:"writable_paths,omitempty" jsonschema:"description=Other files and directories sandboxed commands can write to; relative paths are from the working directory,example=~/.cache/go-build,example=~/go/pkg/mod"`
 }
 
 type Options struct {
-	ContextPaths         []string    `json:"context_paths,omitempty" jsonschema:"description=Paths to files containing context information for the AI,example=.cursorrules,example=CRUSH.md"`
-	TUI                  *TUIOptions `json:"tui,omitempty" jsonschema:"description=Terminal user interface options"`
-	Debug                bool        `json:"debug,omitempty" jsonschema:"description=Enable debug logging,default=false"`
-	DebugLSP             bool        `json:"debug_lsp,omitempty" jsonschema:"description=Enable debug logging for LSP servers,default=false"`
-	DisableAutoSummarize bool        `json:"disable_auto_summarize,omitempty" jsonschema:"description=Disable automatic conversation summarization,default=false"`
-	DataDirectory        string      `json:"data_directory,omitempty" jsonschema:"description=Directory for storing application data (relative to working directory),default=.crush,example=.crush"` // Relative to the cwd
+	ContextPaths           []string    `json:"context_paths,omitempty" jsonschema:"description=Paths to files containing context information for the AI,example=.cursorrules,example=CRUSH.md"`
+	TUI                    *TUIOptions `json:"tui,omitempty" jsonschema:"description=Terminal user interface options"`
+	Debug                  bool        `json:"debug,omitempty" jsonschema:"description=Enable debug logging,default=false"`
+	DebugLSP               bool        `json:"debug_lsp,omitempty" jsonschema:"description=Enable debug logging for LSP servers,default=false"`
+	DisableAutoSummarize   bool        `json:"disable_auto_summarize,omitempty" jsonschema:"description=Disable automatic conversation summarization,default=false"`
+	AutoSummarizeThreshold float64     `json:"auto_summarize_threshold,omitempty" jsonschema:"description=Fraction of the model context window that triggers automatic conversation summarization,minimum=0.1,maximum=1,default=0.85,example=0.8"`
+	DataDirectory          string      `json:"data_directory,omitempty" jsonschema:"description=Directory for storing application data (relative to working directory),default=.crush,example=.crush"` // Relative to the cwd
+	DisabledTools          []string    `json:"disabled_tools,omitempty" jsonschema:"description=Tools that are not made available to the model,example=bash,example=sourcegraph"`
 }
 
 type MCPs map[string]MCPConfig
@@ -209,30 +264,33 @@ func (m MCPConfig) ResolvedHeaders() map[string]string {
 }
 
 type Agent struct {
-	ID          string `json:"id,omitempty"`
-	Name        string `json:"name,omitempty"`
-	Description string `json:"description,omitempty"`
-	// This is the id of the system prompt used by the agent
-	Disabled bool `json:"disabled,omitempty"`
+	ID          string `json:"id,omitempty" jsonschema:"description=Unique identifier for the agent (defaults to its key in the agents map)"`
+	Name        string `json:"name,omitempty" jsonschema:"description=Human-readable name for the agent (defaults to its ID)"`
+	Description string `json:"description,omitempty" jsonschema:"description=What the agent is for (shown when selecting it and to the coder when delegating to it)"`
+	Disabled    bool   `json:"disabled,omitempty" jsonschema:"description=Whether this agent is disabled,default=false"`
+
+	// Path to a file with the system prompt of the agent, relative to the
+	// working directory. A generic prompt is used when empty.
+	Prompt string `json:"prompt,omitempty" jsonschema:"description=Path to a file containing the system prompt of the agent,example=.crush/agents/reviewer.md"`
 
-	Model SelectedModelType `json:"model" jsonschema:"required,description=The model type to use for this agent,enum=large,enum=small,default=large"`
+	Model SelectedModelType `json:"model,omitempty" jsonschema:"description=The model type to use for this agent,enum=large,enum=small,default=large"`
 
 	// The available tools for the agent
 	//  if this is nil, all tools are available
-	AllowedTools []string `json:"allowed_tools,omitempty"`
+	AllowedTools []string `json:"allowed_tools,omitempty" jsonschema:"description=Built-in tools available to the agent (all when omitted),example=view,example=grep"`
 
 	// this tells us which MCPs are available for this agent
-	//  if this is empty all mcps are available
+	//  if this is nil all mcps are available
 	//  the string array is the list of tools from the AllowedMCP the agent has available
 	//  if the string array is nil, all tools from the AllowedMCP are available
-	AllowedMCP map[string][]string `json:"allowed_mcp,omitempty"`
+	AllowedMCP map[string][]string `json:"allowed_mcp,omitempty" jsonschema:"description=MCP servers available to the agent mapped to the tools allowed from each (all tools of a server when null and all servers when omitted)"`
 
 	// The list of LSPs that this agent can use
 	//  if this is nil, all LSPs are available
-	AllowedLSP []string `json:"allowed_lsp,omitempty"`
+	AllowedLSP []string `json:"allowed_lsp,omitempty" jsonschema:"description=LSP servers available to the agent (all when omitted)"`
 
 	// Overrides the context paths for this agent
-	ContextPaths []string `json:"context_paths,omitempty"`
+	ContextPaths []string `json:"context_paths,omitempty" jsonschema:"description=Paths to files containing context information for the agent (the context_paths option when omitted)"`
 }
 
 // Config holds the configuration for crush.
@@ -253,10 +311,16 @@ type Config struct {
 
 	Permissions *Permissions `json:"permissions,omitempty" jsonschema:"description=Permission settings for tool usage"`
 
+	Agents map[string]Agent `json:"agents,omitempty" jsonschema:"description=Custom agents that can be selected for a session or delegated to by the coder"`
+
+	Hooks Hooks `json:"hooks,omitempty" jsonschema:"description=Shell commands run around tool calls and turns of the agent"`
+
+	Bash Bash `json:"bash,omitempty" jsonschema:"description=Commands the bash tool refuses to run or runs without asking for permission"`
+
+	Sandbox Sandbox `json:"sandbox,omitempty" jsonschema:"description=Sandbox restricting what bash commands can do"`
+
 	// Internal
 	workingDir string `json:"-"`
-	// TODO: most likely remove this concept when I come back to it
-	Agents map[string]Agent `json:"-"`
 	// TODO: find a better way to do this this should probably not be part of the config
 	resolver       VariableResolver
 	dataConfigDir  string             `json:"-"`
@@ -415,6 +479,8 @@ func (c *Config) SetProviderAPIKey(providerID, apiKey string) error {
 	return nil
 }
 
+// SetupAgents sets up the built-in agents along with the custom agents of the
+// configuration that are enabled.
 func (c *Config) SetupAgents() {
 	agents := map[string]Agent{
 		"coder": {
@@ -443,9 +509,40 @@ func (c *Config) SetupAgents() {
 			AllowedLSP: []string{},
 		},
 	}
+	for id, agent := range c.Agents {
+		if IsBuiltinAgent(id) || agent.Disabled {
+			continue
+		}
+		agent.ID = id
+		if agent.Name == "" {
+			agent.Name = id
+		}
+		if agent.Model == "" {
+			agent.Model = SelectedModelTypeLarge
+		}
+		if agent.ContextPaths == nil {
+			agent.ContextPaths = c.Options.ContextPaths
+		}
+		agents[id] = agent
+	}
 	c.Agents = agents
 }
 
+// SessionAgent returns the agent a session is run with given its agent ID, the
+// coder when it has none or when the agent isn't configured anymore.
+func (c *Config) SessionAgent(id string) Agent {
+	if agent, ok := c.Agents[id]; ok {
+		return agent
+	}
+	return c.Agents["coder"]
+}
+
+// IsBuiltinAgent returns whether the agent is one of the agents crush comes
+// with, which can't be redefined in the configuration.
+func IsBuiltinAgent(id string) bool {
+	return id == "coder" || id == "task"
+}
+
 func (c *Config) Resolver() VariableResolver {
 	return c.resolver
 }
@@ -64,6 +64,12 @@ func Load(workingDir, dataDir string, debug bool) (*Config, error) {
 		cfg.Options.Debug,
 	)
 
+	for id := range cfg.Agents {
+		if IsBuiltinAgent(id) {
+			slog.Warn("Ignoring agent with the ID of a built-in agent", "agent", id)
+		}
+	}
+
 	// Load known providers, this loads the config from catwalk
 	providers, err := Providers()
 	if err != nil || len(providers) == 0 {
@@ -310,6 +316,9 @@ func (c *Config) setDefaults(workingDir, dataDir string) {
 	if c.Options.ContextPaths == nil {
 		c.Options.ContextPaths = []string{}
 	}
+	if c.Options.AutoSummarizeThreshold <= 0 || c.Options.AutoSummarizeThreshold > 1 {
+		c.Options.AutoSummarizeThreshold = defaultAutoSummarizeThreshold
+	}
 	if dataDir != "" {
 		c.Options.DataDirectory = dataDir
 	} else if c.Options.DataDirectory == "" {
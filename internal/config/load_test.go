@@ -1184,3 +1184,38 @@ func TestConfig_configureSelectedModels(t *testing.T) {
 		require.Equal(t, int64(100), large.MaxTokens)
 	})
 }
+
+func TestConfig_SetupAgents(t *testing.T) {
+	data := strings.NewReader(`{"agents": {
+		"reviewer": {"description": "Reviews changes", "prompt": "reviewer.md", "allowed_tools": ["view", "grep"], "allowed_mcp": {}},
+		"docs": {"name": "Docs", "model": "small", "context_paths": ["docs"]},
+		"old": {"disabled": true},
+		"coder": {"allowed_tools": []}
+	}}`)
+	cfg, err := loadFromReaders([]io.Reader{data})
+	require.NoError(t, err)
+	cfg.setDefaults("/tmp", "")
+
+	cfg.SetupAgents()
+	// Setting up the agents again after the onboarding changes nothing.
+	cfg.SetupAgents()
+
+	require.Len(t, cfg.Agents, 4)
+	require.Nil(t, cfg.Agents["coder"].AllowedTools)
+	require.NotContains(t, cfg.Agents, "old")
+
+	reviewer := cfg.Agents["reviewer"]
+	require.Equal(t, "reviewer", reviewer.ID)
+	require.Equal(t, "reviewer", reviewer.Name)
+	require.Equal(t, "reviewer.md", reviewer.Prompt)
+	require.Equal(t, SelectedModelTypeLarge, reviewer.Model)
+	require.Equal(t, []string{"view", "grep"}, reviewer.AllowedTools)
+	require.NotNil(t, reviewer.AllowedMCP)
+	require.Nil(t, reviewer.AllowedLSP)
+	require.Equal(t, cfg.Options.ContextPaths, reviewer.ContextPaths)
+
+	docs := cfg.Agents["docs"]
+	require.Equal(t, "Docs", docs.Name)
+	require.Equal(t, SelectedModelTypeSmall, docs.Model)
+	require.Equal(t, []string{"docs"}, docs.ContextPaths)
+}
@@ -19,11 +19,15 @@ INSERT INTO messages (
     model,
     provider,
     created_at,
-    updated_at
+    updated_at,
+    seq
 ) VALUES (
-    ?, ?, ?, ?, ?, ?, strftime('%s', 'now'), strftime('%s', 'now')
+    ?, ?, ?, ?, ?, ?,
+    coalesce(?, strftime('%s', 'now')),
+    strftime('%s', 'now'),
+    (SELECT max(coalesce((SELECT max(seq) FROM messages), 0), coalesce((SELECT max(seq) FROM files), 0)) + 1)
 )
-RETURNING id, session_id, role, parts, model, created_at, updated_at, finished_at, provider
+RETURNING id, session_id, role, parts, model, created_at, updated_at, finished_at, provider, seq
 `
 
 type CreateMessageParams struct {
@@ -33,6 +37,7 @@ type CreateMessageParams struct {
 	Parts     string         `json:"parts"`
 	Model     sql.NullString `json:"model"`
 	Provider  sql.NullString `json:"provider"`
+	CreatedAt sql.NullInt64  `json:"created_at"`
 }
 
 func (q *Queries) CreateMessage(ctx context.Context, arg CreateMessageParams) (Message, error) {
@@ -43,6 +48,7 @@ func (q *Queries) CreateMessage(ctx context.Context, arg CreateMessageParams) (M
 		arg.Parts,
 		arg.Model,
 		arg.Provider,
+		arg.CreatedAt,
 	)
 	var i Message
 	err := row.Scan(
@@ -55,6 +61,7 @@ func (q *Queries) CreateMessage(ctx context.Context, arg CreateMessageParams) (M
 		&i.UpdatedAt,
 		&i.FinishedAt,
 		&i.Provider,
+		&i.Seq,
 	)
 	return i, err
 }
@@ -80,7 +87,7 @@ func (q *Queries) DeleteSessionMessages(ctx context.Context, sessionID string) e
 }
 
 const getMessage = `-- name: GetMessage :one
-SELECT id, session_id, role, parts, model, created_at, updated_at, finished_at, provider
+SELECT id, session_id, role, parts, model, created_at, updated_at, finished_at, provider, seq
 FROM messages
 WHERE id = ? LIMIT 1
 `
@@ -98,15 +105,16 @@ func (q *Queries) GetMessage(ctx context.Context, id string) (Message, error) {
 		&i.UpdatedAt,
 		&i.FinishedAt,
 		&i.Provider,
+		&i.Seq,
 	)
 	return i, err
 }
 
 const listMessagesBySession = `-- name: ListMessagesBySession :many
-SELECT id, session_id, role, parts, model, created_at, updated_at, finished_at, provider
+SELECT id, session_id, role, parts, model, created_at, updated_at, finished_at, provider, seq
 FROM messages
 WHERE session_id = ?
-ORDER BY created_at ASC
+ORDER BY seq ASC
 `
 
 func (q *Queries) ListMessagesBySession(ctx context.Context, sessionID string) ([]Message, error) {
@@ -128,6 +136,68 @@ func (q *Queries) ListMessagesBySession(ctx context.Context, sessionID string) (
 			&i.UpdatedAt,
 			&i.FinishedAt,
 			&i.Provider,
+			&i.Seq,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const searchMessages = `-- name: SearchMessages :many
+SELECT
+    messages.id,
+    messages.session_id,
+    messages.role,
+    messages.created_at,
+    sessions.title AS session_title,
+    snippet(messages_fts, 0, '', '', '...', 16) AS snippet
+FROM messages_fts
+JOIN messages ON messages.rowid = messages_fts.rowid
+JOIN sessions ON sessions.id = messages.session_id
+WHERE messages_fts MATCH ?1
+    AND sessions.parent_session_id IS NULL
+ORDER BY rank
+LIMIT ?2
+`
+
+type SearchMessagesParams struct {
+	Query string `json:"query"`
+	Limit int64  `json:"limit"`
+}
+
+type SearchMessagesRow struct {
+	ID           string `json:"id"`
+	SessionID    string `json:"session_id"`
+	Role         string `json:"role"`
+	CreatedAt    int64  `json:"created_at"`
+	SessionTitle string `json:"session_title"`
+	Snippet      string `json:"snippet"`
+}
+
+func (q *Queries) SearchMessages(ctx context.Context, arg SearchMessagesParams) ([]SearchMessagesRow, error) {
+	rows, err := q.query(ctx, q.searchMessagesStmt, searchMessages, arg.Query, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []SearchMessagesRow{}
+	for rows.Next() {
+		var i SearchMessagesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.SessionID,
+			&i.Role,
+			&i.CreatedAt,
+			&i.SessionTitle,
+			&i.Snippet,
 		); err != nil {
 			return nil, err
 		}
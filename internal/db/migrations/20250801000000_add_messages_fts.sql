@@ -0,0 +1,75 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Full-text index of the text, tool calls and tool results of messages. Rows
+-- share the rowid of the message they index.
+CREATE VIRTUAL TABLE IF NOT EXISTS messages_fts USING fts5 (
+    content,
+    tokenize = 'unicode61'
+);
+
+INSERT INTO messages_fts (rowid, content)
+SELECT
+    messages.rowid,
+    coalesce((
+        SELECT group_concat(value, ' ') FROM (
+            SELECT CASE json_extract(p.value, '$.type')
+                WHEN 'text' THEN json_extract(p.value, '$.data.text')
+                WHEN 'tool_call' THEN json_extract(p.value, '$.data.name') || ' ' || json_extract(p.value, '$.data.input')
+                WHEN 'tool_result' THEN json_extract(p.value, '$.data.content')
+            END AS value
+            FROM json_each(messages.parts) AS p
+        )
+    ), '')
+FROM messages;
+
+CREATE TRIGGER IF NOT EXISTS messages_fts_insert
+AFTER INSERT ON messages
+BEGIN
+INSERT INTO messages_fts (rowid, content)
+SELECT
+    new.rowid,
+    coalesce((
+        SELECT group_concat(value, ' ') FROM (
+            SELECT CASE json_extract(p.value, '$.type')
+                WHEN 'text' THEN json_extract(p.value, '$.data.text')
+                WHEN 'tool_call' THEN json_extract(p.value, '$.data.name') || ' ' || json_extract(p.value, '$.data.input')
+                WHEN 'tool_result' THEN json_extract(p.value, '$.data.content')
+            END AS value
+            FROM json_each(new.parts) AS p
+        )
+    ), '');
+END;
+
+CREATE TRIGGER IF NOT EXISTS messages_fts_update
+AFTER UPDATE OF parts ON messages
+BEGIN
+DELETE FROM messages_fts WHERE rowid = old.rowid;
+INSERT INTO messages_fts (rowid, content)
+SELECT
+    new.rowid,
+    coalesce((
+        SELECT group_concat(value, ' ') FROM (
+            SELECT CASE json_extract(p.value, '$.type')
+                WHEN 'text' THEN json_extract(p.value, '$.data.text')
+                WHEN 'tool_call' THEN json_extract(p.value, '$.data.name') || ' ' || json_extract(p.value, '$.data.input')
+                WHEN 'tool_result' THEN json_extract(p.value, '$.data.content')
+            END AS value
+            FROM json_each(new.parts) AS p
+        )
+    ), '');
+END;
+
+CREATE TRIGGER IF NOT EXISTS messages_fts_delete
+AFTER DELETE ON messages
+BEGIN
+DELETE FROM messages_fts WHERE rowid = old.rowid;
+END;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER IF EXISTS messages_fts_insert;
+DROP TRIGGER IF EXISTS messages_fts_update;
+DROP TRIGGER IF EXISTS messages_fts_delete;
+DROP TABLE IF EXISTS messages_fts;
+-- +goose StatementEnd
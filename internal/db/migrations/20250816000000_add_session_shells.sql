@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS session_shells (
+    session_id TEXT PRIMARY KEY,
+    working_dir TEXT NOT NULL,
+    env TEXT NOT NULL DEFAULT '[]',  -- JSON array of KEY=value
+    updated_at INTEGER NOT NULL,  -- Unix timestamp in milliseconds
+    FOREIGN KEY (session_id) REFERENCES sessions (id) ON DELETE CASCADE
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS session_shells;
+-- +goose StatementEnd
@@ -0,0 +1,50 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Messages are updated with every chunk streamed from the provider, so they're
+-- only indexed again once they're finished.
+DROP TRIGGER IF EXISTS messages_fts_update;
+
+CREATE TRIGGER IF NOT EXISTS messages_fts_update
+AFTER UPDATE OF parts ON messages
+WHEN new.finished_at IS NOT NULL
+BEGIN
+DELETE FROM messages_fts WHERE rowid = old.rowid;
+INSERT INTO messages_fts (rowid, content)
+SELECT
+    new.rowid,
+    coalesce((
+        SELECT group_concat(value, ' ') FROM (
+            SELECT CASE json_extract(p.value, '$.type')
+                WHEN 'text' THEN json_extract(p.value, '$.data.text')
+                WHEN 'tool_call' THEN json_extract(p.value, '$.data.name') || ' ' || json_extract(p.value, '$.data.input')
+                WHEN 'tool_result' THEN json_extract(p.value, '$.data.content')
+            END AS value
+            FROM json_each(new.parts) AS p
+        )
+    ), '');
+END;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER IF EXISTS messages_fts_update;
+
+CREATE TRIGGER IF NOT EXISTS messages_fts_update
+AFTER UPDATE OF parts ON messages
+BEGIN
+DELETE FROM messages_fts WHERE rowid = old.rowid;
+INSERT INTO messages_fts (rowid, content)
+SELECT
+    new.rowid,
+    coalesce((
+        SELECT group_concat(value, ' ') FROM (
+            SELECT CASE json_extract(p.value, '$.type')
+                WHEN 'text' THEN json_extract(p.value, '$.data.text')
+                WHEN 'tool_call' THEN json_extract(p.value, '$.data.name') || ' ' || json_extract(p.value, '$.data.input')
+                WHEN 'tool_result' THEN json_extract(p.value, '$.data.content')
+            END AS value
+            FROM json_each(new.parts) AS p
+        )
+    ), '');
+END;
+-- +goose StatementEnd
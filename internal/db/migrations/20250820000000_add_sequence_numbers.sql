@@ -0,0 +1,56 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Timestamps only have a precision of a second, so messages and file
+-- versions are ordered by a sequence number shared by both tables instead.
+-- New rows get a number larger than any other one.
+ALTER TABLE messages ADD COLUMN seq INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE files ADD COLUMN seq INTEGER NOT NULL DEFAULT 0;
+
+-- Existing rows are numbered by creation time, messages first within the
+-- same second, without touching their updated_at.
+DROP TRIGGER IF EXISTS update_messages_updated_at;
+DROP TRIGGER IF EXISTS update_files_updated_at;
+
+CREATE TEMP TABLE sequence_numbers AS
+SELECT kind, id, row_number() OVER (ORDER BY created_at, kind, rid) AS seq
+FROM (
+    SELECT 0 AS kind, id, created_at, rowid AS rid FROM messages
+    UNION ALL
+    SELECT 1 AS kind, id, created_at, rowid AS rid FROM files
+);
+
+UPDATE messages SET seq = sequence_numbers.seq
+FROM sequence_numbers
+WHERE sequence_numbers.kind = 0 AND sequence_numbers.id = messages.id;
+
+UPDATE files SET seq = sequence_numbers.seq
+FROM sequence_numbers
+WHERE sequence_numbers.kind = 1 AND sequence_numbers.id = files.id;
+
+DROP TABLE sequence_numbers;
+
+CREATE TRIGGER IF NOT EXISTS update_messages_updated_at
+AFTER UPDATE ON messages
+BEGIN
+UPDATE messages SET updated_at = strftime('%s', 'now')
+WHERE id = new.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS update_files_updated_at
+AFTER UPDATE ON files
+BEGIN
+UPDATE files SET updated_at = strftime('%s', 'now')
+WHERE id = new.id;
+END;
+
+CREATE INDEX IF NOT EXISTS idx_messages_seq ON messages (seq);
+CREATE INDEX IF NOT EXISTS idx_files_seq ON files (seq);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_messages_seq;
+DROP INDEX IF EXISTS idx_files_seq;
+ALTER TABLE messages DROP COLUMN seq;
+ALTER TABLE files DROP COLUMN seq;
+-- +goose StatementEnd
@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Versions of files are flagged when the file didn't exist, instead of
+-- telling them apart by their empty content, which empty files also have.
+ALTER TABLE files ADD COLUMN missing BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Files were recorded with empty content before being created by a session.
+UPDATE files SET missing = TRUE
+WHERE content = ''
+    AND NOT EXISTS (
+        SELECT 1 FROM files AS earlier
+        WHERE earlier.session_id = files.session_id
+            AND earlier.path = files.path
+            AND earlier.seq < files.seq
+    );
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE files DROP COLUMN missing;
+-- +goose StatementEnd
@@ -14,7 +14,7 @@ LIMIT 1;
 SELECT *
 FROM files
 WHERE session_id = ?
-ORDER BY version ASC, created_at ASC;
+ORDER BY seq ASC;
 
 -- name: ListFilesByPath :many
 SELECT *
@@ -30,9 +30,15 @@ INSERT INTO files (
     content,
     version,
     created_at,
-    updated_at
+    updated_at,
+    seq,
+    missing
 ) VALUES (
-    ?, ?, ?, ?, ?, strftime('%s', 'now'), strftime('%s', 'now')
+    ?, ?, ?, ?, ?,
+    coalesce(sqlc.narg(created_at), strftime('%s', 'now')),
+    strftime('%s', 'now'),
+    (SELECT max(coalesce((SELECT max(seq) FROM messages), 0), coalesce((SELECT max(seq) FROM files), 0)) + 1),
+    sqlc.arg(missing)
 )
 RETURNING *;
 
@@ -7,7 +7,7 @@ WHERE id = ? LIMIT 1;
 SELECT *
 FROM messages
 WHERE session_id = ?
-ORDER BY created_at ASC;
+ORDER BY seq ASC;
 
 -- name: CreateMessage :one
 INSERT INTO messages (
@@ -18,9 +18,13 @@ INSERT INTO messages (
     model,
     provider,
     created_at,
-    updated_at
+    updated_at,
+    seq
 ) VALUES (
-    ?, ?, ?, ?, ?, ?, strftime('%s', 'now'), strftime('%s', 'now')
+    ?, ?, ?, ?, ?, ?,
+    coalesce(sqlc.narg(created_at), strftime('%s', 'now')),
+    strftime('%s', 'now'),
+    (SELECT max(coalesce((SELECT max(seq) FROM messages), 0), coalesce((SELECT max(seq) FROM files), 0)) + 1)
 )
 RETURNING *;
 
@@ -40,3 +44,19 @@ WHERE id = ?;
 -- name: DeleteSessionMessages :exec
 DELETE FROM messages
 WHERE session_id = ?;
+
+-- name: SearchMessages :many
+SELECT
+    messages.id,
+    messages.session_id,
+    messages.role,
+    messages.created_at,
+    sessions.title AS session_title,
+    snippet(messages_fts, 0, '', '', '...', 16) AS snippet
+FROM messages_fts
+JOIN messages ON messages.rowid = messages_fts.rowid
+JOIN sessions ON sessions.id = messages.session_id
+WHERE messages_fts MATCH sqlc.arg(query)
+    AND sessions.parent_session_id IS NULL
+ORDER BY rank
+LIMIT sqlc.arg(limit);
@@ -0,0 +1,18 @@
+-- name: GetSessionShell :one
+SELECT *
+FROM session_shells
+WHERE session_id = ? LIMIT 1;
+
+-- name: UpsertSessionShell :exec
+INSERT INTO session_shells (
+    session_id,
+    working_dir,
+    env,
+    updated_at
+) VALUES (
+    ?, ?, ?, strftime('%s', 'now')
+)
+ON CONFLICT (session_id) DO UPDATE SET
+    working_dir = excluded.working_dir,
+    env = excluded.env,
+    updated_at = excluded.updated_at;
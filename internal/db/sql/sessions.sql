@@ -8,6 +8,7 @@ INSERT INTO sessions (
     completion_tokens,
     cost,
     summary_message_id,
+    forked_from_session_id,
     updated_at,
     created_at
 ) VALUES (
@@ -19,6 +20,7 @@ INSERT INTO sessions (
     ?,
     ?,
     null,
+    ?,
     strftime('%s', 'now'),
     strftime('%s', 'now')
 ) RETURNING *;
@@ -28,6 +30,11 @@ SELECT *
 FROM sessions
 WHERE id = ? LIMIT 1;
 
+-- name: ListAllSessions :many
+SELECT *
+FROM sessions
+ORDER BY created_at DESC, rowid DESC;
+
 -- name: ListSessions :many
 SELECT *
 FROM sessions
@@ -41,11 +48,21 @@ SET
     prompt_tokens = ?,
     completion_tokens = ?,
     summary_message_id = ?,
-    cost = ?
+    cost = ?,
+    agent_id = ?,
+    plan_mode = ?
 WHERE id = ?
 RETURNING *;
 
 
 -- name: DeleteSession :exec
+-- The session is deleted along with its child sessions, and theirs.
+WITH RECURSIVE descendants(id) AS (
+    SELECT sqlc.arg(id)
+    UNION ALL
+    SELECT sessions.id
+    FROM sessions
+    JOIN descendants ON sessions.parent_session_id = descendants.id
+)
 DELETE FROM sessions
-WHERE id = ?;
+WHERE id IN (SELECT id FROM descendants);
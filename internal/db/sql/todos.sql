@@ -0,0 +1,30 @@
+-- name: ListTodosBySession :many
+SELECT *
+FROM todos
+WHERE session_id = ?
+ORDER BY position ASC;
+
+-- name: CreateTodo :one
+INSERT INTO todos (
+    id,
+    session_id,
+    position,
+    content,
+    status,
+    created_at,
+    updated_at
+) VALUES (
+    ?, ?, ?, ?, ?, strftime('%s', 'now'), strftime('%s', 'now')
+)
+RETURNING *;
+
+-- name: UpdateTodo :exec
+UPDATE todos
+SET
+    content = ?,
+    status = ?
+WHERE id = ?;
+
+-- name: DeleteTodo :exec
+DELETE FROM todos
+WHERE id = ?;
@@ -0,0 +1,422 @@
+// Package export turns sessions into shareable transcripts, and portable
+// bundles that can be imported into another project.
+package export
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/db"
+	"github.com/charmbracelet/crush/internal/history"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/charmbracelet/crush/internal/session"
+	"github.com/google/uuid"
+)
+
+// BundleVersion is the version of the bundle format written by this package.
+const BundleVersion = 1
+
+// Format is the format of an exported session.
+type Format string
+
+const (
+	Markdown Format = "markdown"
+	HTML     Format = "html"
+	JSON     Format = "json"
+)
+
+// Formats are all the supported export formats.
+var Formats = []Format{Markdown, HTML, JSON}
+
+// Extension returns the file extension of the format, including the dot.
+func (f Format) Extension() string {
+	switch f {
+	case HTML:
+		return ".html"
+	case JSON:
+		return ".json"
+	default:
+		return ".md"
+	}
+}
+
+// Services are the services sessions are exported from.
+type Services struct {
+	Sessions session.Service
+	Messages message.Service
+	History  history.Service
+}
+
+// Bundle is a session with everything needed to recreate it: its messages,
+// the history of the files it changed and its child sessions.
+type Bundle struct {
+	Version    int       `json:"version"`
+	ExportedAt time.Time `json:"exported_at"`
+	Conversation
+}
+
+// Conversation is a session with its messages, the history of the files it
+// changed, and the same for each of its child sessions, the sessions of the
+// sub-agents it ran and the attempts kept when retrying it.
+type Conversation struct {
+	Session  Session        `json:"session"`
+	Messages []Message      `json:"messages"`
+	Files    []File         `json:"files"`
+	Children []Conversation `json:"children,omitempty"`
+}
+
+type Session struct {
+	ID                  string  `json:"id"`
+	ParentSessionID     string  `json:"parent_session_id,omitempty"`
+	ForkedFromSessionID string  `json:"forked_from_session_id,omitempty"`
+	Title               string  `json:"title"`
+	MessageCount        int64   `json:"message_count"`
+	PromptTokens        int64   `json:"prompt_tokens"`
+	CompletionTokens    int64   `json:"completion_tokens"`
+	SummaryMessageID    string  `json:"summary_message_id,omitempty"`
+	Cost                float64 `json:"cost"`
+	AgentID             string  `json:"agent_id,omitempty"`
+	PlanMode            bool    `json:"plan_mode,omitempty"`
+	CreatedAt           int64   `json:"created_at"`
+	UpdatedAt           int64   `json:"updated_at"`
+}
+
+// Message is a message with its parts encoded the way they are stored, so
+// none of them is lost.
+type Message struct {
+	ID        string              `json:"id"`
+	Role      message.MessageRole `json:"role"`
+	Parts     json.RawMessage     `json:"parts"`
+	Model     string              `json:"model,omitempty"`
+	Provider  string              `json:"provider,omitempty"`
+	CreatedAt int64               `json:"created_at"`
+	UpdatedAt int64               `json:"updated_at"`
+	// Seq orders the messages and file versions of the session.
+	Seq int64 `json:"seq"`
+}
+
+// File is a version of a file changed in the session. Its path is relative
+// to the working directory the session was exported from, unless the file is
+// outside of it.
+type File struct {
+	Path      string `json:"path"`
+	Content   string `json:"content"`
+	Version   int64  `json:"version"`
+	CreatedAt int64  `json:"created_at"`
+	Seq       int64  `json:"seq"`
+	// Missing is set when the file didn't exist at this version.
+	Missing bool `json:"missing,omitempty"`
+}
+
+// Load reads the session with the given ID into a bundle, along with its
+// child sessions. Paths of files in the working directory are made relative to
+// it.
+func Load(ctx context.Context, s Services, sessionID, workingDir string) (Bundle, error) {
+	sessions, err := s.Sessions.ListAll(ctx)
+	if err != nil {
+		return Bundle{}, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	// Sessions are listed newest first, children are kept oldest first.
+	children := make(map[string][]session.Session)
+	for _, sess := range slices.Backward(sessions) {
+		if sess.ParentSessionID != "" {
+			children[sess.ParentSessionID] = append(children[sess.ParentSessionID], sess)
+		}
+	}
+	sess, err := s.Sessions.Get(ctx, sessionID)
+	if err != nil {
+		return Bundle{}, fmt.Errorf("failed to get session: %w", err)
+	}
+	c, err := loadConversation(ctx, s, sess, children, workingDir)
+	if err != nil {
+		return Bundle{}, err
+	}
+	return Bundle{
+		Version:      BundleVersion,
+		ExportedAt:   time.Now().UTC(),
+		Conversation: c,
+	}, nil
+}
+
+func loadConversation(ctx context.Context, s Services, sess session.Session, children map[string][]session.Session, workingDir string) (Conversation, error) {
+	msgs, err := s.Messages.List(ctx, sess.ID)
+	if err != nil {
+		return Conversation{}, fmt.Errorf("failed to list messages: %w", err)
+	}
+	files, err := s.History.ListBySession(ctx, sess.ID)
+	if err != nil {
+		return Conversation{}, fmt.Errorf("failed to list files: %w", err)
+	}
+
+	c := Conversation{
+		Session: Session{
+			ID:                  sess.ID,
+			ParentSessionID:     sess.ParentSessionID,
+			ForkedFromSessionID: sess.ForkedFromSessionID,
+			Title:               sess.Title,
+			MessageCount:        sess.MessageCount,
+			PromptTokens:        sess.PromptTokens,
+			CompletionTokens:    sess.CompletionTokens,
+			SummaryMessageID:    sess.SummaryMessageID,
+			Cost:                sess.Cost,
+			AgentID:             sess.AgentID,
+			PlanMode:            sess.PlanMode,
+			CreatedAt:           sess.CreatedAt,
+			UpdatedAt:           sess.UpdatedAt,
+		},
+		Messages: make([]Message, 0, len(msgs)),
+		Files:    make([]File, 0, len(files)),
+	}
+	for _, msg := range msgs {
+		parts, err := message.MarshalParts(msg.Parts)
+		if err != nil {
+			return Conversation{}, fmt.Errorf("failed to encode message %s: %w", msg.ID, err)
+		}
+		c.Messages = append(c.Messages, Message{
+			ID:        msg.ID,
+			Role:      msg.Role,
+			Parts:     parts,
+			Model:     msg.Model,
+			Provider:  msg.Provider,
+			CreatedAt: msg.CreatedAt,
+			UpdatedAt: msg.UpdatedAt,
+			Seq:       msg.Seq,
+		})
+	}
+	for _, file := range files {
+		c.Files = append(c.Files, File{
+			Path:      relativePath(workingDir, file.Path),
+			Content:   file.Content,
+			Version:   file.Version,
+			CreatedAt: file.CreatedAt,
+			Seq:       file.Seq,
+			Missing:   file.Missing,
+		})
+	}
+	for _, child := range children[sess.ID] {
+		cc, err := loadConversation(ctx, s, child, children, workingDir)
+		if err != nil {
+			return Conversation{}, err
+		}
+		c.Children = append(c.Children, cc)
+	}
+	return c, nil
+}
+
+// relativePath returns the path relative to the working directory, with
+// forward slashes, or the path as is when it's outside of it.
+func relativePath(workingDir, path string) string {
+	if workingDir == "" || !filepath.IsAbs(path) {
+		return path
+	}
+	rel, err := filepath.Rel(workingDir, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return path
+	}
+	return filepath.ToSlash(rel)
+}
+
+// absolutePath returns the path in the working directory when it's relative.
+func absolutePath(workingDir, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(workingDir, filepath.FromSlash(path))
+}
+
+// Write writes the bundle in the given format.
+func (b Bundle) Write(w io.Writer, format Format) error {
+	switch format {
+	case JSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(b)
+	case Markdown:
+		return writeMarkdown(w, newTranscript(b))
+	case HTML:
+		return writeHTML(w, newTranscript(b))
+	default:
+		return fmt.Errorf("unsupported export format %q", format)
+	}
+}
+
+// Read reads a bundle written in the JSON format.
+func Read(r io.Reader) (Bundle, error) {
+	var b Bundle
+	if err := json.NewDecoder(r).Decode(&b); err != nil {
+		return Bundle{}, fmt.Errorf("failed to decode bundle: %w", err)
+	}
+	if b.Version == 0 || b.Version > BundleVersion {
+		return Bundle{}, fmt.Errorf("unsupported bundle version %d", b.Version)
+	}
+	return b, nil
+}
+
+// Import recreates the session of the bundle and its child sessions, with new
+// IDs, in a single transaction, and returns it. Relative paths of files are
+// rebased on the working directory.
+func Import(ctx context.Context, conn *sql.DB, b Bundle, workingDir string) (session.Session, error) {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return session.Session{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	q := db.New(tx)
+	im := importer{
+		q:          q,
+		sessions:   session.NewService(q),
+		messages:   message.NewService(q),
+		workingDir: workingDir,
+		sessionIDs: make(map[string]string),
+	}
+	sess, err := im.importConversation(ctx, b.Conversation, b.Session.ParentSessionID)
+	if err != nil {
+		return session.Session{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return session.Session{}, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return sess, nil
+}
+
+type importer struct {
+	q          *db.Queries
+	sessions   session.Service
+	messages   message.Service
+	workingDir string
+	// sessionIDs maps the IDs of the imported sessions to their new IDs.
+	sessionIDs map[string]string
+}
+
+// importConversation recreates the session as a child of the given parent,
+// then its child sessions.
+func (im *importer) importConversation(ctx context.Context, c Conversation, parentSessionID string) (session.Session, error) {
+	sess, err := im.createSession(ctx, c.Session, parentSessionID)
+	if err != nil {
+		return session.Session{}, fmt.Errorf("failed to create session: %w", err)
+	}
+	im.sessionIDs[c.Session.ID] = sess.ID
+
+	// Messages and file versions are created in the order they were, so the
+	// session can be rewound like the original.
+	msgs, files := c.Messages, c.Files
+	messageIDs := make(map[string]string, len(msgs))
+	for len(msgs) > 0 || len(files) > 0 {
+		if len(files) > 0 && (len(msgs) == 0 || files[0].Seq < msgs[0].Seq) {
+			f := files[0]
+			_, err := im.q.CreateFile(ctx, db.CreateFileParams{
+				ID:        uuid.New().String(),
+				SessionID: sess.ID,
+				Path:      absolutePath(im.workingDir, f.Path),
+				Content:   f.Content,
+				Version:   f.Version,
+				CreatedAt: sql.NullInt64{Int64: f.CreatedAt, Valid: f.CreatedAt != 0},
+				Missing:   f.Missing,
+			})
+			if err != nil {
+				return session.Session{}, fmt.Errorf("failed to create history of %s: %w", f.Path, err)
+			}
+			files = files[1:]
+			continue
+		}
+
+		m := msgs[0]
+		parts, err := message.UnmarshalParts(m.Parts)
+		if err != nil {
+			return session.Session{}, fmt.Errorf("failed to decode message %s: %w", m.ID, err)
+		}
+		msg, err := im.messages.Create(ctx, sess.ID, message.CreateMessageParams{
+			Role:      m.Role,
+			Parts:     parts,
+			Model:     m.Model,
+			Provider:  m.Provider,
+			CreatedAt: m.CreatedAt,
+		})
+		if err != nil {
+			return session.Session{}, fmt.Errorf("failed to create message: %w", err)
+		}
+		// Messages are created with an extra finish part, unless they come
+		// from the assistant, so the original parts are written back as is.
+		msg.Parts = parts
+		if err := im.messages.Update(ctx, msg); err != nil {
+			return session.Session{}, fmt.Errorf("failed to update message: %w", err)
+		}
+		messageIDs[m.ID] = msg.ID
+		msgs = msgs[1:]
+	}
+
+	// The message count is kept up to date by the database.
+	sess, err = im.sessions.Get(ctx, sess.ID)
+	if err != nil {
+		return session.Session{}, fmt.Errorf("failed to get session: %w", err)
+	}
+	sess.PromptTokens = c.Session.PromptTokens
+	sess.CompletionTokens = c.Session.CompletionTokens
+	sess.Cost = c.Session.Cost
+	sess.SummaryMessageID = messageIDs[c.Session.SummaryMessageID]
+	sess.AgentID = c.Session.AgentID
+	sess.PlanMode = c.Session.PlanMode
+	sess, err = im.sessions.Save(ctx, sess)
+	if err != nil {
+		return session.Session{}, fmt.Errorf("failed to save session: %w", err)
+	}
+
+	for _, child := range c.Children {
+		if _, err := im.importConversation(ctx, child, sess.ID); err != nil {
+			return session.Session{}, err
+		}
+	}
+	return sess, nil
+}
+
+// createSession creates the session with a new ID. The session it was forked
+// from and its parent are kept when they were imported along with it or
+// already exist, and left out otherwise.
+func (im *importer) createSession(ctx context.Context, sess Session, parentSessionID string) (session.Session, error) {
+	parentSessionID, err := im.sessionID(ctx, parentSessionID)
+	if err != nil {
+		return session.Session{}, err
+	}
+	forkedFromSessionID, err := im.sessionID(ctx, sess.ForkedFromSessionID)
+	if err != nil {
+		return session.Session{}, err
+	}
+	dbSession, err := im.q.CreateSession(ctx, db.CreateSessionParams{
+		ID:                  uuid.New().String(),
+		ParentSessionID:     sql.NullString{String: parentSessionID, Valid: parentSessionID != ""},
+		Title:               sess.Title,
+		ForkedFromSessionID: sql.NullString{String: forkedFromSessionID, Valid: forkedFromSessionID != ""},
+	})
+	if err != nil {
+		return session.Session{}, err
+	}
+	return im.sessions.Get(ctx, dbSession.ID)
+}
+
+// sessionID returns the new ID of an imported session, the ID as is when the
+// session exists, or an empty ID.
+func (im *importer) sessionID(ctx context.Context, id string) (string, error) {
+	if id == "" {
+		return "", nil
+	}
+	if newID, ok := im.sessionIDs[id]; ok {
+		return newID, nil
+	}
+	_, err := im.q.GetSessionByID(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get session %s: %w", id, err)
+	}
+	return id, nil
+}
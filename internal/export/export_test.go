@@ -0,0 +1,194 @@
+package export
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/db"
+	"github.com/charmbracelet/crush/internal/history"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/charmbracelet/crush/internal/session"
+	"github.com/stretchr/testify/require"
+)
+
+func newServices(t *testing.T) Services {
+	t.Helper()
+	s, _ := newStore(t)
+	return s
+}
+
+func newStore(t *testing.T) (Services, *sql.DB) {
+	t.Helper()
+	conn, err := db.Connect(t.Context(), t.TempDir())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	q := db.New(conn)
+	return Services{
+		Sessions: session.NewService(q),
+		Messages: message.NewService(q),
+		History:  history.NewService(q, conn),
+	}, conn
+}
+
+func createSession(t *testing.T, s Services) session.Session {
+	t.Helper()
+	ctx := t.Context()
+	sess, err := s.Sessions.Create(ctx, "Fix the parser")
+	require.NoError(t, err)
+
+	_, err = s.Messages.Create(ctx, sess.ID, message.CreateMessageParams{
+		Role:      message.User,
+		Parts:     []message.ContentPart{message.TextContent{Text: "Fix the parser"}},
+		CreatedAt: 1700000000,
+	})
+	require.NoError(t, err)
+	_, err = s.History.Create(ctx, sess.ID, "/project/parser.go", "package parser\n")
+	require.NoError(t, err)
+	_, err = s.Messages.Create(ctx, sess.ID, message.CreateMessageParams{
+		Role: message.Assistant,
+		Parts: []message.ContentPart{
+			message.ReasoningContent{Thinking: "Let's look at the parser"},
+			message.TextContent{Text: "Here's the fix:"},
+			message.ToolCall{ID: "call", Name: "edit", Input: `{"file_path":"/project/parser.go"}`, Finished: true},
+			message.Finish{Reason: message.FinishReasonToolUse},
+		},
+		Model: "model",
+	})
+	require.NoError(t, err)
+	_, err = s.Messages.Create(ctx, sess.ID, message.CreateMessageParams{
+		Role:  message.Tool,
+		Parts: []message.ContentPart{message.ToolResult{ToolCallID: "call", Name: "edit", Content: "```go\nedited\n```"}},
+	})
+	require.NoError(t, err)
+	_, err = s.History.CreateVersion(ctx, sess.ID, "/project/parser.go", "package parser\n\nfunc Parse() {}\n")
+	require.NoError(t, err)
+
+	task, err := s.Sessions.CreateTaskSession(ctx, "call", sess.ID, "Look at the parser")
+	require.NoError(t, err)
+	_, err = s.Messages.Create(ctx, task.ID, message.CreateMessageParams{
+		Role:  message.User,
+		Parts: []message.ContentPart{message.TextContent{Text: "Look at the parser"}},
+	})
+	require.NoError(t, err)
+
+	sess.PromptTokens, sess.CompletionTokens, sess.Cost = 100, 20, 0.5
+	sess.AgentID, sess.PlanMode = "reviewer", true
+	sess, err = s.Sessions.Save(ctx, sess)
+	require.NoError(t, err)
+	return sess
+}
+
+func TestBundle(t *testing.T) {
+	t.Parallel()
+
+	t.Run("round trip", func(t *testing.T) {
+		t.Parallel()
+
+		from := newServices(t)
+		sess := createSession(t, from)
+		bundle, err := Load(t.Context(), from, sess.ID, "/project")
+		require.NoError(t, err)
+		require.Equal(t, "parser.go", bundle.Files[0].Path)
+
+		var buf bytes.Buffer
+		require.NoError(t, bundle.Write(&buf, JSON))
+		read, err := Read(&buf)
+		require.NoError(t, err)
+
+		to, conn := newStore(t)
+		imported, err := Import(t.Context(), conn, read, "/other")
+		require.NoError(t, err)
+		require.NotEqual(t, sess.ID, imported.ID)
+		require.Equal(t, sess.Title, imported.Title)
+		require.Equal(t, sess.MessageCount, imported.MessageCount)
+		require.Equal(t, sess.Cost, imported.Cost)
+		require.Equal(t, "reviewer", imported.AgentID)
+		require.True(t, imported.PlanMode)
+		require.Empty(t, imported.ParentSessionID)
+
+		reimported, err := Load(t.Context(), to, imported.ID, "")
+		require.NoError(t, err)
+		require.Len(t, reimported.Messages, len(bundle.Messages))
+		for i, msg := range reimported.Messages {
+			require.Equal(t, bundle.Messages[i].Role, msg.Role)
+			require.JSONEq(t, string(bundle.Messages[i].Parts), string(msg.Parts))
+		}
+		require.Equal(t, int64(1700000000), reimported.Messages[0].CreatedAt)
+		require.Len(t, reimported.Files, 2)
+		require.Equal(t, "/other/parser.go", reimported.Files[0].Path)
+		require.Equal(t, bundle.Files[1].Content, reimported.Files[1].Content)
+		require.Equal(t, int64(1), reimported.Files[1].Version)
+		// The first version of the file was recorded after the first message,
+		// and the second one after the last message.
+		require.Less(t, reimported.Messages[0].Seq, reimported.Files[0].Seq)
+		require.Less(t, reimported.Files[0].Seq, reimported.Messages[1].Seq)
+		require.Less(t, reimported.Messages[2].Seq, reimported.Files[1].Seq)
+
+		require.Len(t, reimported.Children, 1)
+		task := reimported.Children[0]
+		require.Equal(t, imported.ID, task.Session.ParentSessionID)
+		require.Equal(t, "Look at the parser", task.Session.Title)
+		require.Len(t, task.Messages, 1)
+	})
+
+	t.Run("import is all or nothing", func(t *testing.T) {
+		t.Parallel()
+
+		from := newServices(t)
+		bundle, err := Load(t.Context(), from, createSession(t, from).ID, "/project")
+		require.NoError(t, err)
+		bundle.Children[0].Messages[0].Parts = json.RawMessage(`{}`)
+
+		to, conn := newStore(t)
+		_, err = Import(t.Context(), conn, bundle, "/other")
+		require.ErrorContains(t, err, "failed to decode message")
+		sessions, err := to.Sessions.ListAll(t.Context())
+		require.NoError(t, err)
+		require.Empty(t, sessions)
+	})
+
+	t.Run("unsupported version", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := Read(strings.NewReader(`{"version": 99}`))
+		require.ErrorContains(t, err, "unsupported bundle version 99")
+	})
+
+	t.Run("markdown", func(t *testing.T) {
+		t.Parallel()
+
+		s := newServices(t)
+		bundle, err := Load(t.Context(), s, createSession(t, s).ID, "/project")
+		require.NoError(t, err)
+
+		var buf bytes.Buffer
+		require.NoError(t, bundle.Write(&buf, Markdown))
+		out := buf.String()
+		require.Contains(t, out, "# Fix the parser\n")
+		require.Contains(t, out, "## Assistant (model)\n")
+		require.Contains(t, out, "<summary>Reasoning</summary>\n\nLet's look at the parser")
+		require.Contains(t, out, "**Tool call: `edit`**")
+		// The result contains a fence, so it's wrapped in a longer one.
+		require.Contains(t, out, "````\n```go\nedited\n```\n````")
+		require.Contains(t, out, "### `parser.go` (+2 -0)")
+		require.Contains(t, out, "+func Parse() {}")
+	})
+
+	t.Run("html", func(t *testing.T) {
+		t.Parallel()
+
+		s := newServices(t)
+		bundle, err := Load(t.Context(), s, createSession(t, s).ID, "/project")
+		require.NoError(t, err)
+
+		var buf bytes.Buffer
+		require.NoError(t, bundle.Write(&buf, HTML))
+		out := buf.String()
+		require.Contains(t, out, "<title>Fix the parser</title>")
+		require.Contains(t, out, "Let&#39;s look at the parser")
+		require.Contains(t, out, `{&#34;file_path&#34;:&#34;/project/parser.go&#34;}`)
+	})
+}
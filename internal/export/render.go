@@ -0,0 +1,214 @@
+package export
+
+import (
+	_ "embed"
+	"fmt"
+	"html/template"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/diff"
+	"github.com/charmbracelet/crush/internal/message"
+)
+
+// transcript is what's rendered of a bundle.
+type transcript struct {
+	Title     string
+	SessionID string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Usage     Session
+	Turns     []turn
+	Diffs     []fileDiff
+}
+
+type turn struct {
+	Role        message.MessageRole
+	Model       string
+	CreatedAt   time.Time
+	Reasoning   string
+	Text        string
+	Attachments []string
+	ToolCalls   []toolCall
+	Finish      string
+}
+
+type toolCall struct {
+	Name    string
+	Input   string
+	Result  string
+	IsError bool
+}
+
+type fileDiff struct {
+	Path      string
+	Diff      string
+	Additions int
+	Removals  int
+}
+
+func newTranscript(b Bundle) transcript {
+	t := transcript{
+		Title:     b.Session.Title,
+		SessionID: b.Session.ID,
+		CreatedAt: time.Unix(b.Session.CreatedAt, 0),
+		UpdatedAt: time.Unix(b.Session.UpdatedAt, 0),
+		Usage:     b.Session,
+	}
+
+	msgs := make([]message.Message, 0, len(b.Messages))
+	results := make(map[string]message.ToolResult)
+	for _, m := range b.Messages {
+		parts, err := message.UnmarshalParts(m.Parts)
+		if err != nil {
+			slog.Warn("Skipping message that can't be decoded", "id", m.ID, "error", err)
+			continue
+		}
+		msg := message.Message{ID: m.ID, Role: m.Role, Parts: parts, Model: m.Model, CreatedAt: m.CreatedAt}
+		for _, result := range msg.ToolResults() {
+			results[result.ToolCallID] = result
+		}
+		msgs = append(msgs, msg)
+	}
+
+	for _, msg := range msgs {
+		// Tool results are shown with their tool calls.
+		if msg.Role == message.Tool {
+			continue
+		}
+		tt := turn{
+			Role:      msg.Role,
+			Model:     msg.Model,
+			CreatedAt: time.Unix(msg.CreatedAt, 0),
+			Reasoning: strings.TrimSpace(msg.ReasoningContent().Thinking),
+			Text:      strings.TrimSpace(msg.Content().Text),
+		}
+		for _, bin := range msg.BinaryContent() {
+			tt.Attachments = append(tt.Attachments, bin.Path)
+		}
+		for _, image := range msg.ImageURLContent() {
+			tt.Attachments = append(tt.Attachments, image.URL)
+		}
+		for _, call := range msg.ToolCalls() {
+			result := results[call.ID]
+			tt.ToolCalls = append(tt.ToolCalls, toolCall{
+				Name:    call.Name,
+				Input:   call.Input,
+				Result:  result.Content,
+				IsError: result.IsError,
+			})
+		}
+		if msg.Role == message.Assistant {
+			switch reason := msg.FinishReason(); reason {
+			case message.FinishReasonCanceled, message.FinishReasonError, message.FinishReasonPermissionDenied:
+				tt.Finish = string(reason)
+			}
+		}
+		t.Turns = append(t.Turns, tt)
+	}
+
+	// Every file is shown with the changes from its first to its last
+	// version in the session.
+	first := make(map[string]File)
+	last := make(map[string]File)
+	var paths []string
+	for _, f := range b.Files {
+		if _, ok := first[f.Path]; !ok {
+			first[f.Path] = f
+			paths = append(paths, f.Path)
+		}
+		last[f.Path] = f
+	}
+	for _, path := range paths {
+		if first[path].Version == last[path].Version {
+			continue
+		}
+		patch, additions, removals := diff.GenerateDiff(first[path].Content, last[path].Content, path)
+		t.Diffs = append(t.Diffs, fileDiff{Path: path, Diff: patch, Additions: additions, Removals: removals})
+	}
+	return t
+}
+
+func (t turn) Heading() string {
+	switch t.Role {
+	case message.User:
+		return "User"
+	case message.Assistant:
+		if t.Model != "" {
+			return "Assistant (" + t.Model + ")"
+		}
+		return "Assistant"
+	default:
+		return string(t.Role)
+	}
+}
+
+func writeMarkdown(w io.Writer, t transcript) error {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# %s\n\n", t.Title)
+	fmt.Fprintf(&sb, "- Session: `%s`\n", t.SessionID)
+	fmt.Fprintf(&sb, "- Created: %s\n", t.CreatedAt.Format(time.DateTime))
+	fmt.Fprintf(&sb, "- Updated: %s\n", t.UpdatedAt.Format(time.DateTime))
+	fmt.Fprintf(&sb, "- Usage: %d prompt tokens, %d completion tokens, $%.4f\n", t.Usage.PromptTokens, t.Usage.CompletionTokens, t.Usage.Cost)
+
+	for _, tt := range t.Turns {
+		fmt.Fprintf(&sb, "\n## %s\n\n", tt.Heading())
+		if tt.Reasoning != "" {
+			sb.WriteString("<details>\n<summary>Reasoning</summary>\n\n")
+			sb.WriteString(tt.Reasoning)
+			sb.WriteString("\n\n</details>\n\n")
+		}
+		if tt.Text != "" {
+			sb.WriteString(tt.Text)
+			sb.WriteString("\n\n")
+		}
+		for _, attachment := range tt.Attachments {
+			fmt.Fprintf(&sb, "- Attachment: `%s`\n", attachment)
+		}
+		for _, call := range tt.ToolCalls {
+			fmt.Fprintf(&sb, "**Tool call: `%s`**\n\n", call.Name)
+			writeCodeBlock(&sb, "json", call.Input)
+			if call.IsError {
+				sb.WriteString("Error:\n\n")
+			} else {
+				sb.WriteString("Result:\n\n")
+			}
+			writeCodeBlock(&sb, "", call.Result)
+		}
+		if tt.Finish != "" {
+			fmt.Fprintf(&sb, "_Finished: %s_\n\n", tt.Finish)
+		}
+	}
+
+	if len(t.Diffs) > 0 {
+		sb.WriteString("\n## Files Changed\n\n")
+		for _, d := range t.Diffs {
+			fmt.Fprintf(&sb, "### `%s` (+%d -%d)\n\n", d.Path, d.Additions, d.Removals)
+			writeCodeBlock(&sb, "diff", d.Diff)
+		}
+	}
+
+	_, err := io.WriteString(w, strings.TrimRight(sb.String(), "\n")+"\n")
+	return err
+}
+
+// writeCodeBlock writes a fenced code block, with a fence longer than any run
+// of backticks in the content.
+func writeCodeBlock(sb *strings.Builder, lang, content string) {
+	fence := "```"
+	for strings.Contains(content, fence) {
+		fence += "`"
+	}
+	fmt.Fprintf(sb, "%s%s\n%s\n%s\n\n", fence, lang, strings.TrimRight(content, "\n"), fence)
+}
+
+//go:embed transcript.html
+var htmlTemplate string
+
+var transcriptTemplate = template.Must(template.New("transcript").Parse(htmlTemplate))
+
+func writeHTML(w io.Writer, t transcript) error {
+	return transcriptTemplate.Execute(w, t)
+}
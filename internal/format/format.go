@@ -0,0 +1,31 @@
+package format
+
+import (
+	"fmt"
+	"slices"
+)
+
+// OutputFormat is the format of the output of non-interactive runs.
+type OutputFormat string
+
+const (
+	// Text prints the response of the model as it's generated.
+	Text OutputFormat = "text"
+	// JSON prints a single JSON object with the result once the run is done.
+	JSON OutputFormat = "json"
+	// StreamJSON prints newline-delimited JSON events as the run progresses,
+	// ending with the result.
+	StreamJSON OutputFormat = "stream-json"
+)
+
+// OutputFormats are all the supported output formats.
+var OutputFormats = []OutputFormat{Text, JSON, StreamJSON}
+
+// Parse returns the output format with the given name.
+func Parse(s string) (OutputFormat, error) {
+	f := OutputFormat(s)
+	if !slices.Contains(OutputFormats, f) {
+		return "", fmt.Errorf("invalid output format %q, must be one of text, json or stream-json", s)
+	}
+	return f, nil
+}
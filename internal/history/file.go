@@ -23,12 +23,23 @@ type File struct {
 	Version   int64
 	CreatedAt int64
 	UpdatedAt int64
+	// Seq orders the messages and file versions of a session, as timestamps
+	// only have a precision of a second.
+	Seq int64
+	// Missing is set when the file didn't exist at this version, like before
+	// the session created it.
+	Missing bool
 }
 
 type Service interface {
 	pubsub.Suscriber[File]
 	Create(ctx context.Context, sessionID, path, content string) (File, error)
 	CreateVersion(ctx context.Context, sessionID, path, content string) (File, error)
+	// CreateMissing records that the file doesn't exist, as its next version.
+	CreateMissing(ctx context.Context, sessionID, path string) (File, error)
+	// Copy records a version of a file from another session in the session,
+	// with the same version number and creation time.
+	Copy(ctx context.Context, sessionID string, file File) (File, error)
 	Get(ctx context.Context, id string) (File, error)
 	GetByPathAndSession(ctx context.Context, path, sessionID string) (File, error)
 	ListBySession(ctx context.Context, sessionID string) ([]File, error)
@@ -52,29 +63,46 @@ func NewService(q *db.Queries, db *sql.DB) Service {
 }
 
 func (s *service) Create(ctx context.Context, sessionID, path, content string) (File, error) {
-	return s.createWithVersion(ctx, sessionID, path, content, InitialVersion)
+	return s.createWithVersion(ctx, sessionID, path, content, InitialVersion, 0, false)
+}
+
+func (s *service) Copy(ctx context.Context, sessionID string, file File) (File, error) {
+	return s.createWithVersion(ctx, sessionID, file.Path, file.Content, file.Version, file.CreatedAt, file.Missing)
 }
 
 func (s *service) CreateVersion(ctx context.Context, sessionID, path, content string) (File, error) {
-	// Get the latest version for this path
-	files, err := s.q.ListFilesByPath(ctx, path)
+	version, err := s.nextVersion(ctx, path)
 	if err != nil {
 		return File{}, err
 	}
+	return s.createWithVersion(ctx, sessionID, path, content, version, 0, false)
+}
 
-	if len(files) == 0 {
-		// No previous versions, create initial
-		return s.Create(ctx, sessionID, path, content)
+func (s *service) CreateMissing(ctx context.Context, sessionID, path string) (File, error) {
+	version, err := s.nextVersion(ctx, path)
+	if err != nil {
+		return File{}, err
 	}
+	return s.createWithVersion(ctx, sessionID, path, "", version, 0, true)
+}
 
-	// Get the latest version
-	latestFile := files[0] // Files are ordered by version DESC, created_at DESC
-	nextVersion := latestFile.Version + 1
-
-	return s.createWithVersion(ctx, sessionID, path, content, nextVersion)
+// nextVersion returns the version following the latest one of the file, or
+// the initial version when there's none.
+func (s *service) nextVersion(ctx context.Context, path string) (int64, error) {
+	files, err := s.q.ListFilesByPath(ctx, path)
+	if err != nil {
+		return 0, err
+	}
+	if len(files) == 0 {
+		return InitialVersion, nil
+	}
+	// Files are ordered by version DESC, created_at DESC
+	return files[0].Version + 1, nil
 }
 
-func (s *service) createWithVersion(ctx context.Context, sessionID, path, content string, version int64) (File, error) {
+// createWithVersion creates the version of the file, at the given time or now
+// when it's zero.
+func (s *service) createWithVersion(ctx context.Context, sessionID, path, content string, version, createdAt int64, missing bool) (File, error) {
 	// Maximum number of retries for transaction conflicts
 	const maxRetries = 3
 	var file File
@@ -98,6 +126,8 @@ func (s *service) createWithVersion(ctx context.Context, sessionID, path, conten
 			Path:      path,
 			Content:   content,
 			Version:   version,
+			CreatedAt: sql.NullInt64{Int64: createdAt, Valid: createdAt != 0},
+			Missing:   missing,
 		})
 		if txErr != nil {
 			// Rollback the transaction
@@ -206,5 +236,7 @@ func (s *service) fromDBItem(item db.File) File {
 		Version:   item.Version,
 		CreatedAt: item.CreatedAt,
 		UpdatedAt: item.UpdatedAt,
+		Seq:       item.Seq,
+		Missing:   item.Missing,
 	}
 }
@@ -0,0 +1,182 @@
+package history
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/charmbracelet/crush/internal/diff"
+)
+
+// ErrConflict is returned when reverting files that were modified outside of
+// crush since the agent last wrote them.
+var ErrConflict = errors.New("files were modified since the agent last wrote them")
+
+// Revert is a file to restore to an earlier version.
+type Revert struct {
+	Path string
+	// Current is the content of the file on disk, empty if it doesn't exist.
+	Current string
+	// Target is the content the file is restored to.
+	Target string
+	// Delete is set when the file didn't exist at the version it's restored
+	// to, like before the session created it, in which case it's removed
+	// instead.
+	Delete bool
+	// Conflict is set when the file on disk isn't the last version recorded
+	// in the session, meaning it was modified outside of crush.
+	Conflict bool
+}
+
+// Changed reports whether reverting changes the file on disk.
+func (r Revert) Changed() bool {
+	_, err := os.Stat(r.Path)
+	if r.Delete {
+		return err == nil
+	}
+	// An empty file is restored even when it was removed since.
+	return err != nil || r.Current != r.Target
+}
+
+// Diff returns the changes made by the revert as a unified diff.
+func (r Revert) Diff(workingDir string) (string, int, int) {
+	name := r.Path
+	if rel, err := filepath.Rel(workingDir, r.Path); err == nil {
+		name = rel
+	}
+	return diff.GenerateDiff(r.Current, r.Target, name)
+}
+
+// PlanFileRevert returns how to restore a file to the given version, one of
+// the versions recorded in the session.
+func PlanFileRevert(ctx context.Context, files Service, sessionID, path string, version int64) (Revert, error) {
+	versions, err := sessionVersions(ctx, files, sessionID)
+	if err != nil {
+		return Revert{}, err
+	}
+	history := versions[path]
+	idx := slices.IndexFunc(history, func(f File) bool { return f.Version == version })
+	if idx == -1 {
+		return Revert{}, fmt.Errorf("no version %d of %s in the session", version, path)
+	}
+	return newRevert(history, history[idx])
+}
+
+// PlanSessionRevert returns how to undo the changes made to files by the
+// session since the message with the given sequence number. A zero sequence
+// number undoes all the changes of the session.
+func PlanSessionRevert(ctx context.Context, files Service, sessionID string, since int64) ([]Revert, error) {
+	versions, err := sessionVersions(ctx, files, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	paths := make([]string, 0, len(versions))
+	for path := range versions {
+		paths = append(paths, path)
+	}
+	slices.Sort(paths)
+
+	var reverts []Revert
+	for _, path := range paths {
+		history := versions[path]
+		// The file is restored to its last version from before the message,
+		// or to the one it had before the session changed it.
+		idx := 0
+		for i, f := range history {
+			if f.Seq < since {
+				idx = i
+			}
+		}
+		if idx == len(history)-1 && since > 0 && history[idx].Seq < since {
+			// Not changed since.
+			continue
+		}
+		revert, err := newRevert(history, history[idx])
+		if err != nil {
+			return nil, err
+		}
+		if revert.Changed() || revert.Conflict {
+			reverts = append(reverts, revert)
+		}
+	}
+	return reverts, nil
+}
+
+// ApplyReverts restores the files, recording the restored content as a new
+// version in the session. Unless forced, nothing is restored when any of the
+// files has a conflict.
+func ApplyReverts(ctx context.Context, files Service, sessionID string, reverts []Revert, force bool) error {
+	if !force {
+		var conflicts []string
+		for _, r := range reverts {
+			if r.Conflict {
+				conflicts = append(conflicts, r.Path)
+			}
+		}
+		if len(conflicts) > 0 {
+			return fmt.Errorf("%w: %v", ErrConflict, conflicts)
+		}
+	}
+
+	for _, r := range reverts {
+		if !r.Changed() {
+			continue
+		}
+		if r.Delete {
+			if err := os.Remove(r.Path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return fmt.Errorf("failed to remove %s: %w", r.Path, err)
+			}
+		} else {
+			if err := os.MkdirAll(filepath.Dir(r.Path), 0o755); err != nil {
+				return fmt.Errorf("failed to create directory for %s: %w", r.Path, err)
+			}
+			if err := os.WriteFile(r.Path, []byte(r.Target), 0o644); err != nil {
+				return fmt.Errorf("failed to write %s: %w", r.Path, err)
+			}
+		}
+		var err error
+		if r.Delete {
+			_, err = files.CreateMissing(ctx, sessionID, r.Path)
+		} else {
+			_, err = files.CreateVersion(ctx, sessionID, r.Path, r.Target)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to record the history of %s: %w", r.Path, err)
+		}
+	}
+	return nil
+}
+
+// sessionVersions returns the versions of every file recorded in the session,
+// oldest first.
+func sessionVersions(ctx context.Context, files Service, sessionID string) (map[string][]File, error) {
+	all, err := files.ListBySession(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the file history: %w", err)
+	}
+	versions := make(map[string][]File)
+	for _, f := range all {
+		versions[f.Path] = append(versions[f.Path], f)
+	}
+	return versions, nil
+}
+
+func newRevert(history []File, target File) (Revert, error) {
+	revert := Revert{
+		Path:   target.Path,
+		Target: target.Content,
+		Delete: target.Missing,
+	}
+	content, err := os.ReadFile(target.Path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return Revert{}, fmt.Errorf("failed to read %s: %w", target.Path, err)
+	}
+	last := history[len(history)-1]
+	revert.Current = string(content)
+	revert.Conflict = revert.Current != last.Content || (err == nil) == last.Missing
+	return revert, nil
+}
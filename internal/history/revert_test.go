@@ -0,0 +1,174 @@
+package history
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeFiles is a history of files kept in memory.
+type fakeFiles struct {
+	Service
+	files []File
+}
+
+func (f *fakeFiles) add(path, content string, seq int64) {
+	f.files = append(f.files, File{
+		SessionID: "session",
+		Path:      path,
+		Content:   content,
+		Version:   int64(len(f.files)),
+		Seq:       seq,
+	})
+}
+
+func (f *fakeFiles) addMissing(path string, seq int64) {
+	f.add(path, "", seq)
+	f.files[len(f.files)-1].Missing = true
+}
+
+func (f *fakeFiles) ListBySession(_ context.Context, sessionID string) ([]File, error) {
+	return f.files, nil
+}
+
+func (f *fakeFiles) CreateVersion(_ context.Context, sessionID, path, content string) (File, error) {
+	f.add(path, content, 100)
+	return f.files[len(f.files)-1], nil
+}
+
+func (f *fakeFiles) CreateMissing(_ context.Context, sessionID, path string) (File, error) {
+	f.addMissing(path, 100)
+	return f.files[len(f.files)-1], nil
+}
+
+func TestRevert(t *testing.T) {
+	t.Parallel()
+
+	// setup records the history of a session that edited main.go twice and
+	// created new.go, leaving them on disk.
+	setup := func(t *testing.T) (string, *fakeFiles) {
+		dir := t.TempDir()
+		main, created := filepath.Join(dir, "main.go"), filepath.Join(dir, "new.go")
+		files := &fakeFiles{}
+		files.add(main, "original", 10)
+		files.add(main, "first", 11)
+		files.addMissing(created, 20)
+		files.add(created, "created", 21)
+		files.add(main, "second", 30)
+		require.NoError(t, os.WriteFile(main, []byte("second"), 0o644))
+		require.NoError(t, os.WriteFile(created, []byte("created"), 0o644))
+		return dir, files
+	}
+
+	t.Run("session", func(t *testing.T) {
+		t.Parallel()
+
+		dir, files := setup(t)
+		reverts, err := PlanSessionRevert(t.Context(), files, "session", 0)
+		require.NoError(t, err)
+		require.Len(t, reverts, 2)
+		require.Equal(t, "original", reverts[0].Target)
+		require.False(t, reverts[0].Delete)
+		require.True(t, reverts[1].Delete)
+
+		require.NoError(t, ApplyReverts(t.Context(), files, "session", reverts, false))
+		content, err := os.ReadFile(filepath.Join(dir, "main.go"))
+		require.NoError(t, err)
+		require.Equal(t, "original", string(content))
+		require.NoFileExists(t, filepath.Join(dir, "new.go"))
+
+		// The reverted content is recorded, so there's nothing left to revert.
+		reverts, err = PlanSessionRevert(t.Context(), files, "session", 0)
+		require.NoError(t, err)
+		require.Empty(t, reverts)
+	})
+
+	t.Run("since", func(t *testing.T) {
+		t.Parallel()
+
+		dir, files := setup(t)
+		reverts, err := PlanSessionRevert(t.Context(), files, "session", 15)
+		require.NoError(t, err)
+		require.Len(t, reverts, 2)
+		require.Equal(t, "first", reverts[0].Target)
+		require.True(t, reverts[1].Delete)
+
+		// Files not changed since are left alone.
+		reverts, err = PlanSessionRevert(t.Context(), files, "session", 25)
+		require.NoError(t, err)
+		require.Len(t, reverts, 1)
+		require.Equal(t, filepath.Join(dir, "main.go"), reverts[0].Path)
+		require.Equal(t, "first", reverts[0].Target)
+		diff, additions, removals := reverts[0].Diff(dir)
+		require.Contains(t, diff, "main.go")
+		require.Equal(t, 1, additions)
+		require.Equal(t, 1, removals)
+	})
+
+	t.Run("file", func(t *testing.T) {
+		t.Parallel()
+
+		dir, files := setup(t)
+		main := filepath.Join(dir, "main.go")
+		revert, err := PlanFileRevert(t.Context(), files, "session", main, 1)
+		require.NoError(t, err)
+		require.Equal(t, "first", revert.Target)
+		require.NoError(t, ApplyReverts(t.Context(), files, "session", []Revert{revert}, false))
+		content, err := os.ReadFile(main)
+		require.NoError(t, err)
+		require.Equal(t, "first", string(content))
+
+		_, err = PlanFileRevert(t.Context(), files, "session", main, 42)
+		require.ErrorContains(t, err, "no version 42")
+	})
+
+	t.Run("conflict", func(t *testing.T) {
+		t.Parallel()
+
+		dir, files := setup(t)
+		main := filepath.Join(dir, "main.go")
+		require.NoError(t, os.WriteFile(main, []byte("edited by hand"), 0o644))
+
+		reverts, err := PlanSessionRevert(t.Context(), files, "session", 0)
+		require.NoError(t, err)
+		require.True(t, reverts[0].Conflict)
+		require.False(t, reverts[1].Conflict)
+		require.ErrorIs(t, ApplyReverts(t.Context(), files, "session", reverts, false), ErrConflict)
+		// Nothing is reverted when there are conflicts.
+		require.FileExists(t, filepath.Join(dir, "new.go"))
+
+		require.NoError(t, ApplyReverts(t.Context(), files, "session", reverts, true))
+		content, err := os.ReadFile(main)
+		require.NoError(t, err)
+		require.Equal(t, "original", string(content))
+	})
+	t.Run("empty", func(t *testing.T) {
+		t.Parallel()
+
+		// A file that existed empty before the session is emptied, not
+		// removed, and a removed one is recreated.
+		dir := t.TempDir()
+		empty, removed := filepath.Join(dir, "empty.go"), filepath.Join(dir, "removed.go")
+		files := &fakeFiles{}
+		files.add(empty, "", 10)
+		files.add(empty, "filled", 11)
+		files.add(removed, "", 20)
+		files.addMissing(removed, 21)
+		require.NoError(t, os.WriteFile(empty, []byte("filled"), 0o644))
+
+		reverts, err := PlanSessionRevert(t.Context(), files, "session", 0)
+		require.NoError(t, err)
+		require.Len(t, reverts, 2)
+		require.False(t, reverts[0].Delete)
+		require.False(t, reverts[1].Delete)
+		require.False(t, reverts[1].Conflict)
+		require.NoError(t, ApplyReverts(t.Context(), files, "session", reverts, false))
+		content, err := os.ReadFile(empty)
+		require.NoError(t, err)
+		require.Empty(t, content)
+		require.FileExists(t, removed)
+	})
+}
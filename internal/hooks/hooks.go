@@ -0,0 +1,142 @@
+// Package hooks runs the shell commands configured to be run around the tool
+// calls and turns of the agent.
+//
+// Hooks get the event as JSON on stdin. A hook exiting with code 2 blocks
+// what the event allows to block, with what it printed to stderr as the
+// reason. Other failures are logged and otherwise ignored.
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/shell"
+)
+
+// Event is what triggers a hook.
+type Event string
+
+const (
+	PreToolUse        Event = "pre_tool_use"
+	PostToolUse       Event = "post_tool_use"
+	TurnEnd           Event = "turn_end"
+	PermissionRequest Event = "permission_request"
+)
+
+// blockExitCode is the exit code of hooks blocking what triggered them.
+const blockExitCode = 2
+
+const defaultTimeout = 60 * time.Second
+
+// Input is the event given to hooks on stdin.
+type Input struct {
+	Event      Event  `json:"event"`
+	SessionID  string `json:"session_id"`
+	WorkingDir string `json:"cwd"`
+
+	// Tool calls and permission requests.
+	ToolName  string          `json:"tool_name,omitempty"`
+	ToolInput json.RawMessage `json:"tool_input,omitempty"`
+
+	// Post tool use.
+	ToolResult string `json:"tool_result,omitempty"`
+	ToolError  bool   `json:"tool_error,omitempty"`
+
+	// Turn end.
+	FinishReason string `json:"finish_reason,omitempty"`
+	Response     string `json:"response,omitempty"`
+
+	// Permission requests.
+	Action      string `json:"action,omitempty"`
+	Path        string `json:"path,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// Result is the outcome of running the hooks of an event.
+type Result struct {
+	// Blocked is set when a hook exited with code 2.
+	Blocked bool
+	// Message is what the hooks printed: the reason when blocked, and the
+	// feedback on the event otherwise.
+	Message string
+}
+
+// Run runs the hooks matching the input, in order, stopping at the first one
+// that blocks.
+func Run(ctx context.Context, hooks []config.Hook, input Input) Result {
+	if len(hooks) == 0 {
+		return Result{}
+	}
+	stdin, err := json.Marshal(input)
+	if err != nil {
+		slog.Error("Failed to encode hook input", "event", input.Event, "error", err)
+		return Result{}
+	}
+
+	var feedback []string
+	for _, hook := range hooks {
+		if !matches(hook, input.ToolName) {
+			continue
+		}
+		stdout, stderr, err := run(ctx, hook, input, stdin)
+		switch code := shell.ExitCode(err); {
+		case code == blockExitCode:
+			reason := strings.TrimSpace(stderr)
+			if reason == "" {
+				reason = fmt.Sprintf("blocked by the %s hook %q", input.Event, hook.Command)
+			}
+			return Result{Blocked: true, Message: reason}
+		case err != nil:
+			slog.Warn("Hook failed", "event", input.Event, "command", hook.Command, "exit_code", code, "error", err, "stderr", stderr)
+		default:
+			if out := strings.TrimSpace(stdout); out != "" {
+				feedback = append(feedback, out)
+			}
+		}
+	}
+	return Result{Message: strings.Join(feedback, "\n")}
+}
+
+// matches reports whether the hook runs for the tool.
+func matches(hook config.Hook, toolName string) bool {
+	if hook.Matcher == "" {
+		return true
+	}
+	re, err := regexp.Compile(hook.Matcher)
+	if err != nil {
+		slog.Error("Invalid hook matcher", "matcher", hook.Matcher, "error", err)
+		return false
+	}
+	return re.MatchString(toolName)
+}
+
+func run(ctx context.Context, hook config.Hook, input Input, stdin []byte) (string, string, error) {
+	timeout := defaultTimeout
+	if hook.Timeout > 0 {
+		timeout = time.Duration(hook.Timeout) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	sh := shell.NewShell(&shell.Options{
+		WorkingDir: input.WorkingDir,
+		Env: append(os.Environ(),
+			"CRUSH_HOOK_EVENT="+string(input.Event),
+			"CRUSH_SESSION_ID="+input.SessionID,
+			"CRUSH_TOOL_NAME="+input.ToolName,
+		),
+	})
+	stdout, stderr, err := sh.ExecWithInput(ctx, hook.Command, bytes.NewReader(stdin))
+	if ctx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("timed out after %s", timeout)
+	}
+	return stdout, stderr, err
+}
@@ -0,0 +1,72 @@
+package hooks
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+
+	input := Input{
+		Event:      PreToolUse,
+		SessionID:  "session",
+		WorkingDir: t.TempDir(),
+		ToolName:   "bash",
+		ToolInput:  []byte(`{"command":"rm -rf /"}`),
+	}
+
+	t.Run("no hooks", func(t *testing.T) {
+		t.Parallel()
+		require.Equal(t, Result{}, Run(t.Context(), nil, input))
+	})
+
+	t.Run("feedback", func(t *testing.T) {
+		t.Parallel()
+		result := Run(t.Context(), []config.Hook{
+			{Command: "echo first"},
+			{Command: "echo $CRUSH_HOOK_EVENT $CRUSH_TOOL_NAME"},
+		}, input)
+		require.False(t, result.Blocked)
+		require.Equal(t, "first\npre_tool_use bash", result.Message)
+	})
+
+	t.Run("input on stdin", func(t *testing.T) {
+		t.Parallel()
+		result := Run(t.Context(), []config.Hook{{Command: "cat"}}, input)
+		require.Contains(t, result.Message, `"tool_input":{"command":"rm -rf /"}`)
+		require.Contains(t, result.Message, `"event":"pre_tool_use"`)
+	})
+
+	t.Run("block", func(t *testing.T) {
+		t.Parallel()
+		result := Run(t.Context(), []config.Hook{
+			{Command: "echo 'no deleting' >&2; exit 2"},
+			{Command: "echo never run"},
+		}, input)
+		require.True(t, result.Blocked)
+		require.Equal(t, "no deleting", result.Message)
+	})
+
+	t.Run("failures are ignored", func(t *testing.T) {
+		t.Parallel()
+		result := Run(t.Context(), []config.Hook{
+			{Command: "exit 1"},
+			{Command: "echo ok"},
+		}, input)
+		require.False(t, result.Blocked)
+		require.Equal(t, "ok", result.Message)
+	})
+
+	t.Run("matcher", func(t *testing.T) {
+		t.Parallel()
+		result := Run(t.Context(), []config.Hook{
+			{Matcher: "^(edit|write)$", Command: "exit 2"},
+			{Matcher: "^ba", Command: "echo matched"},
+		}, input)
+		require.False(t, result.Blocked)
+		require.Equal(t, "matched", result.Message)
+	})
+}
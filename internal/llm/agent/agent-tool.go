@@ -4,41 +4,105 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"maps"
+	"slices"
+	"strings"
 
+	"github.com/charmbracelet/crush/internal/config"
 	"github.com/charmbracelet/crush/internal/llm/tools"
 	"github.com/charmbracelet/crush/internal/message"
+	"github.com/charmbracelet/crush/internal/permission"
 	"github.com/charmbracelet/crush/internal/session"
 )
 
 type agentTool struct {
-	agent    Service
-	sessions session.Service
-	messages message.Service
+	agents      map[string]Service
+	permissions permission.Service
+	sessions    session.Service
+	messages    message.Service
 }
 
 const (
 	AgentToolName = "agent"
+
+	// defaultSubAgent is the agent tasks are delegated to when the model
+	// doesn't pick one.
+	defaultSubAgent = "task"
 )
 
 type AgentParams struct {
 	Prompt string `json:"prompt"`
+	Agent  string `json:"agent,omitempty"`
 }
 
 func (b *agentTool) Name() string {
 	return AgentToolName
 }
 
+func (b *agentTool) ReadOnly() bool {
+	for _, a := range b.agents {
+		if !isReadOnlyAgent(a) {
+			return false
+		}
+	}
+	return true
+}
+
+// ReadOnlyCall reports whether the agent the task is delegated to can't
+// change anything, like the task agent, so that it can run concurrently with
+// other read-only calls.
+func (b *agentTool) ReadOnlyCall(input string) bool {
+	var params AgentParams
+	if err := json.Unmarshal([]byte(input), &params); err != nil {
+		return false
+	}
+	if params.Agent == "" {
+		params.Agent = defaultSubAgent
+	}
+	a, ok := b.agents[params.Agent]
+	return ok && isReadOnlyAgent(a)
+}
+
+func isReadOnlyAgent(a Service) bool {
+	ro, ok := a.(interface{ readOnly() bool })
+	return ok && ro.readOnly()
+}
+
 func (b *agentTool) Info() tools.ToolInfo {
+	description := "Launch a new agent that has access to the following tools: GlobTool, GrepTool, LS, View. When you are searching for a keyword or file and are not confident that you will find the right match on the first try, use the Agent tool to perform the search for you. For example:\n\n- If you are searching for a keyword like \"config\" or \"logger\", or for questions like \"which file does X?\", the Agent tool is strongly recommended\n- If you want to read a specific file path, use the View or GlobTool tool instead of the Agent tool, to find the match more quickly\n- If you are searching for a specific class definition like \"class Foo\", use the GlobTool tool instead, to find the match more quickly\n\nUsage notes:\n1. Launch multiple agents concurrently whenever possible, to maximize performance; to do that, use a single message with multiple tool uses\n2. When the agent is done, it will return a single message back to you. The result returned by the agent is not visible to the user. To show the user the result, you should send a text message back to the user with a concise summary of the result.\n3. Each agent invocation is stateless. You will not be able to send additional messages to the agent, nor will the agent be able to communicate with you outside of its final report. Therefore, your prompt should contain a highly detailed task description for the agent to perform autonomously and you should specify exactly what information the agent should return back to you in its final and only message to you.\n4. The agent's outputs should generally be trusted\n5. IMPORTANT: The agent can not use Bash, Replace, Edit, so can not modify files. If you want to use these tools, use them directly instead of going through the agent."
+	parameters := map[string]any{
+		"prompt": map[string]any{
+			"type":        "string",
+			"description": "The task for the agent to perform",
+		},
+	}
+
+	ids := slices.Sorted(maps.Keys(b.agents))
+	if len(ids) > 1 || (len(ids) == 1 && ids[0] != defaultSubAgent) {
+		var sb strings.Builder
+		sb.WriteString("\n\nThe following agents are available, the task agent described above is used by default:\n")
+		for _, id := range ids {
+			agentCfg := config.Get().Agents[id]
+			fmt.Fprintf(&sb, "- %s: %s", id, agentCfg.Description)
+			if agentCfg.AllowedTools != nil {
+				fmt.Fprintf(&sb, " (tools: %s)", strings.Join(agentCfg.AllowedTools, ", "))
+			}
+			sb.WriteString("\n")
+		}
+		sb.WriteString("The restrictions on modifying files only apply to agents that don't have access to these tools.")
+		description += sb.String()
+		parameters["agent"] = map[string]any{
+			"type":        "string",
+			"description": "The agent to perform the task, defaults to task",
+			"enum":        ids,
+		}
+	}
+
 	return tools.ToolInfo{
 		Name:        AgentToolName,
-		Description: "Launch a new agent that has access to the following tools: GlobTool, GrepTool, LS, View. When you are searching for a keyword or file and are not confident that you will find the right match on the first try, use the Agent tool to perform the search for you. For example:\n\n- If you are searching for a keyword like \"config\" or \"logger\", or for questions like \"which file does X?\", the Agent tool is strongly recommended\n- If you want to read a specific file path, use the View or GlobTool tool instead of the Agent tool, to find the match more quickly\n- If you are searching for a specific class definition like \"class Foo\", use the GlobTool tool instead, to find the match more quickly\n\nUsage notes:\n1. Launch multiple agents concurrently whenever possible, to maximize performance; to do that, use a single message with multiple tool uses\n2. When the agent is done, it will return a single message back to you. The result returned by the agent is not visible to the user. To show the user the result, you should send a text message back to the user with a concise summary of the result.\n3. Each agent invocation is stateless. You will not be able to send additional messages to the agent, nor will the agent be able to communicate with you outside of its final report. Therefore, your prompt should contain a highly detailed task description for the agent to perform autonomously and you should specify exactly what information the agent should return back to you in its final and only message to you.\n4. The agent's outputs should generally be trusted\n5. IMPORTANT: The agent can not use Bash, Replace, Edit, so can not modify files. If you want to use these tools, use them directly instead of going through the agent.",
-		Parameters: map[string]any{
-			"prompt": map[string]any{
-				"type":        "string",
-				"description": "The task for the agent to perform",
-			},
-		},
-		Required: []string{"prompt"},
+		Description: description,
+		Parameters:  parameters,
+		Required:    []string{"prompt"},
 	}
 }
 
@@ -50,18 +114,32 @@ func (b *agentTool) Run(ctx context.Context, call tools.ToolCall) (tools.ToolRes
 	if params.Prompt == "" {
 		return tools.NewTextErrorResponse("prompt is required"), nil
 	}
+	if params.Agent == "" {
+		params.Agent = defaultSubAgent
+	}
+	agent, ok := b.agents[params.Agent]
+	if !ok {
+		return tools.NewTextErrorResponse(fmt.Sprintf("unknown agent %q", params.Agent)), nil
+	}
 
 	sessionID, messageID := tools.GetContextValues(ctx)
 	if sessionID == "" || messageID == "" {
 		return tools.ToolResponse{}, fmt.Errorf("session_id and message_id are required")
 	}
 
-	session, err := b.sessions.CreateTaskSession(ctx, call.ID, sessionID, "New Agent Session")
+	title := "New Agent Session"
+	if params.Agent != defaultSubAgent {
+		title = fmt.Sprintf("New %s Session", config.Get().Agents[params.Agent].Name)
+	}
+	session, err := b.sessions.CreateTaskSession(ctx, call.ID, sessionID, title)
 	if err != nil {
 		return tools.ToolResponse{}, fmt.Errorf("error creating session: %s", err)
 	}
+	// Permission requests of the sub-agent are handled like the ones of the
+	// session that delegated the task.
+	b.permissions.SetSessionParent(session.ID, sessionID)
 
-	done, err := b.agent.Run(ctx, session.ID, params.Prompt)
+	done, err := agent.Run(ctx, session.ID, params.Prompt)
 	if err != nil {
 		return tools.ToolResponse{}, fmt.Errorf("error generating agent: %s", err)
 	}
@@ -94,13 +172,15 @@ func (b *agentTool) Run(ctx context.Context, call tools.ToolCall) (tools.ToolRes
 }
 
 func NewAgentTool(
-	agent Service,
+	agents map[string]Service,
+	permissions permission.Service,
 	sessions session.Service,
 	messages message.Service,
 ) tools.BaseTool {
 	return &agentTool{
-		sessions: sessions,
-		messages: messages,
-		agent:    agent,
+		permissions: permissions,
+		sessions:    sessions,
+		messages:    messages,
+		agents:      agents,
 	}
 }
@@ -2,17 +2,20 @@ package agent
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
 	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/charmbracelet/catwalk/pkg/catwalk"
 	"github.com/charmbracelet/crush/internal/config"
 	"github.com/charmbracelet/crush/internal/csync"
 	"github.com/charmbracelet/crush/internal/history"
+	"github.com/charmbracelet/crush/internal/hooks"
 	"github.com/charmbracelet/crush/internal/llm/prompt"
 	"github.com/charmbracelet/crush/internal/llm/provider"
 	"github.com/charmbracelet/crush/internal/llm/tools"
@@ -23,8 +26,17 @@ import (
 	"github.com/charmbracelet/crush/internal/pubsub"
 	"github.com/charmbracelet/crush/internal/session"
 	"github.com/charmbracelet/crush/internal/shell"
+	"github.com/charmbracelet/crush/internal/todo"
 )
 
+// maxConcurrentToolCalls is the maximum number of read-only tool calls that
+// are executed at the same time.
+const maxConcurrentToolCalls = 8
+
+// autoSummarizeBackoff is how long automatic summarization of a session stays
+// off after it failed.
+const autoSummarizeBackoff = 5 * time.Minute
+
 // Common errors
 var (
 	ErrRequestCancelled = errors.New("request canceled by user")
@@ -37,6 +49,9 @@ const (
 	AgentEventTypeError     AgentEventType = "error"
 	AgentEventTypeResponse  AgentEventType = "response"
 	AgentEventTypeSummarize AgentEventType = "summarize"
+	// AgentEventTypeToolOutput is published with the output of running tool
+	// calls, a chunk at a time.
+	AgentEventTypeToolOutput AgentEventType = "tool_output"
 )
 
 type AgentEvent struct {
@@ -48,6 +63,10 @@ type AgentEvent struct {
 	SessionID string
 	Progress  string
 	Done      bool
+
+	// When a tool call prints output
+	ToolCallID string
+	Output     string
 }
 
 type Service interface {
@@ -69,6 +88,8 @@ type agent struct {
 	agentCfg config.Agent
 	sessions session.Service
 	messages message.Service
+	todos    todo.Service
+	shells   *shell.SessionShells
 	mcpTools []McpTool
 
 	tools *csync.LazySlice[tools.BaseTool]
@@ -83,6 +104,10 @@ type agent struct {
 	activeRequests *csync.Map[string, context.CancelFunc]
 
 	promptQueue *csync.Map[string, []string]
+
+	// summarizeFailures holds the time of the last failed automatic
+	// summarization of each session.
+	summarizeFailures *csync.Map[string, time.Time]
 }
 
 var agentPromptMap = map[string]prompt.PromptID{
@@ -98,22 +123,18 @@ func NewAgent(
 	sessions session.Service,
 	messages message.Service,
 	history history.Service,
+	todos todo.Service,
+	shells *shell.SessionShells,
+	jobs *shell.JobManager,
 	lspClients map[string]*lsp.Client,
+	// The agents the agent can delegate tasks to through the agent tool.
+	subAgents map[string]Service,
 ) (Service, error) {
 	cfg := config.Get()
 
 	var agentTool tools.BaseTool
-	if agentCfg.ID == "coder" {
-		taskAgentCfg := config.Get().Agents["task"]
-		if taskAgentCfg.ID == "" {
-			return nil, fmt.Errorf("task agent not found in config")
-		}
-		taskAgent, err := NewAgent(ctx, taskAgentCfg, permissions, sessions, messages, history, lspClients)
-		if err != nil {
-			return nil, fmt.Errorf("failed to create task agent: %w", err)
-		}
-
-		agentTool = NewAgentTool(taskAgent, sessions, messages)
+	if len(subAgents) > 0 {
+		agentTool = NewAgentTool(subAgents, permissions, sessions, messages)
 	}
 
 	providerCfg := config.Get().GetProviderForModel(agentCfg.Model)
@@ -126,13 +147,13 @@ func NewAgent(
 		return nil, fmt.Errorf("model not found for agent %s", agentCfg.Name)
 	}
 
-	promptID := agentPromptMap[agentCfg.ID]
-	if promptID == "" {
-		promptID = prompt.PromptDefault
+	systemMessage, err := systemPrompt(agentCfg, providerCfg.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the prompt of agent %s: %w", agentCfg.Name, err)
 	}
 	opts := []provider.ProviderClientOption{
 		provider.WithModel(agentCfg.Model),
-		provider.WithSystemMessage(prompt.GetPrompt(promptID, providerCfg.ID, config.Get().Options.ContextPaths...)),
+		provider.WithSystemMessage(systemMessage),
 	}
 	agentProvider, err := provider.NewProvider(*providerCfg, opts...)
 	if err != nil {
@@ -181,15 +202,19 @@ func NewAgent(
 
 		cwd := cfg.WorkingDir()
 		allTools := []tools.BaseTool{
-			tools.NewBashTool(permissions, cwd),
+			tools.NewBashTool(permissions, shells, jobs, cfg.Bash, cwd),
 			tools.NewDownloadTool(permissions, cwd),
 			tools.NewEditTool(lspClients, permissions, history, cwd),
 			tools.NewMultiEditTool(lspClients, permissions, history, cwd),
 			tools.NewFetchTool(permissions, cwd),
 			tools.NewGlobTool(cwd),
 			tools.NewGrepTool(cwd),
+			tools.NewJobKillTool(jobs),
+			tools.NewJobOutputTool(jobs),
+			tools.NewJobWaitTool(jobs),
 			tools.NewLsTool(permissions, cwd),
 			tools.NewSourcegraphTool(),
+			tools.NewTodoTool(todos),
 			tools.NewViewTool(lspClients, permissions, cwd),
 			tools.NewWriteTool(lspClients, permissions, history, cwd),
 		}
@@ -197,27 +222,43 @@ func NewAgent(
 		mcpToolsOnce.Do(func() {
 			mcpTools = doGetMCPTools(ctx, permissions, cfg)
 		})
-		allTools = append(allTools, mcpTools...)
 
-		if len(lspClients) > 0 {
-			allTools = append(allTools, tools.NewDiagnosticsTool(lspClients))
+		// LSP clients are started in the background, so check the
+		// configuration rather than the clients that are already running.
+		if len(cfg.LSP) > 0 && (agentCfg.AllowedLSP == nil || len(agentCfg.AllowedLSP) > 0) {
+			allTools = append(allTools,
+				tools.NewDiagnosticsTool(lspClients),
+				tools.NewDefinitionTool(lspClients, agentCfg.AllowedLSP, cwd),
+				tools.NewReferencesTool(lspClients, agentCfg.AllowedLSP, cwd),
+				tools.NewHoverTool(lspClients, agentCfg.AllowedLSP, cwd),
+				tools.NewSymbolsTool(lspClients, agentCfg.AllowedLSP, cwd),
+				tools.NewRenameTool(lspClients, agentCfg.AllowedLSP, permissions, history, cwd),
+				tools.NewCodeActionTool(lspClients, agentCfg.AllowedLSP, permissions, history, cwd),
+			)
 		}
 
 		if agentTool != nil {
 			allTools = append(allTools, agentTool)
 		}
 
-		if agentCfg.AllowedTools == nil {
-			return allTools
+		allTools = slices.DeleteFunc(allTools, func(tool tools.BaseTool) bool {
+			return slices.Contains(cfg.Options.DisabledTools, tool.Name())
+		})
+
+		if agentCfg.AllowedTools != nil {
+			allTools = slices.DeleteFunc(allTools, func(tool tools.BaseTool) bool {
+				return !slices.Contains(agentCfg.AllowedTools, tool.Name())
+			})
 		}
 
-		var filteredTools []tools.BaseTool
-		for _, tool := range allTools {
-			if slices.Contains(agentCfg.AllowedTools, tool.Name()) {
-				filteredTools = append(filteredTools, tool)
+		for _, tool := range mcpTools {
+			if mcpToolAllowed(agentCfg, tool) && !slices.Contains(cfg.Options.DisabledTools, tool.Name()) {
+				allTools = append(allTools, tool)
 			}
 		}
-		return filteredTools
+
+		// Only offered in plan mode.
+		return append(allTools, tools.NewPlanTool())
 	}
 
 	return &agent{
@@ -227,15 +268,54 @@ func NewAgent(
 		providerID:          string(providerCfg.ID),
 		messages:            messages,
 		sessions:            sessions,
+		todos:               todos,
+		shells:              shells,
 		titleProvider:       titleProvider,
 		summarizeProvider:   summarizeProvider,
 		summarizeProviderID: string(providerCfg.ID),
 		activeRequests:      csync.NewMap[string, context.CancelFunc](),
 		tools:               csync.NewLazySlice(toolFn),
 		promptQueue:         csync.NewMap[string, []string](),
+		summarizeFailures:   csync.NewMap[string, time.Time](),
 	}, nil
 }
 
+// systemPrompt returns the system prompt of the agent. Custom agents without
+// a prompt file of their own use the default prompt.
+func systemPrompt(agentCfg config.Agent, providerID string) (string, error) {
+	if agentCfg.Prompt != "" {
+		return prompt.CustomPrompt(agentCfg.Prompt, agentCfg.ContextPaths...)
+	}
+	promptID, ok := agentPromptMap[agentCfg.ID]
+	if !ok {
+		promptID = prompt.PromptDefault
+	}
+	return prompt.GetPrompt(promptID, providerID, agentCfg.ContextPaths...), nil
+}
+
+// mcpToolAllowed returns whether the agent has access to the MCP tool.
+func mcpToolAllowed(agentCfg config.Agent, tool tools.BaseTool) bool {
+	if agentCfg.AllowedMCP == nil {
+		return true
+	}
+	mcpTool, ok := tool.(*McpTool)
+	if !ok {
+		return false
+	}
+	allowed, ok := agentCfg.AllowedMCP[mcpTool.mcpName]
+	return ok && (allowed == nil || slices.Contains(allowed, mcpTool.tool.Name))
+}
+
+// readOnly reports whether none of the tools of the agent have side effects.
+func (a *agent) readOnly() bool {
+	for tool := range a.tools.Seq() {
+		if !tools.IsReadOnly(tool) {
+			return false
+		}
+	}
+	return true
+}
+
 func (a *agent) Model() catwalk.Model {
 	return *config.Get().GetModelByType(a.agentCfg.Model)
 }
@@ -402,18 +482,10 @@ func (a *agent) processGeneration(ctx context.Context, sessionID, content string
 	if err != nil {
 		return a.err(fmt.Errorf("failed to get session: %w", err))
 	}
-	if session.SummaryMessageID != "" {
-		summaryMsgInex := -1
-		for i, msg := range msgs {
-			if msg.ID == session.SummaryMessageID {
-				summaryMsgInex = i
-				break
-			}
-		}
-		if summaryMsgInex != -1 {
-			msgs = msgs[summaryMsgInex:]
-			msgs[0].Role = message.User
-		}
+	msgs = messagesSinceSummary(msgs, session.SummaryMessageID)
+	if session.PlanMode {
+		// Sub-agents called while planning can't change anything either.
+		ctx = context.WithValue(ctx, tools.PlanModeContextKey, sessionID)
 	}
 
 	userMsg, err := a.createUserMessage(ctx, sessionID, content, attachmentParts)
@@ -422,6 +494,9 @@ func (a *agent) processGeneration(ctx context.Context, sessionID, content string
 	}
 	// Append the new user message to the conversation history.
 	msgHistory := append(msgs, userMsg)
+	if session.PlanMode {
+		msgHistory[len(msgHistory)-1] = withPlanModePrompt(userMsg)
+	}
 
 	for {
 		// Check for cancellation before each iteration
@@ -431,6 +506,18 @@ func (a *agent) processGeneration(ctx context.Context, sessionID, content string
 		default:
 			// Continue processing
 		}
+		if a.shouldAutoSummarize(ctx, sessionID) {
+			summaryMsg, err := a.summarize(ctx, sessionID)
+			if err != nil {
+				if errors.Is(err, context.Canceled) {
+					return a.err(ErrRequestCancelled)
+				}
+				slog.Error("Failed to summarize session", "session_id", sessionID, "error", err)
+				a.summarizeFailures.Set(sessionID, time.Now())
+			} else {
+				msgHistory = []message.Message{continuationMessage(summaryMsg, session.PlanMode)}
+			}
+		}
 		agentMessage, toolResults, err := a.streamAndHandleEvents(ctx, sessionID, msgHistory)
 		if err != nil {
 			if errors.Is(err, context.Canceled) {
@@ -483,6 +570,16 @@ func (a *agent) processGeneration(ctx context.Context, sessionID, content string
 			_ = a.messages.Update(context.Background(), agentMessage)
 			return a.err(ErrRequestCancelled)
 		}
+		// The turns of sub-agents are part of the turn of their parent.
+		if session.ParentSessionID == "" {
+			hooks.Run(ctx, cfg.Hooks.TurnEnd, hooks.Input{
+				Event:        hooks.TurnEnd,
+				SessionID:    sessionID,
+				WorkingDir:   cfg.WorkingDir(),
+				FinishReason: string(agentMessage.FinishReason()),
+				Response:     agentMessage.Content().String(),
+			})
+		}
 		return AgentEvent{
 			Type:    AgentEventTypeResponse,
 			Message: agentMessage,
@@ -491,6 +588,36 @@ func (a *agent) processGeneration(ctx context.Context, sessionID, content string
 	}
 }
 
+// continuationMessage turns a summary created in the middle of a request into
+// a user message that asks the model to carry on with the pending work. In
+// plan mode, the plan mode instructions are kept along with it.
+func continuationMessage(summary message.Message, planMode bool) message.Message {
+	summary.Role = message.User
+	summary.Parts = []message.ContentPart{
+		message.TextContent{
+			Text: "This conversation was summarized because it was running out of context. Continue with the pending work from where we left off, without asking any further questions.\n\n" + summary.Content().String(),
+		},
+	}
+	if planMode {
+		return withPlanModePrompt(summary)
+	}
+	return summary
+}
+
+// withPlanModePrompt returns the user message with the plan mode instructions
+// added to its text. They're only sent to the model, not stored.
+func withPlanModePrompt(msg message.Message) message.Message {
+	parts := make([]message.ContentPart, 0, len(msg.Parts))
+	for _, part := range msg.Parts {
+		if text, ok := part.(message.TextContent); ok {
+			part = message.TextContent{Text: text.Text + "\n\n" + prompt.PlanModePrompt()}
+		}
+		parts = append(parts, part)
+	}
+	msg.Parts = parts
+	return msg
+}
+
 func (a *agent) createUserMessage(ctx context.Context, sessionID, content string, attachmentParts []message.ContentPart) (message.Message, error) {
 	parts := []message.ContentPart{message.TextContent{Text: content}}
 	parts = append(parts, attachmentParts...)
@@ -515,7 +642,7 @@ func (a *agent) streamAndHandleEvents(ctx context.Context, sessionID string, msg
 	}
 
 	// Now collect tools (which may block on MCP initialization)
-	eventChan := a.provider.StreamResponse(ctx, msgHistory, slices.Collect(a.tools.Seq()))
+	eventChan := a.provider.StreamResponse(ctx, msgHistory, a.availableTools(ctx))
 
 	// Add the session and message ID into the context if needed by tools.
 	ctx = context.WithValue(ctx, tools.MessageIDContextKey, assistantMsg.ID)
@@ -536,105 +663,15 @@ func (a *agent) streamAndHandleEvents(ctx context.Context, sessionID string, msg
 		}
 	}
 
-	toolResults := make([]message.ToolResult, len(assistantMsg.ToolCalls()))
-	toolCalls := assistantMsg.ToolCalls()
-	for i, toolCall := range toolCalls {
-		select {
-		case <-ctx.Done():
+	toolResults, toolErr := a.executeToolCalls(ctx, assistantMsg.ToolCalls())
+	if toolErr != nil {
+		if errors.Is(toolErr, permission.ErrorPermissionDenied) && ctx.Err() == nil {
+			a.finishMessage(ctx, &assistantMsg, message.FinishReasonPermissionDenied, "Permission denied", "")
+		} else {
 			a.finishMessage(context.Background(), &assistantMsg, message.FinishReasonCanceled, "Request cancelled", "")
-			// Make all future tool calls cancelled
-			for j := i; j < len(toolCalls); j++ {
-				toolResults[j] = message.ToolResult{
-					ToolCallID: toolCalls[j].ID,
-					Content:    "Tool execution canceled by user",
-					IsError:    true,
-				}
-			}
-			goto out
-		default:
-			// Continue processing
-			var tool tools.BaseTool
-			for availableTool := range a.tools.Seq() {
-				if availableTool.Info().Name == toolCall.Name {
-					tool = availableTool
-					break
-				}
-			}
-
-			// Tool not found
-			if tool == nil {
-				toolResults[i] = message.ToolResult{
-					ToolCallID: toolCall.ID,
-					Content:    fmt.Sprintf("Tool not found: %s", toolCall.Name),
-					IsError:    true,
-				}
-				continue
-			}
-
-			// Run tool in goroutine to allow cancellation
-			type toolExecResult struct {
-				response tools.ToolResponse
-				err      error
-			}
-			resultChan := make(chan toolExecResult, 1)
-
-			go func() {
-				response, err := tool.Run(ctx, tools.ToolCall{
-					ID:    toolCall.ID,
-					Name:  toolCall.Name,
-					Input: toolCall.Input,
-				})
-				resultChan <- toolExecResult{response: response, err: err}
-			}()
-
-			var toolResponse tools.ToolResponse
-			var toolErr error
-
-			select {
-			case <-ctx.Done():
-				a.finishMessage(context.Background(), &assistantMsg, message.FinishReasonCanceled, "Request cancelled", "")
-				// Mark remaining tool calls as cancelled
-				for j := i; j < len(toolCalls); j++ {
-					toolResults[j] = message.ToolResult{
-						ToolCallID: toolCalls[j].ID,
-						Content:    "Tool execution canceled by user",
-						IsError:    true,
-					}
-				}
-				goto out
-			case result := <-resultChan:
-				toolResponse = result.response
-				toolErr = result.err
-			}
-
-			if toolErr != nil {
-				slog.Error("Tool execution error", "toolCall", toolCall.ID, "error", toolErr)
-				if errors.Is(toolErr, permission.ErrorPermissionDenied) {
-					toolResults[i] = message.ToolResult{
-						ToolCallID: toolCall.ID,
-						Content:    "Permission denied",
-						IsError:    true,
-					}
-					for j := i + 1; j < len(toolCalls); j++ {
-						toolResults[j] = message.ToolResult{
-							ToolCallID: toolCalls[j].ID,
-							Content:    "Tool execution canceled by user",
-							IsError:    true,
-						}
-					}
-					a.finishMessage(ctx, &assistantMsg, message.FinishReasonPermissionDenied, "Permission denied", "")
-					break
-				}
-			}
-			toolResults[i] = message.ToolResult{
-				ToolCallID: toolCall.ID,
-				Content:    toolResponse.Content,
-				Metadata:   toolResponse.Metadata,
-				IsError:    toolResponse.IsError,
-			}
 		}
 	}
-out:
+
 	if len(toolResults) == 0 {
 		return assistantMsg, nil, nil
 	}
@@ -654,6 +691,231 @@ out:
 	return assistantMsg, &msg, err
 }
 
+// executeToolCalls runs the tool calls in order and returns their results.
+// Consecutive read-only tool calls are batched and run concurrently,
+// everything else runs on its own once the calls before it are done. When a
+// call is cancelled or denied, the calls after it are cancelled and the error
+// is returned along with the results.
+func (a *agent) executeToolCalls(ctx context.Context, toolCalls []message.ToolCall) ([]message.ToolResult, error) {
+	toolResults := make([]message.ToolResult, len(toolCalls))
+	for i := 0; i < len(toolCalls); {
+		end := i + 1
+		if a.isReadOnlyCall(ctx, toolCalls[i]) {
+			for end < len(toolCalls) && a.isReadOnlyCall(ctx, toolCalls[end]) {
+				end++
+			}
+		}
+
+		if err := a.runToolCalls(ctx, toolCalls[i:end], toolResults[i:end]); err != nil {
+			// Make all future tool calls cancelled
+			for j := end; j < len(toolCalls); j++ {
+				toolResults[j] = cancelledToolResult(toolCalls[j].ID)
+			}
+			return toolResults, err
+		}
+		i = end
+	}
+	return toolResults, nil
+}
+
+// runToolCalls executes the given tool calls and stores their results in the
+// matching positions of results. When more than one call is given, they are
+// run concurrently, with at most maxConcurrentToolCalls running at once.
+func (a *agent) runToolCalls(ctx context.Context, toolCalls []message.ToolCall, results []message.ToolResult) error {
+	if len(toolCalls) == 1 {
+		var err error
+		results[0], err = a.runToolCall(ctx, toolCalls[0])
+		return err
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(toolCalls))
+	sem := make(chan struct{}, maxConcurrentToolCalls)
+	for i, toolCall := range toolCalls {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				results[i] = cancelledToolResult(toolCall.ID)
+				errs[i] = ctx.Err()
+				return
+			}
+			results[i], errs[i] = a.runToolCall(ctx, toolCall)
+		}()
+	}
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return errors.Join(errs...)
+}
+
+// runToolCall executes a single tool call. The returned error is only set when
+// the request was cancelled or permission was denied, any other tool failure
+// is reported back to the model as part of the result.
+func (a *agent) runToolCall(ctx context.Context, toolCall message.ToolCall) (message.ToolResult, error) {
+	if ctx.Err() != nil {
+		return cancelledToolResult(toolCall.ID), ctx.Err()
+	}
+
+	tool := a.getTool(ctx, toolCall.Name)
+	if tool == nil {
+		return message.ToolResult{
+			ToolCallID: toolCall.ID,
+			Content:    fmt.Sprintf("Tool not found: %s", toolCall.Name),
+			IsError:    true,
+		}, nil
+	}
+
+	cfg := config.Get()
+	sessionID, _ := tools.GetContextValues(ctx)
+	hookInput := hooks.Input{
+		SessionID:  sessionID,
+		WorkingDir: cfg.WorkingDir(),
+		ToolName:   toolCall.Name,
+		ToolInput:  hookToolInput(toolCall.Input),
+	}
+	hookInput.Event = hooks.PreToolUse
+	if pre := hooks.Run(ctx, cfg.Hooks.PreToolUse, hookInput); pre.Blocked {
+		content := "The tool call was blocked by a hook"
+		if pre.Message != "" {
+			content += ": " + pre.Message
+		}
+		return message.ToolResult{
+			ToolCallID: toolCall.ID,
+			Content:    content,
+			IsError:    true,
+		}, nil
+	}
+
+	// Run tool in goroutine to allow cancellation
+	type toolExecResult struct {
+		response tools.ToolResponse
+		err      error
+	}
+	resultChan := make(chan toolExecResult, 1)
+
+	// The output of the tool call is published as it runs, to be shown
+	// before the result.
+	ctx = context.WithValue(ctx, tools.OutputContextKey, tools.OutputFunc(func(output string) {
+		a.Publish(pubsub.CreatedEvent, AgentEvent{
+			Type:       AgentEventTypeToolOutput,
+			SessionID:  sessionID,
+			ToolCallID: toolCall.ID,
+			Output:     output,
+		})
+	}))
+
+	go func() {
+		response, err := tool.Run(ctx, tools.ToolCall{
+			ID:    toolCall.ID,
+			Name:  toolCall.Name,
+			Input: toolCall.Input,
+		})
+		resultChan <- toolExecResult{response: response, err: err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return cancelledToolResult(toolCall.ID), ctx.Err()
+	case result := <-resultChan:
+		if result.err != nil {
+			// Calls denied by the permission rules are reported back to the
+			// model, which can carry on with something else.
+			var denied *permission.DeniedError
+			if errors.As(result.err, &denied) {
+				return message.ToolResult{
+					ToolCallID: toolCall.ID,
+					Content:    denied.Error(),
+					IsError:    true,
+				}, nil
+			}
+			slog.Error("Tool execution error", "toolCall", toolCall.ID, "error", result.err)
+			if errors.Is(result.err, permission.ErrorPermissionDenied) {
+				return message.ToolResult{
+					ToolCallID: toolCall.ID,
+					Content:    "Permission denied",
+					IsError:    true,
+				}, result.err
+			}
+		}
+		toolResult := message.ToolResult{
+			ToolCallID: toolCall.ID,
+			Content:    result.response.Content,
+			Metadata:   result.response.Metadata,
+			IsError:    result.response.IsError,
+		}
+		hookInput.Event = hooks.PostToolUse
+		hookInput.ToolResult = toolResult.Content
+		hookInput.ToolError = toolResult.IsError
+		if post := hooks.Run(ctx, cfg.Hooks.PostToolUse, hookInput); post.Message != "" {
+			toolResult.Content += "\n\n<hook_feedback>\n" + post.Message + "\n</hook_feedback>"
+		}
+		return toolResult, nil
+	}
+}
+
+// hookToolInput returns the input of the tool call for hooks, as a JSON
+// value when it is one.
+func hookToolInput(input string) json.RawMessage {
+	if json.Valid([]byte(input)) {
+		return json.RawMessage(input)
+	}
+	encoded, _ := json.Marshal(input)
+	return encoded
+}
+
+// availableTools returns the tools of the agent available to the request. In
+// plan mode, only the tools that can't change anything are, along with bash,
+// which only runs read-only commands, and the agent tool, whose agents are in
+// plan mode too. The plan tool is only available to the session being planned.
+func (a *agent) availableTools(ctx context.Context) []tools.BaseTool {
+	planning, _ := ctx.Value(tools.PlanModeContextKey).(string)
+	sessionID, _ := tools.GetContextValues(ctx)
+	var available []tools.BaseTool
+	for tool := range a.tools.Seq() {
+		switch tool.Name() {
+		case tools.PlanToolName:
+			if planning == "" || planning != sessionID {
+				continue
+			}
+		case tools.BashToolName, AgentToolName:
+		default:
+			if planning != "" && !tools.IsReadOnly(tool) {
+				continue
+			}
+		}
+		available = append(available, tool)
+	}
+	return available
+}
+
+func (a *agent) getTool(ctx context.Context, name string) tools.BaseTool {
+	for _, tool := range a.availableTools(ctx) {
+		if tool.Info().Name == name {
+			return tool
+		}
+	}
+	return nil
+}
+
+func (a *agent) isReadOnlyCall(ctx context.Context, toolCall message.ToolCall) bool {
+	tool := a.getTool(ctx, toolCall.Name)
+	return tool != nil && tools.IsReadOnlyCall(tool, toolCall.Input)
+}
+
+func cancelledToolResult(toolCallID string) message.ToolResult {
+	return message.ToolResult{
+		ToolCallID: toolCallID,
+		Content:    "Tool execution canceled by user",
+		IsError:    true,
+	}
+}
+
 func (a *agent) finishMessage(ctx context.Context, msg *message.Message, finishReason message.FinishReason, message, details string) {
 	msg.AddFinish(finishReason, message, details)
 	_ = a.messages.Update(ctx, *msg)
@@ -746,163 +1008,200 @@ func (a *agent) Summarize(ctx context.Context, sessionID string) error {
 	go func() {
 		defer a.activeRequests.Del(sessionID + "-summarize")
 		defer cancel()
-		event := AgentEvent{
-			Type:     AgentEventTypeSummarize,
-			Progress: "Starting summarization...",
-		}
 
-		a.Publish(pubsub.CreatedEvent, event)
-		// Get all messages from the session
-		msgs, err := a.messages.List(summarizeCtx, sessionID)
-		if err != nil {
-			event = AgentEvent{
-				Type:  AgentEventTypeError,
-				Error: fmt.Errorf("failed to list messages: %w", err),
-				Done:  true,
-			}
-			a.Publish(pubsub.CreatedEvent, event)
+		if _, err := a.summarize(summarizeCtx, sessionID); err != nil {
+			a.Publish(pubsub.CreatedEvent, AgentEvent{
+				Type:      AgentEventTypeError,
+				SessionID: sessionID,
+				Error:     err,
+				Done:      true,
+			})
 			return
 		}
-		summarizeCtx = context.WithValue(summarizeCtx, tools.SessionIDContextKey, sessionID)
 
-		if len(msgs) == 0 {
-			event = AgentEvent{
-				Type:  AgentEventTypeError,
-				Error: fmt.Errorf("no messages to summarize"),
-				Done:  true,
-			}
-			a.Publish(pubsub.CreatedEvent, event)
-			return
-		}
+		// Send final success event with the session ID
+		a.Publish(pubsub.CreatedEvent, AgentEvent{
+			Type:      AgentEventTypeSummarize,
+			SessionID: sessionID,
+			Progress:  "Summary complete",
+			Done:      true,
+		})
+	}()
 
-		event = AgentEvent{
-			Type:     AgentEventTypeSummarize,
-			Progress: "Analyzing conversation...",
-		}
-		a.Publish(pubsub.CreatedEvent, event)
+	return nil
+}
 
-		// Add a system message to guide the summarization
-		summarizePrompt := "Provide a detailed but concise summary of our conversation above. Focus on information that would be helpful for continuing the conversation, including what we did, what we're doing, which files we're working on, and what we're going to do next."
+// summarize condenses the conversation of the given session into a summary
+// message, which becomes the new starting point of the session history.
+// Progress is published as summarize events.
+func (a *agent) summarize(ctx context.Context, sessionID string) (message.Message, error) {
+	progress := func(p string) {
+		a.Publish(pubsub.CreatedEvent, AgentEvent{
+			Type:      AgentEventTypeSummarize,
+			SessionID: sessionID,
+			Progress:  p,
+		})
+	}
 
-		// Create a new message with the summarize prompt
-		promptMsg := message.Message{
-			Role:  message.User,
-			Parts: []message.ContentPart{message.TextContent{Text: summarizePrompt}},
-		}
+	progress("Starting summarization...")
+	// Get all messages from the session
+	msgs, err := a.messages.List(ctx, sessionID)
+	if err != nil {
+		return message.Message{}, fmt.Errorf("failed to list messages: %w", err)
+	}
+	ctx = context.WithValue(ctx, tools.SessionIDContextKey, sessionID)
 
-		// Append the prompt to the messages
-		msgsWithPrompt := append(msgs, promptMsg)
+	oldSession, err := a.sessions.Get(ctx, sessionID)
+	if err != nil {
+		return message.Message{}, fmt.Errorf("failed to get session: %w", err)
+	}
+	// Anything before a previous summary is already part of it.
+	msgs = messagesSinceSummary(msgs, oldSession.SummaryMessageID)
+	if len(msgs) == 0 {
+		return message.Message{}, fmt.Errorf("no messages to summarize")
+	}
 
-		event = AgentEvent{
-			Type:     AgentEventTypeSummarize,
-			Progress: "Generating summary...",
-		}
+	progress("Analyzing conversation...")
 
-		a.Publish(pubsub.CreatedEvent, event)
-
-		// Send the messages to the summarize provider
-		response := a.summarizeProvider.StreamResponse(
-			summarizeCtx,
-			msgsWithPrompt,
-			nil,
-		)
-		var finalResponse *provider.ProviderResponse
-		for r := range response {
-			if r.Error != nil {
-				event = AgentEvent{
-					Type:  AgentEventTypeError,
-					Error: fmt.Errorf("failed to summarize: %w", err),
-					Done:  true,
-				}
-				a.Publish(pubsub.CreatedEvent, event)
-				return
-			}
-			finalResponse = r.Response
-		}
+	// Add a system message to guide the summarization
+	summarizePrompt := "Provide a detailed but concise summary of our conversation above. Focus on information that would be helpful for continuing the conversation, including what we did, what we're doing, which files we're working on, and what we're going to do next."
 
-		summary := strings.TrimSpace(finalResponse.Content)
-		if summary == "" {
-			event = AgentEvent{
-				Type:  AgentEventTypeError,
-				Error: fmt.Errorf("empty summary returned"),
-				Done:  true,
-			}
-			a.Publish(pubsub.CreatedEvent, event)
-			return
-		}
-		shell := shell.GetPersistentShell(config.Get().WorkingDir())
-		summary += "\n\n**Current working directory of the persistent shell**\n\n" + shell.GetWorkingDir()
-		event = AgentEvent{
-			Type:     AgentEventTypeSummarize,
-			Progress: "Creating new session...",
-		}
+	// Create a new message with the summarize prompt
+	promptMsg := message.Message{
+		Role:  message.User,
+		Parts: []message.ContentPart{message.TextContent{Text: summarizePrompt}},
+	}
 
-		a.Publish(pubsub.CreatedEvent, event)
-		oldSession, err := a.sessions.Get(summarizeCtx, sessionID)
-		if err != nil {
-			event = AgentEvent{
-				Type:  AgentEventTypeError,
-				Error: fmt.Errorf("failed to get session: %w", err),
-				Done:  true,
-			}
+	// Append the prompt to the messages
+	msgsWithPrompt := append(msgs, promptMsg)
 
-			a.Publish(pubsub.CreatedEvent, event)
-			return
+	progress("Generating summary...")
+
+	// Send the messages to the summarize provider
+	response := a.summarizeProvider.StreamResponse(
+		ctx,
+		msgsWithPrompt,
+		nil,
+	)
+	var finalResponse *provider.ProviderResponse
+	for r := range response {
+		if r.Error != nil {
+			return message.Message{}, fmt.Errorf("failed to summarize: %w", r.Error)
 		}
-		// Create a message in the new session with the summary
-		msg, err := a.messages.Create(summarizeCtx, oldSession.ID, message.CreateMessageParams{
-			Role: message.Assistant,
-			Parts: []message.ContentPart{
-				message.TextContent{Text: summary},
-				message.Finish{
-					Reason: message.FinishReasonEndTurn,
-					Time:   time.Now().Unix(),
-				},
+		finalResponse = r.Response
+	}
+	if finalResponse == nil {
+		return message.Message{}, fmt.Errorf("no response received from summarize provider")
+	}
+
+	summary := strings.TrimSpace(finalResponse.Content)
+	if summary == "" {
+		return message.Message{}, fmt.Errorf("empty summary returned")
+	}
+	cwd := a.shells.Get(ctx, sessionID).GetWorkingDir()
+	summary += "\n\n**Current working directory of the persistent shell**\n\n" + cwd
+	// The todo list is kept as it is, for the progress to survive the
+	// summary.
+	todos, err := a.todos.List(ctx, sessionID)
+	if err != nil {
+		return message.Message{}, fmt.Errorf("failed to list todos: %w", err)
+	}
+	if len(todos) > 0 {
+		summary += "\n\n**Todo list**\n\n" + todo.Checklist(todos)
+	}
+
+	progress("Creating new session...")
+
+	// Create a message in the session with the summary
+	msg, err := a.messages.Create(ctx, oldSession.ID, message.CreateMessageParams{
+		Role: message.Assistant,
+		Parts: []message.ContentPart{
+			message.TextContent{Text: summary},
+			message.Finish{
+				Reason: message.FinishReasonEndTurn,
+				Time:   time.Now().Unix(),
 			},
-			Model:    a.summarizeProvider.Model().ID,
-			Provider: a.summarizeProviderID,
-		})
-		if err != nil {
-			event = AgentEvent{
-				Type:  AgentEventTypeError,
-				Error: fmt.Errorf("failed to create summary message: %w", err),
-				Done:  true,
-			}
+		},
+		Model:    a.summarizeProvider.Model().ID,
+		Provider: a.summarizeProviderID,
+	})
+	if err != nil {
+		return message.Message{}, fmt.Errorf("failed to create summary message: %w", err)
+	}
 
-			a.Publish(pubsub.CreatedEvent, event)
-			return
-		}
-		oldSession.SummaryMessageID = msg.ID
-		oldSession.CompletionTokens = finalResponse.Usage.OutputTokens
-		oldSession.PromptTokens = 0
-		model := a.summarizeProvider.Model()
-		usage := finalResponse.Usage
-		cost := model.CostPer1MInCached/1e6*float64(usage.CacheCreationTokens) +
-			model.CostPer1MOutCached/1e6*float64(usage.CacheReadTokens) +
-			model.CostPer1MIn/1e6*float64(usage.InputTokens) +
-			model.CostPer1MOut/1e6*float64(usage.OutputTokens)
-		oldSession.Cost += cost
-		_, err = a.sessions.Save(summarizeCtx, oldSession)
-		if err != nil {
-			event = AgentEvent{
-				Type:  AgentEventTypeError,
-				Error: fmt.Errorf("failed to save session: %w", err),
-				Done:  true,
-			}
-			a.Publish(pubsub.CreatedEvent, event)
-		}
+	// Usage may have been tracked while summarizing, so get a fresh copy.
+	oldSession, err = a.sessions.Get(ctx, sessionID)
+	if err != nil {
+		return message.Message{}, fmt.Errorf("failed to get session: %w", err)
+	}
+	oldSession.SummaryMessageID = msg.ID
+	oldSession.CompletionTokens = finalResponse.Usage.OutputTokens
+	oldSession.PromptTokens = 0
+	model := a.summarizeProvider.Model()
+	usage := finalResponse.Usage
+	cost := model.CostPer1MInCached/1e6*float64(usage.CacheCreationTokens) +
+		model.CostPer1MOutCached/1e6*float64(usage.CacheReadTokens) +
+		model.CostPer1MIn/1e6*float64(usage.InputTokens) +
+		model.CostPer1MOut/1e6*float64(usage.OutputTokens)
+	oldSession.Cost += cost
+	if _, err = a.sessions.Save(ctx, oldSession); err != nil {
+		return message.Message{}, fmt.Errorf("failed to save session: %w", err)
+	}
+	a.summarizeFailures.Del(sessionID)
+	return msg, nil
+}
 
-		event = AgentEvent{
-			Type:      AgentEventTypeSummarize,
-			SessionID: oldSession.ID,
-			Progress:  "Summary complete",
-			Done:      true,
-		}
-		a.Publish(pubsub.CreatedEvent, event)
-		// Send final success event with the new session ID
-	}()
+// shouldAutoSummarize reports whether the conversation of the given session is
+// close enough to the model's context window to be summarized before the next
+// provider call. After a failed summarization it waits for
+// autoSummarizeBackoff before trying again.
+func (a *agent) shouldAutoSummarize(ctx context.Context, sessionID string) bool {
+	cfg := config.Get()
+	if cfg.Options.DisableAutoSummarize || a.summarizeProvider == nil {
+		return false
+	}
+	if a.summarizeBackedOff(sessionID) {
+		return false
+	}
+	sess, err := a.sessions.Get(ctx, sessionID)
+	if err != nil {
+		return false
+	}
+	tokens := sess.PromptTokens + sess.CompletionTokens
+	return exceedsSummarizeThreshold(tokens, a.Model().ContextWindow, cfg.Options.AutoSummarizeThreshold)
+}
 
-	return nil
+// summarizeBackedOff reports whether the last automatic summarization of the
+// session failed less than autoSummarizeBackoff ago.
+func (a *agent) summarizeBackedOff(sessionID string) bool {
+	failedAt, ok := a.summarizeFailures.Get(sessionID)
+	return ok && time.Since(failedAt) < autoSummarizeBackoff
+}
+
+// exceedsSummarizeThreshold reports whether tokens reach the given fraction of
+// the context window. Unknown context windows and thresholds outside (0, 1]
+// never trigger a summary.
+func exceedsSummarizeThreshold(tokens, contextWindow int64, threshold float64) bool {
+	if contextWindow <= 0 || threshold <= 0 || threshold > 1 {
+		return false
+	}
+	return tokens >= int64(float64(contextWindow)*threshold)
+}
+
+// messagesSinceSummary returns the messages starting at the summary message,
+// with the summary acting as the first user message of the conversation.
+func messagesSinceSummary(msgs []message.Message, summaryMessageID string) []message.Message {
+	if summaryMessageID == "" {
+		return msgs
+	}
+	for i, msg := range msgs {
+		if msg.ID == summaryMessageID {
+			msgs = msgs[i:]
+			msgs[0].Role = message.User
+			break
+		}
+	}
+	return msgs
 }
 
 func (a *agent) ClearQueue(sessionID string) {
@@ -948,14 +1247,14 @@ func (a *agent) UpdateModel() error {
 			return fmt.Errorf("model not found for agent %s", a.agentCfg.Name)
 		}
 
-		promptID := agentPromptMap[a.agentCfg.ID]
-		if promptID == "" {
-			promptID = prompt.PromptDefault
+		systemMessage, err := systemPrompt(a.agentCfg, currentProviderCfg.ID)
+		if err != nil {
+			return fmt.Errorf("failed to load the prompt of agent %s: %w", a.agentCfg.Name, err)
 		}
 
 		opts := []provider.ProviderClientOption{
 			provider.WithModel(a.agentCfg.Model),
-			provider.WithSystemMessage(prompt.GetPrompt(promptID, currentProviderCfg.ID, cfg.Options.ContextPaths...)),
+			provider.WithSystemMessage(systemMessage),
 		}
 
 		newProvider, err := provider.NewProvider(*currentProviderCfg, opts...)
@@ -0,0 +1,349 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/csync"
+	"github.com/charmbracelet/crush/internal/llm/prompt"
+	"github.com/charmbracelet/crush/internal/llm/tools"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/charmbracelet/crush/internal/pubsub"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	// Tool calls read the configuration, which is loaded from a temporary
+	// directory with cached providers, to run without a network.
+	dir, err := os.MkdirTemp("", "crush-agent-test")
+	if err != nil {
+		panic(err)
+	}
+	os.Setenv("XDG_CONFIG_HOME", dir)
+	os.Setenv("XDG_DATA_HOME", dir)
+	providers := filepath.Join(dir, "crush", "providers.json")
+	if err := os.MkdirAll(filepath.Dir(providers), 0o755); err != nil {
+		panic(err)
+	}
+	if err := os.WriteFile(providers, []byte(`[{"id":"test","name":"Test"}]`), 0o644); err != nil {
+		panic(err)
+	}
+	if _, err := config.Init(dir, "", false); err != nil {
+		panic("Failed to initialize config: " + err.Error())
+	}
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func TestExceedsSummarizeThreshold(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		tokens        int64
+		contextWindow int64
+		threshold     float64
+		want          bool
+	}{
+		{"below", 84, 100, 0.85, false},
+		{"at", 85, 100, 0.85, true},
+		{"above", 99, 100, 0.85, true},
+		{"full window", 100, 100, 1, true},
+		{"unknown context window", 1000, 0, 0.85, false},
+		{"zero threshold", 1000, 100, 0, false},
+		{"negative threshold", 1000, 100, -0.5, false},
+		{"threshold above one", 1000, 100, 1.5, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tt.want, exceedsSummarizeThreshold(tt.tokens, tt.contextWindow, tt.threshold))
+		})
+	}
+}
+
+func TestSummarizeBackedOff(t *testing.T) {
+	t.Parallel()
+
+	a := &agent{summarizeFailures: csync.NewMap[string, time.Time]()}
+	require.False(t, a.summarizeBackedOff("s1"))
+
+	a.summarizeFailures.Set("s1", time.Now())
+	require.True(t, a.summarizeBackedOff("s1"))
+	require.False(t, a.summarizeBackedOff("s2"))
+
+	a.summarizeFailures.Set("s1", time.Now().Add(-autoSummarizeBackoff))
+	require.False(t, a.summarizeBackedOff("s1"))
+}
+
+func TestMessagesSinceSummary(t *testing.T) {
+	t.Parallel()
+
+	conversation := func() []message.Message {
+		return []message.Message{
+			{ID: "1", Role: message.User},
+			{ID: "2", Role: message.Assistant},
+			{ID: "3", Role: message.Assistant},
+			{ID: "4", Role: message.User},
+		}
+	}
+	ids := func(msgs []message.Message) []string {
+		var ids []string
+		for _, msg := range msgs {
+			ids = append(ids, msg.ID)
+		}
+		return ids
+	}
+
+	t.Run("no summary yet", func(t *testing.T) {
+		t.Parallel()
+		msgs := messagesSinceSummary(conversation(), "")
+		require.Equal(t, []string{"1", "2", "3", "4"}, ids(msgs))
+	})
+
+	t.Run("summary in the middle", func(t *testing.T) {
+		t.Parallel()
+		msgs := messagesSinceSummary(conversation(), "3")
+		require.Equal(t, []string{"3", "4"}, ids(msgs))
+		require.Equal(t, message.User, msgs[0].Role)
+	})
+
+	t.Run("summary as the last message", func(t *testing.T) {
+		t.Parallel()
+		msgs := conversation()
+		msgs = append(msgs, message.Message{ID: "5", Role: message.Assistant})
+		msgs = messagesSinceSummary(msgs, "5")
+		require.Equal(t, []string{"5"}, ids(msgs))
+		require.Equal(t, message.User, msgs[0].Role)
+	})
+
+	t.Run("unknown summary", func(t *testing.T) {
+		t.Parallel()
+		msgs := messagesSinceSummary(conversation(), "missing")
+		require.Equal(t, []string{"1", "2", "3", "4"}, ids(msgs))
+	})
+}
+
+func TestContinuationMessage(t *testing.T) {
+	t.Parallel()
+
+	summary := message.Message{
+		ID:   "summary",
+		Role: message.Assistant,
+		Parts: []message.ContentPart{
+			message.TextContent{Text: "We added a flag."},
+			message.Finish{Reason: message.FinishReasonEndTurn},
+		},
+	}
+	msg := continuationMessage(summary, false)
+	require.Equal(t, "summary", msg.ID)
+	require.Equal(t, message.User, msg.Role)
+	require.Len(t, msg.Parts, 1)
+	text := msg.Content().String()
+	require.Contains(t, text, "Continue with the pending work")
+	require.True(t, strings.HasSuffix(text, "\n\nWe added a flag."))
+	require.Equal(t, message.Assistant, summary.Role, "the summary itself is left alone")
+
+	// Summaries don't take sessions out of plan mode.
+	msg = continuationMessage(summary, true)
+	text = msg.Content().String()
+	require.Contains(t, text, "We added a flag.")
+	require.True(t, strings.HasSuffix(text, prompt.PlanModePrompt()))
+}
+
+type fakeTool struct {
+	name     string
+	readOnly bool
+	run      func(ctx context.Context, call tools.ToolCall) (tools.ToolResponse, error)
+}
+
+func (t *fakeTool) Info() tools.ToolInfo { return tools.ToolInfo{Name: t.name} }
+func (t *fakeTool) Name() string         { return t.name }
+func (t *fakeTool) ReadOnly() bool       { return t.readOnly }
+
+func (t *fakeTool) Run(ctx context.Context, call tools.ToolCall) (tools.ToolResponse, error) {
+	return t.run(ctx, call)
+}
+
+func newToolAgent(toolList ...tools.BaseTool) *agent {
+	return &agent{
+		Broker: pubsub.NewBroker[AgentEvent](),
+		tools:  csync.NewLazySlice(func() []tools.BaseTool { return toolList }),
+	}
+}
+
+// toolLog records the start and the end of tool calls, in order.
+type toolLog struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (l *toolLog) add(event string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, event)
+}
+
+func (l *toolLog) index(event string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return slices.Index(l.events, event)
+}
+
+func TestExecuteToolCalls(t *testing.T) {
+	t.Parallel()
+
+	// sleepTool sleeps for the number of milliseconds given as input.
+	sleepTool := func(name string, readOnly bool, log *toolLog) *fakeTool {
+		return &fakeTool{name: name, readOnly: readOnly, run: func(ctx context.Context, call tools.ToolCall) (tools.ToolResponse, error) {
+			log.add("start " + call.ID)
+			var ms int
+			fmt.Sscan(call.Input, &ms)
+			time.Sleep(time.Duration(ms) * time.Millisecond)
+			log.add("end " + call.ID)
+			return tools.NewTextResponse(call.ID), nil
+		}}
+	}
+
+	t.Run("results keep the order of the calls", func(t *testing.T) {
+		t.Parallel()
+
+		log := &toolLog{}
+		a := newToolAgent(sleepTool("view", true, log))
+		results, err := a.executeToolCalls(t.Context(), []message.ToolCall{
+			{ID: "1", Name: "view", Input: "60"},
+			{ID: "2", Name: "view", Input: "30"},
+			{ID: "3", Name: "view", Input: "0"},
+		})
+		require.NoError(t, err)
+		require.Len(t, results, 3)
+		for i, result := range results {
+			id := fmt.Sprint(i + 1)
+			require.Equal(t, id, result.ToolCallID)
+			require.Equal(t, id, result.Content)
+		}
+	})
+
+	t.Run("read-only calls run in parallel", func(t *testing.T) {
+		t.Parallel()
+
+		// Every call waits for all of them to have started, which only
+		// happens when they run at the same time.
+		const calls = 4
+		var started sync.WaitGroup
+		started.Add(calls)
+		allStarted := make(chan struct{})
+		go func() {
+			started.Wait()
+			close(allStarted)
+		}()
+		a := newToolAgent(&fakeTool{name: "grep", readOnly: true, run: func(ctx context.Context, call tools.ToolCall) (tools.ToolResponse, error) {
+			started.Done()
+			select {
+			case <-allStarted:
+				return tools.NewTextResponse("found"), nil
+			case <-time.After(5 * time.Second):
+				return tools.NewTextErrorResponse("the calls didn't run in parallel"), nil
+			}
+		}})
+
+		var toolCalls []message.ToolCall
+		for i := range calls {
+			toolCalls = append(toolCalls, message.ToolCall{ID: fmt.Sprint(i), Name: "grep"})
+		}
+		results, err := a.executeToolCalls(t.Context(), toolCalls)
+		require.NoError(t, err)
+		for _, result := range results {
+			require.False(t, result.IsError, result.Content)
+		}
+	})
+
+	t.Run("write calls wait for the batch before them", func(t *testing.T) {
+		t.Parallel()
+
+		log := &toolLog{}
+		a := newToolAgent(sleepTool("view", true, log), sleepTool("edit", false, log))
+		_, err := a.executeToolCalls(t.Context(), []message.ToolCall{
+			{ID: "read1", Name: "view", Input: "50"},
+			{ID: "read2", Name: "view", Input: "20"},
+			{ID: "write", Name: "edit", Input: "0"},
+			{ID: "read3", Name: "view", Input: "0"},
+		})
+		require.NoError(t, err)
+		require.Greater(t, log.index("start write"), log.index("end read1"))
+		require.Greater(t, log.index("start write"), log.index("end read2"))
+		require.Greater(t, log.index("start read3"), log.index("end write"))
+	})
+
+	t.Run("cancellation stops the batch", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(t.Context())
+		defer cancel()
+		var started sync.WaitGroup
+		started.Add(2)
+		go func() {
+			started.Wait()
+			cancel()
+		}()
+		log := &toolLog{}
+		a := newToolAgent(
+			&fakeTool{name: "fetch", readOnly: true, run: func(ctx context.Context, call tools.ToolCall) (tools.ToolResponse, error) {
+				started.Done()
+				<-ctx.Done()
+				return tools.ToolResponse{}, ctx.Err()
+			}},
+			sleepTool("edit", false, log),
+		)
+		results, err := a.executeToolCalls(ctx, []message.ToolCall{
+			{ID: "1", Name: "fetch"},
+			{ID: "2", Name: "fetch"},
+			{ID: "3", Name: "edit", Input: "0"},
+		})
+		require.True(t, errors.Is(err, context.Canceled))
+		for i, result := range results {
+			require.Equal(t, cancelledToolResult(fmt.Sprint(i+1)), result)
+		}
+		require.Equal(t, -1, log.index("start 3"), "calls after the batch don't run")
+	})
+}
+
+func TestAgentToolReadOnlyCall(t *testing.T) {
+	t.Parallel()
+
+	readOnly := newToolAgent(&fakeTool{name: "view", readOnly: true})
+	writer := newToolAgent(&fakeTool{name: "edit"})
+	b := &agentTool{agents: map[string]Service{"task": readOnly, "fixer": writer}}
+
+	require.False(t, b.ReadOnly(), "not every agent is read-only")
+	require.True(t, b.ReadOnlyCall(`{"prompt":"Find the config loader"}`))
+	require.True(t, b.ReadOnlyCall(`{"prompt":"Find the config loader","agent":"task"}`))
+	require.False(t, b.ReadOnlyCall(`{"prompt":"Fix the config loader","agent":"fixer"}`))
+	require.False(t, b.ReadOnlyCall(`{"prompt":"Fix it","agent":"missing"}`))
+	require.False(t, b.ReadOnlyCall(`not json`))
+}
+
+func TestSystemPrompt(t *testing.T) {
+	t.Parallel()
+
+	coder, err := systemPrompt(config.Agent{ID: "coder"}, "test")
+	require.NoError(t, err)
+	require.NotContains(t, coder, "You are a helpful assistant")
+
+	// Custom agents without a prompt file don't pass for the coder.
+	custom, err := systemPrompt(config.Agent{ID: "reviewer"}, "test")
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(custom, "You are a helpful assistant"))
+	require.Contains(t, custom, "<env>")
+}
@@ -0,0 +1,213 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"sync"
+
+	"github.com/charmbracelet/catwalk/pkg/catwalk"
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/history"
+	"github.com/charmbracelet/crush/internal/lsp"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/charmbracelet/crush/internal/permission"
+	"github.com/charmbracelet/crush/internal/pubsub"
+	"github.com/charmbracelet/crush/internal/session"
+	"github.com/charmbracelet/crush/internal/shell"
+	"github.com/charmbracelet/crush/internal/todo"
+)
+
+// coordinator runs each session with the agent selected for it.
+type coordinator struct {
+	sessions session.Service
+	coder    Service
+	agents   map[string]Service
+}
+
+// NewCoordinator creates the agents of the configuration and returns a
+// service running each session with the agent selected for it, the coder
+// unless another one was. The coder can delegate tasks to the other agents,
+// and custom agents to the task agent.
+func NewCoordinator(
+	ctx context.Context,
+	permissions permission.Service,
+	sessions session.Service,
+	messages message.Service,
+	history history.Service,
+	todos todo.Service,
+	shells *shell.SessionShells,
+	jobs *shell.JobManager,
+	lspClients map[string]*lsp.Client,
+) (Service, error) {
+	cfg := config.Get()
+	coderCfg, ok := cfg.Agents["coder"]
+	if !ok {
+		return nil, fmt.Errorf("coder agent configuration is missing")
+	}
+	taskCfg, ok := cfg.Agents["task"]
+	if !ok {
+		return nil, fmt.Errorf("task agent not found in config")
+	}
+
+	task, err := NewAgent(ctx, taskCfg, permissions, sessions, messages, history, todos, shells, jobs, lspClients, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create task agent: %w", err)
+	}
+	subAgents := map[string]Service{"task": task}
+	for _, id := range slices.Sorted(maps.Keys(cfg.Agents)) {
+		if config.IsBuiltinAgent(id) {
+			continue
+		}
+		// Custom agents can delegate to the task agent like the coder, but
+		// not to each other, so they can't call each other in circles.
+		a, err := NewAgent(ctx, cfg.Agents[id], permissions, sessions, messages, history, todos, shells, jobs, lspClients, map[string]Service{"task": task})
+		if err != nil {
+			// A broken custom agent shouldn't keep crush from starting.
+			slog.Error("Failed to create agent", "agent", id, "error", err)
+			continue
+		}
+		subAgents[id] = a
+	}
+
+	coder, err := NewAgent(ctx, coderCfg, permissions, sessions, messages, history, todos, shells, jobs, lspClients, subAgents)
+	if err != nil {
+		return nil, err
+	}
+
+	agents := maps.Clone(subAgents)
+	agents["coder"] = coder
+	return &coordinator{
+		sessions: sessions,
+		coder:    coder,
+		agents:   agents,
+	}, nil
+}
+
+// agentFor returns the agent to run the session with. Prompts sent while the
+// session is busy go to the agent running it, to be queued.
+func (c *coordinator) agentFor(ctx context.Context, sessionID string) (Service, error) {
+	for _, a := range c.agents {
+		if a.IsSessionBusy(sessionID) {
+			return a, nil
+		}
+	}
+	sess, err := c.sessions.Get(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+	if sess.AgentID == "" {
+		return c.coder, nil
+	}
+	a, ok := c.agents[sess.AgentID]
+	if !ok {
+		slog.Warn("Agent of the session not found, using the coder", "agent", sess.AgentID, "session_id", sessionID)
+		return c.coder, nil
+	}
+	return a, nil
+}
+
+func (c *coordinator) Subscribe(ctx context.Context) <-chan pubsub.Event[AgentEvent] {
+	events := make(chan pubsub.Event[AgentEvent])
+	var wg sync.WaitGroup
+	for _, a := range c.agents {
+		wg.Add(1)
+		go func(sub <-chan pubsub.Event[AgentEvent]) {
+			defer wg.Done()
+			for event := range sub {
+				select {
+				case events <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}(a.Subscribe(ctx))
+	}
+	go func() {
+		wg.Wait()
+		close(events)
+	}()
+	return events
+}
+
+// Model returns the model of the coder.
+func (c *coordinator) Model() catwalk.Model {
+	return c.coder.Model()
+}
+
+func (c *coordinator) Run(ctx context.Context, sessionID string, content string, attachments ...message.Attachment) (<-chan AgentEvent, error) {
+	a, err := c.agentFor(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return a.Run(ctx, sessionID, content, attachments...)
+}
+
+func (c *coordinator) Cancel(sessionID string) {
+	for _, a := range c.agents {
+		a.Cancel(sessionID)
+	}
+}
+
+func (c *coordinator) CancelAll() {
+	var wg sync.WaitGroup
+	for _, a := range c.agents {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			a.CancelAll()
+		}()
+	}
+	wg.Wait()
+}
+
+func (c *coordinator) IsSessionBusy(sessionID string) bool {
+	for _, a := range c.agents {
+		if a.IsSessionBusy(sessionID) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *coordinator) IsBusy() bool {
+	for _, a := range c.agents {
+		if a.IsBusy() {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *coordinator) Summarize(ctx context.Context, sessionID string) error {
+	a, err := c.agentFor(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	return a.Summarize(ctx, sessionID)
+}
+
+func (c *coordinator) UpdateModel() error {
+	var errs []error
+	for _, a := range c.agents {
+		errs = append(errs, a.UpdateModel())
+	}
+	return errors.Join(errs...)
+}
+
+func (c *coordinator) QueuedPrompts(sessionID string) int {
+	var n int
+	for _, a := range c.agents {
+		n += a.QueuedPrompts(sessionID)
+	}
+	return n
+}
+
+func (c *coordinator) ClearQueue(sessionID string) {
+	for _, a := range c.agents {
+		a.ClearQueue(sessionID)
+	}
+}
@@ -174,7 +174,7 @@ func (b *McpTool) Run(ctx context.Context, params tools.ToolCall) (tools.ToolRes
 		return tools.ToolResponse{}, fmt.Errorf("session ID and message ID are required for creating a new file")
 	}
 	permissionDescription := fmt.Sprintf("execute %s with the following parameters: %s", b.Info().Name, params.Input)
-	p := b.permissions.Request(
+	if err := b.permissions.Request(
 		permission.CreatePermissionRequest{
 			SessionID:   sessionID,
 			ToolCallID:  params.ID,
@@ -184,9 +184,8 @@ func (b *McpTool) Run(ctx context.Context, params tools.ToolCall) (tools.ToolRes
 			Description: permissionDescription,
 			Params:      params.Input,
 		},
-	)
-	if !p {
-		return tools.ToolResponse{}, permission.ErrorPermissionDenied
+	); err != nil {
+		return tools.ToolResponse{}, err
 	}
 
 	return runTool(ctx, b.mcpName, b.tool.Name, params.Input)
@@ -0,0 +1,34 @@
+package agent
+
+import (
+	"slices"
+
+	"github.com/charmbracelet/crush/internal/llm/tools"
+	"github.com/charmbracelet/crush/internal/message"
+)
+
+// PendingPlan returns the plan submitted in the last turn of a conversation,
+// the messages after the last user message, if any.
+func PendingPlan(msgs []message.Message) (tools.PlanParams, bool) {
+	failed := make(map[string]bool)
+	for _, msg := range slices.Backward(msgs) {
+		switch msg.Role {
+		case message.User:
+			return tools.PlanParams{}, false
+		case message.Tool:
+			for _, result := range msg.ToolResults() {
+				failed[result.ToolCallID] = result.IsError
+			}
+		case message.Assistant:
+			for _, call := range slices.Backward(msg.ToolCalls()) {
+				if isError, ok := failed[call.ID]; call.Name != tools.PlanToolName || !ok || isError {
+					continue
+				}
+				if plan, err := tools.ParsePlan(call.Input); err == nil {
+					return plan, true
+				}
+			}
+		}
+	}
+	return tools.PlanParams{}, false
+}
@@ -0,0 +1,46 @@
+package prompt
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/charmbracelet/crush/internal/config"
+)
+
+// CustomPrompt returns the system prompt of a user-defined agent, read from
+// the given file, followed by the information about the environment and the
+// content of the context paths.
+func CustomPrompt(path string, contextPaths ...string) (string, error) {
+	cwd := config.Get().WorkingDir()
+	path = expandPath(path)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(cwd, path)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read prompt file: %w", err)
+	}
+
+	return withEnvironment(strings.TrimSpace(string(content)), contextPaths), nil
+}
+
+// DefaultPrompt returns the system prompt of user-defined agents without a
+// prompt file, followed by the information about the environment and the
+// content of the context paths.
+func DefaultPrompt(contextPaths ...string) string {
+	return withEnvironment("You are a helpful assistant. Use the tools available to you to carry out the requests of the user.", contextPaths)
+}
+
+// withEnvironment appends the information about the environment and the
+// content of the context paths to the prompt.
+func withEnvironment(agentPrompt string, contextPaths []string) string {
+	basePrompt := fmt.Sprintf("%s\n\n%s\n%s", agentPrompt, getEnvironmentInfo(), lspInformation())
+
+	contextContent := getContextFromPaths(config.Get().WorkingDir(), contextPaths)
+	if contextContent != "" {
+		return fmt.Sprintf("%s\n\n# Project-Specific Context\n Make sure to follow the instructions in the context below\n%s", basePrompt, contextContent)
+	}
+	return basePrompt
+}
@@ -0,0 +1,12 @@
+package prompt
+
+import _ "embed"
+
+//go:embed plan.md
+var planModePrompt []byte
+
+// PlanModePrompt returns the instructions sent along with the prompts of a
+// session in plan mode.
+func PlanModePrompt() string {
+	return string(planModePrompt)
+}
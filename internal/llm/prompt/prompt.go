@@ -32,6 +32,8 @@ func GetPrompt(promptID PromptID, provider string, contextPaths ...string) strin
 		basePrompt = TaskPrompt()
 	case PromptSummarizer:
 		basePrompt = SummarizerPrompt()
+	case PromptDefault:
+		basePrompt = DefaultPrompt(contextPaths...)
 	default:
 		basePrompt = "You are a helpful assistant"
 	}
@@ -1,24 +1,31 @@
 package tools
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
+	"slices"
 	"strings"
 	"time"
 
+	"github.com/charmbracelet/crush/internal/config"
 	"github.com/charmbracelet/crush/internal/permission"
 	"github.com/charmbracelet/crush/internal/shell"
 )
 
 type BashParams struct {
-	Command string `json:"command"`
-	Timeout int    `json:"timeout"`
+	Command         string `json:"command"`
+	Timeout         int    `json:"timeout"`
+	RunInBackground bool   `json:"run_in_background"`
 }
 
 type BashPermissionsParams struct {
-	Command string `json:"command"`
-	Timeout int    `json:"timeout"`
+	Command         string `json:"command"`
+	Timeout         int    `json:"timeout"`
+	RunInBackground bool   `json:"run_in_background,omitempty"`
 }
 
 type BashResponseMetadata struct {
@@ -26,9 +33,15 @@ type BashResponseMetadata struct {
 	EndTime          int64  `json:"end_time"`
 	Output           string `json:"output"`
 	WorkingDirectory string `json:"working_directory"`
+	// JobID is set for commands run in the background.
+	JobID string `json:"job_id,omitempty"`
 }
 type bashTool struct {
 	permissions permission.Service
+	shells      *shell.SessionShells
+	jobs        *shell.JobManager
+	commands    config.Bash
+	blockFuncs  []shell.BlockFunc
 	workingDir  string
 }
 
@@ -114,9 +127,12 @@ var bannedCommands = []string{
 	"ufw",
 }
 
-func bashDescription() string {
-	bannedCommandsStr := strings.Join(bannedCommands, ", ")
-	return fmt.Sprintf(`Executes a given bash command in a persistent shell session with optional timeout, ensuring proper handling and security measures.
+func bashDescription(commands config.Bash) string {
+	bannedCommandsStr := strings.Join(slices.Concat(bannedCommands, commands.BannedCommands), ", ")
+	if len(commands.AllowedCommands) > 0 {
+		bannedCommandsStr += ". These are allowed anyway: " + strings.Join(commands.AllowedCommands, ", ")
+	}
+	return fmt.Sprintf(`Executes a given bash command in the persistent shell of the session with optional timeout, ensuring proper handling and security measures.
 
 CROSS-PLATFORM SHELL SUPPORT:
 * This tool uses a shell interpreter (mvdan/sh) that mimics the Bash language,
@@ -154,7 +170,7 @@ Usage notes:
 - You can specify an optional timeout in milliseconds (up to 600000ms / 10 minutes). If not specified, commands will timeout after 30 minutes.
 - VERY IMPORTANT: You MUST avoid using search commands like 'find' and 'grep'. Instead use Grep, Glob, or Agent tools to search. You MUST avoid read tools like 'cat', 'head', 'tail', and 'ls', and use FileRead and LS tools to read files.
 - When issuing multiple commands, use the ';' or '&&' operator to separate them. DO NOT use newlines (newlines are ok in quoted strings).
-- IMPORTANT: All commands share the same shell session. Shell state (environment variables, virtual environments, current directory, etc.) persist between commands. For example, if you set an environment variable as part of a command, the environment variable will persist for subsequent commands.
+- IMPORTANT: All commands of the session share the same shell. Shell state (environment variables, virtual environments, current directory, etc.) persist between commands. For example, if you set an environment variable as part of a command, the environment variable will persist for subsequent commands.
 - Try to maintain your current working directory throughout the session by using absolute paths and avoiding usage of 'cd'. You may use 'cd' if the User explicitly requests it.
 <good-example>
 pytest /foo/bar/tests
@@ -163,6 +179,17 @@ pytest /foo/bar/tests
 cd /foo/bar && pytest tests
 </bad-example>
 
+# Running commands in the background
+
+Set run_in_background to run commands that don't finish on their own or take a long time, like dev servers, file watchers or long test suites, and keep working while they run:
+- The tool returns right away with the ID of the job running the command, which has no timeout
+- Read what the job printed since you last checked with the job_output tool, wait for it to finish with the job_wait tool and stop it with the job_kill tool
+- The job starts in the current working directory and environment of the shell, but its own changes to them, like 'cd' or 'export', aren't kept
+- Kill the jobs you no longer need; they're also killed when Crush exits
+<example>
+Start the server with run_in_background, wait for it to listen with job_wait and a short timeout, then query it with the fetch tool.
+</example>
+
 # Committing changes with git
 
 When the user asks you to create a new git commit, follow these steps carefully:
@@ -272,7 +299,24 @@ Important:
 - Never update git config`, bannedCommandsStr, MaxOutputLength)
 }
 
-func blockFuncs() []shell.BlockFunc {
+// blockFuncs returns the functions blocking the built-in banned commands and
+// the configured ones, unless they're allowed by the configuration.
+func blockFuncs(commands config.Bash) []shell.BlockFunc {
+	funcs := slices.Concat(defaultBlockFuncs(), []shell.BlockFunc{shell.PatternsBlocker(commands.BannedCommands)})
+	if len(commands.AllowedCommands) == 0 {
+		return funcs
+	}
+	allowed := shell.PatternsBlocker(commands.AllowedCommands)
+	return []shell.BlockFunc{
+		func(args []string) bool {
+			return !allowed(args) && slices.ContainsFunc(funcs, func(blocked shell.BlockFunc) bool {
+				return blocked(args)
+			})
+		},
+	}
+}
+
+func defaultBlockFuncs() []shell.BlockFunc {
 	return []shell.BlockFunc{
 		shell.CommandsBlocker(bannedCommands),
 
@@ -304,13 +348,17 @@ func blockFuncs() []shell.BlockFunc {
 	}
 }
 
-func NewBashTool(permission permission.Service, workingDir string) BaseTool {
-	// Set up command blocking on the persistent shell
-	persistentShell := shell.GetPersistentShell(workingDir)
-	persistentShell.SetBlockFuncs(blockFuncs())
+func NewBashTool(permission permission.Service, shells *shell.SessionShells, jobs *shell.JobManager, commands config.Bash, workingDir string) BaseTool {
+	// Set up command blocking on the shells of the sessions
+	funcs := blockFuncs(commands)
+	shells.SetBlockFuncs(funcs)
 
 	return &bashTool{
 		permissions: permission,
+		shells:      shells,
+		jobs:        jobs,
+		commands:    commands,
+		blockFuncs:  funcs,
 		workingDir:  workingDir,
 	}
 }
@@ -322,7 +370,7 @@ func (b *bashTool) Name() string {
 func (b *bashTool) Info() ToolInfo {
 	return ToolInfo{
 		Name:        BashToolName,
-		Description: bashDescription(),
+		Description: bashDescription(b.commands),
 		Parameters: map[string]any{
 			"command": map[string]any{
 				"type":        "string",
@@ -332,6 +380,10 @@ func (b *bashTool) Info() ToolInfo {
 				"type":        "number",
 				"description": "Optional timeout in milliseconds (max 600000)",
 			},
+			"run_in_background": map[string]any{
+				"type":        "boolean",
+				"description": "Run the command in the background and return the ID of its job right away",
+			},
 		},
 		Required: []string{"command"},
 	}
@@ -353,52 +405,85 @@ func (b *bashTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error)
 		return NewTextErrorResponse("missing command"), nil
 	}
 
-	isSafeReadOnly := false
-	cmdLower := strings.ToLower(params.Command)
+	if IsPlanMode(ctx) && !isReadOnlyCommand(params.Command) {
+		return NewTextErrorResponse("Only read-only commands can be run in plan mode, like ls, git status or git diff, without redirections to files. Leave anything else for when the plan is approved."), nil
+	}
 
-	for _, safe := range safeCommands {
-		if strings.HasPrefix(cmdLower, safe) {
-			if len(cmdLower) == len(safe) || cmdLower[len(safe)] == ' ' || cmdLower[len(safe)] == '-' {
-				isSafeReadOnly = true
-				break
-			}
-		}
+	// Banned commands are refused before asking for permission, but are
+	// blocked when running too, as some are only known then.
+	if blocked := b.blockedCommand(params.Command); blocked != "" {
+		return NewTextErrorResponse(fmt.Sprintf("command is not allowed for security reasons: %s", blocked)), nil
 	}
 
 	sessionID, messageID := GetContextValues(ctx)
 	if sessionID == "" || messageID == "" {
 		return ToolResponse{}, fmt.Errorf("session ID and message ID are required for executing shell command")
 	}
-	if !isSafeReadOnly {
-		p := b.permissions.Request(
-			permission.CreatePermissionRequest{
-				SessionID:   sessionID,
-				Path:        b.workingDir,
-				ToolCallID:  call.ID,
-				ToolName:    BashToolName,
-				Action:      "execute",
-				Description: fmt.Sprintf("Execute command: %s", params.Command),
-				Params: BashPermissionsParams{
-					Command: params.Command,
-				},
+	// Safe commands don't prompt, but the deny and ask rules of the
+	// permission policy still apply to them.
+	if err := b.permissions.Request(
+		permission.CreatePermissionRequest{
+			SessionID:   sessionID,
+			Path:        b.workingDir,
+			Command:     params.Command,
+			ToolCallID:  call.ID,
+			ToolName:    BashToolName,
+			Action:      "execute",
+			Description: fmt.Sprintf("Execute command: %s", params.Command),
+			Params: BashPermissionsParams{
+				Command:         params.Command,
+				RunInBackground: params.RunInBackground,
 			},
-		)
-		if !p {
-			return ToolResponse{}, permission.ErrorPermissionDenied
-		}
+			ReadOnly: isSafeCommand(params.Command, b.commands.SafeCommands),
+		},
+	); err != nil {
+		return ToolResponse{}, err
 	}
 	startTime := time.Now()
+	sessionShell := b.shells.Get(ctx, sessionID)
+	if params.RunInBackground {
+		job := b.jobs.Start(sessionID, sessionShell, params.Command)
+		metadata := BashResponseMetadata{
+			StartTime:        startTime.UnixMilli(),
+			EndTime:          time.Now().UnixMilli(),
+			WorkingDirectory: sessionShell.GetWorkingDir(),
+			JobID:            job.ID,
+		}
+		return WithResponseMetadata(NewTextResponse(fmt.Sprintf(
+			"The command is running in the background as job %s. Read its output with the %s tool, wait for it with the %s tool and stop it with the %s tool.",
+			job.ID, JobOutputToolName, JobWaitToolName, JobKillToolName,
+		)), metadata), nil
+	}
+
 	if params.Timeout > 0 {
 		var cancel context.CancelFunc
 		ctx, cancel = context.WithTimeout(ctx, time.Duration(params.Timeout)*time.Millisecond)
 		defer cancel()
 	}
 
-	persistentShell := shell.GetPersistentShell(b.workingDir)
-	stdout, stderr, err := persistentShell.Exec(ctx, params.Command)
+	var stdout, stderr string
+	var err error
+	if output := GetOutputFunc(ctx); output != nil {
+		// The output is streamed as it's produced, while the result still
+		// has all of it, truncated.
+		var stdoutBuf, stderrBuf bytes.Buffer
+		live := newLiveOutput(output)
+		err = sessionShell.ExecStream(ctx, params.Command, live.writer(&stdoutBuf), live.writer(&stderrBuf))
+		live.Close()
+		stdout, stderr = stdoutBuf.String(), stderrBuf.String()
+	} else {
+		stdout, stderr, err = sessionShell.Exec(ctx, params.Command)
+	}
+	if err := b.shells.Save(context.WithoutCancel(ctx), sessionID); err != nil {
+		slog.Error("Failed to save the shell of the session", "session_id", sessionID, "error", err)
+	}
 
 	// Get the current working directory after command execution
-	currentWorkingDir := persistentShell.GetWorkingDir()
+	currentWorkingDir := sessionShell.GetWorkingDir()
+	var sandboxErr *shell.SandboxError
+	if errors.As(err, &sandboxErr) {
+		err = sandboxErr.Err
+	}
 	interrupted := shell.IsInterrupt(err)
 	exitCode := shell.ExitCode(err)
 	if exitCode == 0 && !interrupted && err != nil {
@@ -435,6 +520,10 @@ func (b *bashTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error)
 		stdout += "\n" + errorMessage
 	}
 
+	if sandboxErr != nil {
+		stdout += "\n\n" + sandboxViolationsMessage(sandboxErr.Violations)
+	}
+
 	metadata := BashResponseMetadata{
 		StartTime:        startTime.UnixMilli(),
 		EndTime:          time.Now().UnixMilli(),
@@ -448,6 +537,35 @@ func (b *bashTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error)
 	return WithResponseMetadata(NewTextResponse(stdout), metadata), nil
 }
 
+// blockedCommand returns the first command of the command line that is
+// banned, if any.
+func (b *bashTool) blockedCommand(command string) string {
+	line, err := shell.ParseCommandLine(command)
+	if err != nil {
+		return ""
+	}
+	for _, args := range line.Commands {
+		if slices.ContainsFunc(b.blockFuncs, func(blocked shell.BlockFunc) bool { return blocked(args) }) {
+			return strings.Join(args, " ")
+		}
+	}
+	return ""
+}
+
+// sandboxViolationsMessage tells the model what the sandbox didn't allow, so
+// that it asks the user instead of trying to work around it.
+func sandboxViolationsMessage(violations []shell.SandboxViolation) string {
+	data, err := json.MarshalIndent(violations, "", "  ")
+	if err != nil {
+		slog.Error("Failed to encode the sandbox violations", "error", err)
+		data = []byte("[]")
+	}
+	return fmt.Sprintf(`<sandbox_violations>
+%s
+</sandbox_violations>
+The command runs in a sandbox that only lets it write to the working directory and the temporary directories, and that may block the network. Do not try to work around the sandbox: ask the user to run the command themselves or to allow what it needs in the sandbox configuration.`, data)
+}
+
 func truncateOutput(content string) string {
 	if len(content) <= MaxOutputLength {
 		return content
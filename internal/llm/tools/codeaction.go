@@ -0,0 +1,268 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/crush/internal/history"
+	"github.com/charmbracelet/crush/internal/lsp"
+	"github.com/charmbracelet/crush/internal/lsp/protocol"
+	"github.com/charmbracelet/crush/internal/permission"
+)
+
+type CodeActionParams struct {
+	FilePath string `json:"file_path"`
+	Line     int    `json:"line,omitempty"`
+	EndLine  int    `json:"end_line,omitempty"`
+	Kind     string `json:"kind,omitempty"`
+	Title    string `json:"title,omitempty"`
+}
+
+type codeActionTool struct {
+	lspEditor
+}
+
+// lspCodeAction is a code action along with the client that offered it.
+type lspCodeAction struct {
+	client *lsp.Client
+	action protocol.CodeAction
+}
+
+const (
+	CodeActionToolName    = "code_action"
+	codeActionDescription = `List and apply the code actions offered by the language server (LSP), such as quick fixes, refactorings and source actions.
+
+WHEN TO USE THIS TOOL:
+- Use to fix diagnostics the language server knows how to fix (e.g. add a missing import, remove an unused variable)
+- Use to organize imports or apply other source actions to a whole file
+- Use to run refactorings offered by the server (e.g. extract function, inline variable)
+
+HOW TO USE:
+- First call it without a title to list the actions available for a file, or for a range of lines
+- Then call it again with the exact title of the action to apply it
+- Use kind to only consider actions of a given kind, for example "quickfix", "refactor" or "source.organizeImports"
+- The user is shown the changes to every file before they are applied
+
+LIMITATIONS:
+- Only works for files handled by a configured LSP server
+- Actions that only run a server command, or that create, move or delete files, can't be applied
+- Line numbers are 1-based`
+)
+
+func NewCodeActionTool(lspClients map[string]*lsp.Client, allowedLSP []string, permissions permission.Service, files history.Service, workingDir string) BaseTool {
+	return &codeActionTool{
+		lspEditor: lspEditor{
+			lspClients:  lspClientSet{clients: lspClients, allowed: allowedLSP},
+			permissions: permissions,
+			files:       files,
+			workingDir:  workingDir,
+		},
+	}
+}
+
+func (c *codeActionTool) Name() string {
+	return CodeActionToolName
+}
+
+func (c *codeActionTool) Info() ToolInfo {
+	return ToolInfo{
+		Name:        CodeActionToolName,
+		Description: codeActionDescription,
+		Parameters: map[string]any{
+			"file_path": map[string]any{
+				"type":        "string",
+				"description": "The path to the file to get code actions for",
+			},
+			"line": map[string]any{
+				"type":        "integer",
+				"description": "The first line of the range to get code actions for (1-based). Leave empty for the whole file",
+			},
+			"end_line": map[string]any{
+				"type":        "integer",
+				"description": "The last line of the range to get code actions for (1-based). Defaults to line",
+			},
+			"kind": map[string]any{
+				"type":        "string",
+				"description": "Only consider actions of this kind, e.g. quickfix, refactor, source.organizeImports",
+			},
+			"title": map[string]any{
+				"type":        "string",
+				"description": "The title of the action to apply. Leave empty to list the available actions",
+			},
+		},
+		Required: []string{"file_path"},
+	}
+}
+
+func (c *codeActionTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
+	var params CodeActionParams
+	if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("error parsing parameters: %s", err)), nil
+	}
+
+	filePath, err := resolveFilePath(c.workingDir, params.FilePath)
+	if err != nil {
+		return NewTextErrorResponse(err.Error()), nil
+	}
+
+	actions, err := c.codeActions(ctx, filePath, params)
+	if err != nil {
+		return NewTextErrorResponse(err.Error()), nil
+	}
+	if len(actions) == 0 {
+		return NewTextResponse("No code actions available"), nil
+	}
+
+	if params.Title == "" {
+		var output strings.Builder
+		fmt.Fprintf(&output, "Available code actions for %s:\n", displayPath(c.workingDir, protocol.URIFromPath(filePath)))
+		for _, a := range actions {
+			output.WriteString("- ")
+			if a.action.Kind != "" {
+				fmt.Fprintf(&output, "[%s] ", a.action.Kind)
+			}
+			output.WriteString(a.action.Title)
+			if a.action.IsPreferred {
+				output.WriteString(" (preferred)")
+			}
+			if a.action.Disabled != nil {
+				fmt.Fprintf(&output, " (disabled: %s)", a.action.Disabled.Reason)
+			}
+			output.WriteString("\n")
+		}
+		output.WriteString("\nCall this tool again with the title of an action to apply it.")
+		return NewTextResponse(output.String()), nil
+	}
+
+	selected, ok := findCodeAction(actions, params.Title)
+	if !ok {
+		titles := make([]string, 0, len(actions))
+		for _, a := range actions {
+			titles = append(titles, fmt.Sprintf("%q", a.action.Title))
+		}
+		return NewTextErrorResponse(fmt.Sprintf("no code action titled %q, available actions: %s", params.Title, strings.Join(titles, ", "))), nil
+	}
+	if selected.action.Disabled != nil {
+		return NewTextErrorResponse(fmt.Sprintf("the code action is disabled: %s", selected.action.Disabled.Reason)), nil
+	}
+
+	action := selected.action
+	if action.Edit == nil && action.Data != nil {
+		resolved, err := selected.client.ResolveCodeAction(ctx, action)
+		if err != nil {
+			return NewTextErrorResponse(fmt.Sprintf("error resolving code action: %s", err)), nil
+		}
+		action = resolved
+	}
+	if action.Edit == nil {
+		return NewTextErrorResponse("the code action runs a server command instead of editing files, which is not supported"), nil
+	}
+
+	response, err := c.apply(ctx, call, CodeActionToolName, action.Title, *action.Edit)
+	if err != nil || response.IsError {
+		return response, err
+	}
+	if action.Command != nil {
+		response.Content += fmt.Sprintf("\nNote: the follow-up command %q of the action was not executed.", action.Command.Title)
+	}
+	return response, nil
+}
+
+// codeActions returns the code actions offered by every client handling
+// the file for the requested range.
+func (c *codeActionTool) codeActions(ctx context.Context, filePath string, params CodeActionParams) ([]lspCodeAction, error) {
+	clients := c.lspClients.forFile(filePath)
+	if len(clients) == 0 {
+		return nil, fmt.Errorf("no LSP server available for %s", filePath)
+	}
+
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading file: %w", err)
+	}
+	lines := strings.Split(string(content), "\n")
+
+	startLine, endLine := 1, len(lines)
+	if params.Line > 0 {
+		startLine = params.Line
+		endLine = max(params.EndLine, params.Line)
+	}
+	if startLine > len(lines) {
+		return nil, fmt.Errorf("line %d is out of range, the file has %d lines", startLine, len(lines))
+	}
+	endLine = min(endLine, len(lines))
+	rng := protocol.Range{
+		Start: protocol.Position{Line: uint32(startLine - 1)},
+		End:   lspPosition(lines[endLine-1], endLine-1, len([]rune(lines[endLine-1]))),
+	}
+
+	var only []protocol.CodeActionKind
+	if params.Kind != "" {
+		only = []protocol.CodeActionKind{protocol.CodeActionKind(params.Kind)}
+	}
+
+	var (
+		actions []lspCodeAction
+		errs    []error
+		uri     = protocol.URIFromPath(filePath)
+	)
+	for _, client := range clients {
+		if err := client.OpenFileOnDemand(ctx, filePath); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", client.GetName(), err))
+			continue
+		}
+
+		// Servers need the diagnostics in the range to offer quick fixes.
+		var diagnostics []protocol.Diagnostic
+		for _, d := range client.GetFileDiagnostics(uri) {
+			if d.Range.Start.Line <= rng.End.Line && d.Range.End.Line >= rng.Start.Line {
+				diagnostics = append(diagnostics, d)
+			}
+		}
+
+		result, err := client.CodeAction(ctx, protocol.CodeActionParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+			Range:        rng,
+			Context: protocol.CodeActionContext{
+				Diagnostics: diagnostics,
+				Only:        only,
+			},
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", client.GetName(), err))
+			continue
+		}
+		for _, item := range result {
+			switch v := item.Value.(type) {
+			case protocol.CodeAction:
+				actions = append(actions, lspCodeAction{client: client, action: v})
+			case protocol.Command:
+				actions = append(actions, lspCodeAction{client: client, action: protocol.CodeAction{Title: v.Title, Command: &v}})
+			}
+		}
+	}
+	if len(actions) == 0 {
+		return nil, errors.Join(errs...)
+	}
+	return actions, nil
+}
+
+// findCodeAction looks up an action by title, ignoring case if there's no
+// exact match.
+func findCodeAction(actions []lspCodeAction, title string) (lspCodeAction, bool) {
+	for _, a := range actions {
+		if a.action.Title == title {
+			return a, true
+		}
+	}
+	for _, a := range actions {
+		if strings.EqualFold(a.action.Title, title) {
+			return a, true
+		}
+	}
+	return lspCodeAction{}, false
+}
@@ -0,0 +1,108 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/crush/internal/lsp"
+	"github.com/charmbracelet/crush/internal/lsp/protocol"
+)
+
+type DefinitionParams = LSPPositionParams
+
+type definitionTool struct {
+	lspClients lspClientSet
+	workingDir string
+}
+
+const (
+	DefinitionToolName    = "definition"
+	definitionDescription = `Find where a symbol is defined using the language server (LSP).
+
+WHEN TO USE THIS TOOL:
+- Use when you need to find the declaration of a function, type, variable, method or package
+- Prefer it over grep when the symbol name is common or ambiguous, since the language server resolves the actual symbol being referenced
+
+HOW TO USE:
+- Provide the file where the symbol is used, and either:
+  - the symbol name (optionally with the line it appears on), or
+  - the exact line and column of the symbol
+- Results list the definition locations as path:line:column, followed by the source line
+
+LIMITATIONS:
+- Only works for files handled by a configured LSP server
+- Line and column numbers are 1-based
+
+TIPS:
+- Use the View tool with the returned line as offset to read the full definition
+- Use the References tool to find all usages of a symbol`
+)
+
+func NewDefinitionTool(lspClients map[string]*lsp.Client, allowedLSP []string, workingDir string) BaseTool {
+	return &definitionTool{
+		lspClients: lspClientSet{clients: lspClients, allowed: allowedLSP},
+		workingDir: workingDir,
+	}
+}
+
+func (d *definitionTool) Name() string {
+	return DefinitionToolName
+}
+
+func (d *definitionTool) ReadOnly() bool {
+	return true
+}
+
+func (d *definitionTool) Info() ToolInfo {
+	return ToolInfo{
+		Name:        DefinitionToolName,
+		Description: definitionDescription,
+		Parameters:  lspPositionParameters(),
+		Required:    []string{"file_path"},
+	}
+}
+
+func (d *definitionTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
+	var params DefinitionParams
+	if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("error parsing parameters: %s", err)), nil
+	}
+
+	filePath, err := resolveFilePath(d.workingDir, params.FilePath)
+	if err != nil {
+		return NewTextErrorResponse(err.Error()), nil
+	}
+
+	var locations []protocol.Location
+	err = d.lspClients.queryPosition(ctx, filePath, params, func(client *lsp.Client, pos protocol.TextDocumentPositionParams) (bool, error) {
+		result, err := client.Definition(ctx, protocol.DefinitionParams{TextDocumentPositionParams: pos})
+		if err != nil {
+			return false, err
+		}
+		locations = toLocations(result.Value)
+		return len(locations) > 0, nil
+	})
+	if err != nil {
+		return NewTextErrorResponse(err.Error()), nil
+	}
+	if len(locations) == 0 {
+		return NewTextResponse("No definition found"), nil
+	}
+
+	lines := lineCache{}
+	var output strings.Builder
+	if len(locations) == 1 {
+		output.WriteString("Found 1 definition:\n")
+	} else {
+		fmt.Fprintf(&output, "Found %d definitions:\n", len(locations))
+	}
+	for _, loc := range locations {
+		fmt.Fprintf(&output, "\n%s\n", formatLocation(d.workingDir, loc.URI, loc.Range.Start))
+		if line := lines.line(loc.URI, loc.Range.Start.Line); line != "" {
+			fmt.Fprintf(&output, "  %s\n", line)
+		}
+	}
+	return NewTextResponse(output.String()), nil
+}
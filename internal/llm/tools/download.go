@@ -137,19 +137,18 @@ func (t *downloadTool) Run(ctx context.Context, call ToolCall) (ToolResponse, er
 		return ToolResponse{}, fmt.Errorf("session ID and message ID are required for downloading files")
 	}
 
-	p := t.permissions.Request(
+	if err := t.permissions.Request(
 		permission.CreatePermissionRequest{
 			SessionID:   sessionID,
 			Path:        filePath,
+			Files:       []string{filePath},
 			ToolName:    DownloadToolName,
 			Action:      "download",
 			Description: fmt.Sprintf("Download file from URL: %s to %s", params.URL, filePath),
 			Params:      DownloadPermissionsParams(params),
 		},
-	)
-
-	if !p {
-		return ToolResponse{}, permission.ErrorPermissionDenied
+	); err != nil {
+		return ToolResponse{}, err
 	}
 
 	// Handle timeout with context
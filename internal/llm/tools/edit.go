@@ -217,10 +217,11 @@ func (e *editTool) createNewFile(ctx context.Context, filePath, content string,
 		content,
 		strings.TrimPrefix(filePath, e.workingDir),
 	)
-	p := e.permissions.Request(
+	if err := e.permissions.Request(
 		permission.CreatePermissionRequest{
 			SessionID:   sessionID,
 			Path:        fsext.PathOrPrefix(filePath, e.workingDir),
+			Files:       []string{filePath},
 			ToolCallID:  call.ID,
 			ToolName:    EditToolName,
 			Action:      "write",
@@ -231,9 +232,8 @@ func (e *editTool) createNewFile(ctx context.Context, filePath, content string,
 				NewContent: content,
 			},
 		},
-	)
-	if !p {
-		return ToolResponse{}, permission.ErrorPermissionDenied
+	); err != nil {
+		return ToolResponse{}, err
 	}
 
 	err = os.WriteFile(filePath, []byte(content), 0o644)
@@ -242,7 +242,7 @@ func (e *editTool) createNewFile(ctx context.Context, filePath, content string,
 	}
 
 	// File can't be in the history so we create a new file history
-	_, err = e.files.Create(ctx, sessionID, filePath, "")
+	_, err = e.files.CreateMissing(ctx, sessionID, filePath)
 	if err != nil {
 		// Log error but don't fail the operation
 		return ToolResponse{}, fmt.Errorf("error creating file history: %w", err)
@@ -338,10 +338,11 @@ func (e *editTool) deleteContent(ctx context.Context, filePath, oldString string
 		strings.TrimPrefix(filePath, e.workingDir),
 	)
 
-	p := e.permissions.Request(
+	if err := e.permissions.Request(
 		permission.CreatePermissionRequest{
 			SessionID:   sessionID,
 			Path:        fsext.PathOrPrefix(filePath, e.workingDir),
+			Files:       []string{filePath},
 			ToolCallID:  call.ID,
 			ToolName:    EditToolName,
 			Action:      "write",
@@ -352,9 +353,8 @@ func (e *editTool) deleteContent(ctx context.Context, filePath, oldString string
 				NewContent: newContent,
 			},
 		},
-	)
-	if !p {
-		return ToolResponse{}, permission.ErrorPermissionDenied
+	); err != nil {
+		return ToolResponse{}, err
 	}
 
 	if isCrlf {
@@ -473,10 +473,11 @@ func (e *editTool) replaceContent(ctx context.Context, filePath, oldString, newS
 		strings.TrimPrefix(filePath, e.workingDir),
 	)
 
-	p := e.permissions.Request(
+	if err := e.permissions.Request(
 		permission.CreatePermissionRequest{
 			SessionID:   sessionID,
 			Path:        fsext.PathOrPrefix(filePath, e.workingDir),
+			Files:       []string{filePath},
 			ToolCallID:  call.ID,
 			ToolName:    EditToolName,
 			Action:      "write",
@@ -487,9 +488,8 @@ func (e *editTool) replaceContent(ctx context.Context, filePath, oldString, newS
 				NewContent: newContent,
 			},
 		},
-	)
-	if !p {
-		return ToolResponse{}, permission.ErrorPermissionDenied
+	); err != nil {
+		return ToolResponse{}, err
 	}
 
 	if isCrlf {
@@ -85,6 +85,10 @@ func (t *fetchTool) Name() string {
 	return FetchToolName
 }
 
+func (t *fetchTool) ReadOnly() bool {
+	return true
+}
+
 func (t *fetchTool) Info() ToolInfo {
 	return ToolInfo{
 		Name:        FetchToolName,
@@ -132,7 +136,7 @@ func (t *fetchTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error
 		return ToolResponse{}, fmt.Errorf("session ID and message ID are required for creating a new file")
 	}
 
-	p := t.permissions.Request(
+	if err := t.permissions.Request(
 		permission.CreatePermissionRequest{
 			SessionID:   sessionID,
 			Path:        t.workingDir,
@@ -142,10 +146,8 @@ func (t *fetchTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error
 			Description: fmt.Sprintf("Fetch content from URL: %s", params.URL),
 			Params:      FetchPermissionsParams(params),
 		},
-	)
-
-	if !p {
-		return ToolResponse{}, permission.ErrorPermissionDenied
+	); err != nil {
+		return ToolResponse{}, err
 	}
 
 	// Handle timeout with context
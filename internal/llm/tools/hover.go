@@ -0,0 +1,90 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/crush/internal/lsp"
+	"github.com/charmbracelet/crush/internal/lsp/protocol"
+)
+
+type HoverParams = LSPPositionParams
+
+type hoverTool struct {
+	lspClients lspClientSet
+	workingDir string
+}
+
+const (
+	HoverToolName    = "hover"
+	hoverDescription = `Show the type signature and documentation of a symbol using the language server (LSP).
+
+WHEN TO USE THIS TOOL:
+- Use when you need the exact signature of a function or method, the type of a variable, or the documentation of a symbol
+- Useful to check how to call an API without reading its whole source file
+
+HOW TO USE:
+- Provide the file where the symbol is used, and either:
+  - the symbol name (optionally with the line it appears on), or
+  - the exact line and column of the symbol
+- Returns the information the language server shows when hovering the symbol in an editor
+
+LIMITATIONS:
+- Only works for files handled by a configured LSP server
+- Line and column numbers are 1-based`
+)
+
+func NewHoverTool(lspClients map[string]*lsp.Client, allowedLSP []string, workingDir string) BaseTool {
+	return &hoverTool{
+		lspClients: lspClientSet{clients: lspClients, allowed: allowedLSP},
+		workingDir: workingDir,
+	}
+}
+
+func (h *hoverTool) Name() string {
+	return HoverToolName
+}
+
+func (h *hoverTool) ReadOnly() bool {
+	return true
+}
+
+func (h *hoverTool) Info() ToolInfo {
+	return ToolInfo{
+		Name:        HoverToolName,
+		Description: hoverDescription,
+		Parameters:  lspPositionParameters(),
+		Required:    []string{"file_path"},
+	}
+}
+
+func (h *hoverTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
+	var params HoverParams
+	if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("error parsing parameters: %s", err)), nil
+	}
+
+	filePath, err := resolveFilePath(h.workingDir, params.FilePath)
+	if err != nil {
+		return NewTextErrorResponse(err.Error()), nil
+	}
+
+	var contents string
+	err = h.lspClients.queryPosition(ctx, filePath, params, func(client *lsp.Client, pos protocol.TextDocumentPositionParams) (bool, error) {
+		result, err := client.Hover(ctx, protocol.HoverParams{TextDocumentPositionParams: pos})
+		if err != nil {
+			return false, err
+		}
+		contents = strings.TrimSpace(result.Contents.Value)
+		return contents != "", nil
+	})
+	if err != nil {
+		return NewTextErrorResponse(err.Error()), nil
+	}
+	if contents == "" {
+		return NewTextResponse("No hover information found"), nil
+	}
+	return NewTextResponse(contents), nil
+}
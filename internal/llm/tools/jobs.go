@@ -0,0 +1,168 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/shell"
+)
+
+type JobParams struct {
+	JobID string `json:"job_id"`
+	// Timeout is how long to wait for the job, in milliseconds. Only used by
+	// the job_wait tool.
+	Timeout int `json:"timeout,omitempty"`
+}
+
+type JobResponseMetadata struct {
+	JobID    string `json:"job_id"`
+	Command  string `json:"command"`
+	Running  bool   `json:"running"`
+	ExitCode int    `json:"exit_code"`
+	Killed   bool   `json:"killed"`
+	Output   string `json:"output"`
+}
+
+const (
+	JobOutputToolName = "job_output"
+	JobWaitToolName   = "job_wait"
+	JobKillToolName   = "job_kill"
+
+	DefaultJobWaitTimeout = 30 * 1000 // 30 seconds in milliseconds
+
+	jobOutputDescription = `Returns the output of a job started with the bash tool in the background, printed since it was last read, and whether the job is still running.
+
+Use it to check on servers, watchers and long commands while working on something else. Call it again to get the output that comes next.`
+
+	jobWaitDescription = `Waits for a job started with the bash tool in the background to finish, for up to the given timeout, then returns its output printed since it was last read and whether it's still running.
+
+Use it when there is nothing else to do until the job is done, or with a short timeout to let a server start before using it. The timeout is in milliseconds, 30000 (30 seconds) by default and 600000 (10 minutes) at most.`
+
+	jobKillDescription = `Stops a job started with the bash tool in the background, and returns the output it printed since it was last read.
+
+Kill the jobs you no longer need, like servers once you're done with them.`
+)
+
+type jobTool struct {
+	jobs        *shell.JobManager
+	name        string
+	description string
+}
+
+// NewJobOutputTool returns the tool reading the output of background jobs.
+func NewJobOutputTool(jobs *shell.JobManager) BaseTool {
+	return &jobTool{jobs: jobs, name: JobOutputToolName, description: jobOutputDescription}
+}
+
+// NewJobWaitTool returns the tool waiting for background jobs to finish.
+func NewJobWaitTool(jobs *shell.JobManager) BaseTool {
+	return &jobTool{jobs: jobs, name: JobWaitToolName, description: jobWaitDescription}
+}
+
+// NewJobKillTool returns the tool stopping background jobs.
+func NewJobKillTool(jobs *shell.JobManager) BaseTool {
+	return &jobTool{jobs: jobs, name: JobKillToolName, description: jobKillDescription}
+}
+
+func (j *jobTool) Name() string {
+	return j.name
+}
+
+// ReadOnly reports whether the tool leaves the jobs running as they are.
+func (j *jobTool) ReadOnly() bool {
+	return j.name != JobKillToolName
+}
+
+func (j *jobTool) Info() ToolInfo {
+	parameters := map[string]any{
+		"job_id": map[string]any{
+			"type":        "string",
+			"description": "The ID of the job, as returned by the bash tool",
+		},
+	}
+	if j.name == JobWaitToolName {
+		parameters["timeout"] = map[string]any{
+			"type":        "number",
+			"description": "How long to wait for the job to finish in milliseconds (default 30000, max 600000)",
+		}
+	}
+	return ToolInfo{
+		Name:        j.name,
+		Description: j.description,
+		Parameters:  parameters,
+		Required:    []string{"job_id"},
+	}
+}
+
+func (j *jobTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
+	var params JobParams
+	if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("error parsing parameters: %s", err)), nil
+	}
+	if params.JobID == "" {
+		return NewTextErrorResponse("job_id is required"), nil
+	}
+
+	// Jobs are only visible to the session that started them.
+	sessionID, _ := GetContextValues(ctx)
+	job, err := j.jobs.Get(params.JobID)
+	if err != nil || job.SessionID != sessionID {
+		return NewTextErrorResponse(fmt.Sprintf("job %s not found", params.JobID)), nil
+	}
+
+	switch j.name {
+	case JobWaitToolName:
+		timeout := params.Timeout
+		if timeout > MaxTimeout {
+			timeout = MaxTimeout
+		} else if timeout <= 0 {
+			timeout = DefaultJobWaitTimeout
+		}
+		job, err = j.jobs.Wait(ctx, job.ID, time.Duration(timeout)*time.Millisecond)
+	case JobKillToolName:
+		job, err = j.jobs.Kill(job.ID)
+	}
+	if err != nil {
+		return ToolResponse{}, err
+	}
+
+	out, err := j.jobs.ReadOutput(job.ID)
+	if err != nil {
+		return ToolResponse{}, err
+	}
+	return jobResponse(job, out), nil
+}
+
+func jobResponse(job shell.Job, out shell.JobOutput) ToolResponse {
+	var sb strings.Builder
+	switch {
+	case job.Running():
+		fmt.Fprintf(&sb, "Job %s is still running.", job.ID)
+	case job.Killed:
+		fmt.Fprintf(&sb, "Job %s was killed.", job.ID)
+	default:
+		fmt.Fprintf(&sb, "Job %s exited with code %d.", job.ID, job.ExitCode)
+	}
+
+	output := truncateOutput(out.Output)
+	switch {
+	case out.Dropped > 0:
+		fmt.Fprintf(&sb, "\n\n... [%d bytes of older output dropped] ...\n%s", out.Dropped, output)
+	case output == "":
+		sb.WriteString(" No new output.")
+	default:
+		sb.WriteString("\n\n" + output)
+	}
+
+	return WithResponseMetadata(NewTextResponse(sb.String()), JobResponseMetadata{
+		JobID:    job.ID,
+		Command:  job.Command,
+		Running:  job.Running(),
+		ExitCode: job.ExitCode,
+		Killed:   job.Killed,
+		Output:   output,
+	})
+}
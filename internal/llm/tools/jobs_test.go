@@ -0,0 +1,45 @@
+package tools
+
+import (
+	"context"
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/shell"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJobTools(t *testing.T) {
+	t.Parallel()
+
+	jobs := shell.NewJobManager()
+	t.Cleanup(jobs.KillAll)
+	sh := shell.NewShell(&shell.Options{WorkingDir: t.TempDir()})
+	ctx := context.WithValue(t.Context(), SessionIDContextKey, "session")
+
+	run := func(tool BaseTool, input string) ToolResponse {
+		resp, err := tool.Run(ctx, ToolCall{Name: tool.Name(), Input: input})
+		require.NoError(t, err)
+		return resp
+	}
+
+	job := jobs.Start("session", sh, "echo started; sleep 60")
+	resp := run(NewJobWaitTool(jobs), `{"job_id":"`+job.ID+`","timeout":100}`)
+	require.Contains(t, resp.Content, "is still running")
+	require.Contains(t, resp.Content, "started")
+
+	resp = run(NewJobOutputTool(jobs), `{"job_id":"`+job.ID+`"}`)
+	require.Contains(t, resp.Content, "No new output.")
+
+	resp = run(NewJobKillTool(jobs), `{"job_id":"`+job.ID+`"}`)
+	require.Contains(t, resp.Content, "was killed")
+
+	done := jobs.Start("session", sh, "exit 4")
+	resp = run(NewJobWaitTool(jobs), `{"job_id":"`+done.ID+`"}`)
+	require.Contains(t, resp.Content, "exited with code 4")
+
+	// Jobs of other sessions can't be reached.
+	other := jobs.Start("other", sh, "true")
+	resp = run(NewJobOutputTool(jobs), `{"job_id":"`+other.ID+`"}`)
+	require.True(t, resp.IsError)
+	require.Contains(t, resp.Content, "not found")
+}
@@ -89,6 +89,10 @@ func (l *lsTool) Name() string {
 	return LSToolName
 }
 
+func (l *lsTool) ReadOnly() bool {
+	return true
+}
+
 func (l *lsTool) Info() ToolInfo {
 	return ToolInfo{
 		Name:        LSToolName,
@@ -150,7 +154,7 @@ func (l *lsTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
 			return ToolResponse{}, fmt.Errorf("session ID and message ID are required for accessing directories outside working directory")
 		}
 
-		granted := l.permissions.Request(
+		if err := l.permissions.Request(
 			permission.CreatePermissionRequest{
 				SessionID:   sessionID,
 				Path:        absSearchPath,
@@ -160,10 +164,8 @@ func (l *lsTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
 				Description: fmt.Sprintf("List directory outside working directory: %s", absSearchPath),
 				Params:      LSPermissionsParams(params),
 			},
-		)
-
-		if !granted {
-			return ToolResponse{}, permission.ErrorPermissionDenied
+		); err != nil {
+			return ToolResponse{}, err
 		}
 	}
 
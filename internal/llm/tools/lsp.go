@@ -0,0 +1,345 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+
+	"github.com/charmbracelet/crush/internal/lsp"
+	"github.com/charmbracelet/crush/internal/lsp/protocol"
+)
+
+// LSPPositionParams are the parameters the LSP tools use to point at a symbol
+// in a file, either by name or by position.
+type LSPPositionParams struct {
+	FilePath string `json:"file_path"`
+	Symbol   string `json:"symbol,omitempty"`
+	Line     int    `json:"line,omitempty"`
+	Column   int    `json:"column,omitempty"`
+}
+
+// lspPositionParameters returns the JSON schema of [LSPPositionParams].
+func lspPositionParameters() map[string]any {
+	return map[string]any{
+		"file_path": map[string]any{
+			"type":        "string",
+			"description": "The path to the file containing the symbol",
+		},
+		"symbol": map[string]any{
+			"type":        "string",
+			"description": "The name of the symbol to look up. If line is also given, the symbol is searched for on that line only",
+		},
+		"line": map[string]any{
+			"type":        "integer",
+			"description": "The line number of the symbol (1-based)",
+		},
+		"column": map[string]any{
+			"type":        "integer",
+			"description": "The column number of the symbol (1-based). Requires line",
+		},
+	}
+}
+
+// lspClientSet is the set of LSP clients available to a tool. When allowed is
+// not nil, only the clients with those names are used.
+type lspClientSet struct {
+	clients map[string]*lsp.Client
+	allowed []string
+}
+
+// all returns the usable clients, sorted by name.
+func (s lspClientSet) all() []*lsp.Client {
+	names := make([]string, 0, len(s.clients))
+	for name := range s.clients {
+		if s.allowed != nil && !slices.Contains(s.allowed, name) {
+			continue
+		}
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	clients := make([]*lsp.Client, 0, len(names))
+	for _, name := range names {
+		clients = append(clients, s.clients[name])
+	}
+	return clients
+}
+
+// forFile returns the usable clients that handle the given file.
+func (s lspClientSet) forFile(filePath string) []*lsp.Client {
+	var clients []*lsp.Client
+	for _, client := range s.all() {
+		if client.HandlesFile(filePath) {
+			clients = append(clients, client)
+		}
+	}
+	return clients
+}
+
+// queryPosition resolves the position described by params in every client
+// that handles the file and calls fn with it, until fn reports a result.
+func (s lspClientSet) queryPosition(
+	ctx context.Context,
+	filePath string,
+	params LSPPositionParams,
+	fn func(client *lsp.Client, pos protocol.TextDocumentPositionParams) (bool, error),
+) error {
+	clients := s.forFile(filePath)
+	if len(clients) == 0 {
+		return fmt.Errorf("no LSP server available for %s", filePath)
+	}
+
+	var errs []error
+	for _, client := range clients {
+		if err := client.OpenFileOnDemand(ctx, filePath); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", client.GetName(), err))
+			continue
+		}
+		pos, err := resolveLSPPosition(ctx, client, filePath, params)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		found, err := fn(client, protocol.TextDocumentPositionParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: protocol.URIFromPath(filePath)},
+			Position:     pos,
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", client.GetName(), err))
+			continue
+		}
+		if found {
+			return nil
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// resolveFilePath makes the given path absolute, relative to the working
+// directory, and checks that it exists.
+func resolveFilePath(workingDir, filePath string) (string, error) {
+	if filePath == "" {
+		return "", fmt.Errorf("file_path is required")
+	}
+	if !filepath.IsAbs(filePath) {
+		filePath = filepath.Join(workingDir, filePath)
+	}
+	if _, err := os.Stat(filePath); err != nil {
+		return "", fmt.Errorf("file not found: %s", filePath)
+	}
+	return filePath, nil
+}
+
+// resolveLSPPosition turns the given parameters into a position the LSP
+// server understands. The file must already be opened in the client.
+func resolveLSPPosition(ctx context.Context, client *lsp.Client, filePath string, params LSPPositionParams) (protocol.Position, error) {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return protocol.Position{}, fmt.Errorf("error reading file: %w", err)
+	}
+	lines := strings.Split(string(content), "\n")
+
+	switch {
+	case params.Line > 0 && params.Column > 0:
+		if params.Line > len(lines) {
+			return protocol.Position{}, fmt.Errorf("line %d is out of range, the file has %d lines", params.Line, len(lines))
+		}
+		return lspPosition(lines[params.Line-1], params.Line-1, params.Column-1), nil
+	case params.Symbol == "":
+		return protocol.Position{}, fmt.Errorf("either symbol or line and column are required")
+	case params.Line > 0:
+		if params.Line > len(lines) {
+			return protocol.Position{}, fmt.Errorf("line %d is out of range, the file has %d lines", params.Line, len(lines))
+		}
+		col := indexSymbol(lines[params.Line-1], params.Symbol)
+		if col == -1 {
+			return protocol.Position{}, fmt.Errorf("symbol %q not found on line %d", params.Symbol, params.Line)
+		}
+		return lspPosition(lines[params.Line-1], params.Line-1, col), nil
+	}
+
+	// Prefer the symbol declarations the server knows about, falling back to
+	// the first occurrence of the name in the file.
+	symbols, err := client.DocumentSymbol(ctx, protocol.DocumentSymbolParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: protocol.URIFromPath(filePath)},
+	})
+	if err == nil {
+		if pos, ok := findDocumentSymbol(symbols, params.Symbol); ok {
+			return pos, nil
+		}
+	}
+	for i, line := range lines {
+		if col := indexSymbol(line, params.Symbol); col != -1 {
+			return lspPosition(line, i, col), nil
+		}
+	}
+	return protocol.Position{}, fmt.Errorf("symbol %q not found in %s", params.Symbol, filePath)
+}
+
+// findDocumentSymbol looks up a symbol by name in the result of a
+// textDocument/documentSymbol request.
+func findDocumentSymbol(result protocol.Or_Result_textDocument_documentSymbol, name string) (protocol.Position, bool) {
+	switch symbols := result.Value.(type) {
+	case []protocol.DocumentSymbol:
+		var walk func([]protocol.DocumentSymbol) (protocol.Position, bool)
+		walk = func(symbols []protocol.DocumentSymbol) (protocol.Position, bool) {
+			for _, symbol := range symbols {
+				if symbol.Name == name {
+					return symbol.SelectionRange.Start, true
+				}
+				if pos, ok := walk(symbol.Children); ok {
+					return pos, true
+				}
+			}
+			return protocol.Position{}, false
+		}
+		return walk(symbols)
+	case []protocol.SymbolInformation:
+		for _, symbol := range symbols {
+			if symbol.Name == name {
+				return symbol.Location.Range.Start, true
+			}
+		}
+	}
+	return protocol.Position{}, false
+}
+
+// indexSymbol returns the column (in runes) of the first occurrence of the
+// given symbol in the line that isn't part of a longer identifier, or -1.
+func indexSymbol(line, symbol string) int {
+	if symbol == "" {
+		return -1
+	}
+	runes := []rune(line)
+	target := []rune(symbol)
+	for i := 0; i+len(target) <= len(runes); i++ {
+		if string(runes[i:i+len(target)]) != symbol {
+			continue
+		}
+		if i > 0 && isIdentRune(runes[i-1]) {
+			continue
+		}
+		if end := i + len(target); end < len(runes) && isIdentRune(runes[end]) {
+			continue
+		}
+		return i
+	}
+	return -1
+}
+
+func isIdentRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// lspPosition converts a rune column into an LSP position, which counts
+// UTF-16 code units.
+func lspPosition(line string, lineIdx, col int) protocol.Position {
+	runes := []rune(line)
+	col = min(max(col, 0), len(runes))
+	return protocol.Position{
+		Line:      uint32(lineIdx),
+		Character: uint32(len(utf16.Encode(runes[:col]))),
+	}
+}
+
+// formatLocation renders a location as path:line:column, with the path
+// relative to the working directory when possible.
+func formatLocation(workingDir string, uri protocol.DocumentURI, pos protocol.Position) string {
+	return fmt.Sprintf("%s:%d:%d", displayPath(workingDir, uri), pos.Line+1, pos.Character+1)
+}
+
+func displayPath(workingDir string, uri protocol.DocumentURI) string {
+	path, err := uri.Path()
+	if err != nil {
+		return string(uri)
+	}
+	if rel, err := filepath.Rel(workingDir, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return path
+}
+
+// lineCache reads lines of files, reading each file only once.
+type lineCache map[protocol.DocumentURI][]string
+
+// line returns the trimmed content of the given (0-based) line of a file.
+func (c lineCache) line(uri protocol.DocumentURI, line uint32) string {
+	lines, ok := c[uri]
+	if !ok {
+		if path, err := uri.Path(); err == nil {
+			if content, err := os.ReadFile(path); err == nil {
+				lines = strings.Split(string(content), "\n")
+			}
+		}
+		c[uri] = lines
+	}
+	if int(line) >= len(lines) {
+		return ""
+	}
+	return strings.TrimSpace(lines[line])
+}
+
+// toLocations flattens the different shapes of location results returned by
+// LSP servers.
+func toLocations(result any) []protocol.Location {
+	switch v := result.(type) {
+	case protocol.Location:
+		return []protocol.Location{v}
+	case []protocol.Location:
+		return v
+	case protocol.Or_Definition:
+		return toLocations(v.Value)
+	case []protocol.LocationLink:
+		locations := make([]protocol.Location, 0, len(v))
+		for _, link := range v {
+			locations = append(locations, protocol.Location{
+				URI:   link.TargetURI,
+				Range: link.TargetSelectionRange,
+			})
+		}
+		return locations
+	}
+	return nil
+}
+
+var symbolKindNames = map[protocol.SymbolKind]string{
+	protocol.File:          "file",
+	protocol.Module:        "module",
+	protocol.Namespace:     "namespace",
+	protocol.Package:       "package",
+	protocol.Class:         "class",
+	protocol.Method:        "method",
+	protocol.Property:      "property",
+	protocol.Field:         "field",
+	protocol.Constructor:   "constructor",
+	protocol.Enum:          "enum",
+	protocol.Interface:     "interface",
+	protocol.Function:      "function",
+	protocol.Variable:      "variable",
+	protocol.Constant:      "constant",
+	protocol.String:        "string",
+	protocol.Number:        "number",
+	protocol.Boolean:       "boolean",
+	protocol.Array:         "array",
+	protocol.Object:        "object",
+	protocol.Key:           "key",
+	protocol.Null:          "null",
+	protocol.EnumMember:    "enum member",
+	protocol.Struct:        "struct",
+	protocol.Event:         "event",
+	protocol.Operator:      "operator",
+	protocol.TypeParameter: "type parameter",
+}
+
+func symbolKindName(kind protocol.SymbolKind) string {
+	if name, ok := symbolKindNames[kind]; ok {
+		return name
+	}
+	return "symbol"
+}
@@ -0,0 +1,42 @@
+package tools
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/lsp/protocol"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIndexSymbol(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		line   string
+		symbol string
+		want   int
+	}{
+		{"simple", "func foo() {}", "foo", 5},
+		{"skips longer identifiers", "fooBar := foo()", "foo", 10},
+		{"skips suffixes", "x.myfoo(foo)", "foo", 8},
+		{"not found", "bar := baz", "foo", -1},
+		{"empty symbol", "foo", "", -1},
+		{"multibyte", "é := foo", "foo", 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tt.want, indexSymbol(tt.line, tt.symbol))
+		})
+	}
+}
+
+func TestLSPPosition(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, protocol.Position{Line: 2, Character: 4}, lspPosition("abcdef", 2, 4))
+	// Characters outside the BMP take two UTF-16 code units.
+	require.Equal(t, protocol.Position{Line: 0, Character: 3}, lspPosition("😀x", 0, 2))
+	require.Equal(t, protocol.Position{Line: 0, Character: 3}, lspPosition("abc", 0, 10))
+}
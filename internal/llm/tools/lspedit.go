@@ -0,0 +1,241 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/crush/internal/diff"
+	"github.com/charmbracelet/crush/internal/history"
+	"github.com/charmbracelet/crush/internal/lsp/protocol"
+	"github.com/charmbracelet/crush/internal/lsp/util"
+	"github.com/charmbracelet/crush/internal/permission"
+)
+
+// LSPFileEdit is the change a workspace edit makes to a single file.
+type LSPFileEdit struct {
+	FilePath   string `json:"file_path"`
+	OldContent string `json:"old_content,omitempty"`
+	NewContent string `json:"new_content,omitempty"`
+}
+
+// LSPEditPermissionsParams are the parameters of the permission request
+// of the tools that apply workspace edits computed by LSP servers.
+type LSPEditPermissionsParams struct {
+	Files []LSPFileEdit `json:"files"`
+}
+
+type LSPEditFileMetadata struct {
+	FilePath  string `json:"file_path"`
+	Additions int    `json:"additions"`
+	Removals  int    `json:"removals"`
+}
+
+type LSPEditResponseMetadata struct {
+	Files []LSPEditFileMetadata `json:"files"`
+}
+
+// lspEditor applies workspace edits after asking for permission, recording
+// every touched file in the file history.
+type lspEditor struct {
+	lspClients  lspClientSet
+	permissions permission.Service
+	files       history.Service
+	workingDir  string
+}
+
+// workspaceEditFiles computes the new content of every file changed by the
+// given workspace edit, without touching the filesystem.
+func workspaceEditFiles(edit protocol.WorkspaceEdit) ([]LSPFileEdit, error) {
+	var (
+		fileEdits []LSPFileEdit
+		indexes   = map[protocol.DocumentURI]int{}
+	)
+	apply := func(uri protocol.DocumentURI, edits []protocol.TextEdit) error {
+		idx, ok := indexes[uri]
+		if !ok {
+			path, err := uri.Path()
+			if err != nil {
+				return fmt.Errorf("invalid URI: %w", err)
+			}
+			content, err := os.ReadFile(path)
+			if err != nil {
+				return fmt.Errorf("failed to read file: %w", err)
+			}
+			idx = len(fileEdits)
+			indexes[uri] = idx
+			fileEdits = append(fileEdits, LSPFileEdit{
+				FilePath:   path,
+				OldContent: string(content),
+				NewContent: string(content),
+			})
+		}
+		newContent, err := util.ApplyTextEditsToContent(fileEdits[idx].NewContent, edits)
+		if err != nil {
+			return fmt.Errorf("%s: %w", fileEdits[idx].FilePath, err)
+		}
+		fileEdits[idx].NewContent = newContent
+		return nil
+	}
+
+	uris := make([]protocol.DocumentURI, 0, len(edit.Changes))
+	for uri := range edit.Changes {
+		uris = append(uris, uri)
+	}
+	slices.Sort(uris)
+	for _, uri := range uris {
+		if err := apply(uri, edit.Changes[uri]); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, change := range edit.DocumentChanges {
+		if change.CreateFile != nil || change.RenameFile != nil || change.DeleteFile != nil {
+			return nil, errors.New("the edit creates, renames or deletes files, which is not supported")
+		}
+		if change.TextDocumentEdit == nil {
+			continue
+		}
+		edits := make([]protocol.TextEdit, len(change.TextDocumentEdit.Edits))
+		for i, e := range change.TextDocumentEdit.Edits {
+			var err error
+			edits[i], err = e.AsTextEdit()
+			if err != nil {
+				return nil, fmt.Errorf("invalid edit type: %w", err)
+			}
+		}
+		if err := apply(change.TextDocumentEdit.TextDocument.URI, edits); err != nil {
+			return nil, err
+		}
+	}
+
+	return slices.DeleteFunc(fileEdits, func(e LSPFileEdit) bool {
+		return e.OldContent == e.NewContent
+	}), nil
+}
+
+// apply shows the changes of the given workspace edit to the user and writes
+// them once allowed.
+func (e *lspEditor) apply(ctx context.Context, call ToolCall, toolName, description string, edit protocol.WorkspaceEdit) (ToolResponse, error) {
+	fileEdits, err := workspaceEditFiles(edit)
+	if err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("error computing changes: %s", err)), nil
+	}
+	if len(fileEdits) == 0 {
+		return NewTextErrorResponse("the language server returned no changes"), nil
+	}
+
+	sessionID, messageID := GetContextValues(ctx)
+	if sessionID == "" || messageID == "" {
+		return ToolResponse{}, fmt.Errorf("session ID and message ID are required for editing files")
+	}
+
+	paths := make([]string, len(fileEdits))
+	for i, fileEdit := range fileEdits {
+		paths[i] = fileEdit.FilePath
+	}
+	if err := e.permissions.Request(permission.CreatePermissionRequest{
+		SessionID:   sessionID,
+		Path:        e.workingDir,
+		Files:       paths,
+		ToolCallID:  call.ID,
+		ToolName:    toolName,
+		Action:      "write",
+		Description: description,
+		Params:      LSPEditPermissionsParams{Files: fileEdits},
+	}); err != nil {
+		return ToolResponse{}, err
+	}
+
+	var (
+		metadata LSPEditResponseMetadata
+		output   strings.Builder
+	)
+	if err := writeFileEdits(fileEdits); err != nil {
+		return ToolResponse{}, err
+	}
+	fmt.Fprintf(&output, "%s. Changed %d files:\n", description, len(fileEdits))
+	for _, fileEdit := range fileEdits {
+		if err := e.recordHistory(ctx, sessionID, fileEdit); err != nil {
+			return ToolResponse{}, err
+		}
+		recordFileWrite(fileEdit.FilePath)
+		recordFileRead(fileEdit.FilePath)
+
+		_, additions, removals := diff.GenerateDiff(fileEdit.OldContent, fileEdit.NewContent, strings.TrimPrefix(fileEdit.FilePath, e.workingDir))
+		metadata.Files = append(metadata.Files, LSPEditFileMetadata{
+			FilePath:  fileEdit.FilePath,
+			Additions: additions,
+			Removals:  removals,
+		})
+		fmt.Fprintf(&output, "- %s (+%d -%d)\n", displayPath(e.workingDir, protocol.URIFromPath(fileEdit.FilePath)), additions, removals)
+	}
+
+	// Let the servers know about the new contents of the files they have
+	// open, so later requests don't work on stale data.
+	for _, client := range e.lspClients.all() {
+		for _, fileEdit := range fileEdits {
+			if client.IsFileOpen(fileEdit.FilePath) {
+				if err := client.NotifyChange(ctx, fileEdit.FilePath); err != nil {
+					slog.Debug("Error notifying LSP of file change", "lsp", client.GetName(), "file", fileEdit.FilePath, "error", err)
+				}
+			}
+		}
+	}
+
+	return WithResponseMetadata(NewTextResponse(output.String()), metadata), nil
+}
+
+// writeFileEdits writes the new contents of the files, keeping their modes.
+// When writing one of them fails, the files written so far are restored, so
+// that the workspace edit is applied entirely or not at all.
+func writeFileEdits(fileEdits []LSPFileEdit) error {
+	modes := make([]fs.FileMode, len(fileEdits))
+	for i, fileEdit := range fileEdits {
+		info, err := os.Stat(fileEdit.FilePath)
+		if err != nil {
+			return fmt.Errorf("failed to stat file: %w", err)
+		}
+		modes[i] = info.Mode().Perm()
+	}
+
+	for i, fileEdit := range fileEdits {
+		err := os.WriteFile(fileEdit.FilePath, []byte(fileEdit.NewContent), modes[i])
+		if err == nil {
+			continue
+		}
+		// The file that failed may have been truncated already, so it's
+		// restored too, though that's likely to fail again.
+		for j, written := range fileEdits[:i+1] {
+			if restoreErr := os.WriteFile(written.FilePath, []byte(written.OldContent), modes[j]); restoreErr != nil && j < i {
+				slog.Error("Failed to restore file", "file", written.FilePath, "error", restoreErr)
+			}
+		}
+		return fmt.Errorf("failed to write %s: %w", fileEdit.FilePath, err)
+	}
+	return nil
+}
+
+func (e *lspEditor) recordHistory(ctx context.Context, sessionID string, fileEdit LSPFileEdit) error {
+	file, err := e.files.GetByPathAndSession(ctx, fileEdit.FilePath, sessionID)
+	if err != nil {
+		if _, err = e.files.Create(ctx, sessionID, fileEdit.FilePath, fileEdit.OldContent); err != nil {
+			return fmt.Errorf("error creating file history: %w", err)
+		}
+	} else if file.Content != fileEdit.OldContent {
+		// User manually changed the content, store an intermediate version
+		if _, err = e.files.CreateVersion(ctx, sessionID, fileEdit.FilePath, fileEdit.OldContent); err != nil {
+			slog.Debug("Error creating file history version", "error", err)
+		}
+	}
+
+	if _, err = e.files.CreateVersion(ctx, sessionID, fileEdit.FilePath, fileEdit.NewContent); err != nil {
+		slog.Debug("Error creating file history version", "error", err)
+	}
+	return nil
+}
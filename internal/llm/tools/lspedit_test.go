@@ -0,0 +1,116 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/lsp/protocol"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkspaceEditFiles(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.go")
+	b := filepath.Join(dir, "b.go")
+	require.NoError(t, os.WriteFile(a, []byte("package a\n\nfunc foo() {}\n"), 0o644))
+	require.NoError(t, os.WriteFile(b, []byte("package a\n\nvar x = foo()\n"), 0o644))
+
+	rename := func(line, char uint32) protocol.TextEdit {
+		return protocol.TextEdit{
+			Range: protocol.Range{
+				Start: protocol.Position{Line: line, Character: char},
+				End:   protocol.Position{Line: line, Character: char + 3},
+			},
+			NewText: "bar",
+		}
+	}
+
+	t.Run("changes", func(t *testing.T) {
+		t.Parallel()
+
+		files, err := workspaceEditFiles(protocol.WorkspaceEdit{
+			Changes: map[protocol.DocumentURI][]protocol.TextEdit{
+				protocol.URIFromPath(b): {rename(2, 8)},
+				protocol.URIFromPath(a): {rename(2, 5)},
+			},
+		})
+		require.NoError(t, err)
+		require.Equal(t, []LSPFileEdit{
+			{FilePath: a, OldContent: "package a\n\nfunc foo() {}\n", NewContent: "package a\n\nfunc bar() {}\n"},
+			{FilePath: b, OldContent: "package a\n\nvar x = foo()\n", NewContent: "package a\n\nvar x = bar()\n"},
+		}, files)
+
+		// Nothing is written until the edits are applied.
+		content, err := os.ReadFile(a)
+		require.NoError(t, err)
+		require.Equal(t, "package a\n\nfunc foo() {}\n", string(content))
+	})
+
+	t.Run("unchanged files are skipped", func(t *testing.T) {
+		t.Parallel()
+
+		files, err := workspaceEditFiles(protocol.WorkspaceEdit{
+			Changes: map[protocol.DocumentURI][]protocol.TextEdit{
+				protocol.URIFromPath(a): {{
+					Range:   protocol.Range{Start: protocol.Position{Line: 2, Character: 5}, End: protocol.Position{Line: 2, Character: 8}},
+					NewText: "foo",
+				}},
+			},
+		})
+		require.NoError(t, err)
+		require.Empty(t, files)
+	})
+
+	t.Run("resource operations", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := workspaceEditFiles(protocol.WorkspaceEdit{
+			DocumentChanges: []protocol.DocumentChange{{
+				DeleteFile: &protocol.DeleteFile{URI: protocol.URIFromPath(b)},
+			}},
+		})
+		require.Error(t, err)
+	})
+}
+
+func TestWriteFileEdits(t *testing.T) {
+	t.Parallel()
+
+	t.Run("modes", func(t *testing.T) {
+		t.Parallel()
+
+		script := filepath.Join(t.TempDir(), "build.sh")
+		require.NoError(t, os.WriteFile(script, []byte("echo foo\n"), 0o755))
+		require.NoError(t, writeFileEdits([]LSPFileEdit{{FilePath: script, OldContent: "echo foo\n", NewContent: "echo bar\n"}}))
+
+		content, err := os.ReadFile(script)
+		require.NoError(t, err)
+		require.Equal(t, "echo bar\n", string(content))
+		info, err := os.Stat(script)
+		require.NoError(t, err)
+		require.Equal(t, os.FileMode(0o755), info.Mode().Perm())
+	})
+
+	t.Run("rollback", func(t *testing.T) {
+		t.Parallel()
+
+		dir := t.TempDir()
+		a := filepath.Join(dir, "a.go")
+		require.NoError(t, os.WriteFile(a, []byte("package a\n"), 0o644))
+		// Directories can't be written, even by root.
+		broken := filepath.Join(dir, "b.go")
+		require.NoError(t, os.Mkdir(broken, 0o755))
+
+		err := writeFileEdits([]LSPFileEdit{
+			{FilePath: a, OldContent: "package a\n", NewContent: "package b\n"},
+			{FilePath: broken, NewContent: "package b\n"},
+		})
+		require.ErrorContains(t, err, "failed to write "+broken)
+		content, err := os.ReadFile(a)
+		require.NoError(t, err)
+		require.Equal(t, "package a\n", string(content), "files written before the failure are restored")
+	})
+}
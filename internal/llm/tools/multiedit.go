@@ -251,9 +251,10 @@ func (m *multiEditTool) processMultiEditWithCreation(ctx context.Context, params
 	// Check permissions
 	_, additions, removals := diff.GenerateDiff("", currentContent, strings.TrimPrefix(params.FilePath, m.workingDir))
 
-	p := m.permissions.Request(permission.CreatePermissionRequest{
+	if err := m.permissions.Request(permission.CreatePermissionRequest{
 		SessionID:   sessionID,
 		Path:        fsext.PathOrPrefix(params.FilePath, m.workingDir),
+		Files:       []string{params.FilePath},
 		ToolCallID:  call.ID,
 		ToolName:    MultiEditToolName,
 		Action:      "write",
@@ -263,9 +264,8 @@ func (m *multiEditTool) processMultiEditWithCreation(ctx context.Context, params
 			OldContent: "",
 			NewContent: currentContent,
 		},
-	})
-	if !p {
-		return ToolResponse{}, permission.ErrorPermissionDenied
+	}); err != nil {
+		return ToolResponse{}, err
 	}
 
 	// Write the file
@@ -275,7 +275,7 @@ func (m *multiEditTool) processMultiEditWithCreation(ctx context.Context, params
 	}
 
 	// Update file history
-	_, err = m.files.Create(ctx, sessionID, params.FilePath, "")
+	_, err = m.files.CreateMissing(ctx, sessionID, params.FilePath)
 	if err != nil {
 		return ToolResponse{}, fmt.Errorf("error creating file history: %w", err)
 	}
@@ -360,9 +360,10 @@ func (m *multiEditTool) processMultiEditExistingFile(ctx context.Context, params
 
 	// Generate diff and check permissions
 	_, additions, removals := diff.GenerateDiff(oldContent, currentContent, strings.TrimPrefix(params.FilePath, m.workingDir))
-	p := m.permissions.Request(permission.CreatePermissionRequest{
+	if err := m.permissions.Request(permission.CreatePermissionRequest{
 		SessionID:   sessionID,
 		Path:        fsext.PathOrPrefix(params.FilePath, m.workingDir),
+		Files:       []string{params.FilePath},
 		ToolCallID:  call.ID,
 		ToolName:    MultiEditToolName,
 		Action:      "write",
@@ -372,9 +373,8 @@ func (m *multiEditTool) processMultiEditExistingFile(ctx context.Context, params
 			OldContent: oldContent,
 			NewContent: currentContent,
 		},
-	})
-	if !p {
-		return ToolResponse{}, permission.ErrorPermissionDenied
+	}); err != nil {
+		return ToolResponse{}, err
 	}
 
 	if isCrlf {
@@ -0,0 +1,95 @@
+package tools
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// outputInterval is how often the output of running commands is streamed,
+// so that commands printing a lot don't flood the subscribers.
+const outputInterval = 100 * time.Millisecond
+
+// liveOutput streams the output of a running command to an OutputFunc, while
+// keeping all of it for the result.
+type liveOutput struct {
+	output OutputFunc
+	done   chan struct{}
+	closed sync.WaitGroup
+
+	mu      sync.Mutex
+	pending []byte
+}
+
+func newLiveOutput(output OutputFunc) *liveOutput {
+	o := &liveOutput{
+		output: output,
+		done:   make(chan struct{}),
+	}
+	o.closed.Add(1)
+	go func() {
+		defer o.closed.Done()
+		ticker := time.NewTicker(outputInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				o.flush(false)
+			case <-o.done:
+				o.flush(true)
+				return
+			}
+		}
+	}()
+	return o
+}
+
+// writer returns a writer writing to the buffer, and to the streamed output.
+func (o *liveOutput) writer(buf *bytes.Buffer) io.Writer {
+	return liveOutputWriter{o: o, buf: buf}
+}
+
+// flush streams the pending output. Unless all of it is, an incomplete
+// character at the end is kept for later.
+func (o *liveOutput) flush(all bool) {
+	o.mu.Lock()
+	n := len(o.pending)
+	if !all {
+		for i := n - 1; i >= 0 && i >= n-utf8.UTFMax; i-- {
+			if utf8.RuneStart(o.pending[i]) {
+				if !utf8.FullRune(o.pending[i:]) {
+					n = i
+				}
+				break
+			}
+		}
+	}
+	chunk := string(o.pending[:n])
+	o.pending = o.pending[n:]
+	o.mu.Unlock()
+
+	if chunk != "" {
+		o.output(chunk)
+	}
+}
+
+// Close streams what's left of the output.
+func (o *liveOutput) Close() {
+	close(o.done)
+	o.closed.Wait()
+}
+
+type liveOutputWriter struct {
+	o   *liveOutput
+	buf *bytes.Buffer
+}
+
+func (w liveOutputWriter) Write(p []byte) (int, error) {
+	w.o.mu.Lock()
+	defer w.o.mu.Unlock()
+
+	w.o.pending = append(w.o.pending, p...)
+	return w.buf.Write(p)
+}
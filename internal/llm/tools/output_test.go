@@ -0,0 +1,48 @@
+package tools
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLiveOutput(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mu     sync.Mutex
+		chunks []string
+	)
+	live := newLiveOutput(func(output string) {
+		mu.Lock()
+		defer mu.Unlock()
+		chunks = append(chunks, output)
+	})
+
+	var stdout, stderr bytes.Buffer
+	_, err := live.writer(&stdout).Write([]byte("building\n"))
+	require.NoError(t, err)
+	_, err = live.writer(&stderr).Write([]byte("warning\n"))
+	require.NoError(t, err)
+
+	// Incomplete characters wait for the rest of them.
+	_, err = live.writer(&stdout).Write([]byte("done \xe2\x9c"))
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return strings.Join(chunks, "") == "building\nwarning\ndone "
+	}, time.Second, outputInterval/2)
+
+	_, err = live.writer(&stdout).Write([]byte("\x93\n"))
+	require.NoError(t, err)
+	live.Close()
+
+	require.Equal(t, "building\nwarning\ndone ✓\n", strings.Join(chunks, ""))
+	require.Equal(t, "building\ndone ✓\n", stdout.String())
+	require.Equal(t, "warning\n", stderr.String())
+}
@@ -0,0 +1,147 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+type PlanStep struct {
+	Title   string   `json:"title"`
+	Details string   `json:"details,omitempty"`
+	Files   []string `json:"files,omitempty"`
+}
+
+type PlanParams struct {
+	Summary string     `json:"summary"`
+	Steps   []PlanStep `json:"steps"`
+}
+
+type planTool struct{}
+
+const (
+	PlanToolName    = "plan"
+	planDescription = `Submits the plan for the user's request to the user for approval. Only available in plan mode.
+
+WHEN TO USE THIS TOOL:
+- Once you have researched the codebase enough to know what has to be done
+- Call it only once per request, with the complete plan
+
+HOW TO USE:
+- Provide a short summary of the approach
+- Provide the steps to carry out, in order, each with a short title, the details of what changes and the files involved
+
+AFTER USING THIS TOOL:
+- Stop and wait: the user approves, edits or rejects the plan
+- When the plan is approved, you will be asked to carry it out with all the tools available
+- When it's rejected, the user tells you what to change`
+)
+
+func NewPlanTool() BaseTool {
+	return &planTool{}
+}
+
+func (p *planTool) Name() string {
+	return PlanToolName
+}
+
+func (p *planTool) ReadOnly() bool {
+	return true
+}
+
+func (p *planTool) Info() ToolInfo {
+	return ToolInfo{
+		Name:        PlanToolName,
+		Description: planDescription,
+		Parameters: map[string]any{
+			"summary": map[string]any{
+				"type":        "string",
+				"description": "A short summary of the approach",
+			},
+			"steps": map[string]any{
+				"type":        "array",
+				"description": "The steps to carry out, in order",
+				"items": map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"title": map[string]any{
+							"type":        "string",
+							"description": "What the step does, in a few words",
+						},
+						"details": map[string]any{
+							"type":        "string",
+							"description": "What changes in the step and how",
+						},
+						"files": map[string]any{
+							"type":        "array",
+							"description": "The files the step changes",
+							"items": map[string]any{
+								"type": "string",
+							},
+						},
+					},
+					"required": []string{"title"},
+				},
+			},
+		},
+		Required: []string{"summary", "steps"},
+	}
+}
+
+func (p *planTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
+	if !IsPlanMode(ctx) {
+		return NewTextErrorResponse("plans can only be submitted in plan mode"), nil
+	}
+	params, err := ParsePlan(call.Input)
+	if err != nil {
+		return NewTextErrorResponse(err.Error()), nil
+	}
+	return WithResponseMetadata(
+		NewTextResponse("The plan was submitted to the user for approval. Wait for their answer without doing anything else."),
+		params,
+	), nil
+}
+
+// ParsePlan parses the input of a call to the plan tool.
+func ParsePlan(input string) (PlanParams, error) {
+	var params PlanParams
+	if err := json.Unmarshal([]byte(input), &params); err != nil {
+		return PlanParams{}, fmt.Errorf("error parsing parameters: %w", err)
+	}
+	if len(params.Steps) == 0 {
+		return PlanParams{}, fmt.Errorf("the plan needs at least one step")
+	}
+	for i, step := range params.Steps {
+		if strings.TrimSpace(step.Title) == "" {
+			return PlanParams{}, fmt.Errorf("step %d has no title", i+1)
+		}
+	}
+	return params, nil
+}
+
+// Markdown returns the plan as a Markdown list of steps, preceded by its
+// summary.
+func (p PlanParams) Markdown() string {
+	var sb strings.Builder
+	if p.Summary != "" {
+		sb.WriteString(strings.TrimSpace(p.Summary))
+		sb.WriteString("\n\n")
+	}
+	for i, step := range p.Steps {
+		fmt.Fprintf(&sb, "%d. **%s**", i+1, strings.TrimSpace(step.Title))
+		if len(step.Files) > 0 {
+			fmt.Fprintf(&sb, " (`%s`)", strings.Join(step.Files, "`, `"))
+		}
+		sb.WriteString("\n")
+		if details := strings.TrimSpace(step.Details); details != "" {
+			for line := range strings.SplitSeq(details, "\n") {
+				if line != "" {
+					sb.WriteString("   " + line)
+				}
+				sb.WriteString("\n")
+			}
+		}
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
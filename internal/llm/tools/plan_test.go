@@ -0,0 +1,67 @@
+package tools
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePlan(t *testing.T) {
+	t.Parallel()
+
+	plan, err := ParsePlan(`{"summary":"Add a flag","steps":[{"title":"Parse it","details":"Use cobra.\nDefault to false.","files":["cmd/root.go"]},{"title":"Document it"}]}`)
+	require.NoError(t, err)
+	require.Equal(t, "Add a flag\n\n"+
+		"1. **Parse it** (`cmd/root.go`)\n"+
+		"   Use cobra.\n"+
+		"   Default to false.\n"+
+		"2. **Document it**", plan.Markdown())
+
+	_, err = ParsePlan(`{"summary":"Nothing","steps":[]}`)
+	require.ErrorContains(t, err, "at least one step")
+	_, err = ParsePlan(`{"summary":"Untitled","steps":[{"details":"Something"}]}`)
+	require.ErrorContains(t, err, "step 1 has no title")
+}
+
+func TestPlanTool(t *testing.T) {
+	t.Parallel()
+
+	call := ToolCall{Name: PlanToolName, Input: `{"summary":"Fix it","steps":[{"title":"Fix the bug"}]}`}
+	resp, err := NewPlanTool().Run(t.Context(), call)
+	require.NoError(t, err)
+	require.True(t, resp.IsError)
+
+	ctx := context.WithValue(t.Context(), PlanModeContextKey, "session")
+	resp, err = NewPlanTool().Run(ctx, call)
+	require.NoError(t, err)
+	require.False(t, resp.IsError)
+}
+
+func TestIsReadOnlyCommand(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		command string
+		want    bool
+	}{
+		{"git status", true},
+		{"ls -la", true},
+		{"git log --oneline", true},
+		{"git branch -D main", false},
+		{"kill 1", false},
+		{"ls > files.txt", false},
+		{"git log | git shortlog -s", true},
+		{"ls 2>/dev/null", true},
+		{"git status; rm -rf /", false},
+		{"echo $(rm file)", false},
+		{"rm file", false},
+		{"go build ./...", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tt.want, isReadOnlyCommand(tt.command))
+		})
+	}
+}
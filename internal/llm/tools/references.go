@@ -0,0 +1,160 @@
+package tools
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/crush/internal/lsp"
+	"github.com/charmbracelet/crush/internal/lsp/protocol"
+)
+
+type ReferencesParams struct {
+	LSPPositionParams
+	IncludeDeclaration bool `json:"include_declaration,omitempty"`
+}
+
+type ReferencesResponseMetadata struct {
+	NumberOfReferences int  `json:"number_of_references"`
+	NumberOfFiles      int  `json:"number_of_files"`
+	Truncated          bool `json:"truncated"`
+}
+
+type referencesTool struct {
+	lspClients lspClientSet
+	workingDir string
+}
+
+const (
+	ReferencesToolName    = "references"
+	MaxReferences         = 200
+	referencesDescription = `Find all references to a symbol across the project using the language server (LSP).
+
+WHEN TO USE THIS TOOL:
+- Use when you need to find every usage of a function, type, variable or method
+- Prefer it over grep when the symbol name is common or ambiguous, since only real references to the same symbol are returned
+- Useful to understand the impact of a change before making it
+
+HOW TO USE:
+- Provide the file containing the symbol, and either:
+  - the symbol name (optionally with the line it appears on), or
+  - the exact line and column of the symbol
+- Results are grouped by file, with the line and column of each reference and the source line
+
+LIMITATIONS:
+- Only works for files handled by a configured LSP server
+- Results are limited to 200 references
+- Line and column numbers are 1-based`
+)
+
+func NewReferencesTool(lspClients map[string]*lsp.Client, allowedLSP []string, workingDir string) BaseTool {
+	return &referencesTool{
+		lspClients: lspClientSet{clients: lspClients, allowed: allowedLSP},
+		workingDir: workingDir,
+	}
+}
+
+func (r *referencesTool) Name() string {
+	return ReferencesToolName
+}
+
+func (r *referencesTool) ReadOnly() bool {
+	return true
+}
+
+func (r *referencesTool) Info() ToolInfo {
+	parameters := lspPositionParameters()
+	parameters["include_declaration"] = map[string]any{
+		"type":        "boolean",
+		"description": "Whether to include the declaration of the symbol in the results (default false)",
+	}
+	return ToolInfo{
+		Name:        ReferencesToolName,
+		Description: referencesDescription,
+		Parameters:  parameters,
+		Required:    []string{"file_path"},
+	}
+}
+
+func (r *referencesTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
+	var params ReferencesParams
+	if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("error parsing parameters: %s", err)), nil
+	}
+
+	filePath, err := resolveFilePath(r.workingDir, params.FilePath)
+	if err != nil {
+		return NewTextErrorResponse(err.Error()), nil
+	}
+
+	var locations []protocol.Location
+	err = r.lspClients.queryPosition(ctx, filePath, params.LSPPositionParams, func(client *lsp.Client, pos protocol.TextDocumentPositionParams) (bool, error) {
+		result, err := client.References(ctx, protocol.ReferenceParams{
+			TextDocumentPositionParams: pos,
+			Context: protocol.ReferenceContext{
+				IncludeDeclaration: params.IncludeDeclaration,
+			},
+		})
+		if err != nil {
+			return false, err
+		}
+		locations = result
+		return len(locations) > 0, nil
+	})
+	if err != nil {
+		return NewTextErrorResponse(err.Error()), nil
+	}
+	if len(locations) == 0 {
+		return NewTextResponse("No references found"), nil
+	}
+
+	slices.SortFunc(locations, func(a, b protocol.Location) int {
+		return cmp.Or(
+			cmp.Compare(a.URI, b.URI),
+			cmp.Compare(a.Range.Start.Line, b.Range.Start.Line),
+			cmp.Compare(a.Range.Start.Character, b.Range.Start.Character),
+		)
+	})
+
+	total := len(locations)
+	truncated := total > MaxReferences
+	if truncated {
+		locations = locations[:MaxReferences]
+	}
+
+	var files []protocol.DocumentURI
+	for _, loc := range locations {
+		if !slices.Contains(files, loc.URI) {
+			files = append(files, loc.URI)
+		}
+	}
+
+	lines := lineCache{}
+	var output strings.Builder
+	fmt.Fprintf(&output, "Found %d references in %d files", total, len(files))
+	if truncated {
+		fmt.Fprintf(&output, " (showing the first %d)", MaxReferences)
+	}
+	output.WriteString(":\n")
+
+	var currentFile protocol.DocumentURI
+	for _, loc := range locations {
+		if loc.URI != currentFile {
+			currentFile = loc.URI
+			fmt.Fprintf(&output, "\n%s:\n", displayPath(r.workingDir, loc.URI))
+		}
+		fmt.Fprintf(&output, "  Line %d, Col %d: %s\n", loc.Range.Start.Line+1, loc.Range.Start.Character+1, lines.line(loc.URI, loc.Range.Start.Line))
+	}
+
+	return WithResponseMetadata(
+		NewTextResponse(output.String()),
+		ReferencesResponseMetadata{
+			NumberOfReferences: total,
+			NumberOfFiles:      len(files),
+			Truncated:          truncated,
+		},
+	), nil
+}
@@ -0,0 +1,126 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/charmbracelet/crush/internal/history"
+	"github.com/charmbracelet/crush/internal/lsp"
+	"github.com/charmbracelet/crush/internal/lsp/protocol"
+	"github.com/charmbracelet/crush/internal/permission"
+)
+
+type RenameParams struct {
+	LSPPositionParams
+	NewName string `json:"new_name"`
+}
+
+type renameTool struct {
+	lspEditor
+}
+
+const (
+	RenameToolName    = "rename"
+	renameDescription = `Rename a symbol across the whole project using the language server (LSP).
+
+WHEN TO USE THIS TOOL:
+- Use to rename a function, type, method, variable, field or package everywhere it's used
+- Prefer it over multiple Edit calls, since the language server updates every reference, and only the references to that symbol
+
+HOW TO USE:
+- Provide the file containing the symbol, the new name, and either:
+  - the symbol name (optionally with the line it appears on), or
+  - the exact line and column of the symbol
+- The user is shown the changes to every file before they are applied
+
+LIMITATIONS:
+- Only works for files handled by a configured LSP server
+- Renames that would create, move or delete files are not supported
+- Line and column numbers are 1-based
+
+TIPS:
+- Use the Diagnostics tool afterwards to check the project still builds`
+)
+
+func NewRenameTool(lspClients map[string]*lsp.Client, allowedLSP []string, permissions permission.Service, files history.Service, workingDir string) BaseTool {
+	return &renameTool{
+		lspEditor: lspEditor{
+			lspClients:  lspClientSet{clients: lspClients, allowed: allowedLSP},
+			permissions: permissions,
+			files:       files,
+			workingDir:  workingDir,
+		},
+	}
+}
+
+func (r *renameTool) Name() string {
+	return RenameToolName
+}
+
+func (r *renameTool) Info() ToolInfo {
+	parameters := lspPositionParameters()
+	parameters["new_name"] = map[string]any{
+		"type":        "string",
+		"description": "The new name of the symbol",
+	}
+	return ToolInfo{
+		Name:        RenameToolName,
+		Description: renameDescription,
+		Parameters:  parameters,
+		Required:    []string{"file_path", "new_name"},
+	}
+}
+
+func (r *renameTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
+	var params RenameParams
+	if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("error parsing parameters: %s", err)), nil
+	}
+	if params.NewName == "" {
+		return NewTextErrorResponse("new_name is required"), nil
+	}
+
+	filePath, err := resolveFilePath(r.workingDir, params.FilePath)
+	if err != nil {
+		return NewTextErrorResponse(err.Error()), nil
+	}
+
+	var (
+		edit    protocol.WorkspaceEdit
+		oldName = params.Symbol
+	)
+	err = r.lspClients.queryPosition(ctx, filePath, params.LSPPositionParams, func(client *lsp.Client, pos protocol.TextDocumentPositionParams) (bool, error) {
+		// Not every server supports prepareRename, so only use it to reject
+		// positions that can't be renamed.
+		prepared, err := client.PrepareRename(ctx, protocol.PrepareRenameParams{TextDocumentPositionParams: pos})
+		if err == nil {
+			switch v := prepared.Value.(type) {
+			case nil:
+				return false, fmt.Errorf("the symbol at %s can't be renamed", formatLocation(r.workingDir, pos.TextDocument.URI, pos.Position))
+			case protocol.PrepareRenamePlaceholder:
+				oldName = v.Placeholder
+			}
+		}
+
+		result, err := client.Rename(ctx, protocol.RenameParams{
+			TextDocument: pos.TextDocument,
+			Position:     pos.Position,
+			NewName:      params.NewName,
+		})
+		if err != nil {
+			return false, err
+		}
+		edit = result
+		return len(edit.Changes) > 0 || len(edit.DocumentChanges) > 0, nil
+	})
+	if err != nil {
+		return NewTextErrorResponse(err.Error()), nil
+	}
+
+	description := fmt.Sprintf("Rename %s to %s", oldName, params.NewName)
+	if oldName == "" {
+		description = fmt.Sprintf("Rename symbol to %s", params.NewName)
+	}
+	return r.apply(ctx, call, RenameToolName, description, edit)
+}
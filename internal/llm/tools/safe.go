@@ -1,6 +1,12 @@
 package tools
 
-import "runtime"
+import (
+	"runtime"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/crush/internal/shell"
+)
 
 var safeCommands = []string{
 	// Bash builtins and core utils
@@ -54,6 +60,79 @@ var safeCommands = []string{
 	"git tag",
 }
 
+// planModeExcludedCommands are the safe commands that can still change
+// something, and can't be run in plan mode.
+var planModeExcludedCommands = []string{
+	"env",
+	"git branch",
+	"git remote",
+	"git tag",
+	"kill",
+	"killall",
+	"nice",
+	"nohup",
+	"set",
+	"time",
+	"timeout",
+	"unset",
+}
+
+// commandRunners are the safe commands that run the command given as
+// argument, which are only safe without one.
+var commandRunners = []string{"env", "nice", "nohup", "time", "timeout"}
+
+// isSafeCommand reports whether every command of the command line is one of
+// the commands that run without approval, or of the extra ones configured,
+// and none of them writes to files through redirections.
+func isSafeCommand(command string, extra []string) bool {
+	return checkCommands(command, func(args []string) bool {
+		return matchBuiltinSafeCommand(args) != "" || matchCommand(args, extra) != ""
+	})
+}
+
+// isReadOnlyCommand reports whether the command line can't change anything:
+// every command of it is a safe command that doesn't write, and none of them
+// writes to files through redirections.
+func isReadOnlyCommand(command string) bool {
+	return checkCommands(command, func(args []string) bool {
+		safe := matchBuiltinSafeCommand(args)
+		return safe != "" && !slices.Contains(planModeExcludedCommands, safe)
+	})
+}
+
+// checkCommands reports whether the command line parses, doesn't write to
+// files and all its commands are allowed.
+func checkCommands(command string, allowed func(args []string) bool) bool {
+	line, err := shell.ParseCommandLine(command)
+	if err != nil || line.WritesFiles || len(line.Commands) == 0 {
+		return false
+	}
+	return !slices.ContainsFunc(line.Commands, func(args []string) bool {
+		return !allowed(args)
+	})
+}
+
+// matchBuiltinSafeCommand returns the built-in safe command the command
+// matches.
+func matchBuiltinSafeCommand(args []string) string {
+	if slices.Contains(commandRunners, args[0]) && slices.ContainsFunc(args[1:], func(arg string) bool {
+		return !strings.HasPrefix(arg, "-")
+	}) {
+		return ""
+	}
+	return matchCommand(args, safeCommands)
+}
+
+// matchCommand returns the pattern of the list the command matches.
+func matchCommand(args []string, patterns []string) string {
+	for _, pattern := range patterns {
+		if shell.MatchCommand(pattern, args) {
+			return pattern
+		}
+	}
+	return ""
+}
+
 func init() {
 	if runtime.GOOS == "windows" {
 		safeCommands = append(
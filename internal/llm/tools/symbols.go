@@ -0,0 +1,225 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/crush/internal/lsp"
+	"github.com/charmbracelet/crush/internal/lsp/protocol"
+)
+
+type SymbolsParams struct {
+	Query    string `json:"query,omitempty"`
+	FilePath string `json:"file_path,omitempty"`
+}
+
+type symbolsTool struct {
+	lspClients lspClientSet
+	workingDir string
+}
+
+const (
+	SymbolsToolName    = "symbols"
+	MaxSymbols         = 100
+	symbolsDescription = `Search for symbols (functions, types, methods, variables, ...) using the language server (LSP).
+
+WHEN TO USE THIS TOOL:
+- Use to find where a type or function is declared when you only know its name
+- Use to get an outline of the declarations of a file without reading all of it
+
+HOW TO USE:
+- Provide a query to search the symbols of the whole workspace by name
+- Provide a file path to list the symbols declared in that file, optionally filtered by the query
+- Results include the kind of each symbol and its location as path:line:column
+
+LIMITATIONS:
+- Only works for languages with a configured LSP server
+- Workspace results are limited to 100 symbols
+- How the query is matched depends on the language server, usually it's a fuzzy, case-insensitive match`
+)
+
+func NewSymbolsTool(lspClients map[string]*lsp.Client, allowedLSP []string, workingDir string) BaseTool {
+	return &symbolsTool{
+		lspClients: lspClientSet{clients: lspClients, allowed: allowedLSP},
+		workingDir: workingDir,
+	}
+}
+
+func (s *symbolsTool) Name() string {
+	return SymbolsToolName
+}
+
+func (s *symbolsTool) ReadOnly() bool {
+	return true
+}
+
+func (s *symbolsTool) Info() ToolInfo {
+	return ToolInfo{
+		Name:        SymbolsToolName,
+		Description: symbolsDescription,
+		Parameters: map[string]any{
+			"query": map[string]any{
+				"type":        "string",
+				"description": "The name, or part of the name, of the symbols to search for",
+			},
+			"file_path": map[string]any{
+				"type":        "string",
+				"description": "List the symbols of this file instead of searching the whole workspace",
+			},
+		},
+		Required: []string{},
+	}
+}
+
+func (s *symbolsTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
+	var params SymbolsParams
+	if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("error parsing parameters: %s", err)), nil
+	}
+
+	if params.FilePath != "" {
+		return s.documentSymbols(ctx, params)
+	}
+	if params.Query == "" {
+		return NewTextErrorResponse("either query or file_path is required"), nil
+	}
+	return s.workspaceSymbols(ctx, params)
+}
+
+func (s *symbolsTool) documentSymbols(ctx context.Context, params SymbolsParams) (ToolResponse, error) {
+	filePath, err := resolveFilePath(s.workingDir, params.FilePath)
+	if err != nil {
+		return NewTextErrorResponse(err.Error()), nil
+	}
+
+	clients := s.lspClients.forFile(filePath)
+	if len(clients) == 0 {
+		return NewTextErrorResponse(fmt.Sprintf("no LSP server available for %s", filePath)), nil
+	}
+
+	query := strings.ToLower(params.Query)
+	matches := func(name string) bool {
+		return query == "" || strings.Contains(strings.ToLower(name), query)
+	}
+
+	var errs []error
+	for _, client := range clients {
+		if err := client.OpenFileOnDemand(ctx, filePath); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", client.GetName(), err))
+			continue
+		}
+		result, err := client.DocumentSymbol(ctx, protocol.DocumentSymbolParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: protocol.URIFromPath(filePath)},
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", client.GetName(), err))
+			continue
+		}
+
+		var output strings.Builder
+		switch symbols := result.Value.(type) {
+		case []protocol.DocumentSymbol:
+			var walk func(symbols []protocol.DocumentSymbol, depth int)
+			walk = func(symbols []protocol.DocumentSymbol, depth int) {
+				for _, symbol := range symbols {
+					// Keep the nesting when filtering, so matching children
+					// of non-matching parents are still listed.
+					if matches(symbol.Name) {
+						fmt.Fprintf(&output, "%s%s %s", strings.Repeat("  ", depth), symbolKindName(symbol.Kind), symbol.Name)
+						if symbol.Detail != "" {
+							fmt.Fprintf(&output, " %s", symbol.Detail)
+						}
+						fmt.Fprintf(&output, " (line %d)\n", symbol.SelectionRange.Start.Line+1)
+					}
+					walk(symbol.Children, depth+1)
+				}
+			}
+			walk(symbols, 0)
+		case []protocol.SymbolInformation:
+			for _, symbol := range symbols {
+				if !matches(symbol.Name) {
+					continue
+				}
+				fmt.Fprintf(&output, "%s %s", symbolKindName(symbol.Kind), symbol.Name)
+				if symbol.ContainerName != "" {
+					fmt.Fprintf(&output, " in %s", symbol.ContainerName)
+				}
+				fmt.Fprintf(&output, " (line %d)\n", symbol.Location.Range.Start.Line+1)
+			}
+		}
+		if output.Len() > 0 {
+			return NewTextResponse(output.String()), nil
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return NewTextErrorResponse(err.Error()), nil
+	}
+	return NewTextResponse("No symbols found"), nil
+}
+
+func (s *symbolsTool) workspaceSymbols(ctx context.Context, params SymbolsParams) (ToolResponse, error) {
+	clients := s.lspClients.all()
+	if len(clients) == 0 {
+		return NewTextErrorResponse("no LSP clients available"), nil
+	}
+
+	var (
+		lines []string
+		errs  []error
+	)
+	for _, client := range clients {
+		result, err := client.Symbol(ctx, protocol.WorkspaceSymbolParams{Query: params.Query})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", client.GetName(), err))
+			continue
+		}
+		switch symbols := result.Value.(type) {
+		case []protocol.SymbolInformation:
+			for _, symbol := range symbols {
+				base := protocol.BaseSymbolInformation{
+					Name:          symbol.Name,
+					Kind:          symbol.Kind,
+					Tags:          symbol.Tags,
+					ContainerName: symbol.ContainerName,
+				}
+				lines = append(lines, s.formatSymbol(base, symbol.Location))
+			}
+		case []protocol.WorkspaceSymbol:
+			for _, symbol := range symbols {
+				switch loc := symbol.Location.Value.(type) {
+				case protocol.Location:
+					lines = append(lines, s.formatSymbol(symbol.BaseSymbolInformation, loc))
+				case protocol.LocationUriOnly:
+					lines = append(lines, s.formatSymbol(symbol.BaseSymbolInformation, protocol.Location{URI: loc.URI}))
+				}
+			}
+		}
+	}
+	if len(lines) == 0 {
+		if err := errors.Join(errs...); err != nil {
+			return NewTextErrorResponse(err.Error()), nil
+		}
+		return NewTextResponse("No symbols found"), nil
+	}
+
+	var output strings.Builder
+	fmt.Fprintf(&output, "Found %d symbols", len(lines))
+	if len(lines) > MaxSymbols {
+		fmt.Fprintf(&output, " (showing the first %d)", MaxSymbols)
+		lines = lines[:MaxSymbols]
+	}
+	output.WriteString(":\n\n")
+	output.WriteString(strings.Join(lines, "\n"))
+	return NewTextResponse(output.String()), nil
+}
+
+func (s *symbolsTool) formatSymbol(symbol protocol.BaseSymbolInformation, loc protocol.Location) string {
+	line := fmt.Sprintf("%s %s", symbolKindName(symbol.Kind), symbol.Name)
+	if symbol.ContainerName != "" {
+		line += " in " + symbol.ContainerName
+	}
+	return line + " - " + formatLocation(s.workingDir, loc.URI, loc.Range.Start)
+}
@@ -0,0 +1,181 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/crush/internal/todo"
+)
+
+type TodoParams struct {
+	Action  string   `json:"action"`
+	Items   []string `json:"items,omitempty"`
+	ID      int64    `json:"id,omitempty"`
+	Content string   `json:"content,omitempty"`
+	Status  string   `json:"status,omitempty"`
+}
+
+type TodoResponseMetadata struct {
+	Total     int `json:"total"`
+	Completed int `json:"completed"`
+}
+
+type todoTool struct {
+	todos todo.Service
+}
+
+const (
+	TodoToolName    = "todo"
+	todoDescription = `Keeps the todo list of the session, to track the progress of multi-step tasks. The user sees the list as it changes.
+
+WHEN TO USE THIS TOOL:
+- For tasks that take three steps or more, or when the user gives you several things to do
+- Skip it for simple tasks that take a single step
+
+HOW TO USE:
+- "add" adds the given items to the end of the list, as pending
+- "update" changes the content and/or the status (pending, in_progress or completed) of the item with the given id
+- "complete" marks the item with the given id as completed
+- "remove" removes the item with the given id, when it's no longer needed
+- "list" returns the list as it is
+- Every action returns the whole list, with the id of each item
+
+TIPS:
+- Add all the steps up front, then work through them in order
+- Mark an item as in_progress before starting it, and have a single item in progress at a time
+- Complete items as soon as they're done rather than all at once at the end
+- Only complete items that are fully done; add new items for what's left when you hit blockers`
+)
+
+func NewTodoTool(todos todo.Service) BaseTool {
+	return &todoTool{todos: todos}
+}
+
+func (t *todoTool) Name() string {
+	return TodoToolName
+}
+
+func (t *todoTool) Info() ToolInfo {
+	return ToolInfo{
+		Name:        TodoToolName,
+		Description: todoDescription,
+		Parameters: map[string]any{
+			"action": map[string]any{
+				"type":        "string",
+				"description": "The action to take on the list",
+				"enum":        []string{"add", "update", "complete", "remove", "list"},
+			},
+			"items": map[string]any{
+				"type":        "array",
+				"description": "The items to add, for the add action",
+				"items": map[string]any{
+					"type": "string",
+				},
+			},
+			"id": map[string]any{
+				"type":        "integer",
+				"description": "The id of the item, for the update, complete and remove actions",
+			},
+			"content": map[string]any{
+				"type":        "string",
+				"description": "The new content of the item, for the update action",
+			},
+			"status": map[string]any{
+				"type":        "string",
+				"description": "The new status of the item, for the update action",
+				"enum":        []string{string(todo.StatusPending), string(todo.StatusInProgress), string(todo.StatusCompleted)},
+			},
+		},
+		Required: []string{"action"},
+	}
+}
+
+func (t *todoTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
+	var params TodoParams
+	if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("error parsing parameters: %s", err)), nil
+	}
+	sessionID, _ := GetContextValues(ctx)
+	if sessionID == "" {
+		return ToolResponse{}, fmt.Errorf("session ID is required for the todo list")
+	}
+
+	todos, err := t.todos.List(ctx, sessionID)
+	if err != nil {
+		return ToolResponse{}, fmt.Errorf("error listing todos: %w", err)
+	}
+	if params.Action == "add" {
+		if len(params.Items) == 0 {
+			return NewTextErrorResponse("items are required to add to the list"), nil
+		}
+		for _, item := range params.Items {
+			if strings.TrimSpace(item) == "" {
+				continue
+			}
+			added, err := t.todos.Add(ctx, sessionID, strings.TrimSpace(item))
+			if err != nil {
+				return ToolResponse{}, fmt.Errorf("error adding todo: %w", err)
+			}
+			todos = append(todos, added)
+		}
+		return todoResponse(todos), nil
+	}
+	if params.Action == "list" {
+		return todoResponse(todos), nil
+	}
+
+	idx := slices.IndexFunc(todos, func(t todo.Todo) bool { return t.Position == params.ID })
+	if idx == -1 {
+		return NewTextErrorResponse(fmt.Sprintf("no item with id %d in the list", params.ID)), nil
+	}
+	item := todos[idx]
+	switch params.Action {
+	case "update":
+		if params.Content == "" && params.Status == "" {
+			return NewTextErrorResponse("content or status is required to update an item"), nil
+		}
+		if params.Status != "" && !slices.Contains(todo.Statuses, todo.Status(params.Status)) {
+			return NewTextErrorResponse(fmt.Sprintf("invalid status %q, must be pending, in_progress or completed", params.Status)), nil
+		}
+		if params.Content != "" {
+			item.Content = strings.TrimSpace(params.Content)
+		}
+		if params.Status != "" {
+			item.Status = todo.Status(params.Status)
+		}
+	case "complete":
+		item.Status = todo.StatusCompleted
+	case "remove":
+		if err := t.todos.Delete(ctx, item); err != nil {
+			return ToolResponse{}, fmt.Errorf("error removing todo: %w", err)
+		}
+		return todoResponse(slices.Delete(todos, idx, idx+1)), nil
+	default:
+		return NewTextErrorResponse(fmt.Sprintf("invalid action %q, must be add, update, complete, remove or list", params.Action)), nil
+	}
+
+	if todos[idx], err = t.todos.Update(ctx, item); err != nil {
+		return ToolResponse{}, fmt.Errorf("error updating todo: %w", err)
+	}
+	return todoResponse(todos), nil
+}
+
+// todoResponse returns the list as the response of the tool.
+func todoResponse(todos []todo.Todo) ToolResponse {
+	if len(todos) == 0 {
+		return NewTextResponse("The todo list is empty.")
+	}
+	metadata := TodoResponseMetadata{Total: len(todos)}
+	for _, t := range todos {
+		if t.Status == todo.StatusCompleted {
+			metadata.Completed++
+		}
+	}
+	return WithResponseMetadata(
+		NewTextResponse("Todo list (id, content and status of each item):\n\n"+todo.Checklist(todos)),
+		metadata,
+	)
+}
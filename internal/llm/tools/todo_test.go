@@ -0,0 +1,49 @@
+package tools
+
+import (
+	"context"
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/db"
+	"github.com/charmbracelet/crush/internal/session"
+	"github.com/charmbracelet/crush/internal/todo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTodoTool(t *testing.T) {
+	t.Parallel()
+
+	conn, err := db.Connect(t.Context(), t.TempDir())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	q := db.New(conn)
+	sess, err := session.NewService(q).Create(t.Context(), "Todos")
+	require.NoError(t, err)
+	tool := NewTodoTool(todo.NewService(q))
+	ctx := context.WithValue(t.Context(), SessionIDContextKey, sess.ID)
+
+	run := func(input string) ToolResponse {
+		resp, err := tool.Run(ctx, ToolCall{Name: TodoToolName, Input: input})
+		require.NoError(t, err)
+		return resp
+	}
+
+	resp := run(`{"action":"list"}`)
+	require.Equal(t, "The todo list is empty.", resp.Content)
+
+	resp = run(`{"action":"add","items":["Parse the flag","Document it"]}`)
+	require.Contains(t, resp.Content, "- [ ] 1. Parse the flag\n- [ ] 2. Document it")
+
+	resp = run(`{"action":"update","id":1,"status":"in_progress"}`)
+	require.Contains(t, resp.Content, "- [ ] 1. Parse the flag (in progress)")
+	resp = run(`{"action":"complete","id":1}`)
+	require.Contains(t, resp.Content, "- [x] 1. Parse the flag")
+	resp = run(`{"action":"remove","id":2}`)
+	require.NotContains(t, resp.Content, "Document it")
+	require.JSONEq(t, `{"total":1,"completed":1}`, resp.Metadata)
+
+	resp = run(`{"action":"complete","id":2}`)
+	require.True(t, resp.IsError)
+	resp = run(`{"action":"update","id":1,"status":"done"}`)
+	require.True(t, resp.IsError)
+}
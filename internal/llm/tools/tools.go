@@ -17,6 +17,8 @@ type toolResponseType string
 type (
 	sessionIDContextKey string
 	messageIDContextKey string
+	planModeContextKey  string
+	outputContextKey    string
 )
 
 const (
@@ -25,8 +27,17 @@ const (
 
 	SessionIDContextKey sessionIDContextKey = "session_id"
 	MessageIDContextKey messageIDContextKey = "message_id"
+	// PlanModeContextKey holds the ID of the session being planned, which
+	// sub-agents called while planning inherit.
+	PlanModeContextKey planModeContextKey = "plan_mode"
+	// OutputContextKey holds the OutputFunc the output of the tool call is
+	// streamed to while it runs.
+	OutputContextKey outputContextKey = "output"
 )
 
+// OutputFunc receives the output of a running tool call, a chunk at a time.
+type OutputFunc func(output string)
+
 type ToolResponse struct {
 	Type     toolResponseType `json:"type"`
 	Content  string           `json:"content"`
@@ -72,6 +83,49 @@ type BaseTool interface {
 	Run(ctx context.Context, params ToolCall) (ToolResponse, error)
 }
 
+// ReadOnlyTool is implemented by tools that don't have side effects. Calls to
+// read-only tools can be safely executed concurrently.
+type ReadOnlyTool interface {
+	BaseTool
+	ReadOnly() bool
+}
+
+// IsReadOnly reports whether the given tool is free of side effects.
+func IsReadOnly(tool BaseTool) bool {
+	t, ok := tool.(ReadOnlyTool)
+	return ok && t.ReadOnly()
+}
+
+// ReadOnlyCallTool is implemented by tools that are free of side effects for
+// some of their calls, depending on their input.
+type ReadOnlyCallTool interface {
+	BaseTool
+	ReadOnlyCall(input string) bool
+}
+
+// IsReadOnlyCall reports whether the call of the given tool with the given
+// input is free of side effects.
+func IsReadOnlyCall(tool BaseTool, input string) bool {
+	if t, ok := tool.(ReadOnlyCallTool); ok {
+		return t.ReadOnlyCall(input)
+	}
+	return IsReadOnly(tool)
+}
+
+// IsPlanMode reports whether the tool is called while planning, when nothing
+// can be changed.
+func IsPlanMode(ctx context.Context) bool {
+	sessionID, _ := ctx.Value(PlanModeContextKey).(string)
+	return sessionID != ""
+}
+
+// GetOutputFunc returns the function the output of the tool call is streamed
+// to, nil when it isn't.
+func GetOutputFunc(ctx context.Context) OutputFunc {
+	output, _ := ctx.Value(OutputContextKey).(OutputFunc)
+	return output
+}
+
 func GetContextValues(ctx context.Context) (string, string) {
 	sessionID := ctx.Value(SessionIDContextKey)
 	messageID := ctx.Value(MessageIDContextKey)
@@ -93,6 +93,10 @@ func (v *viewTool) Name() string {
 	return ViewToolName
 }
 
+func (v *viewTool) ReadOnly() bool {
+	return true
+}
+
 func (v *viewTool) Info() ToolInfo {
 	return ToolInfo{
 		Name:        ViewToolName,
@@ -151,20 +155,19 @@ func (v *viewTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error)
 			return ToolResponse{}, fmt.Errorf("session ID and message ID are required for accessing files outside working directory")
 		}
 
-		granted := v.permissions.Request(
+		if err := v.permissions.Request(
 			permission.CreatePermissionRequest{
 				SessionID:   sessionID,
 				Path:        absFilePath,
+				Files:       []string{absFilePath},
 				ToolCallID:  call.ID,
 				ToolName:    ViewToolName,
 				Action:      "read",
 				Description: fmt.Sprintf("Read file outside working directory: %s", absFilePath),
 				Params:      ViewPermissionsParams(params),
 			},
-		)
-
-		if !granted {
-			return ToolResponse{}, permission.ErrorPermissionDenied
+		); err != nil {
+			return ToolResponse{}, err
 		}
 	}
 
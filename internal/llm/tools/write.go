@@ -173,10 +173,11 @@ func (w *writeTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error
 		strings.TrimPrefix(filePath, w.workingDir),
 	)
 
-	p := w.permissions.Request(
+	if err := w.permissions.Request(
 		permission.CreatePermissionRequest{
 			SessionID:   sessionID,
 			Path:        fsext.PathOrPrefix(filePath, w.workingDir),
+			Files:       []string{filePath},
 			ToolCallID:  call.ID,
 			ToolName:    WriteToolName,
 			Action:      "write",
@@ -187,9 +188,8 @@ func (w *writeTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error
 				NewContent: params.Content,
 			},
 		},
-	)
-	if !p {
-		return ToolResponse{}, permission.ErrorPermissionDenied
+	); err != nil {
+		return ToolResponse{}, err
 	}
 
 	err = os.WriteFile(filePath, []byte(params.Content), 0o644)
@@ -200,7 +200,11 @@ func (w *writeTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error
 	// Check if file exists in history
 	file, err := w.files.GetByPathAndSession(ctx, filePath, sessionID)
 	if err != nil {
-		_, err = w.files.Create(ctx, sessionID, filePath, oldContent)
+		if fileInfo == nil {
+			file, err = w.files.CreateMissing(ctx, sessionID, filePath)
+		} else {
+			file, err = w.files.Create(ctx, sessionID, filePath, oldContent)
+		}
 		if err != nil {
 			// Log error but don't fail the operation
 			return ToolResponse{}, fmt.Errorf("error creating file history: %w", err)
@@ -122,6 +122,9 @@ type Message struct {
 	Provider  string
 	CreatedAt int64
 	UpdatedAt int64
+	// Seq orders the messages and file versions of a session, as timestamps
+	// only have a precision of a second.
+	Seq int64
 }
 
 func (m *Message) Content() TextContent {
@@ -5,6 +5,8 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/charmbracelet/crush/internal/db"
@@ -17,6 +19,9 @@ type CreateMessageParams struct {
 	Parts    []ContentPart
 	Model    string
 	Provider string
+	// CreatedAt is set when copying a message, to keep its creation time.
+	// New messages are created now.
+	CreatedAt int64
 }
 
 type Service interface {
@@ -27,6 +32,18 @@ type Service interface {
 	List(ctx context.Context, sessionID string) ([]Message, error)
 	Delete(ctx context.Context, id string) error
 	DeleteSessionMessages(ctx context.Context, sessionID string) error
+	Search(ctx context.Context, query string, limit int) ([]SearchResult, error)
+}
+
+// SearchResult is a message matching a full-text search.
+type SearchResult struct {
+	MessageID    string
+	SessionID    string
+	SessionTitle string
+	Role         MessageRole
+	// Snippet is the part of the message around the match.
+	Snippet   string
+	CreatedAt int64
 }
 
 type service struct {
@@ -60,7 +77,7 @@ func (s *service) Create(ctx context.Context, sessionID string, params CreateMes
 			Reason: "stop",
 		})
 	}
-	partsJSON, err := marshallParts(params.Parts)
+	partsJSON, err := MarshalParts(params.Parts)
 	if err != nil {
 		return Message{}, err
 	}
@@ -71,6 +88,7 @@ func (s *service) Create(ctx context.Context, sessionID string, params CreateMes
 		Parts:     string(partsJSON),
 		Model:     sql.NullString{String: string(params.Model), Valid: true},
 		Provider:  sql.NullString{String: params.Provider, Valid: params.Provider != ""},
+		CreatedAt: sql.NullInt64{Int64: params.CreatedAt, Valid: params.CreatedAt != 0},
 	})
 	if err != nil {
 		return Message{}, err
@@ -99,8 +117,56 @@ func (s *service) DeleteSessionMessages(ctx context.Context, sessionID string) e
 	return nil
 }
 
+// Search returns the messages of top-level sessions matching all the words of
+// the query, best matches first. A word ending with * matches any word it's
+// a prefix of.
+func (s *service) Search(ctx context.Context, query string, limit int) ([]SearchResult, error) {
+	match := searchQuery(query)
+	if match == "" {
+		return []SearchResult{}, nil
+	}
+	rows, err := s.q.SearchMessages(ctx, db.SearchMessagesParams{
+		Query: match,
+		Limit: int64(limit),
+	})
+	if err != nil {
+		return nil, err
+	}
+	results := make([]SearchResult, len(rows))
+	for i, row := range rows {
+		results[i] = SearchResult{
+			MessageID:    row.ID,
+			SessionID:    row.SessionID,
+			SessionTitle: row.SessionTitle,
+			Role:         MessageRole(row.Role),
+			Snippet:      row.Snippet,
+			CreatedAt:    row.CreatedAt,
+		}
+	}
+	return results, nil
+}
+
+// searchQuery turns the words of a query into an FTS5 query, quoting them so
+// punctuation doesn't end up as query syntax.
+func searchQuery(query string) string {
+	words := strings.Fields(query)
+	for i, word := range words {
+		prefix := strings.HasSuffix(word, "*")
+		word = strings.TrimRight(word, "*")
+		if word == "" {
+			words[i] = ""
+			continue
+		}
+		words[i] = `"` + strings.ReplaceAll(word, `"`, `""`) + `"`
+		if prefix {
+			words[i] += "*"
+		}
+	}
+	return strings.Join(slices.DeleteFunc(words, func(w string) bool { return w == "" }), " ")
+}
+
 func (s *service) Update(ctx context.Context, message Message) error {
-	parts, err := marshallParts(message.Parts)
+	parts, err := MarshalParts(message.Parts)
 	if err != nil {
 		return err
 	}
@@ -146,7 +212,7 @@ func (s *service) List(ctx context.Context, sessionID string) ([]Message, error)
 }
 
 func (s *service) fromDBItem(item db.Message) (Message, error) {
-	parts, err := unmarshallParts([]byte(item.Parts))
+	parts, err := UnmarshalParts([]byte(item.Parts))
 	if err != nil {
 		return Message{}, err
 	}
@@ -159,6 +225,7 @@ func (s *service) fromDBItem(item db.Message) (Message, error) {
 		Provider:  item.Provider.String,
 		CreatedAt: item.CreatedAt,
 		UpdatedAt: item.UpdatedAt,
+		Seq:       item.Seq,
 	}, nil
 }
 
@@ -179,7 +246,8 @@ type partWrapper struct {
 	Data ContentPart `json:"data"`
 }
 
-func marshallParts(parts []ContentPart) ([]byte, error) {
+// MarshalParts encodes the parts of a message the way they are stored.
+func MarshalParts(parts []ContentPart) ([]byte, error) {
 	wrappedParts := make([]partWrapper, len(parts))
 
 	for i, part := range parts {
@@ -212,7 +280,8 @@ func marshallParts(parts []ContentPart) ([]byte, error) {
 	return json.Marshal(wrappedParts)
 }
 
-func unmarshallParts(data []byte) ([]ContentPart, error) {
+// UnmarshalParts decodes parts encoded with MarshalParts.
+func UnmarshalParts(data []byte) ([]ContentPart, error) {
 	temp := []json.RawMessage{}
 
 	if err := json.Unmarshal(data, &temp); err != nil {
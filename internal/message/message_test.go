@@ -0,0 +1,95 @@
+package message
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/db"
+	"github.com/charmbracelet/crush/internal/session"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchQuery(t *testing.T) {
+	t.Parallel()
+
+	for query, expected := range map[string]string{
+		"":                   "",
+		"parser":             `"parser"`,
+		"fix  parser":        `"fix" "parser"`,
+		"parse*":             `"parse"*`,
+		`foo-bar "baz" AND*`: `"foo-bar" """baz""" "AND"*`,
+		"*":                  "",
+	} {
+		require.Equal(t, expected, searchQuery(query), query)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	conn, err := db.Connect(ctx, t.TempDir())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	q := db.New(conn)
+	sessions := session.NewService(q)
+	messages := NewService(q)
+
+	sess, err := sessions.Create(ctx, "Parser work")
+	require.NoError(t, err)
+	user, err := messages.Create(ctx, sess.ID, CreateMessageParams{
+		Role:  User,
+		Parts: []ContentPart{TextContent{Text: "The tokenizer panics on empty input"}},
+	})
+	require.NoError(t, err)
+	assistant, err := messages.Create(ctx, sess.ID, CreateMessageParams{Role: Assistant})
+	require.NoError(t, err)
+	assistant.AddToolCall(ToolCall{ID: "call", Name: "grep", Input: `{"pattern":"func Tokenize"}`})
+	require.NoError(t, messages.Update(ctx, assistant))
+
+	// Messages being streamed are only indexed once they're finished.
+	results, err := messages.Search(ctx, "grep", 10)
+	require.NoError(t, err)
+	require.Empty(t, results)
+	assistant.AddFinish(FinishReasonToolUse, "", "")
+	require.NoError(t, messages.Update(ctx, assistant))
+	results, err = messages.Search(ctx, "grep", 10)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	require.Equal(t, assistant.ID, results[0].MessageID)
+
+	_, err = messages.Create(ctx, sess.ID, CreateMessageParams{
+		Role:  Tool,
+		Parts: []ContentPart{ToolResult{ToolCallID: "call", Name: "grep", Content: "lexer.go:12: func Tokenize(src string)"}},
+	})
+	require.NoError(t, err)
+
+	// Messages of task sessions are left out.
+	task, err := sessions.CreateTaskSession(ctx, "task", sess.ID, "Search")
+	require.NoError(t, err)
+	_, err = messages.Create(ctx, task.ID, CreateMessageParams{
+		Role:  User,
+		Parts: []ContentPart{TextContent{Text: "Find the tokenizer"}},
+	})
+	require.NoError(t, err)
+
+	results, err = messages.Search(ctx, "tokenizer panics", 10)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	require.Equal(t, user.ID, results[0].MessageID)
+	require.Equal(t, "Parser work", results[0].SessionTitle)
+	require.Equal(t, "The tokenizer panics on empty input", results[0].Snippet)
+
+	// Tool inputs and results are indexed too, and kept up to date.
+	results, err = messages.Search(ctx, "Tokeni*", 10)
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+	results, err = messages.Search(ctx, "lexer.go", 10)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	require.Equal(t, Tool, results[0].Role)
+
+	require.NoError(t, messages.Delete(ctx, user.ID))
+	results, err = messages.Search(ctx, "panics", 10)
+	require.NoError(t, err)
+	require.Empty(t, results)
+}
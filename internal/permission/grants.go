@@ -0,0 +1,218 @@
+package permission
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// GrantsFile is the name of the file grants are saved to, both in the
+// project and in the global data directories.
+const GrantsFile = "permissions.json"
+
+// Scope is how widely a grant applies.
+type Scope string
+
+const (
+	// ScopeSession grants apply to a single session.
+	ScopeSession Scope = "session"
+	// ScopeProject grants apply to every session of the project.
+	ScopeProject Scope = "project"
+	// ScopeAlways grants apply to every session of every project.
+	ScopeAlways Scope = "always"
+)
+
+var ErrGrantNotFound = errors.New("grant not found")
+
+// Grant allows a tool to run an action on a path without prompting.
+type Grant struct {
+	ID        string `json:"id"`
+	Scope     Scope  `json:"scope"`
+	SessionID string `json:"session_id,omitempty"`
+	ToolName  string `json:"tool_name"`
+	Action    string `json:"action"`
+	Path      string `json:"path"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+func (g Grant) matches(sessionID, toolName, action, path string) bool {
+	if g.Scope == ScopeSession && g.SessionID != sessionID {
+		return false
+	}
+	return g.ToolName == toolName && g.Action == action && g.Path == path
+}
+
+// GrantStore keeps the grants that outlive a single tool call. Session and
+// project grants are saved in the project data directory, so that resumed
+// sessions keep theirs until they're deleted, and grants that always apply in
+// the global one. The files are read again on every access,
+// so changes made by other instances are picked up.
+type GrantStore struct {
+	mu          sync.Mutex
+	projectFile string
+	globalFile  string
+
+	// grants of the scopes without a file, which are only kept in memory.
+	memory []Grant
+}
+
+// NewGrantStore creates a grant store saving to the given data directories.
+// Grants whose directory is empty are only kept in memory.
+func NewGrantStore(projectDataDir, globalDataDir string) *GrantStore {
+	s := &GrantStore{}
+	if projectDataDir != "" {
+		s.projectFile = filepath.Join(projectDataDir, GrantsFile)
+	}
+	if globalDataDir != "" {
+		s.globalFile = filepath.Join(globalDataDir, GrantsFile)
+	}
+	return s
+}
+
+func (s *GrantStore) fileFor(scope Scope) string {
+	if scope == ScopeAlways {
+		return s.globalFile
+	}
+	return s.projectFile
+}
+
+// List returns all the grants, oldest first.
+func (s *GrantStore) List() ([]Grant, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.load()
+}
+
+// Matches reports whether a grant allows the tool to run the action on the
+// path in the given session.
+func (s *GrantStore) Matches(sessionID, toolName, action, path string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	grants, err := s.load()
+	if err != nil {
+		return false, err
+	}
+	return slices.ContainsFunc(grants, func(g Grant) bool {
+		return g.matches(sessionID, toolName, action, path)
+	}), nil
+}
+
+// Add saves a new grant, filling in its ID and creation time.
+func (s *GrantStore) Add(grant Grant) (Grant, error) {
+	if grant.Scope != ScopeSession {
+		grant.SessionID = ""
+	}
+	grant.ID = uuid.New().String()
+	grant.CreatedAt = time.Now().Unix()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	grants, err := s.load()
+	if err != nil {
+		return Grant{}, err
+	}
+	for _, g := range grants {
+		if g.Scope == grant.Scope && g.matches(grant.SessionID, grant.ToolName, grant.Action, grant.Path) {
+			return g, nil
+		}
+	}
+	return grant, s.save(append(grants, grant), grant.Scope)
+}
+
+// Revoke removes the grant with the given ID.
+func (s *GrantStore) Revoke(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	grants, err := s.load()
+	if err != nil {
+		return err
+	}
+	idx := slices.IndexFunc(grants, func(g Grant) bool { return g.ID == id })
+	if idx < 0 {
+		return ErrGrantNotFound
+	}
+	scope := grants[idx].Scope
+	return s.save(slices.Delete(grants, idx, idx+1), scope)
+}
+
+// PruneSessions removes the grants of the sessions that don't exist anymore,
+// as they can't apply again.
+func (s *GrantStore) PruneSessions(exists func(sessionID string) bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	grants, err := s.load()
+	if err != nil {
+		return err
+	}
+	kept := slices.DeleteFunc(slices.Clone(grants), func(g Grant) bool {
+		return g.Scope == ScopeSession && !exists(g.SessionID)
+	})
+	if len(kept) == len(grants) {
+		return nil
+	}
+	return s.save(kept, ScopeSession)
+}
+
+func (s *GrantStore) load() ([]Grant, error) {
+	grants := slices.Clone(s.memory)
+	files := []string{s.projectFile}
+	if s.globalFile != s.projectFile {
+		files = append(files, s.globalFile)
+	}
+	for _, file := range files {
+		if file == "" {
+			continue
+		}
+		data, err := os.ReadFile(file)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read grants: %w", err)
+		}
+		var saved []Grant
+		if err := json.Unmarshal(data, &saved); err != nil {
+			return nil, fmt.Errorf("failed to parse grants in %s: %w", file, err)
+		}
+		grants = append(grants, saved...)
+	}
+	slices.SortStableFunc(grants, func(a, b Grant) int {
+		return cmp.Compare(a.CreatedAt, b.CreatedAt)
+	})
+	return grants, nil
+}
+
+// save writes the grants stored in the same place as the given scope.
+func (s *GrantStore) save(grants []Grant, scope Scope) error {
+	file := s.fileFor(scope)
+	var toSave []Grant
+	for _, g := range grants {
+		if s.fileFor(g.Scope) == file {
+			toSave = append(toSave, g)
+		}
+	}
+	if file == "" {
+		s.memory = toSave
+		return nil
+	}
+
+	data, err := json.MarshalIndent(toSave, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal grants: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0o700); err != nil {
+		return fmt.Errorf("failed to create grants directory: %w", err)
+	}
+	if err := os.WriteFile(file, data, 0o600); err != nil {
+		return fmt.Errorf("failed to save grants: %w", err)
+	}
+	return nil
+}
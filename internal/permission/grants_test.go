@@ -0,0 +1,120 @@
+package permission
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGrantStore(t *testing.T) {
+	t.Parallel()
+
+	t.Run("scopes", func(t *testing.T) {
+		t.Parallel()
+
+		store := NewGrantStore(t.TempDir(), t.TempDir())
+		_, err := store.Add(Grant{Scope: ScopeSession, SessionID: "a", ToolName: "bash", Action: "execute", Path: "/project"})
+		require.NoError(t, err)
+		_, err = store.Add(Grant{Scope: ScopeProject, SessionID: "a", ToolName: "edit", Action: "write", Path: "/project"})
+		require.NoError(t, err)
+
+		for _, tt := range []struct {
+			sessionID, toolName, action, path string
+			expected                          bool
+		}{
+			{"a", "bash", "execute", "/project", true},
+			{"b", "bash", "execute", "/project", false},
+			{"b", "edit", "write", "/project", true},
+			{"b", "edit", "write", "/other", false},
+			{"a", "edit", "read", "/project", false},
+		} {
+			granted, err := store.Matches(tt.sessionID, tt.toolName, tt.action, tt.path)
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, granted, "%s %s %s in %s", tt.sessionID, tt.toolName, tt.action, tt.path)
+		}
+	})
+
+	t.Run("persisted across stores", func(t *testing.T) {
+		t.Parallel()
+
+		projectDir, globalDir := t.TempDir(), t.TempDir()
+		store := NewGrantStore(projectDir, globalDir)
+		project, err := store.Add(Grant{Scope: ScopeProject, ToolName: "edit", Action: "write", Path: "/project"})
+		require.NoError(t, err)
+		always, err := store.Add(Grant{Scope: ScopeAlways, ToolName: "view", Action: "read", Path: "/usr/include"})
+		require.NoError(t, err)
+
+		grants, err := NewGrantStore(projectDir, globalDir).List()
+		require.NoError(t, err)
+		require.ElementsMatch(t, []Grant{project, always}, grants)
+
+		// Grants that always apply are shared by every project.
+		grants, err = NewGrantStore(t.TempDir(), globalDir).List()
+		require.NoError(t, err)
+		require.Equal(t, []Grant{always}, grants)
+	})
+
+	t.Run("duplicates", func(t *testing.T) {
+		t.Parallel()
+
+		store := NewGrantStore(t.TempDir(), t.TempDir())
+		first, err := store.Add(Grant{Scope: ScopeProject, ToolName: "edit", Action: "write", Path: "/project"})
+		require.NoError(t, err)
+		second, err := store.Add(Grant{Scope: ScopeProject, ToolName: "edit", Action: "write", Path: "/project"})
+		require.NoError(t, err)
+		require.Equal(t, first.ID, second.ID)
+
+		grants, err := store.List()
+		require.NoError(t, err)
+		require.Len(t, grants, 1)
+	})
+
+	t.Run("revoke", func(t *testing.T) {
+		t.Parallel()
+
+		projectDir := t.TempDir()
+		store := NewGrantStore(projectDir, t.TempDir())
+		grant, err := store.Add(Grant{Scope: ScopeProject, ToolName: "bash", Action: "execute", Path: "/project"})
+		require.NoError(t, err)
+
+		// Revoking from another instance, like the CLI, applies to all of them.
+		require.NoError(t, NewGrantStore(projectDir, "").Revoke(grant.ID))
+		granted, err := store.Matches("", "bash", "execute", "/project")
+		require.NoError(t, err)
+		require.False(t, granted)
+
+		require.ErrorIs(t, store.Revoke(grant.ID), ErrGrantNotFound)
+	})
+
+	t.Run("in memory", func(t *testing.T) {
+		t.Parallel()
+
+		store := NewGrantStore("", "")
+		grant, err := store.Add(Grant{Scope: ScopeAlways, ToolName: "bash", Action: "execute", Path: "/project"})
+		require.NoError(t, err)
+		granted, err := store.Matches("a", "bash", "execute", "/project")
+		require.NoError(t, err)
+		require.True(t, granted)
+
+		require.NoError(t, store.Revoke(grant.ID))
+		grants, err := store.List()
+		require.NoError(t, err)
+		require.Empty(t, grants)
+	})
+	t.Run("prune sessions", func(t *testing.T) {
+		t.Parallel()
+
+		store := NewGrantStore(t.TempDir(), t.TempDir())
+		kept, err := store.Add(Grant{Scope: ScopeSession, SessionID: "kept", ToolName: "bash", Action: "execute", Path: "/project"})
+		require.NoError(t, err)
+		_, err = store.Add(Grant{Scope: ScopeSession, SessionID: "deleted", ToolName: "bash", Action: "execute", Path: "/project"})
+		require.NoError(t, err)
+		project, err := store.Add(Grant{Scope: ScopeProject, ToolName: "edit", Action: "write", Path: "/project"})
+		require.NoError(t, err)
+
+		require.NoError(t, store.PruneSessions(func(sessionID string) bool { return sessionID == "kept" }))
+		grants, err := store.List()
+		require.NoError(t, err)
+		require.Equal(t, []Grant{kept, project}, grants)
+	})
+}
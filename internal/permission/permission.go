@@ -3,6 +3,8 @@ package permission
 import (
 	"context"
 	"errors"
+	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"slices"
@@ -23,14 +25,47 @@ type CreatePermissionRequest struct {
 	Action      string `json:"action"`
 	Params      any    `json:"params"`
 	Path        string `json:"path"`
+
+	// Files and Command describe what the tool call touches, to match it
+	// against the permission policy rules.
+	Files   []string `json:"files,omitempty"`
+	Command string   `json:"command,omitempty"`
+
+	// ReadOnly requests, like safe shell commands, are granted without
+	// prompting unless a deny or ask rule of the policy matches them.
+	ReadOnly bool `json:"read_only,omitempty"`
 }
 
 type PermissionNotification struct {
 	ToolCallID string `json:"tool_call_id"`
 	Granted    bool   `json:"granted"`
 	Denied     bool   `json:"denied"`
+
+	// ToolName and Reason are set when the request is denied without asking
+	// the user, by the permission policy or the session mode.
+	ToolName string `json:"tool_name,omitempty"`
+	Reason   string `json:"reason,omitempty"`
 }
 
+// Mode is how the requests that would prompt the user are handled in
+// sessions nobody is watching, like the ones of non-interactive runs.
+type Mode string
+
+const (
+	// ModeApproveAll approves every request that isn't denied by the
+	// permission policy.
+	ModeApproveAll Mode = "approve-all"
+	// ModeDenyUnlisted denies the requests that aren't allowed by the
+	// configuration, reporting them back to the model.
+	ModeDenyUnlisted Mode = "deny-unlisted"
+	// ModeFailOnPrompt stops at the first request that isn't allowed by the
+	// configuration.
+	ModeFailOnPrompt Mode = "fail-on-prompt"
+)
+
+// Modes are all the valid session modes.
+var Modes = []Mode{ModeApproveAll, ModeDenyUnlisted, ModeFailOnPrompt}
+
 type PermissionRequest struct {
 	ID          string `json:"id"`
 	SessionID   string `json:"session_id"`
@@ -44,35 +79,45 @@ type PermissionRequest struct {
 
 type Service interface {
 	pubsub.Suscriber[PermissionRequest]
-	GrantPersistent(permission PermissionRequest)
+	GrantPersistent(permission PermissionRequest, scope Scope)
 	Grant(permission PermissionRequest)
 	Deny(permission PermissionRequest)
-	Request(opts CreatePermissionRequest) bool
-	AutoApproveSession(sessionID string)
+	Request(opts CreatePermissionRequest) error
+	SetSessionMode(sessionID string, mode Mode)
+	SetSessionParent(sessionID, parentSessionID string)
 	SetSkipRequests(skip bool)
 	SkipRequests() bool
 	SubscribeNotifications(ctx context.Context) <-chan pubsub.Event[PermissionNotification]
+	ListGrants() ([]Grant, error)
+	RevokeGrant(id string) error
+	SetRequestHook(hook RequestHook)
 }
 
+// RequestHook is called with every request about to prompt the user. A
+// returned *DeniedError denies the request without asking.
+type RequestHook func(permission PermissionRequest) error
+
 type permissionService struct {
 	*pubsub.Broker[PermissionRequest]
 
-	notificationBroker    *pubsub.Broker[PermissionNotification]
-	workingDir            string
-	sessionPermissions    []PermissionRequest
-	sessionPermissionsMu  sync.RWMutex
-	pendingRequests       *csync.Map[string, chan bool]
-	autoApproveSessions   map[string]bool
-	autoApproveSessionsMu sync.RWMutex
-	skip                  bool
-	allowedTools          []string
+	notificationBroker *pubsub.Broker[PermissionNotification]
+	workingDir         string
+	grants             *GrantStore
+	pendingRequests    *csync.Map[string, chan bool]
+	sessionModes       map[string]Mode
+	sessionParents     map[string]string
+	sessionModesMu     sync.RWMutex
+	skip               bool
+	allowedTools       []string
+	policy             *Policy
+	requestHook        RequestHook
 
 	// used to make sure we only process one request at a time
 	requestMu     sync.Mutex
 	activeRequest *PermissionRequest
 }
 
-func (s *permissionService) GrantPersistent(permission PermissionRequest) {
+func (s *permissionService) GrantPersistent(permission PermissionRequest, scope Scope) {
 	s.notificationBroker.Publish(pubsub.CreatedEvent, PermissionNotification{
 		ToolCallID: permission.ToolCallID,
 		Granted:    true,
@@ -82,9 +127,15 @@ func (s *permissionService) GrantPersistent(permission PermissionRequest) {
 		respCh <- true
 	}
 
-	s.sessionPermissionsMu.Lock()
-	s.sessionPermissions = append(s.sessionPermissions, permission)
-	s.sessionPermissionsMu.Unlock()
+	if _, err := s.grants.Add(Grant{
+		Scope:     scope,
+		SessionID: permission.SessionID,
+		ToolName:  permission.ToolName,
+		Action:    permission.Action,
+		Path:      permission.Path,
+	}); err != nil {
+		slog.Error("Failed to save permission grant", "error", err)
+	}
 
 	if s.activeRequest != nil && s.activeRequest.ID == permission.ID {
 		s.activeRequest = nil
@@ -122,9 +173,24 @@ func (s *permissionService) Deny(permission PermissionRequest) {
 	}
 }
 
-func (s *permissionService) Request(opts CreatePermissionRequest) bool {
+// Request asks for permission to run a tool call. It returns nil if the
+// call is allowed, ErrorPermissionDenied if the user denied it, and a
+// *DeniedError if the permission policy or the session mode denied it.
+func (s *permissionService) Request(opts CreatePermissionRequest) error {
+	decision, reason := s.policy.Evaluate(opts)
+	switch decision {
+	case DecisionDeny:
+		s.publishDenied(opts, reason)
+		return &DeniedError{Reason: reason}
+	case DecisionAllow:
+		return nil
+	}
+	if opts.ReadOnly && decision != DecisionAsk {
+		return nil
+	}
+
 	if s.skip {
-		return true
+		return nil
 	}
 
 	// tell the UI that a permission was requested
@@ -134,18 +200,20 @@ func (s *permissionService) Request(opts CreatePermissionRequest) bool {
 	s.requestMu.Lock()
 	defer s.requestMu.Unlock()
 
-	// Check if the tool/action combination is in the allowlist
-	commandKey := opts.ToolName + ":" + opts.Action
-	if slices.Contains(s.allowedTools, commandKey) || slices.Contains(s.allowedTools, opts.ToolName) {
-		return true
+	mode := s.sessionMode(opts.SessionID)
+
+	if mode == ModeApproveAll {
+		return nil
 	}
 
-	s.autoApproveSessionsMu.RLock()
-	autoApprove := s.autoApproveSessions[opts.SessionID]
-	s.autoApproveSessionsMu.RUnlock()
+	// Ask rules always prompt, regardless of the allowlist and of the
+	// permissions granted for the session.
+	ask := decision == DecisionAsk
 
-	if autoApprove {
-		return true
+	// Check if the tool/action combination is in the allowlist
+	commandKey := opts.ToolName + ":" + opts.Action
+	if !ask && (slices.Contains(s.allowedTools, commandKey) || slices.Contains(s.allowedTools, opts.ToolName)) {
+		return nil
 	}
 
 	fileInfo, err := os.Stat(opts.Path)
@@ -172,23 +240,33 @@ func (s *permissionService) Request(opts CreatePermissionRequest) bool {
 		Params:      opts.Params,
 	}
 
-	s.sessionPermissionsMu.RLock()
-	for _, p := range s.sessionPermissions {
-		if p.ToolName == permission.ToolName && p.Action == permission.Action && p.SessionID == permission.SessionID && p.Path == permission.Path {
-			s.sessionPermissionsMu.RUnlock()
-			return true
+	if !ask {
+		granted, err := s.grants.Matches(permission.SessionID, permission.ToolName, permission.Action, permission.Path)
+		if err != nil {
+			slog.Error("Failed to check permission grants", "error", err)
+		}
+		if granted {
+			return nil
 		}
 	}
-	s.sessionPermissionsMu.RUnlock()
 
-	s.sessionPermissionsMu.RLock()
-	for _, p := range s.sessionPermissions {
-		if p.ToolName == permission.ToolName && p.Action == permission.Action && p.SessionID == permission.SessionID && p.Path == permission.Path {
-			s.sessionPermissionsMu.RUnlock()
-			return true
+	switch mode {
+	case ModeDenyUnlisted:
+		reason := fmt.Sprintf("%s needs approval, which is not available in this non-interactive run", describeRequest(opts))
+		s.publishDenied(opts, reason)
+		return &DeniedError{Reason: reason}
+	case ModeFailOnPrompt:
+		s.publishDenied(opts, fmt.Sprintf("%s needs approval, stopping the non-interactive run", describeRequest(opts)))
+		return ErrorPermissionDenied
+	}
+
+	if s.requestHook != nil {
+		var denied *DeniedError
+		if err := s.requestHook(permission); errors.As(err, &denied) {
+			s.publishDenied(opts, denied.Reason)
+			return denied
 		}
 	}
-	s.sessionPermissionsMu.RUnlock()
 
 	s.activeRequest = &permission
 
@@ -199,19 +277,70 @@ func (s *permissionService) Request(opts CreatePermissionRequest) bool {
 	// Publish the request
 	s.Publish(pubsub.CreatedEvent, permission)
 
-	return <-respCh
+	if !<-respCh {
+		return ErrorPermissionDenied
+	}
+	return nil
+}
+
+func (s *permissionService) publishDenied(opts CreatePermissionRequest, reason string) {
+	s.notificationBroker.Publish(pubsub.CreatedEvent, PermissionNotification{
+		ToolCallID: opts.ToolCallID,
+		Denied:     true,
+		ToolName:   opts.ToolName,
+		Reason:     reason,
+	})
+}
+
+func (s *permissionService) SetSessionMode(sessionID string, mode Mode) {
+	s.sessionModesMu.Lock()
+	s.sessionModes[sessionID] = mode
+	s.sessionModesMu.Unlock()
+}
+
+// SetSessionParent makes the session use the mode of its parent when it
+// doesn't have one, so the sessions of sub-agents are handled like the
+// session that started them.
+func (s *permissionService) SetSessionParent(sessionID, parentSessionID string) {
+	s.sessionModesMu.Lock()
+	s.sessionParents[sessionID] = parentSessionID
+	s.sessionModesMu.Unlock()
 }
 
-func (s *permissionService) AutoApproveSession(sessionID string) {
-	s.autoApproveSessionsMu.Lock()
-	s.autoApproveSessions[sessionID] = true
-	s.autoApproveSessionsMu.Unlock()
+// sessionMode returns the mode of the session, or of its closest ancestor
+// with one.
+func (s *permissionService) sessionMode(sessionID string) Mode {
+	s.sessionModesMu.RLock()
+	defer s.sessionModesMu.RUnlock()
+	for range len(s.sessionParents) + 1 {
+		if mode, ok := s.sessionModes[sessionID]; ok {
+			return mode
+		}
+		parentID, ok := s.sessionParents[sessionID]
+		if !ok {
+			break
+		}
+		sessionID = parentID
+	}
+	return ""
 }
 
 func (s *permissionService) SubscribeNotifications(ctx context.Context) <-chan pubsub.Event[PermissionNotification] {
 	return s.notificationBroker.Subscribe(ctx)
 }
 
+func (s *permissionService) ListGrants() ([]Grant, error) {
+	return s.grants.List()
+}
+
+func (s *permissionService) RevokeGrant(id string) error {
+	return s.grants.Revoke(id)
+}
+
+func (s *permissionService) SetRequestHook(hook RequestHook) {
+	s.requestHook = hook
+}
+
 func (s *permissionService) SetSkipRequests(skip bool) {
 	s.skip = skip
 }
@@ -220,15 +349,20 @@ func (s *permissionService) SkipRequests() bool {
 	return s.skip
 }
 
-func NewPermissionService(workingDir string, skip bool, allowedTools []string) Service {
+func NewPermissionService(workingDir string, skip bool, allowedTools []string, policy *Policy, grants *GrantStore) Service {
+	if grants == nil {
+		grants = NewGrantStore("", "")
+	}
 	return &permissionService{
-		Broker:              pubsub.NewBroker[PermissionRequest](),
-		notificationBroker:  pubsub.NewBroker[PermissionNotification](),
-		workingDir:          workingDir,
-		sessionPermissions:  make([]PermissionRequest, 0),
-		autoApproveSessions: make(map[string]bool),
-		skip:                skip,
-		allowedTools:        allowedTools,
-		pendingRequests:     csync.NewMap[string, chan bool](),
+		Broker:             pubsub.NewBroker[PermissionRequest](),
+		notificationBroker: pubsub.NewBroker[PermissionNotification](),
+		workingDir:         workingDir,
+		grants:             grants,
+		sessionModes:       make(map[string]Mode),
+		sessionParents:     make(map[string]string),
+		skip:               skip,
+		allowedTools:       allowedTools,
+		policy:             policy,
+		pendingRequests:    csync.NewMap[string, chan bool](),
 	}
 }
@@ -54,7 +54,7 @@ func TestPermissionService_AllowedCommands(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			service := NewPermissionService("/tmp", false, tt.allowedTools)
+			service := NewPermissionService("/tmp", false, tt.allowedTools, nil, nil)
 
 			// Create a channel to capture the permission request
 			// Since we're testing the allowlist logic, we need to simulate the request
@@ -79,7 +79,7 @@ func TestPermissionService_AllowedCommands(t *testing.T) {
 }
 
 func TestPermissionService_SkipMode(t *testing.T) {
-	service := NewPermissionService("/tmp", true, []string{})
+	service := NewPermissionService("/tmp", true, []string{}, nil, nil)
 
 	result := service.Request(CreatePermissionRequest{
 		SessionID:   "test-session",
@@ -87,7 +87,7 @@ func TestPermissionService_SkipMode(t *testing.T) {
 		Action:      "execute",
 		Description: "test command",
 		Path:        "/tmp",
-	})
+	}) == nil
 
 	if !result {
 		t.Error("expected permission to be granted in skip mode")
@@ -96,7 +96,7 @@ func TestPermissionService_SkipMode(t *testing.T) {
 
 func TestPermissionService_SequentialProperties(t *testing.T) {
 	t.Run("Sequential permission requests with persistent grants", func(t *testing.T) {
-		service := NewPermissionService("/tmp", false, []string{})
+		service := NewPermissionService("/tmp", false, []string{}, nil, nil)
 
 		req1 := CreatePermissionRequest{
 			SessionID:   "session1",
@@ -115,14 +115,14 @@ func TestPermissionService_SequentialProperties(t *testing.T) {
 
 		go func() {
 			defer wg.Done()
-			result1 = service.Request(req1)
+			result1 = service.Request(req1) == nil
 		}()
 
 		var permissionReq PermissionRequest
 		event := <-events
 
 		permissionReq = event.Payload
-		service.GrantPersistent(permissionReq)
+		service.GrantPersistent(permissionReq, ScopeSession)
 
 		wg.Wait()
 		assert.True(t, result1, "First request should be granted")
@@ -136,11 +136,11 @@ func TestPermissionService_SequentialProperties(t *testing.T) {
 			Params:      map[string]string{"file": "test.txt"},
 			Path:        "/tmp/test.txt",
 		}
-		result2 := service.Request(req2)
+		result2 := service.Request(req2) == nil
 		assert.True(t, result2, "Second request should be auto-approved")
 	})
 	t.Run("Sequential requests with temporary grants", func(t *testing.T) {
-		service := NewPermissionService("/tmp", false, []string{})
+		service := NewPermissionService("/tmp", false, []string{}, nil, nil)
 
 		req := CreatePermissionRequest{
 			SessionID:   "session2",
@@ -158,7 +158,7 @@ func TestPermissionService_SequentialProperties(t *testing.T) {
 
 		go func() {
 			defer wg.Done()
-			result1 = service.Request(req)
+			result1 = service.Request(req) == nil
 		}()
 
 		var permissionReq PermissionRequest
@@ -174,7 +174,7 @@ func TestPermissionService_SequentialProperties(t *testing.T) {
 
 		go func() {
 			defer wg.Done()
-			result2 = service.Request(req)
+			result2 = service.Request(req) == nil
 		}()
 
 		event = <-events
@@ -184,7 +184,7 @@ func TestPermissionService_SequentialProperties(t *testing.T) {
 		assert.False(t, result2, "Second request should be denied")
 	})
 	t.Run("Concurrent requests with different outcomes", func(t *testing.T) {
-		service := NewPermissionService("/tmp", false, []string{})
+		service := NewPermissionService("/tmp", false, []string{}, nil, nil)
 
 		events := service.Subscribe(t.Context())
 
@@ -219,7 +219,7 @@ func TestPermissionService_SequentialProperties(t *testing.T) {
 			wg.Add(1)
 			go func(index int, request CreatePermissionRequest) {
 				defer wg.Done()
-				results = append(results, service.Request(request))
+				results = append(results, service.Request(request) == nil)
 			}(i, req)
 		}
 
@@ -229,7 +229,7 @@ func TestPermissionService_SequentialProperties(t *testing.T) {
 			case "tool1":
 				service.Grant(event.Payload)
 			case "tool2":
-				service.GrantPersistent(event.Payload)
+				service.GrantPersistent(event.Payload, ScopeSession)
 			case "tool3":
 				service.Deny(event.Payload)
 			}
@@ -245,7 +245,129 @@ func TestPermissionService_SequentialProperties(t *testing.T) {
 		assert.Equal(t, 2, grantedCount, "Should have 2 granted and 1 denied")
 		secondReq := requests[1]
 		secondReq.Description = "Repeat of second request"
-		result := service.Request(secondReq)
+		result := service.Request(secondReq) == nil
 		assert.True(t, result, "Repeated request should be auto-approved due to persistent permission")
 	})
 }
+
+func TestPermissionService_SessionModes(t *testing.T) {
+	request := CreatePermissionRequest{
+		SessionID: "test-session",
+		ToolName:  "bash",
+		Action:    "execute",
+		Path:      "/tmp",
+		Command:   "make build",
+	}
+
+	t.Run("approve all", func(t *testing.T) {
+		service := NewPermissionService("/tmp", false, []string{}, nil, nil)
+		service.SetSessionMode(request.SessionID, ModeApproveAll)
+		assert.NoError(t, service.Request(request))
+	})
+
+	t.Run("deny unlisted", func(t *testing.T) {
+		service := NewPermissionService("/tmp", false, []string{}, nil, nil)
+		service.SetSessionMode(request.SessionID, ModeDenyUnlisted)
+		notifications := service.SubscribeNotifications(t.Context())
+
+		var denied *DeniedError
+		assert.ErrorAs(t, service.Request(request), &denied)
+
+		// The request notification comes first, then the denial.
+		<-notifications
+		event := <-notifications
+		assert.True(t, event.Payload.Denied)
+		assert.Equal(t, "bash", event.Payload.ToolName)
+		assert.NotEmpty(t, event.Payload.Reason)
+	})
+
+	t.Run("deny unlisted allows listed tools", func(t *testing.T) {
+		service := NewPermissionService("/tmp", false, []string{"bash:execute"}, nil, nil)
+		service.SetSessionMode(request.SessionID, ModeDenyUnlisted)
+		assert.NoError(t, service.Request(request))
+	})
+
+	t.Run("fail on prompt", func(t *testing.T) {
+		service := NewPermissionService("/tmp", false, []string{}, nil, nil)
+		service.SetSessionMode(request.SessionID, ModeFailOnPrompt)
+		assert.ErrorIs(t, service.Request(request), ErrorPermissionDenied)
+	})
+
+	// The sessions of sub-agents, and of the sub-agents they start, ask for
+	// permission without a mode of their own.
+	subAgentRequest := request
+	subAgentRequest.SessionID = "nested-task-session"
+
+	t.Run("sub-agent deny unlisted", func(t *testing.T) {
+		service := NewPermissionService("/tmp", false, []string{}, nil, nil)
+		service.SetSessionMode(request.SessionID, ModeDenyUnlisted)
+		service.SetSessionParent("task-session", request.SessionID)
+		service.SetSessionParent(subAgentRequest.SessionID, "task-session")
+
+		var denied *DeniedError
+		assert.ErrorAs(t, service.Request(subAgentRequest), &denied)
+	})
+
+	t.Run("sub-agent fail on prompt", func(t *testing.T) {
+		service := NewPermissionService("/tmp", false, []string{}, nil, nil)
+		service.SetSessionMode(request.SessionID, ModeFailOnPrompt)
+		service.SetSessionParent("task-session", request.SessionID)
+		service.SetSessionParent(subAgentRequest.SessionID, "task-session")
+
+		assert.ErrorIs(t, service.Request(subAgentRequest), ErrorPermissionDenied)
+	})
+
+	t.Run("sub-agent own mode", func(t *testing.T) {
+		service := NewPermissionService("/tmp", false, []string{}, nil, nil)
+		service.SetSessionMode(request.SessionID, ModeFailOnPrompt)
+		service.SetSessionParent(subAgentRequest.SessionID, request.SessionID)
+		service.SetSessionMode(subAgentRequest.SessionID, ModeApproveAll)
+
+		assert.NoError(t, service.Request(subAgentRequest))
+	})
+}
+
+func TestPermissionService_RequestHook(t *testing.T) {
+	request := CreatePermissionRequest{
+		SessionID: "test-session",
+		ToolName:  "bash",
+		Action:    "execute",
+		Path:      "/tmp",
+		Command:   "rm -rf build",
+	}
+
+	t.Run("denies", func(t *testing.T) {
+		service := NewPermissionService("/tmp", false, []string{}, nil, nil)
+		var hooked PermissionRequest
+		service.SetRequestHook(func(req PermissionRequest) error {
+			hooked = req
+			return &DeniedError{Reason: "no rm"}
+		})
+
+		var denied *DeniedError
+		assert.ErrorAs(t, service.Request(request), &denied)
+		assert.Equal(t, "no rm", denied.Reason)
+		assert.Equal(t, "bash", hooked.ToolName)
+	})
+
+	t.Run("falls through to the user", func(t *testing.T) {
+		service := NewPermissionService("/tmp", false, []string{}, nil, nil)
+		service.SetRequestHook(func(PermissionRequest) error { return nil })
+		events := service.Subscribe(t.Context())
+
+		result := make(chan error, 1)
+		go func() { result <- service.Request(request) }()
+		event := <-events
+		service.Grant(event.Payload)
+		assert.NoError(t, <-result)
+	})
+
+	t.Run("not run for allowed tools", func(t *testing.T) {
+		service := NewPermissionService("/tmp", false, []string{"bash"}, nil, nil)
+		service.SetRequestHook(func(PermissionRequest) error {
+			t.Fatal("hook run for an allowed tool")
+			return nil
+		})
+		assert.NoError(t, service.Request(request))
+	})
+}
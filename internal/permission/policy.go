@@ -0,0 +1,377 @@
+package permission
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/charmbracelet/crush/internal/config"
+	"mvdan.cc/sh/v3/expand"
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// Decision is the outcome of evaluating the permission policy for a request.
+type Decision int
+
+const (
+	// DecisionNone means no rule matched, and the regular permission checks
+	// apply.
+	DecisionNone Decision = iota
+	// DecisionAllow means the request is granted without prompting.
+	DecisionAllow
+	// DecisionDeny means the request is denied without prompting.
+	DecisionDeny
+	// DecisionAsk means the user is always prompted, even if the tool is in
+	// the allowed tools or was granted for the session.
+	DecisionAsk
+)
+
+// DeniedError is returned when a request is denied by the permission policy
+// instead of by the user. The reason is reported to the model, which can carry
+// on with something else.
+type DeniedError struct {
+	Reason string
+}
+
+func (e *DeniedError) Error() string {
+	return "permission denied by policy: " + e.Reason
+}
+
+// Policy is a compiled set of allow, deny and ask rules.
+type Policy struct {
+	workingDir string
+	allow      []rule
+	deny       []rule
+	ask        []rule
+}
+
+type rule struct {
+	config.PermissionRule
+	paths        []string
+	command      *regexp.Regexp
+	commandRegex *regexp.Regexp
+}
+
+// NewPolicy compiles the given permission rules. Relative path patterns are
+// matched from the working directory.
+func NewPolicy(workingDir string, permissions *config.Permissions) (*Policy, error) {
+	p := &Policy{workingDir: workingDir}
+	if permissions == nil {
+		return p, nil
+	}
+
+	var err error
+	if p.allow, err = compileRules("allow", permissions.Allow); err != nil {
+		return nil, err
+	}
+	if p.deny, err = compileRules("deny", permissions.Deny); err != nil {
+		return nil, err
+	}
+	if p.ask, err = compileRules("ask", permissions.Ask); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func compileRules(kind string, rules []config.PermissionRule) ([]rule, error) {
+	compiled := make([]rule, 0, len(rules))
+	for i, r := range rules {
+		c := rule{PermissionRule: r}
+		if r.Tool != "" {
+			if _, err := path.Match(r.Tool, ""); err != nil {
+				return nil, fmt.Errorf("invalid tool pattern in %s rule %d: %w", kind, i+1, err)
+			}
+		}
+		if r.Path != "" {
+			c.paths = expandPathPattern(r.Path)
+			for _, pattern := range c.paths {
+				if !doublestar.ValidatePattern(pattern) {
+					return nil, fmt.Errorf("invalid path pattern in %s rule %d: %s", kind, i+1, r.Path)
+				}
+			}
+		}
+		if r.Command != "" {
+			c.command = compileCommandPattern(r.Command)
+		}
+		if r.CommandRegex != "" {
+			re, err := regexp.Compile(r.CommandRegex)
+			if err != nil {
+				return nil, fmt.Errorf("invalid command regex in %s rule %d: %w", kind, i+1, err)
+			}
+			c.commandRegex = re
+		}
+		compiled = append(compiled, c)
+	}
+	return compiled, nil
+}
+
+// expandPathPattern returns the globs a path pattern stands for. Like in
+// .gitignore files, patterns without a slash match at any depth, and
+// patterns matching a directory also match everything inside it.
+func expandPathPattern(pattern string) []string {
+	pattern = filepath.ToSlash(pattern)
+	pattern = strings.TrimPrefix(pattern, "./")
+	pattern = strings.TrimSuffix(pattern, "/")
+
+	bases := []string{pattern}
+	if !strings.Contains(pattern, "/") {
+		bases = append(bases, "**/"+pattern)
+	}
+	var patterns []string
+	for _, base := range bases {
+		patterns = append(patterns, base)
+		if !strings.HasSuffix(base, "/**") {
+			patterns = append(patterns, base+"/**")
+		}
+	}
+	return patterns
+}
+
+// compileCommandPattern turns a command pattern into a regular expression
+// matching whole commands, where * matches any characters. A trailing " *"
+// also matches the command without arguments, so "git status *" matches
+// "git status".
+func compileCommandPattern(pattern string) *regexp.Regexp {
+	pattern = strings.TrimSpace(pattern)
+	suffix := ""
+	if trimmed, ok := strings.CutSuffix(pattern, " *"); ok {
+		pattern = trimmed
+		suffix = "(?: .*)?"
+	}
+	parts := strings.Split(pattern, "*")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+	return regexp.MustCompile("^" + strings.Join(parts, ".*") + suffix + "$")
+}
+
+// Evaluate returns the decision of the policy for the given request, along
+// with the reason when it's denied. Deny rules take precedence over ask
+// rules, which take precedence over allow rules.
+func (p *Policy) Evaluate(opts CreatePermissionRequest) (Decision, string) {
+	if p == nil {
+		return DecisionNone, ""
+	}
+
+	command := parseCommand(opts.Command)
+	files := opts.Files
+	if len(files) == 0 && opts.Path != "" {
+		files = []string{opts.Path}
+	}
+	files = append(slices.Clone(files), command.redirects...)
+	paths := make([]string, len(files))
+	for i, f := range files {
+		paths[i] = p.relativePath(f)
+	}
+	commands := command.commands
+
+	for _, r := range p.deny {
+		if r.matches(opts, paths, commands, false) {
+			reason := r.Reason
+			if reason == "" {
+				reason = fmt.Sprintf("%s is not allowed by the permission rules", describeRequest(opts))
+			}
+			return DecisionDeny, reason
+		}
+	}
+	for _, r := range p.ask {
+		if r.matches(opts, paths, commands, false) {
+			return DecisionAsk, ""
+		}
+	}
+	for _, r := range p.allow {
+		// Allowing a command doesn't allow it to write anywhere, so commands
+		// writing to files need a rule with paths covering them.
+		if command.writes && len(r.paths) == 0 {
+			continue
+		}
+		if r.matches(opts, paths, commands, true) {
+			return DecisionAllow, ""
+		}
+	}
+	return DecisionNone, ""
+}
+
+// matches reports whether the rule applies to the request. When all is set,
+// every path and command of the request must match the rule, otherwise a
+// single one is enough.
+func (r rule) matches(opts CreatePermissionRequest, paths, commands []string, all bool) bool {
+	if r.NeedsApproval && opts.ReadOnly {
+		return false
+	}
+	if r.Tool != "" {
+		if ok, _ := path.Match(r.Tool, opts.ToolName); !ok {
+			return false
+		}
+	}
+	if r.Action != "" && r.Action != opts.Action {
+		return false
+	}
+	if len(r.paths) > 0 && !matchAll(paths, r.matchPath, all) {
+		return false
+	}
+	if r.command != nil && !matchAll(commands, r.command.MatchString, all) {
+		return false
+	}
+	if r.commandRegex != nil && !matchAll(commands, r.commandRegex.MatchString, all) {
+		return false
+	}
+	return true
+}
+
+func (r rule) matchPath(p string) bool {
+	for _, pattern := range r.paths {
+		if ok, _ := doublestar.Match(pattern, p); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// matchAll reports whether all the values match when all is set, or any of
+// them otherwise. It's false if there are no values.
+func matchAll(values []string, match func(string) bool, all bool) bool {
+	if len(values) == 0 {
+		return false
+	}
+	if all {
+		return !slices.ContainsFunc(values, func(v string) bool { return !match(v) })
+	}
+	return slices.ContainsFunc(values, match)
+}
+
+// relativePath makes the path relative to the working directory when it's
+// inside it, using forward slashes.
+func (p *Policy) relativePath(f string) string {
+	if filepath.IsAbs(f) && p.workingDir != "" {
+		if rel, err := filepath.Rel(p.workingDir, f); err == nil && !strings.HasPrefix(rel, "..") {
+			f = rel
+		}
+	}
+	return filepath.ToSlash(f)
+}
+
+// parsedCommand is a shell command as matched by the policy rules.
+type parsedCommand struct {
+	// commands are the simple commands it runs, so "go test ./... && rm -rf
+	// /" is matched as two separate commands.
+	commands []string
+	// redirects are the files it redirects input or output from or to.
+	redirects []string
+	// writes is set when any of the redirects writes to a file.
+	writes bool
+}
+
+// parseCommand splits a shell command into the simple commands it runs and
+// the files it redirects to. Commands that can't be parsed are returned as
+// is.
+func parseCommand(command string) parsedCommand {
+	command = strings.TrimSpace(command)
+	if command == "" {
+		return parsedCommand{}
+	}
+	file, err := syntax.NewParser().Parse(strings.NewReader(command), "")
+	if err != nil {
+		return parsedCommand{commands: []string{command}}
+	}
+
+	var (
+		parsed  parsedCommand
+		printer = syntax.NewPrinter(syntax.Minify(true))
+	)
+	syntax.Walk(file, func(node syntax.Node) bool {
+		switch node := node.(type) {
+		case *syntax.Redirect:
+			if target, writes, ok := redirectTarget(node); ok {
+				parsed.redirects = append(parsed.redirects, target)
+				parsed.writes = parsed.writes || writes
+			}
+		case *syntax.CallExpr:
+			if len(node.Args) == 0 {
+				return true
+			}
+			var sb strings.Builder
+			for i, arg := range node.Args {
+				if i > 0 {
+					sb.WriteByte(' ')
+				}
+				if err := printer.Print(&sb, arg); err != nil {
+					return true
+				}
+			}
+			parsed.commands = append(parsed.commands, sb.String())
+		}
+		return true
+	})
+	if len(parsed.commands) == 0 {
+		parsed.commands = []string{command}
+	}
+	return parsed
+}
+
+// redirectTarget returns the file the redirection reads or writes, and
+// whether it writes to it. It's not ok for here-documents, file descriptor
+// duplications like 2>&1, and /dev/null.
+func redirectTarget(redirect *syntax.Redirect) (target string, writes bool, ok bool) {
+	if redirect.Word == nil {
+		return "", false, false
+	}
+	target = wordValue(redirect.Word)
+	switch redirect.Op {
+	case syntax.RdrOut, syntax.AppOut, syntax.RdrInOut, syntax.ClbOut, syntax.RdrAll, syntax.AppAll:
+		writes = true
+	case syntax.RdrIn:
+	case syntax.DplOut:
+		if target == "-" || strings.Trim(target, "0123456789") == "" {
+			return "", false, false
+		}
+		// Bash treats >&file like &>file.
+		writes = true
+	default:
+		return "", false, false
+	}
+	if target == "" || target == "/dev/null" {
+		return "", false, false
+	}
+	return target, writes, true
+}
+
+// wordValue returns the value of the word when it's made of literals and
+// quotes only, or the word as written otherwise.
+func wordValue(word *syntax.Word) string {
+	literal := !slices.ContainsFunc(word.Parts, func(part syntax.WordPart) bool {
+		switch part := part.(type) {
+		case *syntax.Lit, *syntax.SglQuoted:
+			return false
+		case *syntax.DblQuoted:
+			return slices.ContainsFunc(part.Parts, func(part syntax.WordPart) bool {
+				_, ok := part.(*syntax.Lit)
+				return !ok
+			})
+		}
+		return true
+	})
+	if literal {
+		if s, err := expand.Literal(&expand.Config{}, word); err == nil {
+			return s
+		}
+	}
+	var sb strings.Builder
+	_ = syntax.NewPrinter().Print(&sb, word)
+	return sb.String()
+}
+
+func describeRequest(opts CreatePermissionRequest) string {
+	switch {
+	case opts.Command != "":
+		return fmt.Sprintf("running %q", opts.Command)
+	case len(opts.Files) > 0:
+		return fmt.Sprintf("%s %s on %s", opts.ToolName, opts.Action, strings.Join(opts.Files, ", "))
+	default:
+		return fmt.Sprintf("%s %s", opts.ToolName, opts.Action)
+	}
+}
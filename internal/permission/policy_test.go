@@ -0,0 +1,230 @@
+package permission
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicy_Evaluate(t *testing.T) {
+	t.Parallel()
+
+	policy, err := NewPolicy("/project", &config.Permissions{
+		Allow: []config.PermissionRule{
+			{Tool: "bash", Command: "go test *"},
+			{Tool: "bash", CommandRegex: `^git (status|diff)\b`},
+			{Tool: "edit", Path: "internal/"},
+		},
+		Deny: []config.PermissionRule{
+			{Path: ".env*", Reason: "secrets are off limits"},
+			{Path: ".git/"},
+			{Tool: "bash", Command: "make *", NeedsApproval: true},
+		},
+		Ask: []config.PermissionRule{
+			{Tool: "edit", Path: "internal/config/**"},
+		},
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		request  CreatePermissionRequest
+		expected Decision
+	}{
+		{
+			name:     "allowed command",
+			request:  CreatePermissionRequest{ToolName: "bash", Action: "execute", Command: "go test ./..."},
+			expected: DecisionAllow,
+		},
+		{
+			name:     "rule only for calls needing approval leaves out read-only ones",
+			request:  CreatePermissionRequest{ToolName: "bash", Action: "execute", Command: "make --version", ReadOnly: true},
+			expected: DecisionNone,
+		},
+		{
+			name:     "rule only for calls needing approval",
+			request:  CreatePermissionRequest{ToolName: "bash", Action: "execute", Command: "make build"},
+			expected: DecisionDeny,
+		},
+		{
+			name:     "allowed command without arguments",
+			request:  CreatePermissionRequest{ToolName: "bash", Action: "execute", Command: "go test"},
+			expected: DecisionAllow,
+		},
+		{
+			name:     "allowed command regex",
+			request:  CreatePermissionRequest{ToolName: "bash", Action: "execute", Command: "git diff HEAD"},
+			expected: DecisionAllow,
+		},
+		{
+			name:     "chained commands must all be allowed",
+			request:  CreatePermissionRequest{ToolName: "bash", Action: "execute", Command: "go test ./... && rm -rf /"},
+			expected: DecisionNone,
+		},
+		{
+			name:     "unmatched command",
+			request:  CreatePermissionRequest{ToolName: "bash", Action: "execute", Command: "go build ./..."},
+			expected: DecisionNone,
+		},
+		{
+			name:     "allowed path",
+			request:  CreatePermissionRequest{ToolName: "edit", Action: "write", Files: []string{"/project/internal/app/app.go"}},
+			expected: DecisionAllow,
+		},
+		{
+			name:     "path outside the allowed directory",
+			request:  CreatePermissionRequest{ToolName: "edit", Action: "write", Files: []string{"/project/cmd/main.go"}},
+			expected: DecisionNone,
+		},
+		{
+			name:     "ask takes precedence over allow",
+			request:  CreatePermissionRequest{ToolName: "edit", Action: "write", Files: []string{"/project/internal/config/config.go"}},
+			expected: DecisionAsk,
+		},
+		{
+			name:     "denied file at any depth",
+			request:  CreatePermissionRequest{ToolName: "write", Action: "write", Files: []string{"/project/internal/.env.local"}},
+			expected: DecisionDeny,
+		},
+		{
+			name:     "denied directory",
+			request:  CreatePermissionRequest{ToolName: "edit", Action: "write", Files: []string{"/project/.git/config"}},
+			expected: DecisionDeny,
+		},
+		{
+			name:     "deny takes precedence over allow",
+			request:  CreatePermissionRequest{ToolName: "edit", Action: "write", Files: []string{"/project/internal/.env"}},
+			expected: DecisionDeny,
+		},
+		{
+			name:     "redirect to a denied file",
+			request:  CreatePermissionRequest{ToolName: "bash", Action: "execute", Path: "/project", Command: "cat notes.txt > .env"},
+			expected: DecisionDeny,
+		},
+		{
+			name:     "quoted redirect to a denied file",
+			request:  CreatePermissionRequest{ToolName: "bash", Action: "execute", Path: "/project", Command: `go test ./... >> "internal/.env"`},
+			expected: DecisionDeny,
+		},
+		{
+			name:     "input from a denied file",
+			request:  CreatePermissionRequest{ToolName: "bash", Action: "execute", Path: "/project", Command: "wc -l < .env.local"},
+			expected: DecisionDeny,
+		},
+		{
+			name:     "allowed command writing to a file",
+			request:  CreatePermissionRequest{ToolName: "bash", Action: "execute", Path: "/project", Command: "go test ./... > out.txt"},
+			expected: DecisionNone,
+		},
+		{
+			name:     "allowed command discarding its output",
+			request:  CreatePermissionRequest{ToolName: "bash", Action: "execute", Path: "/project", Command: "go test ./... > /dev/null 2>&1"},
+			expected: DecisionAllow,
+		},
+		{
+			name:     "path falls back to the request path",
+			request:  CreatePermissionRequest{ToolName: "download", Action: "download", Path: "/project/.env"},
+			expected: DecisionDeny,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			decision, _ := policy.Evaluate(tt.request)
+			require.Equal(t, tt.expected, decision)
+		})
+	}
+}
+
+func TestPolicy_DenyReason(t *testing.T) {
+	t.Parallel()
+
+	policy, err := NewPolicy("/project", &config.Permissions{
+		Deny: []config.PermissionRule{
+			{Path: ".env*", Reason: "secrets are off limits"},
+			{Tool: "bash", Command: "rm *"},
+		},
+	})
+	require.NoError(t, err)
+
+	_, reason := policy.Evaluate(CreatePermissionRequest{ToolName: "edit", Action: "write", Files: []string{"/project/.env"}})
+	require.Equal(t, "secrets are off limits", reason)
+
+	_, reason = policy.Evaluate(CreatePermissionRequest{ToolName: "bash", Action: "execute", Command: "ls && rm -rf build"})
+	require.Equal(t, `running "ls && rm -rf build" is not allowed by the permission rules`, reason)
+}
+
+func TestPolicy_InvalidRules(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewPolicy("/project", &config.Permissions{
+		Deny: []config.PermissionRule{{CommandRegex: "("}},
+	})
+	require.Error(t, err)
+
+	_, err = NewPolicy("/project", &config.Permissions{
+		Allow: []config.PermissionRule{{Tool: "[bash"}},
+	})
+	require.Error(t, err)
+}
+
+func TestPermissionService_Policy(t *testing.T) {
+	t.Parallel()
+
+	policy, err := NewPolicy("/project", &config.Permissions{
+		Deny: []config.PermissionRule{{Tool: "bash", Command: "rm *"}},
+		Ask:  []config.PermissionRule{{Tool: "bash", Command: "git push *"}},
+	})
+	require.NoError(t, err)
+
+	t.Run("deny applies even when skipping requests", func(t *testing.T) {
+		t.Parallel()
+
+		service := NewPermissionService("/project", true, nil, policy, nil)
+		err := service.Request(CreatePermissionRequest{SessionID: "s", ToolName: "bash", Action: "execute", Command: "rm -rf /"})
+		var denied *DeniedError
+		require.ErrorAs(t, err, &denied)
+	})
+
+	t.Run("ask bypasses the allowlist", func(t *testing.T) {
+		t.Parallel()
+
+		service := NewPermissionService("/project", false, []string{"bash"}, policy, nil)
+		events := service.Subscribe(t.Context())
+
+		result := make(chan error, 1)
+		go func() {
+			result <- service.Request(CreatePermissionRequest{SessionID: "s", ToolName: "bash", Action: "execute", Path: "/project", Command: "git push origin main"})
+		}()
+
+		event := <-events
+		service.Deny(event.Payload)
+		require.ErrorIs(t, <-result, ErrorPermissionDenied)
+
+		require.NoError(t, service.Request(CreatePermissionRequest{SessionID: "s", ToolName: "bash", Action: "execute", Path: "/project", Command: "git status"}))
+	})
+
+	t.Run("rules apply to read-only requests", func(t *testing.T) {
+		t.Parallel()
+
+		service := NewPermissionService("/project", false, nil, policy, nil)
+		events := service.Subscribe(t.Context())
+
+		require.NoError(t, service.Request(CreatePermissionRequest{SessionID: "s", ToolName: "bash", Action: "execute", Path: "/project", Command: "git status", ReadOnly: true}))
+
+		var denied *DeniedError
+		err := service.Request(CreatePermissionRequest{SessionID: "s", ToolName: "bash", Action: "execute", Path: "/project", Command: "rm -rf /", ReadOnly: true})
+		require.ErrorAs(t, err, &denied)
+
+		result := make(chan error, 1)
+		go func() {
+			result <- service.Request(CreatePermissionRequest{SessionID: "s", ToolName: "bash", Action: "execute", Path: "/project", Command: "git push origin main", ReadOnly: true})
+		}()
+		event := <-events
+		service.Grant(event.Payload)
+		require.NoError(t, <-result)
+	})
+}
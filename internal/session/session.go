@@ -3,6 +3,9 @@ package session
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
 
 	"github.com/charmbracelet/crush/internal/db"
 	"github.com/charmbracelet/crush/internal/pubsub"
@@ -20,6 +23,19 @@ type Session struct {
 	Cost             float64
 	CreatedAt        int64
 	UpdatedAt        int64
+	// The session this one was forked from, if any.
+	ForkedFromSessionID string
+	// The agent the session is run with, the coder when empty.
+	AgentID string
+	// Whether the agent only plans the work, with read-only tools, until
+	// the plan is approved.
+	PlanMode bool
+}
+
+// IsAttempt reports whether the session is an attempt kept when retrying the
+// last turn of its parent.
+func (s Session) IsAttempt() bool {
+	return s.ParentSessionID != "" && s.ForkedFromSessionID == s.ParentSessionID
 }
 
 type Service interface {
@@ -27,10 +43,22 @@ type Service interface {
 	Create(ctx context.Context, title string) (Session, error)
 	CreateTitleSession(ctx context.Context, parentSessionID string) (Session, error)
 	CreateTaskSession(ctx context.Context, toolCallID, parentSessionID, title string) (Session, error)
+	CreateForkSession(ctx context.Context, forkedFromSessionID, title string) (Session, error)
+	// CreateAttemptSession creates a session forked from the given one that
+	// is kept as its child, out of the list of sessions.
+	CreateAttemptSession(ctx context.Context, parentSessionID, title string) (Session, error)
 	Get(ctx context.Context, id string) (Session, error)
 	List(ctx context.Context) ([]Session, error)
+	// ListAll returns all the sessions, including the ones of sub-agents.
+	ListAll(ctx context.Context) ([]Session, error)
 	Save(ctx context.Context, session Session) (Session, error)
+	// Delete deletes the session along with its child sessions, and theirs.
 	Delete(ctx context.Context, id string) error
+	// LoadShell returns the working directory and the environment variables
+	// the shell of the session was saved with. The working directory is empty
+	// when it was never saved.
+	LoadShell(ctx context.Context, sessionID string) (workingDir string, env []string, err error)
+	SaveShell(ctx context.Context, sessionID, workingDir string, env []string) error
 }
 
 type service struct {
@@ -65,6 +93,35 @@ func (s *service) CreateTaskSession(ctx context.Context, toolCallID, parentSessi
 	return session, nil
 }
 
+func (s *service) CreateForkSession(ctx context.Context, forkedFromSessionID, title string) (Session, error) {
+	dbSession, err := s.q.CreateSession(ctx, db.CreateSessionParams{
+		ID:                  uuid.New().String(),
+		Title:               title,
+		ForkedFromSessionID: sql.NullString{String: forkedFromSessionID, Valid: true},
+	})
+	if err != nil {
+		return Session{}, err
+	}
+	session := s.fromDBItem(dbSession)
+	s.Publish(pubsub.CreatedEvent, session)
+	return session, nil
+}
+
+func (s *service) CreateAttemptSession(ctx context.Context, parentSessionID, title string) (Session, error) {
+	dbSession, err := s.q.CreateSession(ctx, db.CreateSessionParams{
+		ID:                  uuid.New().String(),
+		ParentSessionID:     sql.NullString{String: parentSessionID, Valid: true},
+		Title:               title,
+		ForkedFromSessionID: sql.NullString{String: parentSessionID, Valid: true},
+	})
+	if err != nil {
+		return Session{}, err
+	}
+	session := s.fromDBItem(dbSession)
+	s.Publish(pubsub.CreatedEvent, session)
+	return session, nil
+}
+
 func (s *service) CreateTitleSession(ctx context.Context, parentSessionID string) (Session, error) {
 	dbSession, err := s.q.CreateSession(ctx, db.CreateSessionParams{
 		ID:              "title-" + parentSessionID,
@@ -111,6 +168,11 @@ func (s *service) Save(ctx context.Context, session Session) (Session, error) {
 			Valid:  session.SummaryMessageID != "",
 		},
 		Cost: session.Cost,
+		AgentID: sql.NullString{
+			String: session.AgentID,
+			Valid:  session.AgentID != "",
+		},
+		PlanMode: session.PlanMode,
 	})
 	if err != nil {
 		return Session{}, err
@@ -120,6 +182,36 @@ func (s *service) Save(ctx context.Context, session Session) (Session, error) {
 	return session, nil
 }
 
+func (s *service) LoadShell(ctx context.Context, sessionID string) (string, []string, error) {
+	dbShell, err := s.q.GetSessionShell(ctx, sessionID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil, nil
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	var env []string
+	if err := json.Unmarshal([]byte(dbShell.Env), &env); err != nil {
+		return "", nil, fmt.Errorf("failed to decode the shell environment: %w", err)
+	}
+	return dbShell.WorkingDir, env, nil
+}
+
+func (s *service) SaveShell(ctx context.Context, sessionID, workingDir string, env []string) error {
+	if env == nil {
+		env = []string{}
+	}
+	data, err := json.Marshal(env)
+	if err != nil {
+		return fmt.Errorf("failed to encode the shell environment: %w", err)
+	}
+	return s.q.UpsertSessionShell(ctx, db.UpsertSessionShellParams{
+		SessionID:  sessionID,
+		WorkingDir: workingDir,
+		Env:        string(data),
+	})
+}
+
 func (s *service) List(ctx context.Context) ([]Session, error) {
 	dbSessions, err := s.q.ListSessions(ctx)
 	if err != nil {
@@ -132,18 +224,33 @@ func (s *service) List(ctx context.Context) ([]Session, error) {
 	return sessions, nil
 }
 
+func (s *service) ListAll(ctx context.Context) ([]Session, error) {
+	dbSessions, err := s.q.ListAllSessions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sessions := make([]Session, len(dbSessions))
+	for i, dbSession := range dbSessions {
+		sessions[i] = s.fromDBItem(dbSession)
+	}
+	return sessions, nil
+}
+
 func (s service) fromDBItem(item db.Session) Session {
 	return Session{
-		ID:               item.ID,
-		ParentSessionID:  item.ParentSessionID.String,
-		Title:            item.Title,
-		MessageCount:     item.MessageCount,
-		PromptTokens:     item.PromptTokens,
-		CompletionTokens: item.CompletionTokens,
-		SummaryMessageID: item.SummaryMessageID.String,
-		Cost:             item.Cost,
-		CreatedAt:        item.CreatedAt,
-		UpdatedAt:        item.UpdatedAt,
+		ID:                  item.ID,
+		ParentSessionID:     item.ParentSessionID.String,
+		Title:               item.Title,
+		MessageCount:        item.MessageCount,
+		PromptTokens:        item.PromptTokens,
+		CompletionTokens:    item.CompletionTokens,
+		SummaryMessageID:    item.SummaryMessageID.String,
+		Cost:                item.Cost,
+		CreatedAt:           item.CreatedAt,
+		UpdatedAt:           item.UpdatedAt,
+		ForkedFromSessionID: item.ForkedFromSessionID.String,
+		AgentID:             item.AgentID.String,
+		PlanMode:            item.PlanMode,
 	}
 }
 
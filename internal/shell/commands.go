@@ -0,0 +1,164 @@
+package shell
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"mvdan.cc/sh/v3/expand"
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// CommandLine is a command line parsed into the commands it runs.
+type CommandLine struct {
+	// Commands are the simple commands of the line, including the ones in
+	// command substitutions, split into their arguments. Words only known
+	// when running, like "$DIR", are kept as written.
+	Commands [][]string
+	// WritesFiles is set when the output of a command is redirected to a
+	// file other than /dev/null.
+	WritesFiles bool
+}
+
+// ParseCommandLine parses the command line, so that compound commands and
+// pipelines can be checked command by command.
+func ParseCommandLine(command string) (*CommandLine, error) {
+	file, err := syntax.NewParser().Parse(strings.NewReader(command), "")
+	if err != nil {
+		return nil, fmt.Errorf("could not parse command: %w", err)
+	}
+
+	var (
+		line    CommandLine
+		printer = syntax.NewPrinter(syntax.Minify(true))
+	)
+	syntax.Walk(file, func(node syntax.Node) bool {
+		switch node := node.(type) {
+		case *syntax.CallExpr:
+			if len(node.Args) == 0 {
+				return true
+			}
+			args := make([]string, len(node.Args))
+			for i, word := range node.Args {
+				args[i] = wordString(printer, word)
+			}
+			line.Commands = append(line.Commands, args)
+		case *syntax.Redirect:
+			if redirectWrites(node) {
+				line.WritesFiles = true
+			}
+		}
+		return true
+	})
+	return &line, nil
+}
+
+// wordString returns the value of the word when it's a literal, or the word
+// as written otherwise.
+func wordString(printer *syntax.Printer, word *syntax.Word) string {
+	literal := !slices.ContainsFunc(word.Parts, func(part syntax.WordPart) bool {
+		switch part := part.(type) {
+		case *syntax.Lit, *syntax.SglQuoted:
+			return false
+		case *syntax.DblQuoted:
+			return slices.ContainsFunc(part.Parts, func(part syntax.WordPart) bool {
+				_, ok := part.(*syntax.Lit)
+				return !ok
+			})
+		}
+		return true
+	})
+	if literal {
+		if s, err := expand.Literal(&expand.Config{}, word); err == nil {
+			return s
+		}
+	}
+	var sb strings.Builder
+	_ = printer.Print(&sb, word)
+	return sb.String()
+}
+
+// redirectWrites reports whether the redirection writes to a file.
+func redirectWrites(redirect *syntax.Redirect) bool {
+	var target string
+	if redirect.Word != nil {
+		target = redirect.Word.Lit()
+	}
+	switch redirect.Op {
+	case syntax.RdrOut, syntax.AppOut, syntax.RdrInOut, syntax.ClbOut, syntax.RdrAll, syntax.AppAll:
+		return target != "/dev/null"
+	case syntax.DplOut:
+		// Duplicating a file descriptor, like 2>&1, doesn't write to a file.
+		dup := target == "-" || (target != "" && strings.Trim(target, "0123456789") == "")
+		return !dup
+	}
+	return false
+}
+
+// MatchCommand reports whether the command, split into its arguments,
+// matches the pattern. Patterns are a command name followed by the first
+// arguments the command must have, in order, and the flags it must have, in
+// any order; * matches any characters. "git push" matches
+// "git push --force origin main" but not "git status", and "npm install -g"
+// matches "npm install -g typescript".
+func MatchCommand(pattern string, args []string) bool {
+	words := strings.Fields(pattern)
+	if len(words) == 0 || len(args) == 0 || !matchWildcard(words[0], args[0]) {
+		return false
+	}
+
+	patternArgs, patternFlags := splitArgsFlags(words[1:])
+	cmdArgs, cmdFlags := splitArgsFlags(args[1:])
+	if len(cmdArgs) < len(patternArgs) {
+		return false
+	}
+	for i, arg := range patternArgs {
+		if !matchWildcard(arg, cmdArgs[i]) {
+			return false
+		}
+	}
+	for _, flag := range patternFlags {
+		if !slices.ContainsFunc(cmdFlags, func(f string) bool { return matchWildcard(flag, f) }) {
+			return false
+		}
+	}
+	return true
+}
+
+// PatternsBlocker creates a BlockFunc that blocks the commands matching any
+// of the patterns, as matched by MatchCommand.
+func PatternsBlocker(patterns []string) BlockFunc {
+	return func(args []string) bool {
+		return slices.ContainsFunc(patterns, func(pattern string) bool {
+			return MatchCommand(pattern, args)
+		})
+	}
+}
+
+// matchWildcard reports whether s matches the pattern, where * matches any
+// characters.
+func matchWildcard(pattern, s string) bool {
+	// star and match are where the last * was found in the pattern, and the
+	// position in s it's matching up to, to backtrack to when a later part
+	// doesn't match.
+	p, i, star, match := 0, 0, -1, 0
+	for i < len(s) {
+		switch {
+		case p < len(pattern) && pattern[p] == '*':
+			star, match = p, i
+			p++
+		case p < len(pattern) && pattern[p] == s[i]:
+			p++
+			i++
+		case star != -1:
+			match++
+			p, i = star+1, match
+		default:
+			return false
+		}
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
@@ -0,0 +1,73 @@
+package shell
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCommandLine(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		command     string
+		commands    [][]string
+		writesFiles bool
+	}{
+		{"git status", [][]string{{"git", "status"}}, false},
+		{"go vet ./... && git push", [][]string{{"go", "vet", "./..."}, {"git", "push"}}, false},
+		{"git log | head -n 5", [][]string{{"git", "log"}, {"head", "-n", "5"}}, false},
+		{`grep "a b" 'c d' $DIR`, [][]string{{"grep", "a b", "c d", "$DIR"}}, false},
+		{"echo $(rm -rf /)", [][]string{{"echo", "$(rm -rf /)"}, {"rm", "-rf", "/"}}, false},
+		{"if true; then ls; fi", [][]string{{"true"}, {"ls"}}, false},
+		{"ls > files.txt", [][]string{{"ls"}}, true},
+		{"ls >> $LOG", [][]string{{"ls"}}, true},
+		{"go test ./... 2>&1", [][]string{{"go", "test", "./..."}}, false},
+		{"ls 2> /dev/null", [][]string{{"ls"}}, false},
+		{"wc -l < main.go", [][]string{{"wc", "-l"}}, false},
+		{"FOO=bar", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			t.Parallel()
+			line, err := ParseCommandLine(tt.command)
+			require.NoError(t, err)
+			require.Equal(t, tt.commands, line.Commands)
+			require.Equal(t, tt.writesFiles, line.WritesFiles)
+		})
+	}
+
+	_, err := ParseCommandLine("echo 'unterminated")
+	require.Error(t, err)
+}
+
+func TestMatchCommand(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		pattern string
+		args    []string
+		want    bool
+	}{
+		{"curl", []string{"curl", "https://example.com"}, true},
+		{"curl", []string{"curlie"}, false},
+		{"git push", []string{"git", "push", "--force", "origin", "main"}, true},
+		{"git push", []string{"git", "status"}, false},
+		{"git push", []string{"git"}, false},
+		{"git config --get", []string{"git", "config", "--get", "user.name"}, true},
+		{"git config --get", []string{"git", "config", "user.name", "me"}, false},
+		{"npm install -g", []string{"npm", "install", "typescript", "-g"}, true},
+		{"curl http://localhost*", []string{"curl", "-s", "http://localhost:8080/api"}, true},
+		{"curl http://localhost*", []string{"curl", "https://example.com"}, false},
+		{"npm run *", []string{"npm", "run", "lint"}, true},
+		{"npm run *", []string{"npm", "run"}, false},
+		{"*", []string{"anything"}, true},
+		{"", []string{"ls"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tt.want, MatchCommand(tt.pattern, tt.args))
+		})
+	}
+}
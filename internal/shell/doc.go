@@ -16,10 +16,11 @@ package shell
 //	shell.Exec(ctx, "export FOO=bar")
 //	shell.Exec(ctx, "echo $FOO")  // Will print "bar"
 //
-// 3. For the singleton persistent shell (used by tools):
+// 3. For the shells of the sessions (used by tools):
 //
-//	shell := shell.GetPersistentShell("/path/to/cwd")
-//	stdout, stderr, err := shell.Exec(ctx, "ls -la")
+//	shells := shell.NewSessionShells("/path/to/cwd", store)
+//	stdout, stderr, err := shells.Get(ctx, sessionID).Exec(ctx, "cd src")
+//	shells.Save(ctx, sessionID) // The session starts in src after a restart
 //
 // 4. Managing environment and working directory:
 //
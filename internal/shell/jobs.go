@@ -0,0 +1,243 @@
+package shell
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/pubsub"
+	"mvdan.cc/sh/v3/interp"
+)
+
+// maxJobOutput is how much of the output of a job is kept. Older output is
+// dropped, so that long running jobs like servers don't grow without bounds.
+const maxJobOutput = 1024 * 1024
+
+// Job is a command run in the background.
+type Job struct {
+	ID         string
+	SessionID  string
+	Command    string
+	StartedAt  time.Time
+	FinishedAt time.Time
+	ExitCode   int
+	// Killed is set when the job was killed before it finished.
+	Killed bool
+}
+
+// Running reports whether the job is still running.
+func (j Job) Running() bool {
+	return j.FinishedAt.IsZero()
+}
+
+// JobOutput is the output of a job not read yet.
+type JobOutput struct {
+	Output string
+	// Dropped is how much of the output was dropped before it was read.
+	Dropped int
+}
+
+type job struct {
+	mu     sync.Mutex
+	info   Job
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	output []byte
+	// written is how much output was written in total, and read how much of
+	// it was read.
+	written int
+	read    int
+}
+
+func (j *job) Write(p []byte) (int, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.output = append(j.output, p...)
+	j.written += len(p)
+	if len(j.output) > maxJobOutput {
+		j.output = slices.Clone(j.output[len(j.output)-maxJobOutput:])
+	}
+	return len(p), nil
+}
+
+func (j *job) snapshot() Job {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.info
+}
+
+// JobManager runs commands in the background and keeps track of them until
+// they're done.
+type JobManager struct {
+	*pubsub.Broker[Job]
+
+	mu     sync.Mutex
+	jobs   map[string]*job
+	nextID int
+}
+
+// NewJobManager creates a job manager with no jobs.
+func NewJobManager() *JobManager {
+	return &JobManager{
+		Broker: pubsub.NewBroker[Job](),
+		jobs:   make(map[string]*job),
+	}
+}
+
+// Start runs the command in the background, in a clone of the shell so that
+// it doesn't hold it or change its state.
+func (m *JobManager) Start(sessionID string, sh *Shell, command string) Job {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	m.mu.Lock()
+	m.nextID++
+	j := &job{
+		info: Job{
+			ID:        "job-" + strconv.Itoa(m.nextID),
+			SessionID: sessionID,
+			Command:   command,
+			StartedAt: time.Now(),
+		},
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	m.jobs[j.info.ID] = j
+	m.mu.Unlock()
+
+	info := j.snapshot()
+	m.Publish(pubsub.CreatedEvent, info)
+
+	clone := sh.Clone()
+	go func() {
+		defer close(j.done)
+		defer cancel()
+
+		err := clone.ExecStream(ctx, command, j, j)
+		var status interp.ExitStatus
+		if err != nil && !IsInterrupt(err) && !errors.As(err, &status) {
+			// Errors other than exit codes, like blocked commands, are
+			// reported in the output.
+			fmt.Fprintf(j, "%s\n", err)
+		}
+
+		j.mu.Lock()
+		j.info.FinishedAt = time.Now()
+		j.info.ExitCode = ExitCode(err)
+		j.info.Killed = ctx.Err() != nil
+		info := j.info
+		j.mu.Unlock()
+
+		m.Publish(pubsub.UpdatedEvent, info)
+	}()
+	return info
+}
+
+func (m *JobManager) get(id string) (*job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	j, ok := m.jobs[id]
+	if !ok {
+		return nil, fmt.Errorf("job %s not found", id)
+	}
+	return j, nil
+}
+
+// Get returns the job with the given ID.
+func (m *JobManager) Get(id string) (Job, error) {
+	j, err := m.get(id)
+	if err != nil {
+		return Job{}, err
+	}
+	return j.snapshot(), nil
+}
+
+// List returns the jobs of the session, in the order they were started.
+func (m *JobManager) List(sessionID string) []Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var jobs []Job
+	for _, j := range m.jobs {
+		if info := j.snapshot(); info.SessionID == sessionID {
+			jobs = append(jobs, info)
+		}
+	}
+	slices.SortFunc(jobs, func(a, b Job) int {
+		return a.StartedAt.Compare(b.StartedAt)
+	})
+	return jobs
+}
+
+// ReadOutput returns the output the job produced since it was last read.
+func (m *JobManager) ReadOutput(id string) (JobOutput, error) {
+	j, err := m.get(id)
+	if err != nil {
+		return JobOutput{}, err
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	var out JobOutput
+	start := j.written - len(j.output)
+	if j.read < start {
+		out.Dropped = start - j.read
+		j.read = start
+	}
+	out.Output = string(j.output[j.read-start:])
+	j.read = j.written
+	return out, nil
+}
+
+// Wait waits for the job to finish, for at most the given timeout, and
+// returns it as it is then.
+func (m *JobManager) Wait(ctx context.Context, id string, timeout time.Duration) (Job, error) {
+	j, err := m.get(id)
+	if err != nil {
+		return Job{}, err
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-j.done:
+	case <-timer.C:
+	case <-ctx.Done():
+		return Job{}, ctx.Err()
+	}
+	return j.snapshot(), nil
+}
+
+// Kill stops the job and waits for it to finish.
+func (m *JobManager) Kill(id string) (Job, error) {
+	j, err := m.get(id)
+	if err != nil {
+		return Job{}, err
+	}
+	j.cancel()
+	<-j.done
+	return j.snapshot(), nil
+}
+
+// KillAll stops all the jobs that are still running and waits for them to
+// finish.
+func (m *JobManager) KillAll() {
+	m.mu.Lock()
+	jobs := slices.Collect(maps.Values(m.jobs))
+	m.mu.Unlock()
+
+	for _, j := range jobs {
+		j.cancel()
+	}
+	for _, j := range jobs {
+		<-j.done
+	}
+}
@@ -0,0 +1,81 @@
+package shell
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestJobManager(t *testing.T) {
+	t.Parallel()
+
+	sh := NewShell(&Options{WorkingDir: t.TempDir()})
+	m := NewJobManager()
+	t.Cleanup(m.KillAll)
+
+	t.Run("output and exit code", func(t *testing.T) {
+		t.Parallel()
+
+		job := m.Start("output", sh, "echo one; echo two >&2; exit 3")
+		require.True(t, job.Running())
+		job, err := m.Wait(t.Context(), job.ID, 10*time.Second)
+		require.NoError(t, err)
+		require.False(t, job.Running())
+		require.Equal(t, 3, job.ExitCode)
+		require.False(t, job.Killed)
+
+		out, err := m.ReadOutput(job.ID)
+		require.NoError(t, err)
+		require.Equal(t, "one\ntwo\n", out.Output)
+
+		// Only the new output is returned.
+		out, err = m.ReadOutput(job.ID)
+		require.NoError(t, err)
+		require.Empty(t, out.Output)
+	})
+
+	t.Run("kill", func(t *testing.T) {
+		t.Parallel()
+
+		job := m.Start("kill", sh, "sleep 60")
+		job, err := m.Wait(t.Context(), job.ID, 10*time.Millisecond)
+		require.NoError(t, err)
+		require.True(t, job.Running())
+
+		job, err = m.Kill(job.ID)
+		require.NoError(t, err)
+		require.False(t, job.Running())
+		require.True(t, job.Killed)
+		require.Len(t, m.List("kill"), 1)
+	})
+
+	t.Run("state is not shared", func(t *testing.T) {
+		t.Parallel()
+
+		job := m.Start("state", sh, "export JOB_VAR=1; cd ..")
+		_, err := m.Wait(t.Context(), job.ID, 10*time.Second)
+		require.NoError(t, err)
+		require.NotContains(t, sh.GetEnv(), "JOB_VAR=1")
+	})
+
+	t.Run("dropped output", func(t *testing.T) {
+		t.Parallel()
+
+		j := &job{cancel: func() {}, done: make(chan struct{})}
+		close(j.done)
+		_, err := j.Write(make([]byte, maxJobOutput+10))
+		require.NoError(t, err)
+		m.mu.Lock()
+		m.jobs["dropped"] = j
+		m.mu.Unlock()
+
+		out, err := m.ReadOutput("dropped")
+		require.NoError(t, err)
+		require.Equal(t, 10, out.Dropped)
+		require.Len(t, out.Output, maxJobOutput)
+	})
+
+	_, err := m.Get("job-missing")
+	require.ErrorContains(t, err, "not found")
+}
@@ -1,32 +1,144 @@
 package shell
 
 import (
+	"context"
 	"log/slog"
+	"os"
+	"slices"
+	"strings"
 	"sync"
 )
 
-// PersistentShell is a singleton shell instance that maintains state across the application
-type PersistentShell struct {
-	*Shell
+// Store keeps the state of the shells of the sessions across restarts.
+type Store interface {
+	// LoadShell returns the state the shell of the session was saved with,
+	// an empty working directory when there is none.
+	LoadShell(ctx context.Context, sessionID string) (workingDir string, env []string, err error)
+	SaveShell(ctx context.Context, sessionID, workingDir string, env []string) error
 }
 
-var (
-	once          sync.Once
-	shellInstance *PersistentShell
-)
+// interpVars are the variables set by the interpreter on every run, which
+// aren't worth saving.
+var interpVars = []string{"EUID", "GID", "IFS", "OLDPWD", "OPTIND", "PPID", "PWD", "UID"}
+
+// SessionShells keeps a shell per session, whose working directory and
+// environment variables persist across the commands run in the session, and
+// across restarts when saved to the store.
+type SessionShells struct {
+	workingDir string
+	// env is the environment shells start with. Only the variables that
+	// differ from it are saved.
+	env   []string
+	store Store
+
+	mu         sync.Mutex
+	shells     map[string]*Shell
+	blockFuncs []BlockFunc
+	sandbox    *Sandbox
+}
+
+// NewSessionShells creates the shells of the sessions, starting in the
+// working directory. The store may be nil to keep them in memory only.
+func NewSessionShells(workingDir string, store Store) *SessionShells {
+	return &SessionShells{
+		workingDir: workingDir,
+		env:        os.Environ(),
+		store:      store,
+		shells:     make(map[string]*Shell),
+	}
+}
 
-// GetPersistentShell returns the singleton persistent shell instance
-// This maintains backward compatibility with the existing API
-func GetPersistentShell(cwd string) *PersistentShell {
-	once.Do(func() {
-		shellInstance = &PersistentShell{
-			Shell: NewShell(&Options{
-				WorkingDir: cwd,
-				Logger:     &loggingAdapter{},
-			}),
+// Get returns the shell of the session, restoring the state it was saved
+// with the first time.
+func (s *SessionShells) Get(ctx context.Context, sessionID string) *Shell {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if sh, ok := s.shells[sessionID]; ok {
+		return sh
+	}
+
+	cwd, env := s.workingDir, slices.Clone(s.env)
+	if s.store != nil {
+		savedCwd, savedEnv, err := s.store.LoadShell(ctx, sessionID)
+		if err != nil {
+			slog.Error("Failed to load the shell of the session", "session_id", sessionID, "error", err)
+		}
+		if info, err := os.Stat(savedCwd); err == nil && info.IsDir() {
+			cwd = savedCwd
 		}
+		env = mergeEnv(env, savedEnv)
+	}
+
+	sh := NewShell(&Options{
+		WorkingDir: cwd,
+		Env:        env,
+		Logger:     &loggingAdapter{},
+		BlockFuncs: s.blockFuncs,
+		Sandbox:    s.sandbox,
 	})
-	return shellInstance
+	s.shells[sessionID] = sh
+	return sh
+}
+
+// Save saves the state of the shell of the session to the store.
+func (s *SessionShells) Save(ctx context.Context, sessionID string) error {
+	s.mu.Lock()
+	sh, ok := s.shells[sessionID]
+	s.mu.Unlock()
+	if !ok || s.store == nil {
+		return nil
+	}
+
+	var changed []string
+	for _, v := range sh.GetEnv() {
+		name, _, _ := strings.Cut(v, "=")
+		if !slices.Contains(interpVars, name) && !slices.Contains(s.env, v) {
+			changed = append(changed, v)
+		}
+	}
+	slices.Sort(changed)
+	return s.store.SaveShell(ctx, sessionID, sh.GetWorkingDir(), changed)
+}
+
+// SetBlockFuncs sets the command block functions of all the shells.
+func (s *SessionShells) SetBlockFuncs(blockFuncs []BlockFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.blockFuncs = blockFuncs
+	for _, sh := range s.shells {
+		sh.SetBlockFuncs(blockFuncs)
+	}
+}
+
+// SetSandbox sets the sandbox the commands of all the shells run in, nil to
+// run them unrestricted.
+func (s *SessionShells) SetSandbox(sandbox *Sandbox) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sandbox = sandbox
+	for _, sh := range s.shells {
+		sh.SetSandbox(sandbox)
+	}
+}
+
+// mergeEnv returns the environment with the variables set, replacing the
+// ones it already has.
+func mergeEnv(env, vars []string) []string {
+	for _, v := range vars {
+		name, _, _ := strings.Cut(v, "=")
+		idx := slices.IndexFunc(env, func(e string) bool {
+			return strings.HasPrefix(e, name+"=")
+		})
+		if idx == -1 {
+			env = append(env, v)
+		} else {
+			env[idx] = v
+		}
+	}
+	return env
 }
 
 // slog.dapter adapts the internal slog.package to the Logger interface
@@ -0,0 +1,63 @@
+package shell
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type memoryStore map[string][]string
+
+func (m memoryStore) LoadShell(_ context.Context, sessionID string) (string, []string, error) {
+	state, ok := m[sessionID]
+	if !ok {
+		return "", nil, nil
+	}
+	return state[0], state[1:], nil
+}
+
+func (m memoryStore) SaveShell(_ context.Context, sessionID, workingDir string, env []string) error {
+	m[sessionID] = append([]string{workingDir}, env...)
+	return nil
+}
+
+func TestSessionShells(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	dir := t.TempDir()
+	sub := filepath.Join(dir, "sub")
+	require.NoError(t, os.Mkdir(sub, 0o755))
+	store := memoryStore{}
+
+	shells := NewSessionShells(dir, store)
+	first := shells.Get(ctx, "first")
+	require.Same(t, first, shells.Get(ctx, "first"))
+	_, _, err := first.Exec(ctx, "cd sub && export SESSION_SHELL_VAR=first")
+	require.NoError(t, err)
+	require.NoError(t, shells.Save(ctx, "first"))
+
+	// Sessions don't share their state.
+	second := shells.Get(ctx, "second")
+	require.Equal(t, dir, second.GetWorkingDir())
+	stdout, _, err := second.Exec(ctx, "echo $SESSION_SHELL_VAR")
+	require.NoError(t, err)
+	require.Equal(t, "\n", stdout)
+
+	// Only the variables that changed are saved.
+	require.Equal(t, []string{sub, "SESSION_SHELL_VAR=first"}, store["first"])
+
+	// The state is restored after a restart.
+	restored := NewSessionShells(dir, store).Get(ctx, "first")
+	require.Equal(t, sub, restored.GetWorkingDir())
+	stdout, _, err = restored.Exec(ctx, "echo $SESSION_SHELL_VAR")
+	require.NoError(t, err)
+	require.Equal(t, "first\n", stdout)
+
+	// Removed directories fall back to the working directory.
+	require.NoError(t, os.Remove(sub))
+	require.Equal(t, dir, NewSessionShells(dir, store).Get(ctx, "first").GetWorkingDir())
+}
@@ -0,0 +1,252 @@
+package shell
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"mvdan.cc/sh/v3/interp"
+)
+
+// sandboxHelperArg is the argument crush is re-executed with to run a command
+// in the sandbox.
+const sandboxHelperArg = "__crush_sandbox"
+
+// Sandbox restricts what the commands run by a shell can do: they can read
+// everything but only write to the writable paths, and only use the network
+// when allowed. It's only supported on Linux, where it relies on Landlock;
+// commands fail everywhere else.
+type Sandbox struct {
+	// WritablePaths are the files and directories commands can write to,
+	// with everything under them.
+	WritablePaths []string `json:"writable_paths"`
+	// AllowNetwork lets commands use the network.
+	AllowNetwork bool `json:"allow_network"`
+}
+
+// NewSandbox returns a sandbox letting commands write to the working
+// directory, the temporary directories and the given paths, relative to the
+// working directory.
+func NewSandbox(workingDir string, writablePaths []string, allowNetwork bool) *Sandbox {
+	paths := []string{workingDir, os.TempDir()}
+	paths = append(paths, defaultWritablePaths...)
+	home, _ := os.UserHomeDir()
+	for _, path := range writablePaths {
+		if home != "" && (path == "~" || strings.HasPrefix(path, "~/")) {
+			path = filepath.Join(home, path[1:])
+		} else if !filepath.IsAbs(path) {
+			path = filepath.Join(workingDir, path)
+		}
+		paths = append(paths, filepath.Clean(path))
+	}
+	slices.Sort(paths)
+	return &Sandbox{
+		WritablePaths: slices.Compact(paths),
+		AllowNetwork:  allowNetwork,
+	}
+}
+
+// Writable reports whether commands can write to the path.
+func (s *Sandbox) Writable(path string) bool {
+	path = filepath.Clean(path)
+	for _, p := range s.WritablePaths {
+		if path == p || strings.HasPrefix(path, p+string(filepath.Separator)) || p == string(filepath.Separator) {
+			return true
+		}
+	}
+	return false
+}
+
+// Violation kinds.
+const (
+	ViolationFilesystem = "filesystem"
+	ViolationNetwork    = "network"
+)
+
+// SandboxViolation is something a command tried to do that the sandbox
+// doesn't allow.
+type SandboxViolation struct {
+	Kind      string `json:"kind"`
+	Operation string `json:"operation"`
+	Path      string `json:"path,omitempty"`
+	// Message is what reported the violation, like the error printed by the
+	// command.
+	Message string `json:"message"`
+}
+
+func (v *SandboxViolation) Error() string {
+	if v.Path != "" {
+		return fmt.Sprintf("sandbox: %s of %s is not allowed", v.Operation, v.Path)
+	}
+	return fmt.Sprintf("sandbox: %s is not allowed", v.Operation)
+}
+
+// SandboxError is returned for commands with sandbox violations. Err is the
+// error the command failed with, nil when it succeeded anyway.
+type SandboxError struct {
+	Violations []SandboxViolation
+	Err        error
+}
+
+func (e *SandboxError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("%d sandbox violations", len(e.Violations))
+	}
+	return fmt.Sprintf("%v (%d sandbox violations)", e.Err, len(e.Violations))
+}
+
+func (e *SandboxError) Unwrap() error {
+	return e.Err
+}
+
+// sandboxRun collects the violations of a command run in the sandbox.
+type sandboxRun struct {
+	sandbox *Sandbox
+
+	mu         sync.Mutex
+	violations []SandboxViolation
+	// stderr is the end of what the command printed to stderr, where the
+	// violations of the processes it started are looked for.
+	stderr tailBuffer
+}
+
+func (r *sandboxRun) add(v SandboxViolation) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !slices.ContainsFunc(r.violations, func(o SandboxViolation) bool {
+		return o.Kind == v.Kind && o.Operation == v.Operation && o.Path == v.Path
+	}) {
+		r.violations = append(r.violations, v)
+	}
+}
+
+// execHandler runs external commands through crush, which restricts itself
+// before executing them.
+func (r *sandboxRun) execHandler(next interp.ExecHandlerFunc) interp.ExecHandlerFunc {
+	return func(ctx context.Context, args []string) error {
+		exe, err := os.Executable()
+		if err != nil {
+			return fmt.Errorf("sandbox: could not find the crush executable: %w", err)
+		}
+		policy, err := json.Marshal(r.sandbox)
+		if err != nil {
+			return fmt.Errorf("sandbox: could not encode the policy: %w", err)
+		}
+		return next(ctx, append([]string{exe, sandboxHelperArg, string(policy), "--"}, args...))
+	}
+}
+
+// openHandler keeps the shell itself from writing outside of the writable
+// paths, through redirections.
+func (r *sandboxRun) openHandler(ctx context.Context, path string, flag int, perm os.FileMode) (io.ReadWriteCloser, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_APPEND|os.O_CREATE|os.O_TRUNC) != 0 {
+		abs := path
+		if !filepath.IsAbs(abs) {
+			abs = filepath.Join(interp.HandlerCtx(ctx).Dir, path)
+		}
+		if !r.sandbox.Writable(abs) {
+			v := SandboxViolation{
+				Kind:      ViolationFilesystem,
+				Operation: "write",
+				Path:      abs,
+				Message:   "redirection to a path outside of the writable paths",
+			}
+			r.add(v)
+			return nil, &v
+		}
+	}
+	return interp.DefaultOpenHandler()(ctx, path, flag, perm)
+}
+
+var (
+	deniedPattern = regexp.MustCompile(`(?i)permission denied|operation not permitted|read-only file system|network is unreachable`)
+	pathPattern   = regexp.MustCompile(`(?:^|[\s'"‘“` + "`" + `(:])(/[^\s'"’”` + "`" + `):]*)`)
+)
+
+// err returns the error of the command, with the violations the sandbox
+// caught. When the command failed, errors printed by the processes it started
+// are taken for violations when they are about paths that aren't writable, or
+// about connections while the network isn't allowed, even when the error is
+// printed apart from the connection, like in tracebacks.
+func (r *sandboxRun) err(err error) error {
+	var stderr string
+	if err != nil {
+		stderr = r.stderr.String()
+	}
+	lower := strings.ToLower(stderr)
+	network := !r.sandbox.AllowNetwork && (strings.Contains(lower, "connect") || strings.Contains(lower, "socket") || strings.Contains(lower, "network"))
+	for line := range strings.SplitSeq(stderr, "\n") {
+		if !deniedPattern.MatchString(line) {
+			continue
+		}
+		paths := pathPattern.FindAllStringSubmatch(line, -1)
+		idx := slices.IndexFunc(paths, func(m []string) bool {
+			return !r.sandbox.Writable(m[1])
+		})
+		if idx != -1 {
+			r.add(SandboxViolation{
+				Kind:      ViolationFilesystem,
+				Operation: "write",
+				Path:      paths[idx][1],
+				Message:   strings.TrimSpace(line),
+			})
+		} else if network && len(paths) == 0 {
+			r.add(SandboxViolation{
+				Kind:      ViolationNetwork,
+				Operation: "connect",
+				Message:   strings.TrimSpace(line),
+			})
+		}
+	}
+	if len(r.violations) == 0 {
+		return err
+	}
+	return &SandboxError{Violations: r.violations, Err: err}
+}
+
+// tailBuffer keeps the last bytes written to it.
+type tailBuffer struct {
+	mu  sync.Mutex
+	buf []byte
+}
+
+const tailBufferSize = 16 * 1024
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.buf = append(b.buf, p...)
+	if len(b.buf) > tailBufferSize {
+		b.buf = slices.Clone(b.buf[len(b.buf)-tailBufferSize:])
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return string(b.buf)
+}
+
+// RunSandboxHelper runs the command crush was re-executed with to run in the
+// sandbox, if it was, and exits. It must be called first thing in main.
+func RunSandboxHelper() {
+	if len(os.Args) < 5 || os.Args[1] != sandboxHelperArg || os.Args[3] != "--" {
+		return
+	}
+	var sandbox Sandbox
+	if err := json.Unmarshal([]byte(os.Args[2]), &sandbox); err != nil {
+		fmt.Fprintf(os.Stderr, "sandbox: invalid policy: %v\n", err)
+		os.Exit(126)
+	}
+	os.Exit(runSandboxed(&sandbox, os.Args[4:]))
+}
@@ -0,0 +1,214 @@
+package shell
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"runtime"
+	"slices"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+var defaultWritablePaths = []string{
+	"/tmp",
+	"/var/tmp",
+	"/dev/null",
+	"/dev/zero",
+	"/dev/full",
+	"/dev/tty",
+	"/dev/ptmx",
+	"/dev/pts",
+	"/dev/shm",
+}
+
+// Landlock access rights, by the ABI version they were introduced in.
+const (
+	landlockReadAccess = unix.LANDLOCK_ACCESS_FS_EXECUTE |
+		unix.LANDLOCK_ACCESS_FS_READ_FILE |
+		unix.LANDLOCK_ACCESS_FS_READ_DIR
+	landlockAccessV1 = landlockReadAccess |
+		unix.LANDLOCK_ACCESS_FS_WRITE_FILE |
+		unix.LANDLOCK_ACCESS_FS_REMOVE_DIR |
+		unix.LANDLOCK_ACCESS_FS_REMOVE_FILE |
+		unix.LANDLOCK_ACCESS_FS_MAKE_CHAR |
+		unix.LANDLOCK_ACCESS_FS_MAKE_DIR |
+		unix.LANDLOCK_ACCESS_FS_MAKE_REG |
+		unix.LANDLOCK_ACCESS_FS_MAKE_SOCK |
+		unix.LANDLOCK_ACCESS_FS_MAKE_FIFO |
+		unix.LANDLOCK_ACCESS_FS_MAKE_BLOCK |
+		unix.LANDLOCK_ACCESS_FS_MAKE_SYM
+	landlockAccessV2 = landlockAccessV1 | unix.LANDLOCK_ACCESS_FS_REFER
+	landlockAccessV3 = landlockAccessV2 | unix.LANDLOCK_ACCESS_FS_TRUNCATE
+	landlockAccessV5 = landlockAccessV3 | unix.LANDLOCK_ACCESS_FS_IOCTL_DEV
+
+	// landlockFileAccess are the rights that apply to files, rather than
+	// directories.
+	landlockFileAccess = unix.LANDLOCK_ACCESS_FS_EXECUTE |
+		unix.LANDLOCK_ACCESS_FS_READ_FILE |
+		unix.LANDLOCK_ACCESS_FS_WRITE_FILE |
+		unix.LANDLOCK_ACCESS_FS_TRUNCATE |
+		unix.LANDLOCK_ACCESS_FS_IOCTL_DEV
+
+	landlockNetAccess = unix.LANDLOCK_ACCESS_NET_BIND_TCP | unix.LANDLOCK_ACCESS_NET_CONNECT_TCP
+)
+
+// sandboxNetNSEnv is set when the sandbox helper is re-executed in a
+// network namespace.
+const sandboxNetNSEnv = "CRUSH_SANDBOX_NETNS"
+
+// runSandboxed restricts the process with Landlock and runs the command in
+// it, returning its exit code. The network is blocked with Landlock when the
+// kernel supports it, and with a network namespace otherwise.
+func runSandboxed(sandbox *Sandbox, args []string) int {
+	path, err := exec.LookPath(args[0])
+	if err != nil && !errors.Is(err, exec.ErrDot) {
+		fmt.Fprintf(os.Stderr, "%s: command not found\n", args[0])
+		return 127
+	}
+
+	abi, err := landlockABI()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "sandbox: Landlock is not available on this system (%v); disable the sandbox to run commands\n", err)
+		return 126
+	}
+
+	env := os.Environ()
+	if i := slices.IndexFunc(env, func(v string) bool {
+		return strings.HasPrefix(v, sandboxNetNSEnv+"=")
+	}); i != -1 {
+		env = slices.Delete(env, i, i+1)
+	} else if !sandbox.AllowNetwork && abi < 4 {
+		// The namespace has to be set up before Landlock, which would keep
+		// the ID maps from being written.
+		return runInNetNS()
+	}
+
+	// Landlock restricts the calling thread, which then executes the
+	// command.
+	runtime.LockOSThread()
+	if err := restrict(sandbox, abi); err != nil {
+		fmt.Fprintf(os.Stderr, "sandbox: %v\n", err)
+		return 126
+	}
+	err = syscall.Exec(path, args, env)
+	fmt.Fprintf(os.Stderr, "sandbox: could not run %s: %v\n", args[0], err)
+	return 126
+}
+
+// runInNetNS runs the sandbox helper again in new user and network
+// namespaces, without network access, and returns its exit code.
+func runInNetNS() int {
+	exe, err := os.Executable()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "sandbox: could not find the crush executable: %v\n", err)
+		return 126
+	}
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Env = append(os.Environ(), sandboxNetNSEnv+"=1")
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Cloneflags:  syscall.CLONE_NEWUSER | syscall.CLONE_NEWNET,
+		UidMappings: []syscall.SysProcIDMap{{ContainerID: os.Getuid(), HostID: os.Getuid(), Size: 1}},
+		GidMappings: []syscall.SysProcIDMap{{ContainerID: os.Getgid(), HostID: os.Getgid(), Size: 1}},
+	}
+	if err := cmd.Start(); err != nil {
+		fmt.Fprintf(os.Stderr, "sandbox: could not block the network: %v\n", err)
+		return 126
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		for sig := range signals {
+			_ = cmd.Process.Signal(sig)
+		}
+	}()
+
+	err = cmd.Wait()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "sandbox: %v\n", err)
+		return 126
+	}
+	return 0
+}
+
+func landlockABI() (int, error) {
+	abi, _, errno := unix.Syscall(unix.SYS_LANDLOCK_CREATE_RULESET, 0, 0, unix.LANDLOCK_CREATE_RULESET_VERSION)
+	if errno != 0 {
+		return 0, errno
+	}
+	return int(abi), nil
+}
+
+// restrict restricts the calling thread, and the processes it starts, to
+// reading everything and writing to the writable paths only.
+func restrict(sandbox *Sandbox, abi int) error {
+	access := uint64(landlockAccessV1)
+	switch {
+	case abi >= 5:
+		access = landlockAccessV5
+	case abi >= 3:
+		access = landlockAccessV3
+	case abi >= 2:
+		access = landlockAccessV2
+	}
+	attr := unix.LandlockRulesetAttr{Access_fs: access}
+	if !sandbox.AllowNetwork && abi >= 4 {
+		attr.Access_net = landlockNetAccess
+	}
+
+	fd, _, errno := unix.Syscall(unix.SYS_LANDLOCK_CREATE_RULESET, uintptr(unsafe.Pointer(&attr)), unsafe.Sizeof(attr), 0)
+	if errno != 0 {
+		return fmt.Errorf("could not create the Landlock ruleset: %w", errno)
+	}
+	defer unix.Close(int(fd))
+
+	if err := allowPath(int(fd), "/", landlockReadAccess); err != nil {
+		return err
+	}
+	for _, path := range sandbox.WritablePaths {
+		if err := allowPath(int(fd), path, access); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+		return fmt.Errorf("could not restrict privileges: %w", err)
+	}
+	if _, _, errno := unix.Syscall(unix.SYS_LANDLOCK_RESTRICT_SELF, fd, 0, 0); errno != 0 {
+		return fmt.Errorf("could not apply the Landlock ruleset: %w", errno)
+	}
+	return nil
+}
+
+func allowPath(rulesetFD int, path string, access uint64) error {
+	fd, err := unix.Open(path, unix.O_PATH|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return &os.PathError{Op: "open", Path: path, Err: err}
+	}
+	defer unix.Close(fd)
+
+	var stat unix.Stat_t
+	if err := unix.Fstat(fd, &stat); err != nil {
+		return &os.PathError{Op: "stat", Path: path, Err: err}
+	}
+	if stat.Mode&unix.S_IFMT != unix.S_IFDIR {
+		access &= landlockFileAccess
+	}
+
+	attr := unix.LandlockPathBeneathAttr{Allowed_access: access, Parent_fd: int32(fd)}
+	if _, _, errno := unix.Syscall6(unix.SYS_LANDLOCK_ADD_RULE, uintptr(rulesetFD), unix.LANDLOCK_RULE_PATH_BENEATH, uintptr(unsafe.Pointer(&attr)), 0, 0, 0); errno != 0 {
+		return fmt.Errorf("could not allow %s in the sandbox: %w", path, errno)
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package shell
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSandbox(t *testing.T) {
+	t.Parallel()
+
+	if _, err := landlockABI(); err != nil {
+		t.Skipf("Landlock is not available: %v", err)
+	}
+
+	dir := t.TempDir()
+	workingDir := filepath.Join(dir, "project")
+	outside := filepath.Join(dir, "outside")
+	require.NoError(t, os.Mkdir(workingDir, 0o755))
+	require.NoError(t, os.Mkdir(outside, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(outside, "file"), []byte("secret\n"), 0o644))
+
+	newShell := func() *Shell {
+		return NewShell(&Options{
+			WorkingDir: workingDir,
+			Sandbox:    &Sandbox{WritablePaths: []string{workingDir, "/dev/null"}},
+		})
+	}
+
+	t.Run("writes in the writable paths", func(t *testing.T) {
+		t.Parallel()
+		_, _, err := newShell().Exec(t.Context(), "touch inside && mkdir dir && cp inside dir/copy")
+		require.NoError(t, err)
+		require.FileExists(t, filepath.Join(workingDir, "dir", "copy"))
+	})
+
+	t.Run("reads outside the writable paths", func(t *testing.T) {
+		t.Parallel()
+		stdout, _, err := newShell().Exec(t.Context(), "cat "+filepath.Join(outside, "file"))
+		require.NoError(t, err)
+		require.Equal(t, "secret\n", stdout)
+	})
+
+	t.Run("blocks writes outside the writable paths", func(t *testing.T) {
+		t.Parallel()
+		path := filepath.Join(outside, "created")
+		_, _, err := newShell().Exec(t.Context(), "touch "+path)
+
+		var sandboxErr *SandboxError
+		require.True(t, errors.As(err, &sandboxErr))
+		require.Len(t, sandboxErr.Violations, 1)
+		require.Equal(t, ViolationFilesystem, sandboxErr.Violations[0].Kind)
+		require.Equal(t, path, sandboxErr.Violations[0].Path)
+		require.NotZero(t, ExitCode(err))
+		require.NoFileExists(t, path)
+	})
+
+	t.Run("blocks redirections outside the writable paths", func(t *testing.T) {
+		t.Parallel()
+		path := filepath.Join(outside, "redirected")
+		_, _, err := newShell().Exec(t.Context(), "echo hello > "+path)
+
+		var sandboxErr *SandboxError
+		require.True(t, errors.As(err, &sandboxErr))
+		require.Equal(t, path, sandboxErr.Violations[0].Path)
+		require.NoFileExists(t, path)
+	})
+
+	t.Run("blocks deletions outside the writable paths", func(t *testing.T) {
+		t.Parallel()
+		_, _, err := newShell().Exec(t.Context(), "rm -f "+filepath.Join(outside, "file"))
+
+		var sandboxErr *SandboxError
+		require.True(t, errors.As(err, &sandboxErr))
+		require.FileExists(t, filepath.Join(outside, "file"))
+	})
+
+	t.Run("blocks the network", func(t *testing.T) {
+		t.Parallel()
+		if _, err := exec.LookPath("python3"); err != nil {
+			t.Skip("python3 is not available")
+		}
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		defer listener.Close()
+
+		script := fmt.Sprintf(`python3 -c 'import socket; socket.create_connection(("127.0.0.1", %d))'`, listener.Addr().(*net.TCPAddr).Port)
+		_, _, err = newShell().Exec(t.Context(), script)
+		var sandboxErr *SandboxError
+		require.True(t, errors.As(err, &sandboxErr))
+		require.Equal(t, ViolationNetwork, sandboxErr.Violations[0].Kind)
+
+		sh := newShell()
+		sh.SetSandbox(&Sandbox{WritablePaths: []string{workingDir}, AllowNetwork: true})
+		_, _, err = sh.Exec(t.Context(), script)
+		require.NoError(t, err)
+	})
+
+	t.Run("reports missing commands", func(t *testing.T) {
+		t.Parallel()
+		_, stderr, err := newShell().Exec(t.Context(), "crush-missing-command")
+		require.Equal(t, 127, ExitCode(err))
+		require.Contains(t, stderr, "command not found")
+	})
+}
@@ -0,0 +1,18 @@
+//go:build !linux
+
+package shell
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+)
+
+var defaultWritablePaths []string
+
+// runSandboxed refuses to run the command, as the sandbox is only supported
+// on Linux.
+func runSandboxed(*Sandbox, []string) int {
+	fmt.Fprintf(os.Stderr, "sandbox: not supported on %s; disable the sandbox to run commands\n", runtime.GOOS)
+	return 126
+}
@@ -0,0 +1,107 @@
+package shell
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"mvdan.cc/sh/v3/interp"
+)
+
+func TestMain(m *testing.M) {
+	// Sandboxed commands run through the test binary.
+	RunSandboxHelper()
+	os.Exit(m.Run())
+}
+
+func TestNewSandbox(t *testing.T) {
+	t.Parallel()
+
+	home, err := os.UserHomeDir()
+	require.NoError(t, err)
+	workingDir := filepath.Join(string(filepath.Separator), "project")
+	sandbox := NewSandbox(workingDir, []string{"build", "~/.cache", "/opt/data/"}, true)
+
+	require.True(t, sandbox.AllowNetwork)
+	require.Contains(t, sandbox.WritablePaths, workingDir)
+	require.Contains(t, sandbox.WritablePaths, os.TempDir())
+	require.Contains(t, sandbox.WritablePaths, filepath.Join(workingDir, "build"))
+	require.Contains(t, sandbox.WritablePaths, filepath.Join(home, ".cache"))
+	require.Contains(t, sandbox.WritablePaths, filepath.Clean("/opt/data"))
+
+	require.True(t, sandbox.Writable(workingDir))
+	require.True(t, sandbox.Writable(filepath.Join(workingDir, "main.go")))
+	require.True(t, sandbox.Writable(filepath.Join(home, ".cache", "go-build")))
+	require.False(t, sandbox.Writable(workingDir+"-other"))
+	require.False(t, sandbox.Writable(filepath.Join(home, ".bashrc")))
+}
+
+func TestSandboxRunErr(t *testing.T) {
+	t.Parallel()
+
+	sandbox := &Sandbox{WritablePaths: []string{"/project"}}
+
+	t.Run("success without violations", func(t *testing.T) {
+		t.Parallel()
+		r := &sandboxRun{sandbox: sandbox}
+		require.NoError(t, r.err(nil))
+	})
+
+	t.Run("failure without violations", func(t *testing.T) {
+		t.Parallel()
+		r := &sandboxRun{sandbox: sandbox}
+		r.stderr.Write([]byte("cp: cannot stat '/project/missing': No such file or directory\n"))
+		require.Equal(t, interp.ExitStatus(1), r.err(interp.ExitStatus(1)))
+	})
+
+	t.Run("denied write", func(t *testing.T) {
+		t.Parallel()
+		r := &sandboxRun{sandbox: sandbox}
+		r.stderr.Write([]byte("touch: cannot touch '/etc/hosts': Permission denied\n"))
+
+		err := r.err(interp.ExitStatus(1))
+		var sandboxErr *SandboxError
+		require.True(t, errors.As(err, &sandboxErr))
+		require.Equal(t, []SandboxViolation{{
+			Kind:      ViolationFilesystem,
+			Operation: "write",
+			Path:      "/etc/hosts",
+			Message:   "touch: cannot touch '/etc/hosts': Permission denied",
+		}}, sandboxErr.Violations)
+		require.Equal(t, 1, ExitCode(err))
+	})
+
+	t.Run("denied connection", func(t *testing.T) {
+		t.Parallel()
+		r := &sandboxRun{sandbox: sandbox}
+		r.stderr.Write([]byte("curl: (7) Failed to connect to example.com port 443: Permission denied\n"))
+
+		var sandboxErr *SandboxError
+		require.True(t, errors.As(r.err(interp.ExitStatus(7)), &sandboxErr))
+		require.Len(t, sandboxErr.Violations, 1)
+		require.Equal(t, ViolationNetwork, sandboxErr.Violations[0].Kind)
+	})
+
+	t.Run("denied connection in a traceback", func(t *testing.T) {
+		t.Parallel()
+		r := &sandboxRun{sandbox: sandbox}
+		r.stderr.Write([]byte(`  File "/usr/lib/python3/socket.py", line 836, in create_connection
+    sock.connect(sa)
+PermissionError: [Errno 13] Permission denied
+`))
+
+		var sandboxErr *SandboxError
+		require.True(t, errors.As(r.err(interp.ExitStatus(1)), &sandboxErr))
+		require.Len(t, sandboxErr.Violations, 1)
+		require.Equal(t, ViolationNetwork, sandboxErr.Violations[0].Kind)
+	})
+
+	t.Run("denied write in the working directory", func(t *testing.T) {
+		t.Parallel()
+		r := &sandboxRun{sandbox: sandbox}
+		r.stderr.Write([]byte("rm: cannot remove '/project/file': Permission denied\n"))
+		require.Equal(t, interp.ExitStatus(1), r.err(interp.ExitStatus(1)))
+	})
+}
@@ -2,7 +2,7 @@
 //
 // This package offers two main types:
 // - Shell: A general-purpose shell executor for one-off or managed commands
-// - PersistentShell: A singleton shell that maintains state across the application
+// - SessionShells: A shell per session that maintains state across the commands of the session
 //
 // WINDOWS COMPATIBILITY:
 // This implementation provides both POSIX shell emulation (mvdan.cc/sh/v3),
@@ -15,6 +15,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"slices"
 	"strings"
@@ -56,6 +57,7 @@ type Shell struct {
 	mu         sync.Mutex
 	logger     Logger
 	blockFuncs []BlockFunc
+	sandbox    *Sandbox
 }
 
 // Options for creating a new shell
@@ -64,6 +66,8 @@ type Options struct {
 	Env        []string
 	Logger     Logger
 	BlockFuncs []BlockFunc
+	// Sandbox restricts what the commands can do, when set
+	Sandbox *Sandbox
 }
 
 // NewShell creates a new shell instance with the given options
@@ -92,6 +96,7 @@ func NewShell(opts *Options) *Shell {
 		env:        env,
 		logger:     logger,
 		blockFuncs: opts.BlockFuncs,
+		sandbox:    opts.Sandbox,
 	}
 }
 
@@ -100,7 +105,39 @@ func (s *Shell) Exec(ctx context.Context, command string) (string, string, error
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	return s.execPOSIX(ctx, command)
+	return s.execPOSIX(ctx, command, nil)
+}
+
+// ExecWithInput executes a command in the shell with the input on its stdin
+func (s *Shell) ExecWithInput(ctx context.Context, command string, input io.Reader) (string, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.execPOSIX(ctx, command, input)
+}
+
+// ExecStream executes a command in the shell, writing its output to the
+// given writers as it's produced
+func (s *Shell) ExecStream(ctx context.Context, command string, stdout, stderr io.Writer) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.run(ctx, command, nil, stdout, stderr)
+}
+
+// Clone returns a new shell with the same working directory, environment
+// block functions and sandbox, whose state is independent from this one
+func (s *Shell) Clone() *Shell {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return &Shell{
+		cwd:        s.cwd,
+		env:        slices.Clone(s.env),
+		logger:     s.logger,
+		blockFuncs: s.blockFuncs,
+		sandbox:    s.sandbox,
+	}
 }
 
 // GetWorkingDir returns the current working directory
@@ -157,6 +194,13 @@ func (s *Shell) SetBlockFuncs(blockFuncs []BlockFunc) {
 	s.blockFuncs = blockFuncs
 }
 
+// SetSandbox sets the sandbox commands run in, nil to run them unrestricted
+func (s *Shell) SetSandbox(sandbox *Sandbox) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sandbox = sandbox
+}
+
 // CommandsBlocker creates a BlockFunc that blocks exact command matches
 func CommandsBlocker(cmds []string) BlockFunc {
 	bannedSet := make(map[string]struct{})
@@ -229,32 +273,57 @@ func (s *Shell) blockHandler() func(next interp.ExecHandlerFunc) interp.ExecHand
 }
 
 // execPOSIX executes commands using POSIX shell emulation (cross-platform)
-func (s *Shell) execPOSIX(ctx context.Context, command string) (string, string, error) {
+func (s *Shell) execPOSIX(ctx context.Context, command string, stdin io.Reader) (string, string, error) {
+	var stdout, stderr bytes.Buffer
+	err := s.run(ctx, command, stdin, &stdout, &stderr)
+	return stdout.String(), stderr.String(), err
+}
+
+// run runs the command, keeping the working directory and environment it
+// leaves the shell with
+func (s *Shell) run(ctx context.Context, command string, stdin io.Reader, stdout, stderr io.Writer) error {
 	line, err := syntax.NewParser().Parse(strings.NewReader(command), "")
 	if err != nil {
-		return "", "", fmt.Errorf("could not parse command: %w", err)
+		return fmt.Errorf("could not parse command: %w", err)
 	}
 
-	var stdout, stderr bytes.Buffer
-	runner, err := interp.New(
-		interp.StdIO(nil, &stdout, &stderr),
+	opts := []interp.RunnerOption{
 		interp.Interactive(false),
 		interp.Env(expand.ListEnviron(s.env...)),
 		interp.Dir(s.cwd),
-		interp.ExecHandlers(s.blockHandler(), coreutils.ExecHandler),
-	)
+	}
+	var sandbox *sandboxRun
+	if s.sandbox != nil {
+		// The in-process coreutils would escape the sandbox, so commands
+		// all run as separate processes.
+		sandbox = &sandboxRun{sandbox: s.sandbox}
+		opts = append(opts,
+			interp.StdIO(stdin, stdout, io.MultiWriter(stderr, &sandbox.stderr)),
+			interp.ExecHandlers(s.blockHandler(), sandbox.execHandler),
+			interp.OpenHandler(sandbox.openHandler),
+		)
+	} else {
+		opts = append(opts,
+			interp.StdIO(stdin, stdout, stderr),
+			interp.ExecHandlers(s.blockHandler(), coreutils.ExecHandler),
+		)
+	}
+	runner, err := interp.New(opts...)
 	if err != nil {
-		return "", "", fmt.Errorf("could not run command: %w", err)
+		return fmt.Errorf("could not run command: %w", err)
 	}
 
 	err = runner.Run(ctx, line)
+	if sandbox != nil {
+		err = sandbox.err(err)
+	}
 	s.cwd = runner.Dir
 	s.env = []string{}
 	for name, vr := range runner.Vars {
 		s.env = append(s.env, fmt.Sprintf("%s=%s", name, vr.Str))
 	}
 	s.logger.InfoPersist("POSIX command finished", "command", command, "err", err)
-	return stdout.String(), stderr.String(), err
+	return err
 }
 
 // IsInterrupt checks if an error is due to interruption
@@ -265,6 +334,10 @@ func IsInterrupt(err error) bool {
 
 // ExitCode extracts the exit code from an error
 func ExitCode(err error) int {
+	var sandboxErr *SandboxError
+	if errors.As(err, &sandboxErr) {
+		err = sandboxErr.Err
+	}
 	if err == nil {
 		return 0
 	}
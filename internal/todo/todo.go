@@ -0,0 +1,143 @@
+// Package todo keeps the todo list the agent tracks the progress of a session
+// with.
+package todo
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/crush/internal/db"
+	"github.com/charmbracelet/crush/internal/pubsub"
+	"github.com/google/uuid"
+)
+
+type Status string
+
+const (
+	StatusPending    Status = "pending"
+	StatusInProgress Status = "in_progress"
+	StatusCompleted  Status = "completed"
+)
+
+// Statuses are the valid statuses of a todo.
+var Statuses = []Status{StatusPending, StatusInProgress, StatusCompleted}
+
+type Todo struct {
+	ID        string
+	SessionID string
+	// Position is the number of the todo in the list of the session. It
+	// doesn't change when other todos are removed.
+	Position  int64
+	Content   string
+	Status    Status
+	CreatedAt int64
+	UpdatedAt int64
+}
+
+type Service interface {
+	pubsub.Suscriber[Todo]
+	Add(ctx context.Context, sessionID, content string) (Todo, error)
+	List(ctx context.Context, sessionID string) ([]Todo, error)
+	Update(ctx context.Context, todo Todo) (Todo, error)
+	Delete(ctx context.Context, todo Todo) error
+}
+
+type service struct {
+	*pubsub.Broker[Todo]
+	q db.Querier
+}
+
+func NewService(q db.Querier) Service {
+	return &service{
+		Broker: pubsub.NewBroker[Todo](),
+		q:      q,
+	}
+}
+
+// Add adds a pending todo at the end of the list of the session.
+func (s *service) Add(ctx context.Context, sessionID, content string) (Todo, error) {
+	todos, err := s.q.ListTodosBySession(ctx, sessionID)
+	if err != nil {
+		return Todo{}, err
+	}
+	var position int64 = 1
+	if len(todos) > 0 {
+		position = todos[len(todos)-1].Position + 1
+	}
+	dbTodo, err := s.q.CreateTodo(ctx, db.CreateTodoParams{
+		ID:        uuid.New().String(),
+		SessionID: sessionID,
+		Position:  position,
+		Content:   content,
+		Status:    string(StatusPending),
+	})
+	if err != nil {
+		return Todo{}, err
+	}
+	todo := s.fromDBItem(dbTodo)
+	s.Publish(pubsub.CreatedEvent, todo)
+	return todo, nil
+}
+
+func (s *service) List(ctx context.Context, sessionID string) ([]Todo, error) {
+	dbTodos, err := s.q.ListTodosBySession(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	todos := make([]Todo, len(dbTodos))
+	for i, dbTodo := range dbTodos {
+		todos[i] = s.fromDBItem(dbTodo)
+	}
+	return todos, nil
+}
+
+func (s *service) Update(ctx context.Context, todo Todo) (Todo, error) {
+	err := s.q.UpdateTodo(ctx, db.UpdateTodoParams{
+		ID:      todo.ID,
+		Content: todo.Content,
+		Status:  string(todo.Status),
+	})
+	if err != nil {
+		return Todo{}, err
+	}
+	s.Publish(pubsub.UpdatedEvent, todo)
+	return todo, nil
+}
+
+func (s *service) Delete(ctx context.Context, todo Todo) error {
+	if err := s.q.DeleteTodo(ctx, todo.ID); err != nil {
+		return err
+	}
+	s.Publish(pubsub.DeletedEvent, todo)
+	return nil
+}
+
+func (s *service) fromDBItem(item db.Todo) Todo {
+	return Todo{
+		ID:        item.ID,
+		SessionID: item.SessionID,
+		Position:  item.Position,
+		Content:   item.Content,
+		Status:    Status(item.Status),
+		CreatedAt: item.CreatedAt,
+		UpdatedAt: item.UpdatedAt,
+	}
+}
+
+// Checklist returns the todos as a Markdown checklist, numbered by position.
+func Checklist(todos []Todo) string {
+	var sb strings.Builder
+	for _, todo := range todos {
+		check := " "
+		if todo.Status == StatusCompleted {
+			check = "x"
+		}
+		fmt.Fprintf(&sb, "- [%s] %d. %s", check, todo.Position, todo.Content)
+		if todo.Status == StatusInProgress {
+			sb.WriteString(" (in progress)")
+		}
+		sb.WriteString("\n")
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
@@ -0,0 +1,45 @@
+package todo
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/db"
+	"github.com/charmbracelet/crush/internal/session"
+	"github.com/stretchr/testify/require"
+)
+
+func TestService(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	conn, err := db.Connect(ctx, t.TempDir())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	q := db.New(conn)
+	sess, err := session.NewService(q).Create(ctx, "Todos")
+	require.NoError(t, err)
+	s := NewService(q)
+
+	parse, err := s.Add(ctx, sess.ID, "Parse the flag")
+	require.NoError(t, err)
+	docs, err := s.Add(ctx, sess.ID, "Document it")
+	require.NoError(t, err)
+	require.Equal(t, StatusPending, parse.Status)
+	require.EqualValues(t, 2, docs.Position)
+
+	tests, err := s.Add(ctx, sess.ID, "Test it")
+	require.NoError(t, err)
+
+	parse.Status = StatusCompleted
+	_, err = s.Update(ctx, parse)
+	require.NoError(t, err)
+	tests.Status = StatusInProgress
+	_, err = s.Update(ctx, tests)
+	require.NoError(t, err)
+	require.NoError(t, s.Delete(ctx, docs))
+
+	// Removing an item leaves the positions of the others as they are.
+	todos, err := s.List(ctx, sess.ID)
+	require.NoError(t, err)
+	require.Equal(t, "- [x] 1. Parse the flag\n- [ ] 3. Test it (in progress)", Checklist(todos))
+}
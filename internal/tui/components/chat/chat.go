@@ -30,6 +30,23 @@ type SessionSelectedMsg = session.Session
 
 type SessionClearedMsg struct{}
 
+// MessageSelectedMsg selects a message of the current session, scrolling it
+// into view.
+type MessageSelectedMsg struct {
+	MessageID string
+}
+
+// ReloadSessionMsg reloads the messages of the session, after some of them
+// were removed.
+type ReloadSessionMsg struct {
+	SessionID string
+}
+
+// EditPromptMsg puts a prompt in the editor to be edited and sent again.
+type EditPromptMsg struct {
+	Text string
+}
+
 type SelectionCopyMsg struct {
 	clickCount   int
 	endSelection bool
@@ -197,6 +214,15 @@ func (m *messageListCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			cmds = append(cmds, m.SetSession(msg))
 		}
 		return m, tea.Batch(cmds...)
+	case MessageSelectedMsg:
+		return m, m.selectMessage(msg.MessageID)
+	case ReloadSessionMsg:
+		if msg.SessionID == m.session.ID {
+			sess := m.session
+			m.session = session.Session{}
+			cmds = append(cmds, m.SetSession(sess))
+		}
+		return m, tea.Batch(cmds...)
 	case SessionClearedMsg:
 		m.session = session.Session{}
 		cmds = append(cmds, m.listCmp.SetItems([]list.Item{}))
@@ -206,6 +232,12 @@ func (m *messageListCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		cmds = append(cmds, m.handleMessageEvent(msg))
 		return m, tea.Batch(cmds...)
 
+	case pubsub.Event[agent.AgentEvent]:
+		if msg.Payload.Type == agent.AgentEventTypeToolOutput && msg.Payload.SessionID == m.session.ID {
+			m.handleToolOutput(msg.Payload.ToolCallID, msg.Payload.Output)
+		}
+		return m, nil
+
 	case tea.MouseWheelMsg:
 		u, cmd := m.listCmp.Update(msg)
 		m.listCmp = u.(list.List[list.Item])
@@ -353,6 +385,29 @@ func (m *messageListCmp) messageExists(messageID string) bool {
 	return false
 }
 
+// selectMessage selects the item showing the given message. Messages that
+// aren't shown on their own, like tool results, select their tool call.
+func (m *messageListCmp) selectMessage(messageID string) tea.Cmd {
+	itemID := messageID
+	msg, err := m.app.Messages.Get(context.Background(), messageID)
+	if err != nil {
+		return util.ReportError(err)
+	}
+	if !m.messageExists(messageID) {
+		switch msg.Role {
+		case message.Assistant:
+			if calls := msg.ToolCalls(); len(calls) > 0 {
+				itemID = calls[0].ID
+			}
+		case message.Tool:
+			if results := msg.ToolResults(); len(results) > 0 {
+				itemID = results[0].ToolCallID
+			}
+		}
+	}
+	return m.listCmp.SetSelected(itemID)
+}
+
 // handleNewMessage routes new messages to appropriate handlers based on role.
 func (m *messageListCmp) handleNewMessage(msg message.Message) tea.Cmd {
 	switch msg.Role {
@@ -385,6 +440,16 @@ func (m *messageListCmp) handleToolMessage(msg message.Message) tea.Cmd {
 	return nil
 }
 
+// handleToolOutput shows the output of a running tool call.
+func (m *messageListCmp) handleToolOutput(toolCallID, output string) {
+	items := m.listCmp.Items()
+	if toolCallIndex := m.findToolCallByID(items, toolCallID); toolCallIndex != NotFound {
+		toolCall := items[toolCallIndex].(messages.ToolCallCmp)
+		toolCall.AppendOutput(output)
+		m.listCmp.UpdateItem(toolCall.ID(), toolCall)
+	}
+}
+
 // findToolCallByID searches for a tool call with the specified ID.
 // Returns the index if found, NotFound otherwise.
 func (m *messageListCmp) findToolCallByID(items []list.Item, toolCallID string) int {
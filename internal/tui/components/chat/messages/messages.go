@@ -31,6 +31,30 @@ var CopyKey = key.NewBinding(key.WithKeys("c", "y", "C", "Y"), key.WithHelp("c/y
 // ClearSelectionKey is the key binding for clearing the current selection in the chat interface.
 var ClearSelectionKey = key.NewBinding(key.WithKeys("esc"), key.WithHelp("esc", "clear selection"))
 
+// RewindKey is the key binding for rewinding the session to before a user message.
+var RewindKey = key.NewBinding(key.WithKeys("r"), key.WithHelp("r", "rewind"))
+
+// ForkKey is the key binding for forking the session into a new one from a message.
+var ForkKey = key.NewBinding(key.WithKeys("F"), key.WithHelp("F", "fork"))
+
+// EditKey is the key binding for editing a user message in a fork of the session.
+var EditKey = key.NewBinding(key.WithKeys("e"), key.WithHelp("e", "edit"))
+
+// RewindMsg requests rewinding a session to before one of its user messages.
+type RewindMsg struct {
+	SessionID string
+	MessageID string
+}
+
+// ForkMsg requests forking a session into a new one, from one of its
+// messages. When editing, the fork stops before the user message, so that
+// another version of it can be sent.
+type ForkMsg struct {
+	SessionID string
+	MessageID string
+	Edit      bool
+}
+
 // MessageCmp defines the interface for message components in the chat interface.
 // It combines standard UI model interfaces with message-specific functionality.
 type MessageCmp interface {
@@ -113,6 +137,19 @@ func (m *messageCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				util.ReportInfo("Message copied to clipboard"),
 			)
 		}
+		if key.Matches(msg, RewindKey) && m.message.Role == message.User {
+			return m, util.CmdHandler(RewindMsg{
+				SessionID: m.message.SessionID,
+				MessageID: m.message.ID,
+			})
+		}
+		if key.Matches(msg, ForkKey) || (key.Matches(msg, EditKey) && m.message.Role == message.User) {
+			return m, util.CmdHandler(ForkMsg{
+				SessionID: m.message.SessionID,
+				MessageID: m.message.ID,
+				Edit:      key.Matches(msg, EditKey),
+			})
+		}
 	}
 	return m, nil
 }
@@ -174,6 +174,17 @@ func init() {
 	registry.register(tools.LSToolName, func() renderer { return lsRenderer{} })
 	registry.register(tools.SourcegraphToolName, func() renderer { return sourcegraphRenderer{} })
 	registry.register(tools.DiagnosticsToolName, func() renderer { return diagnosticsRenderer{} })
+	registry.register(tools.DefinitionToolName, func() renderer { return lspPositionRenderer{name: "Definition"} })
+	registry.register(tools.ReferencesToolName, func() renderer { return referencesRenderer{} })
+	registry.register(tools.HoverToolName, func() renderer { return lspPositionRenderer{name: "Hover"} })
+	registry.register(tools.SymbolsToolName, func() renderer { return symbolsRenderer{} })
+	registry.register(tools.RenameToolName, func() renderer { return renameRenderer{} })
+	registry.register(tools.CodeActionToolName, func() renderer { return codeActionRenderer{} })
+	registry.register(tools.PlanToolName, func() renderer { return planRenderer{} })
+	registry.register(tools.TodoToolName, func() renderer { return todoRenderer{} })
+	registry.register(tools.JobOutputToolName, func() renderer { return jobRenderer{} })
+	registry.register(tools.JobWaitToolName, func() renderer { return jobRenderer{} })
+	registry.register(tools.JobKillToolName, func() renderer { return jobRenderer{} })
 	registry.register(agent.AgentToolName, func() renderer { return agentRenderer{} })
 }
 
@@ -211,13 +222,22 @@ func (br bashRenderer) Render(v *toolCallCmp) string {
 
 	cmd := strings.ReplaceAll(params.Command, "\n", " ")
 	cmd = strings.ReplaceAll(cmd, "\t", "    ")
-	args := newParamBuilder().addMain(cmd).build()
+	args := newParamBuilder().
+		addMain(cmd).
+		addFlag("background", params.RunInBackground).
+		build()
 
 	return br.renderWithParams(v, "Bash", args, func() string {
+		if v.result.ToolCallID == "" {
+			return renderLiveOutput(v, v.output)
+		}
 		var meta tools.BashResponseMetadata
 		if err := br.unmarshalParams(v.result.Metadata, &meta); err != nil {
 			return renderPlainContent(v, v.result.Content)
 		}
+		if meta.JobID != "" {
+			return renderPlainContent(v, "Running in the background as "+meta.JobID)
+		}
 		// for backwards compatibility with older tool calls.
 		if meta.Output == "" && v.result.Content != tools.BashNoOutput {
 			meta.Output = v.result.Content
@@ -589,6 +609,218 @@ func (dr diagnosticsRenderer) Render(v *toolCallCmp) string {
 	})
 }
 
+// -----------------------------------------------------------------------------
+//  LSP renderers
+// -----------------------------------------------------------------------------
+
+// lspPositionArgs builds the display arguments of a symbol position
+func lspPositionArgs(params tools.LSPPositionParams) *paramBuilder {
+	return newParamBuilder().
+		addMain(fsext.PrettyPath(params.FilePath)).
+		addKeyValue("symbol", params.Symbol).
+		addKeyValue("line", formatNonZero(params.Line)).
+		addKeyValue("column", formatNonZero(params.Column))
+}
+
+// lspPositionRenderer handles LSP lookups of a single symbol position
+type lspPositionRenderer struct {
+	baseRenderer
+	name string
+}
+
+// Render displays the file and symbol position with plain content formatting
+func (lr lspPositionRenderer) Render(v *toolCallCmp) string {
+	var params tools.LSPPositionParams
+	var args []string
+	if err := lr.unmarshalParams(v.call.Input, &params); err == nil {
+		args = lspPositionArgs(params).build()
+	}
+
+	return lr.renderWithParams(v, lr.name, args, func() string {
+		return renderPlainContent(v, v.result.Content)
+	})
+}
+
+// referencesRenderer handles LSP reference lookups
+type referencesRenderer struct {
+	baseRenderer
+}
+
+// Render displays the symbol position and declaration flag with plain content formatting
+func (rr referencesRenderer) Render(v *toolCallCmp) string {
+	var params tools.ReferencesParams
+	var args []string
+	if err := rr.unmarshalParams(v.call.Input, &params); err == nil {
+		args = lspPositionArgs(params.LSPPositionParams).
+			addFlag("declaration", params.IncludeDeclaration).
+			build()
+	}
+
+	return rr.renderWithParams(v, "References", args, func() string {
+		return renderPlainContent(v, v.result.Content)
+	})
+}
+
+// symbolsRenderer handles LSP symbol searches
+type symbolsRenderer struct {
+	baseRenderer
+}
+
+// Render displays the symbol query with optional file parameter
+func (sr symbolsRenderer) Render(v *toolCallCmp) string {
+	var params tools.SymbolsParams
+	var args []string
+	if err := sr.unmarshalParams(v.call.Input, &params); err == nil {
+		main := params.Query
+		if main == "" {
+			main = fsext.PrettyPath(params.FilePath)
+		}
+		pb := newParamBuilder().addMain(main)
+		if params.Query != "" && params.FilePath != "" {
+			pb = pb.addKeyValue("file", fsext.PrettyPath(params.FilePath))
+		}
+		args = pb.build()
+	}
+
+	return sr.renderWithParams(v, "Symbols", args, func() string {
+		return renderPlainContent(v, v.result.Content)
+	})
+}
+
+// renameRenderer handles LSP symbol renames
+type renameRenderer struct {
+	baseRenderer
+}
+
+// Render displays the new name and symbol position with plain content formatting
+func (rr renameRenderer) Render(v *toolCallCmp) string {
+	var params tools.RenameParams
+	var args []string
+	if err := rr.unmarshalParams(v.call.Input, &params); err == nil {
+		args = newParamBuilder().
+			addMain(params.NewName).
+			addKeyValue("file", fsext.PrettyPath(params.FilePath)).
+			addKeyValue("symbol", params.Symbol).
+			addKeyValue("line", formatNonZero(params.Line)).
+			addKeyValue("column", formatNonZero(params.Column)).
+			build()
+	}
+
+	return rr.renderWithParams(v, "Rename", args, func() string {
+		return renderPlainContent(v, v.result.Content)
+	})
+}
+
+// codeActionRenderer handles listing and applying LSP code actions
+type codeActionRenderer struct {
+	baseRenderer
+}
+
+// Render displays the file and the selected action with plain content formatting
+func (cr codeActionRenderer) Render(v *toolCallCmp) string {
+	var params tools.CodeActionParams
+	var args []string
+	if err := cr.unmarshalParams(v.call.Input, &params); err == nil {
+		args = newParamBuilder().
+			addMain(fsext.PrettyPath(params.FilePath)).
+			addKeyValue("title", params.Title).
+			addKeyValue("kind", params.Kind).
+			addKeyValue("line", formatNonZero(params.Line)).
+			addKeyValue("end_line", formatNonZero(params.EndLine)).
+			build()
+	}
+
+	return cr.renderWithParams(v, "Code Action", args, func() string {
+		return renderPlainContent(v, v.result.Content)
+	})
+}
+
+// todoRenderer handles changes to the todo list of the session
+type todoRenderer struct {
+	baseRenderer
+}
+
+// Render displays the action and the resulting checklist
+func (tr todoRenderer) Render(v *toolCallCmp) string {
+	var params tools.TodoParams
+	var args []string
+	if err := tr.unmarshalParams(v.call.Input, &params); err == nil {
+		id := ""
+		if params.ID > 0 {
+			id = fmt.Sprintf("%d", params.ID)
+		}
+		args = newParamBuilder().
+			addMain(params.Action).
+			addKeyValue("id", id).
+			addKeyValue("status", params.Status).
+			build()
+	}
+
+	return tr.renderWithParams(v, "Todo", args, func() string {
+		// Leave out the line introducing the list.
+		_, list, ok := strings.Cut(v.result.Content, "\n\n")
+		if !ok {
+			list = v.result.Content
+		}
+		return renderPlainContent(v, list)
+	})
+}
+
+// jobRenderer handles the tools checking on background jobs
+type jobRenderer struct {
+	baseRenderer
+}
+
+// Render displays the job, its status and its new output
+func (jr jobRenderer) Render(v *toolCallCmp) string {
+	var params tools.JobParams
+	if err := jr.unmarshalParams(v.call.Input, &params); err != nil {
+		return jr.renderError(v, "Invalid job parameters")
+	}
+	args := newParamBuilder().addMain(params.JobID).build()
+
+	return jr.renderWithParams(v, prettifyToolName(v.call.Name), args, func() string {
+		var meta tools.JobResponseMetadata
+		if err := jr.unmarshalParams(v.result.Metadata, &meta); err != nil {
+			return renderPlainContent(v, v.result.Content)
+		}
+		status := "Running"
+		switch {
+		case meta.Running:
+		case meta.Killed:
+			status = "Killed"
+		default:
+			status = fmt.Sprintf("Exited with code %d", meta.ExitCode)
+		}
+		if meta.Output == "" {
+			return renderPlainContent(v, status)
+		}
+		return renderPlainContent(v, status+"\n\n"+meta.Output)
+	})
+}
+
+// planRenderer handles plans submitted for approval in plan mode
+type planRenderer struct {
+	baseRenderer
+}
+
+// Render displays the steps of the plan as Markdown
+func (pr planRenderer) Render(v *toolCallCmp) string {
+	params, err := tools.ParsePlan(v.call.Input)
+	var args []string
+	if err == nil {
+		args = newParamBuilder().
+			addMain(fmt.Sprintf("%d steps", len(params.Steps))).
+			build()
+	}
+
+	return pr.renderWithParams(v, "Plan", args, func() string {
+		r := styles.GetMarkdownRenderer(v.textWidth() - 2)
+		rendered, _ := r.Render(params.Markdown())
+		return strings.TrimSuffix(rendered, "\n")
+	})
+}
+
 // -----------------------------------------------------------------------------
 //  Task renderer
 // -----------------------------------------------------------------------------
@@ -723,7 +955,7 @@ func earlyState(header string, v *toolCallCmp) (string, bool) {
 		message = v.renderToolError()
 	case v.cancelled:
 		message = t.S().Base.Foreground(t.FgSubtle).Render("Canceled.")
-	case v.result.ToolCallID == "":
+	case v.result.ToolCallID == "" && v.output == "":
 		if v.permissionRequested && !v.permissionGranted {
 			message = t.S().Base.Foreground(t.FgSubtle).Render("Requesting for permission...")
 		} else {
@@ -780,6 +1012,23 @@ func renderPlainContent(v *toolCallCmp, content string) string {
 	return strings.Join(out, "\n")
 }
 
+// renderLiveOutput displays the last lines of the output of a running tool
+// call.
+func renderLiveOutput(v *toolCallCmp, output string) string {
+	output = strings.TrimSpace(strings.ReplaceAll(output, "\r\n", "\n"))
+	lines := strings.Split(output, "\n")
+	if len(lines) <= responseContextHeight {
+		return renderPlainContent(v, output)
+	}
+
+	t := styles.CurrentTheme()
+	skipped := t.S().Muted.
+		Background(t.BgBaseLighter).
+		Width(v.textWidth() - 2).
+		Render(fmt.Sprintf("… (%d lines)", len(lines)-responseContextHeight))
+	return skipped + "\n" + renderPlainContent(v, strings.Join(lines[len(lines)-responseContextHeight:], "\n"))
+}
+
 func getDigits(n int) int {
 	if n == 0 {
 		return 1
@@ -884,8 +1133,30 @@ func prettifyToolName(name string) string {
 		return "Sourcegraph"
 	case tools.ViewToolName:
 		return "View"
+	case tools.DefinitionToolName:
+		return "Definition"
+	case tools.ReferencesToolName:
+		return "References"
+	case tools.HoverToolName:
+		return "Hover"
+	case tools.SymbolsToolName:
+		return "Symbols"
+	case tools.RenameToolName:
+		return "Rename"
+	case tools.CodeActionToolName:
+		return "Code Action"
 	case tools.WriteToolName:
 		return "Write"
+	case tools.PlanToolName:
+		return "Plan"
+	case tools.TodoToolName:
+		return "Todo"
+	case tools.JobOutputToolName:
+		return "Job Output"
+	case tools.JobWaitToolName:
+		return "Job Wait"
+	case tools.JobKillToolName:
+		return "Job Kill"
 	default:
 		return name
 	}
@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/atotto/clipboard"
 	"github.com/charmbracelet/bubbles/v2/key"
@@ -43,8 +44,13 @@ type ToolCallCmp interface {
 	ID() string
 	SetPermissionRequested() // Mark permission request
 	SetPermissionGranted()   // Mark permission granted
+	AppendOutput(string)     // Add output printed by the running tool
 }
 
+// maxLiveOutput is how much of the output printed by a running tool is kept
+// to be shown.
+const maxLiveOutput = 16 * 1024
+
 // toolCallCmp implements the ToolCallCmp interface for displaying tool calls.
 // It handles rendering of tool execution states including pending, completed, and error states.
 type toolCallCmp struct {
@@ -59,6 +65,7 @@ type toolCallCmp struct {
 	cancelled           bool               // Whether the tool call was cancelled
 	permissionRequested bool
 	permissionGranted   bool
+	output              string // Output printed by the tool while running
 
 	// Animation state for pending tool calls
 	spinning bool       // Whether to show loading animation
@@ -397,7 +404,9 @@ func (m *toolCallCmp) formatResultForCopy() string {
 		return m.formatFetchResultForCopy()
 	case agent.AgentToolName:
 		return m.formatAgentResultForCopy()
-	case tools.DownloadToolName, tools.GrepToolName, tools.GlobToolName, tools.LSToolName, tools.SourcegraphToolName, tools.DiagnosticsToolName:
+	case tools.DownloadToolName, tools.GrepToolName, tools.GlobToolName, tools.LSToolName, tools.SourcegraphToolName, tools.DiagnosticsToolName,
+		tools.DefinitionToolName, tools.ReferencesToolName, tools.HoverToolName, tools.SymbolsToolName,
+		tools.RenameToolName, tools.CodeActionToolName:
 		return fmt.Sprintf("```\n%s\n```", m.result.Content)
 	default:
 		return m.result.Content
@@ -665,6 +674,7 @@ func (m *toolCallCmp) ParentMessageID() string {
 // SetToolResult updates the tool result and stops the spinning animation
 func (m *toolCallCmp) SetToolResult(result message.ToolResult) {
 	m.result = result
+	m.output = ""
 	m.spinning = false
 }
 
@@ -810,3 +820,19 @@ func (m *toolCallCmp) SetPermissionRequested() {
 func (m *toolCallCmp) SetPermissionGranted() {
 	m.permissionGranted = true
 }
+
+// AppendOutput adds output printed by the tool while it runs, shown until
+// the result arrives. Only the end of it is kept.
+func (m *toolCallCmp) AppendOutput(output string) {
+	if m.result.ToolCallID != "" {
+		return
+	}
+	m.output += output
+	if len(m.output) > maxLiveOutput {
+		start := len(m.output) - maxLiveOutput
+		for start < len(m.output) && !utf8.RuneStart(m.output[start]) {
+			start++
+		}
+		m.output = m.output[start:]
+	}
+}
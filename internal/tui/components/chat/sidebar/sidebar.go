@@ -17,6 +17,8 @@ import (
 	"github.com/charmbracelet/crush/internal/lsp"
 	"github.com/charmbracelet/crush/internal/pubsub"
 	"github.com/charmbracelet/crush/internal/session"
+	"github.com/charmbracelet/crush/internal/shell"
+	"github.com/charmbracelet/crush/internal/todo"
 	"github.com/charmbracelet/crush/internal/tui/components/chat"
 	"github.com/charmbracelet/crush/internal/tui/components/core"
 	"github.com/charmbracelet/crush/internal/tui/components/core/layout"
@@ -28,6 +30,7 @@ import (
 	"github.com/charmbracelet/crush/internal/tui/util"
 	"github.com/charmbracelet/crush/internal/version"
 	"github.com/charmbracelet/lipgloss/v2"
+	"github.com/charmbracelet/x/ansi"
 	"golang.org/x/text/cases"
 	"golang.org/x/text/language"
 )
@@ -44,6 +47,8 @@ const (
 	DefaultMaxFilesShown = 10
 	DefaultMaxLSPsShown  = 8
 	DefaultMaxMCPsShown  = 8
+	DefaultMaxTodosShown = 10
+	DefaultMaxJobsShown  = 5
 	MinItemsPerSection   = 2 // Minimum items to show per section
 )
 
@@ -57,6 +62,12 @@ type SessionFilesMsg struct {
 	Files []SessionFile
 }
 
+// SessionTodosMsg holds the todo list of the session.
+type SessionTodosMsg struct {
+	SessionID string
+	Todos     []todo.Todo
+}
+
 type Sidebar interface {
 	util.Model
 	layout.Sizeable
@@ -73,12 +84,18 @@ type sidebarCmp struct {
 	compactMode   bool
 	history       history.Service
 	files         *csync.Map[string, SessionFile]
+	todoService   todo.Service
+	todos         []todo.Todo
+	jobManager    *shell.JobManager
+	jobs          []shell.Job
 }
 
-func New(history history.Service, lspClients map[string]*lsp.Client, compact bool) Sidebar {
+func New(history history.Service, todos todo.Service, jobs *shell.JobManager, lspClients map[string]*lsp.Client, compact bool) Sidebar {
 	return &sidebarCmp{
 		lspClients:  lspClients,
 		history:     history,
+		todoService: todos,
+		jobManager:  jobs,
 		compactMode: compact,
 		files:       csync.NewMap[string, SessionFile](),
 	}
@@ -97,8 +114,23 @@ func (m *sidebarCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 		return m, nil
 
+	case SessionTodosMsg:
+		if msg.SessionID == m.session.ID {
+			m.todos = msg.Todos
+		}
+		return m, nil
+	case pubsub.Event[todo.Todo]:
+		if msg.Payload.SessionID == m.session.ID {
+			return m, m.loadSessionTodos
+		}
+	case pubsub.Event[shell.Job]:
+		if msg.Payload.SessionID == m.session.ID {
+			m.jobs = m.jobManager.List(m.session.ID)
+		}
 	case chat.SessionClearedMsg:
 		m.session = session.Session{}
+		m.todos = nil
+		m.jobs = nil
 	case pubsub.Event[history.File]:
 		return m, m.handleFileHistoryEvent(msg)
 	case pubsub.Event[session.Session]:
@@ -159,6 +191,12 @@ func (m *sidebarCmp) View() string {
 		}
 	} else {
 		// Vertical layout (default)
+		if len(m.todos) > 0 {
+			parts = append(parts, "", m.todosBlock())
+		}
+		if len(m.jobs) > 0 {
+			parts = append(parts, "", m.jobsBlock())
+		}
 		if m.session.ID != "" {
 			parts = append(parts, "", m.filesBlock())
 		}
@@ -265,6 +303,20 @@ func (m *sidebarCmp) loadSessionFiles() tea.Msg {
 	}
 }
 
+func (m *sidebarCmp) loadSessionTodos() tea.Msg {
+	todos, err := m.todoService.List(context.Background(), m.session.ID)
+	if err != nil {
+		return util.InfoMsg{
+			Type: util.InfoTypeError,
+			Msg:  err.Error(),
+		}
+	}
+	return SessionTodosMsg{
+		SessionID: m.session.ID,
+		Todos:     todos,
+	}
+}
+
 func (m *sidebarCmp) SetSize(width, height int) tea.Cmd {
 	m.logo = m.logoBlock()
 	m.cwd = cwd()
@@ -318,6 +370,14 @@ func (m *sidebarCmp) calculateAvailableHeight() int {
 
 	usedHeight += 2 // Model info
 
+	if len(m.todos) > 0 {
+		usedHeight += 3 + min(len(m.todos), DefaultMaxTodosShown) // Todos section
+	}
+
+	if len(m.jobs) > 0 {
+		usedHeight += 3 + min(len(m.jobs), DefaultMaxJobsShown) // Jobs section
+	}
+
 	usedHeight += 6 // 3 sections × 2 lines each (header + empty line)
 
 	// Base padding
@@ -477,6 +537,92 @@ func (m *sidebarCmp) filesBlock() string {
 	}, true)
 }
 
+// todosBlock renders the todo list of the session as a checklist.
+func (m *sidebarCmp) todosBlock() string {
+	t := styles.CurrentTheme()
+	maxWidth := m.getMaxWidth()
+
+	completed := 0
+	for _, item := range m.todos {
+		if item.Status == todo.StatusCompleted {
+			completed++
+		}
+	}
+	info := t.S().Subtle.Render(fmt.Sprintf("%d/%d", completed, len(m.todos)))
+	list := []string{
+		core.SectionWithInfo(t.S().Subtle.Render("Todos"), maxWidth, info),
+		"",
+	}
+
+	for i, item := range m.todos {
+		if i == DefaultMaxTodosShown {
+			list = append(list, t.S().Base.Foreground(t.FgSubtle).Render(fmt.Sprintf("…and %d more", len(m.todos)-i)))
+			break
+		}
+		icon := t.S().Base.Foreground(t.FgMuted).Render("○")
+		content := t.S().Text
+		switch item.Status {
+		case todo.StatusCompleted:
+			icon = t.S().Base.Foreground(t.Success).Render(styles.CheckIcon)
+			content = t.S().Subtle.Strikethrough(true)
+		case todo.StatusInProgress:
+			icon = t.S().Base.Foreground(t.Primary).Render(styles.ToolPending)
+		}
+		text := ansi.Truncate(item.Content, maxWidth-2, "…")
+		list = append(list, icon+" "+content.Render(text))
+	}
+
+	return lipgloss.JoinVertical(lipgloss.Left, list...)
+}
+
+// jobsBlock renders the background jobs of the session, the latest first.
+func (m *sidebarCmp) jobsBlock() string {
+	t := styles.CurrentTheme()
+	maxWidth := m.getMaxWidth()
+
+	running := 0
+	for _, job := range m.jobs {
+		if job.Running() {
+			running++
+		}
+	}
+	info := t.S().Subtle.Render(fmt.Sprintf("%d running", running))
+	list := []string{
+		core.SectionWithInfo(t.S().Subtle.Render("Background Jobs"), maxWidth, info),
+		"",
+	}
+
+	jobs := slices.Clone(m.jobs)
+	slices.Reverse(jobs)
+	for i, job := range jobs {
+		if i == DefaultMaxJobsShown {
+			list = append(list, t.S().Base.Foreground(t.FgSubtle).Render(fmt.Sprintf("…and %d more", len(jobs)-i)))
+			break
+		}
+		icon := t.S().Base.Foreground(t.Primary).Render(styles.ToolPending)
+		status := ""
+		switch {
+		case job.Running():
+		case job.Killed:
+			icon = t.S().Base.Foreground(t.FgMuted).Render(styles.ToolError)
+			status = "killed"
+		case job.ExitCode != 0:
+			icon = t.S().Base.Foreground(t.Error).Render(styles.ToolError)
+			status = fmt.Sprintf("exit %d", job.ExitCode)
+		default:
+			icon = t.S().Base.Foreground(t.Success).Render(styles.CheckIcon)
+		}
+		if status != "" {
+			status = " " + t.S().Subtle.Render(status)
+		}
+		command := strings.Join(strings.Fields(job.Command), " ")
+		text := ansi.Truncate(command, maxWidth-2-lipgloss.Width(status), "…")
+		list = append(list, icon+" "+t.S().Text.Render(text)+status)
+	}
+
+	return lipgloss.JoinVertical(lipgloss.Left, list...)
+}
+
 func (m *sidebarCmp) lspBlock() string {
 	// Limit the number of LSPs shown
 	_, maxLSPs, _ := m.getDynamicLimits()
@@ -545,7 +691,7 @@ func formatTokensAndCost(tokens, contextWindow int64, cost float64) string {
 
 func (s *sidebarCmp) currentModelBlock() string {
 	cfg := config.Get()
-	agentCfg := cfg.Agents["coder"]
+	agentCfg := cfg.SessionAgent(s.session.AgentID)
 
 	selectedModel := cfg.Models[agentCfg.Model]
 
@@ -560,6 +706,12 @@ func (s *sidebarCmp) currentModelBlock() string {
 	parts := []string{
 		modelInfo,
 	}
+	if agentCfg.ID != "coder" {
+		parts = append(parts, t.S().Subtle.PaddingLeft(2).Render("Agent "+agentCfg.Name))
+	}
+	if s.session.PlanMode {
+		parts = append(parts, t.S().Base.Foreground(t.Primary).PaddingLeft(2).Render("Plan mode"))
+	}
 	if model.CanReason {
 		reasoningInfoStyle := t.S().Subtle.PaddingLeft(2)
 		switch modelProvider.Type {
@@ -598,7 +750,9 @@ func (s *sidebarCmp) currentModelBlock() string {
 // SetSession implements Sidebar.
 func (m *sidebarCmp) SetSession(session session.Session) tea.Cmd {
 	m.session = session
-	return m.loadSessionFiles
+	m.todos = nil
+	m.jobs = m.jobManager.List(session.ID)
+	return tea.Batch(m.loadSessionFiles, m.loadSessionTodos)
 }
 
 // SetCompactMode sets the compact mode for the sidebar.
@@ -63,6 +63,8 @@ type (
 	SwitchSessionsMsg     struct{}
 	NewSessionsMsg        struct{}
 	SwitchModelMsg        struct{}
+	SwitchAgentMsg        struct{}
+	TogglePlanModeMsg     struct{}
 	QuitMsg               struct{}
 	OpenFilePickerMsg     struct{}
 	ToggleHelpMsg         struct{}
@@ -70,9 +72,20 @@ type (
 	ToggleThinkingMsg     struct{}
 	OpenExternalEditorMsg struct{}
 	ToggleYoloModeMsg     struct{}
+	ManagePermissionsMsg  struct{}
+	SearchSessionsMsg     struct{}
 	CompactMsg            struct {
 		SessionID string
 	}
+	ExportSessionMsg struct {
+		SessionID string
+	}
+	RetryMsg struct {
+		SessionID string
+	}
+	RetryWithModelMsg struct {
+		SessionID string
+	}
 )
 
 func NewCommandDialog(sessionID string) CommandsDialog {
@@ -276,6 +289,14 @@ func (c *commandDialogCmp) defaultCommands() []Command {
 				return util.CmdHandler(SwitchSessionsMsg{})
 			},
 		},
+		{
+			ID:          "search_sessions",
+			Title:       "Search Sessions",
+			Description: "Search the messages of all sessions",
+			Handler: func(cmd Command) tea.Cmd {
+				return util.CmdHandler(SearchSessionsMsg{})
+			},
+		},
 		{
 			ID:          "switch_model",
 			Title:       "Switch Model",
@@ -284,6 +305,22 @@ func (c *commandDialogCmp) defaultCommands() []Command {
 				return util.CmdHandler(SwitchModelMsg{})
 			},
 		},
+		{
+			ID:          "switch_agent",
+			Title:       "Switch Agent",
+			Description: "Switch to a different agent for the session",
+			Handler: func(cmd Command) tea.Cmd {
+				return util.CmdHandler(SwitchAgentMsg{})
+			},
+		},
+		{
+			ID:          "toggle_plan_mode",
+			Title:       "Toggle Plan Mode",
+			Description: "Only plan the work with read-only tools and approve the plan before it's carried out",
+			Handler: func(cmd Command) tea.Cmd {
+				return util.CmdHandler(TogglePlanModeMsg{})
+			},
+		},
 	}
 
 	// Only show compact command if there's an active session
@@ -298,6 +335,36 @@ func (c *commandDialogCmp) defaultCommands() []Command {
 				})
 			},
 		})
+		commands = append(commands, Command{
+			ID:          "retry",
+			Title:       "Retry Last Turn",
+			Description: "Run the last prompt again, keeping the previous attempt in a child session",
+			Handler: func(cmd Command) tea.Cmd {
+				return util.CmdHandler(RetryMsg{
+					SessionID: c.sessionID,
+				})
+			},
+		})
+		commands = append(commands, Command{
+			ID:          "retry_with_model",
+			Title:       "Retry Last Turn with Model...",
+			Description: "Switch to another model and run the last prompt again",
+			Handler: func(cmd Command) tea.Cmd {
+				return util.CmdHandler(RetryWithModelMsg{
+					SessionID: c.sessionID,
+				})
+			},
+		})
+		commands = append(commands, Command{
+			ID:          "export_session",
+			Title:       "Export Session",
+			Description: "Export the current session as a Markdown transcript in the working directory",
+			Handler: func(cmd Command) tea.Cmd {
+				return util.CmdHandler(ExportSessionMsg{
+					SessionID: c.sessionID,
+				})
+			},
+		})
 	}
 
 	// Only show thinking toggle for Anthropic models that can reason
@@ -371,6 +438,14 @@ func (c *commandDialogCmp) defaultCommands() []Command {
 				return util.CmdHandler(ToggleYoloModeMsg{})
 			},
 		},
+		{
+			ID:          "manage_permissions",
+			Title:       "Manage Permissions",
+			Description: "Review and revoke remembered permissions",
+			Handler: func(cmd Command) tea.Cmd {
+				return util.CmdHandler(ManagePermissionsMsg{})
+			},
+		},
 		{
 			ID:          "toggle_help",
 			Title:       "Toggle Help",
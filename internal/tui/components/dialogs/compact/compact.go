@@ -104,7 +104,10 @@ func (c *compactDialogCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 
 	case agent.AgentEvent:
-		if msg.Type == agent.AgentEventTypeSummarize {
+		if msg.Type == agent.AgentEventTypeError && msg.SessionID == c.sessionID {
+			c.state = stateError
+			c.progress = "Error: " + msg.Error.Error()
+		} else if msg.Type == agent.AgentEventTypeSummarize {
 			if msg.Error != nil {
 				c.state = stateError
 				c.progress = "Error: " + msg.Error.Error()
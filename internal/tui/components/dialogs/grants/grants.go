@@ -0,0 +1,189 @@
+package grants
+
+import (
+	"fmt"
+
+	"github.com/charmbracelet/bubbles/v2/help"
+	"github.com/charmbracelet/bubbles/v2/key"
+	tea "github.com/charmbracelet/bubbletea/v2"
+	"github.com/charmbracelet/crush/internal/fsext"
+	"github.com/charmbracelet/crush/internal/permission"
+	"github.com/charmbracelet/crush/internal/tui/components/core"
+	"github.com/charmbracelet/crush/internal/tui/components/dialogs"
+	"github.com/charmbracelet/crush/internal/tui/exp/list"
+	"github.com/charmbracelet/crush/internal/tui/styles"
+	"github.com/charmbracelet/crush/internal/tui/util"
+	"github.com/charmbracelet/lipgloss/v2"
+)
+
+const GrantsDialogID dialogs.DialogID = "grants"
+
+// GrantsDialog interface for the dialog listing the remembered permissions
+type GrantsDialog interface {
+	dialogs.DialogModel
+}
+
+type GrantsList = list.FilterableList[list.CompletionItem[permission.Grant]]
+
+type grantsDialogCmp struct {
+	wWidth      int
+	wHeight     int
+	width       int
+	keyMap      KeyMap
+	permissions permission.Service
+	grantsList  GrantsList
+	grantCount  int
+	help        help.Model
+}
+
+// NewGrantsDialogCmp creates a dialog to review and revoke the permissions
+// granted beyond a single tool call.
+func NewGrantsDialogCmp(permissions permission.Service, grants []permission.Grant) GrantsDialog {
+	t := styles.CurrentTheme()
+	listKeyMap := list.DefaultKeyMap()
+	keyMap := DefaultKeyMap()
+	listKeyMap.Down.SetEnabled(false)
+	listKeyMap.Up.SetEnabled(false)
+	listKeyMap.DownOneItem = keyMap.Next
+	listKeyMap.UpOneItem = keyMap.Previous
+
+	inputStyle := t.S().Base.PaddingLeft(1).PaddingBottom(1)
+	grantsList := list.NewFilterableList(
+		grantItems(grants),
+		list.WithFilterPlaceholder("Filter permissions"),
+		list.WithFilterInputStyle(inputStyle),
+		list.WithFilterListOptions(
+			list.WithKeyMap(listKeyMap),
+			list.WithWrapNavigation(),
+		),
+	)
+	help := help.New()
+	help.Styles = t.S().Help
+	return &grantsDialogCmp{
+		keyMap:      keyMap,
+		permissions: permissions,
+		grantsList:  grantsList,
+		grantCount:  len(grants),
+		help:        help,
+	}
+}
+
+func grantItems(grants []permission.Grant) []list.CompletionItem[permission.Grant] {
+	items := make([]list.CompletionItem[permission.Grant], len(grants))
+	for i, grant := range grants {
+		text := fmt.Sprintf("%s %s in %s", grant.ToolName, grant.Action, fsext.PrettyPath(grant.Path))
+		items[i] = list.NewCompletionItem(
+			text,
+			grant,
+			list.WithCompletionID(grant.ID),
+			list.WithCompletionShortcut(string(grant.Scope)),
+		)
+	}
+	return items
+}
+
+func (g *grantsDialogCmp) Init() tea.Cmd {
+	return tea.Sequence(g.grantsList.Init(), g.grantsList.Focus())
+}
+
+func (g *grantsDialogCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		g.wWidth = msg.Width
+		g.wHeight = msg.Height
+		g.width = min(120, g.wWidth-8)
+		g.grantsList.SetInputWidth(g.listWidth() - 2)
+		return g, g.grantsList.SetSize(g.listWidth(), g.listHeight())
+	case tea.KeyPressMsg:
+		switch {
+		case key.Matches(msg, g.keyMap.Revoke):
+			selectedItem := g.grantsList.SelectedItem()
+			if selectedItem == nil {
+				return g, nil
+			}
+			if err := g.permissions.RevokeGrant((*selectedItem).Value().ID); err != nil {
+				return g, util.ReportError(err)
+			}
+			grants, err := g.permissions.ListGrants()
+			if err != nil {
+				return g, util.ReportError(err)
+			}
+			g.grantCount = len(grants)
+			return g, tea.Batch(
+				g.grantsList.SetItems(grantItems(grants)),
+				util.ReportInfo("Permission revoked"),
+			)
+		case key.Matches(msg, g.keyMap.Close):
+			return g, util.CmdHandler(dialogs.CloseDialogMsg{})
+		default:
+			u, cmd := g.grantsList.Update(msg)
+			g.grantsList = u.(GrantsList)
+			return g, cmd
+		}
+	}
+	return g, nil
+}
+
+func (g *grantsDialogCmp) View() string {
+	t := styles.CurrentTheme()
+	listView := g.grantsList.View()
+	if g.grantCount == 0 {
+		listView = t.S().Muted.PaddingLeft(1).Render("No remembered permissions")
+	}
+	content := lipgloss.JoinVertical(
+		lipgloss.Left,
+		t.S().Base.Padding(0, 1, 1, 1).Render(core.Title("Permissions", g.width-4)),
+		listView,
+		"",
+		t.S().Base.Width(g.width-2).PaddingLeft(1).AlignHorizontal(lipgloss.Left).Render(g.help.View(g.keyMap)),
+	)
+
+	return g.style().Render(content)
+}
+
+func (g *grantsDialogCmp) Cursor() *tea.Cursor {
+	if cursor, ok := g.grantsList.(util.Cursor); ok {
+		cursor := cursor.Cursor()
+		if cursor != nil {
+			cursor = g.moveCursor(cursor)
+		}
+		return cursor
+	}
+	return nil
+}
+
+func (g *grantsDialogCmp) style() lipgloss.Style {
+	t := styles.CurrentTheme()
+	return t.S().Base.
+		Width(g.width).
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(t.BorderFocus)
+}
+
+func (g *grantsDialogCmp) listHeight() int {
+	return g.wHeight/2 - 6 // 5 for the border, title and help
+}
+
+func (g *grantsDialogCmp) listWidth() int {
+	return g.width - 2 // 2 for the border
+}
+
+func (g *grantsDialogCmp) Position() (int, int) {
+	row := g.wHeight/4 - 2 // just a bit above the center
+	col := g.wWidth / 2
+	col -= g.width / 2
+	return row, col
+}
+
+func (g *grantsDialogCmp) moveCursor(cursor *tea.Cursor) *tea.Cursor {
+	row, col := g.Position()
+	offset := row + 3 // Border + title
+	cursor.Y += offset
+	cursor.X = cursor.X + col + 2
+	return cursor
+}
+
+// ID implements GrantsDialog.
+func (g *grantsDialogCmp) ID() dialogs.DialogID {
+	return GrantsDialogID
+}
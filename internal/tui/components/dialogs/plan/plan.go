@@ -0,0 +1,199 @@
+package plan
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/v2/key"
+	tea "github.com/charmbracelet/bubbletea/v2"
+	"github.com/charmbracelet/crush/internal/llm/tools"
+	"github.com/charmbracelet/crush/internal/tui/components/dialogs"
+	"github.com/charmbracelet/crush/internal/tui/styles"
+	"github.com/charmbracelet/crush/internal/tui/util"
+	"github.com/charmbracelet/lipgloss/v2"
+	"github.com/charmbracelet/x/ansi"
+)
+
+const (
+	title                         = "Approve the plan?"
+	maxWidth                      = 80
+	PlanDialogID dialogs.DialogID = "plan"
+)
+
+type option int
+
+const (
+	optionApprove option = iota
+	optionEdit
+	optionReject
+)
+
+// PlanApprovedMsg is sent when the plan is approved, to be carried out as it
+// is.
+type PlanApprovedMsg struct {
+	SessionID string
+	Plan      tools.PlanParams
+}
+
+// PlanEditMsg is sent when the plan is to be edited before it's carried out.
+type PlanEditMsg struct {
+	SessionID string
+	Plan      tools.PlanParams
+}
+
+// PlanRejectedMsg is sent when the plan is rejected, for the agent to plan
+// again.
+type PlanRejectedMsg struct {
+	SessionID string
+}
+
+// PlanDialog represents the dialog approving the plan of a session in plan
+// mode.
+type PlanDialog interface {
+	dialogs.DialogModel
+}
+
+type planDialogCmp struct {
+	wWidth  int
+	wHeight int
+
+	sessionID string
+	plan      tools.PlanParams
+	selected  option
+	keymap    KeyMap
+}
+
+// NewPlanDialog creates a dialog asking to approve, edit or reject the plan
+// submitted in the session.
+func NewPlanDialog(sessionID string, plan tools.PlanParams) PlanDialog {
+	return &planDialogCmp{
+		sessionID: sessionID,
+		plan:      plan,
+		selected:  optionApprove,
+		keymap:    DefaultKeymap(),
+	}
+}
+
+func (p *planDialogCmp) Init() tea.Cmd {
+	return nil
+}
+
+// Update handles keyboard input for the plan dialog.
+func (p *planDialogCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		p.wWidth = msg.Width
+		p.wHeight = msg.Height
+	case tea.KeyPressMsg:
+		switch {
+		case key.Matches(msg, p.keymap.LeftRight, p.keymap.Tab):
+			if msg.String() == "left" {
+				p.selected = (p.selected + 2) % 3
+			} else {
+				p.selected = (p.selected + 1) % 3
+			}
+		case key.Matches(msg, p.keymap.EnterSpace):
+			return p, p.choose(p.selected)
+		case key.Matches(msg, p.keymap.Approve):
+			return p, p.choose(optionApprove)
+		case key.Matches(msg, p.keymap.Edit):
+			return p, p.choose(optionEdit)
+		case key.Matches(msg, p.keymap.Reject):
+			return p, p.choose(optionReject)
+		case key.Matches(msg, p.keymap.Close):
+			return p, util.CmdHandler(dialogs.CloseDialogMsg{})
+		}
+	}
+	return p, nil
+}
+
+func (p *planDialogCmp) choose(o option) tea.Cmd {
+	var msg tea.Msg
+	switch o {
+	case optionApprove:
+		msg = PlanApprovedMsg{SessionID: p.sessionID, Plan: p.plan}
+	case optionEdit:
+		msg = PlanEditMsg{SessionID: p.sessionID, Plan: p.plan}
+	default:
+		msg = PlanRejectedMsg{SessionID: p.sessionID}
+	}
+	return tea.Sequence(
+		util.CmdHandler(dialogs.CloseDialogMsg{}),
+		util.CmdHandler(msg),
+	)
+}
+
+// width returns the width of the content of the dialog.
+func (p *planDialogCmp) width() int {
+	return max(min(maxWidth, p.wWidth-10), lipgloss.Width(title))
+}
+
+// steps renders the steps of the plan, as many as fit in the window.
+func (p *planDialogCmp) steps(width int) []string {
+	t := styles.CurrentTheme()
+	maxSteps := max(p.wHeight-16, 3)
+	var lines []string
+	for i, step := range p.plan.Steps {
+		if i == maxSteps-1 && len(p.plan.Steps) > maxSteps {
+			lines = append(lines, t.S().Subtle.Render(fmt.Sprintf("…and %d more steps", len(p.plan.Steps)-i)))
+			break
+		}
+		line := fmt.Sprintf("%d. %s", i+1, strings.TrimSpace(step.Title))
+		if len(step.Files) > 0 {
+			line += t.S().Subtle.Render(" " + strings.Join(step.Files, ", "))
+		}
+		lines = append(lines, ansi.Truncate(line, width, "…"))
+	}
+	return lines
+}
+
+// View renders the plan with the Approve/Edit/Reject buttons.
+func (p *planDialogCmp) View() string {
+	t := styles.CurrentTheme()
+	baseStyle := t.S().Base
+	width := p.width()
+
+	const horizontalPadding = 3
+	var buttons []string
+	for i, label := range []string{"Approve", "Edit", "Reject"} {
+		style := t.S().Text.Background(t.BgSubtle)
+		if option(i) == p.selected {
+			style = t.S().Text.Foreground(t.White).Background(t.Secondary)
+		}
+		buttons = append(buttons,
+			style.PaddingLeft(horizontalPadding).Underline(true).Render(label[:1])+
+				style.PaddingRight(horizontalPadding).Render(label[1:]),
+			"  ",
+		)
+	}
+	buttonsRow := baseStyle.Width(width).Align(lipgloss.Right).Render(
+		lipgloss.JoinHorizontal(lipgloss.Center, buttons[:len(buttons)-1]...),
+	)
+
+	parts := []string{title, ""}
+	if summary := strings.TrimSpace(p.plan.Summary); summary != "" {
+		parts = append(parts, t.S().Muted.Width(width).Render(summary), "")
+	}
+	parts = append(parts, p.steps(width)...)
+	parts = append(parts, "", buttonsRow)
+
+	content := baseStyle.Render(lipgloss.JoinVertical(lipgloss.Left, parts...))
+
+	planDialogStyle := baseStyle.
+		Padding(1, 2).
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(t.BorderFocus)
+
+	return planDialogStyle.Render(content)
+}
+
+func (p *planDialogCmp) Position() (int, int) {
+	view := p.View()
+	row := p.wHeight/2 - lipgloss.Height(view)/2
+	col := p.wWidth/2 - lipgloss.Width(view)/2
+	return max(row, 0), max(col, 0)
+}
+
+func (p *planDialogCmp) ID() dialogs.DialogID {
+	return PlanDialogID
+}
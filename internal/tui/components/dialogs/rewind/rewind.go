@@ -0,0 +1,166 @@
+package rewind
+
+import (
+	"fmt"
+
+	"github.com/charmbracelet/bubbles/v2/key"
+	tea "github.com/charmbracelet/bubbletea/v2"
+	"github.com/charmbracelet/crush/internal/checkpoint"
+	"github.com/charmbracelet/crush/internal/tui/components/dialogs"
+	"github.com/charmbracelet/crush/internal/tui/styles"
+	"github.com/charmbracelet/crush/internal/tui/util"
+	"github.com/charmbracelet/lipgloss/v2"
+)
+
+const (
+	question                        = "Rewind the session to before this message?"
+	RewindDialogID dialogs.DialogID = "rewind"
+)
+
+// RewindConfirmedMsg is sent when the rewind of a session is confirmed.
+type RewindConfirmedMsg struct {
+	Checkpoint checkpoint.Checkpoint
+}
+
+// RewindDialog represents a confirmation dialog for rewinding a session.
+type RewindDialog interface {
+	dialogs.DialogModel
+}
+
+type rewindDialogCmp struct {
+	wWidth  int
+	wHeight int
+
+	checkpoint checkpoint.Checkpoint
+	selectedNo bool // true if "No" button is selected
+	keymap     KeyMap
+}
+
+// NewRewindDialog creates a dialog confirming the rewind of a session to the
+// checkpoint.
+func NewRewindDialog(c checkpoint.Checkpoint) RewindDialog {
+	return &rewindDialogCmp{
+		checkpoint: c,
+		selectedNo: true, // Default to "No" for safety
+		keymap:     DefaultKeymap(),
+	}
+}
+
+func (r *rewindDialogCmp) Init() tea.Cmd {
+	return nil
+}
+
+// Update handles keyboard input for the rewind dialog.
+func (r *rewindDialogCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		r.wWidth = msg.Width
+		r.wHeight = msg.Height
+	case tea.KeyPressMsg:
+		switch {
+		case key.Matches(msg, r.keymap.LeftRight, r.keymap.Tab):
+			r.selectedNo = !r.selectedNo
+			return r, nil
+		case key.Matches(msg, r.keymap.EnterSpace):
+			if !r.selectedNo {
+				return r, r.confirm()
+			}
+			return r, util.CmdHandler(dialogs.CloseDialogMsg{})
+		case key.Matches(msg, r.keymap.Yes):
+			return r, r.confirm()
+		case key.Matches(msg, r.keymap.No, r.keymap.Close):
+			return r, util.CmdHandler(dialogs.CloseDialogMsg{})
+		}
+	}
+	return r, nil
+}
+
+func (r *rewindDialogCmp) confirm() tea.Cmd {
+	return tea.Sequence(
+		util.CmdHandler(dialogs.CloseDialogMsg{}),
+		util.CmdHandler(RewindConfirmedMsg{Checkpoint: r.checkpoint}),
+	)
+}
+
+// details describes what the rewind does.
+func (r *rewindDialogCmp) details() []string {
+	lines := []string{
+		fmt.Sprintf("%d messages will be removed", len(r.checkpoint.Messages)),
+		fmt.Sprintf("%d files will be restored", len(r.checkpoint.Reverts)),
+	}
+	if conflicts := len(r.checkpoint.Conflicts()); conflicts > 0 {
+		lines = append(lines, fmt.Sprintf("%d files modified outside of Crush will be overwritten", conflicts))
+	}
+	return lines
+}
+
+// View renders the rewind dialog with Yes/No buttons.
+func (r *rewindDialogCmp) View() string {
+	t := styles.CurrentTheme()
+	baseStyle := t.S().Base
+	yesStyle := t.S().Text
+	noStyle := yesStyle
+
+	if r.selectedNo {
+		noStyle = noStyle.Foreground(t.White).Background(t.Secondary)
+		yesStyle = yesStyle.Background(t.BgSubtle)
+	} else {
+		yesStyle = yesStyle.Foreground(t.White).Background(t.Secondary)
+		noStyle = noStyle.Background(t.BgSubtle)
+	}
+
+	const horizontalPadding = 3
+	yesButton := yesStyle.PaddingLeft(horizontalPadding).Underline(true).Render("Y") +
+		yesStyle.PaddingRight(horizontalPadding).Render("es")
+	noButton := noStyle.PaddingLeft(horizontalPadding).Underline(true).Render("N") +
+		noStyle.PaddingRight(horizontalPadding).Render("o")
+
+	details := r.details()
+	if len(r.checkpoint.Conflicts()) > 0 {
+		details[len(details)-1] = t.S().Base.Foreground(t.Error).Render(details[len(details)-1])
+	}
+	width := r.width()
+	buttons := baseStyle.Width(width).Align(lipgloss.Right).Render(
+		lipgloss.JoinHorizontal(lipgloss.Center, yesButton, "  ", noButton),
+	)
+
+	content := baseStyle.Render(
+		lipgloss.JoinVertical(
+			lipgloss.Left,
+			question,
+			"",
+			t.S().Muted.Render(lipgloss.JoinVertical(lipgloss.Left, details...)),
+			"",
+			buttons,
+		),
+	)
+
+	rewindDialogStyle := baseStyle.
+		Padding(1, 2).
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(t.BorderFocus)
+
+	return rewindDialogStyle.Render(content)
+}
+
+// width returns the width of the content of the dialog.
+func (r *rewindDialogCmp) width() int {
+	width := lipgloss.Width(question)
+	for _, line := range r.details() {
+		width = max(width, lipgloss.Width(line))
+	}
+	return width
+}
+
+func (r *rewindDialogCmp) Position() (int, int) {
+	row := r.wHeight / 2
+	row -= (8 + len(r.details())) / 2
+	col := r.wWidth / 2
+	col -= (r.width() + 6) / 2
+
+	return row, col
+}
+
+func (r *rewindDialogCmp) ID() dialogs.DialogID {
+	return RewindDialogID
+}
@@ -0,0 +1,234 @@
+package search
+
+import (
+	"context"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/v2/help"
+	"github.com/charmbracelet/bubbles/v2/key"
+	"github.com/charmbracelet/bubbles/v2/textinput"
+	tea "github.com/charmbracelet/bubbletea/v2"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/charmbracelet/crush/internal/session"
+	"github.com/charmbracelet/crush/internal/tui/components/chat"
+	"github.com/charmbracelet/crush/internal/tui/components/core"
+	"github.com/charmbracelet/crush/internal/tui/components/dialogs"
+	"github.com/charmbracelet/crush/internal/tui/exp/list"
+	"github.com/charmbracelet/crush/internal/tui/styles"
+	"github.com/charmbracelet/crush/internal/tui/util"
+	"github.com/charmbracelet/lipgloss/v2"
+)
+
+const (
+	SearchDialogID dialogs.DialogID = "search"
+
+	maxResults = 50
+)
+
+// SearchDialog interface for the dialog searching the messages of all the
+// sessions
+type SearchDialog interface {
+	dialogs.DialogModel
+}
+
+type ResultsList = list.List[list.CompletionItem[message.SearchResult]]
+
+// resultsMsg carries the results of the search for a query.
+type resultsMsg struct {
+	query   string
+	results []message.SearchResult
+}
+
+type searchDialogCmp struct {
+	wWidth   int
+	wHeight  int
+	width    int
+	keyMap   KeyMap
+	sessions session.Service
+	messages message.Service
+	input    textinput.Model
+	query    string
+	results  ResultsList
+	count    int
+	help     help.Model
+}
+
+// NewSearchDialogCmp creates a dialog to search the messages of all the
+// sessions and jump to one of them.
+func NewSearchDialogCmp(sessions session.Service, messages message.Service) SearchDialog {
+	t := styles.CurrentTheme()
+	listKeyMap := list.DefaultKeyMap()
+	keyMap := DefaultKeyMap()
+	listKeyMap.Down.SetEnabled(false)
+	listKeyMap.Up.SetEnabled(false)
+	listKeyMap.DownOneItem = keyMap.Next
+	listKeyMap.UpOneItem = keyMap.Previous
+
+	input := textinput.New()
+	input.Placeholder = "Search messages"
+	input.SetVirtualCursor(false)
+	input.Focus()
+	input.SetStyles(t.S().TextInput)
+
+	help := help.New()
+	help.Styles = t.S().Help
+	return &searchDialogCmp{
+		keyMap:   keyMap,
+		sessions: sessions,
+		messages: messages,
+		input:    input,
+		results: list.New(
+			[]list.CompletionItem[message.SearchResult]{},
+			list.WithKeyMap(listKeyMap),
+			list.WithWrapNavigation(),
+		),
+		help: help,
+	}
+}
+
+func (s *searchDialogCmp) Init() tea.Cmd {
+	return s.results.Init()
+}
+
+func (s *searchDialogCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		s.wWidth = msg.Width
+		s.wHeight = msg.Height
+		s.width = min(120, s.wWidth-8)
+		s.input.SetWidth(s.listWidth() - 2)
+		return s, s.results.SetSize(s.listWidth(), s.listHeight())
+	case resultsMsg:
+		// Results of a query that has been typed over since are dropped.
+		if msg.query != s.query {
+			return s, nil
+		}
+		s.count = len(msg.results)
+		return s, s.results.SetItems(s.resultItems(msg.results))
+	case tea.KeyPressMsg:
+		switch {
+		case key.Matches(msg, s.keyMap.Select):
+			selectedItem := s.results.SelectedItem()
+			if selectedItem == nil {
+				return s, nil
+			}
+			return s, s.open((*selectedItem).Value())
+		case key.Matches(msg, s.keyMap.Close):
+			return s, util.CmdHandler(dialogs.CloseDialogMsg{})
+		case key.Matches(msg, s.keyMap.Next), key.Matches(msg, s.keyMap.Previous):
+			u, cmd := s.results.Update(msg)
+			s.results = u.(ResultsList)
+			return s, cmd
+		default:
+			var cmd tea.Cmd
+			s.input, cmd = s.input.Update(msg)
+			if s.input.Value() == s.query {
+				return s, cmd
+			}
+			s.query = s.input.Value()
+			return s, tea.Batch(cmd, s.search(s.query))
+		}
+	}
+	return s, nil
+}
+
+func (s *searchDialogCmp) search(query string) tea.Cmd {
+	return func() tea.Msg {
+		results, err := s.messages.Search(context.Background(), query, maxResults)
+		if err != nil {
+			return util.InfoMsg{Type: util.InfoTypeError, Msg: err.Error()}
+		}
+		return resultsMsg{query: query, results: results}
+	}
+}
+
+// open switches to the session of the result and selects its message.
+func (s *searchDialogCmp) open(result message.SearchResult) tea.Cmd {
+	sess, err := s.sessions.Get(context.Background(), result.SessionID)
+	if err != nil {
+		return util.ReportError(err)
+	}
+	return tea.Sequence(
+		util.CmdHandler(dialogs.CloseDialogMsg{}),
+		util.CmdHandler(chat.SessionSelectedMsg(sess)),
+		util.CmdHandler(chat.MessageSelectedMsg{MessageID: result.MessageID}),
+	)
+}
+
+func (s *searchDialogCmp) resultItems(results []message.SearchResult) []list.CompletionItem[message.SearchResult] {
+	const maxTitleWidth = 30
+	items := make([]list.CompletionItem[message.SearchResult], len(results))
+	for i, result := range results {
+		title := result.SessionTitle
+		if len([]rune(title)) > maxTitleWidth {
+			title = string([]rune(title)[:maxTitleWidth-1]) + "…"
+		}
+		items[i] = list.NewCompletionItem(
+			strings.Join(strings.Fields(result.Snippet), " "),
+			result,
+			list.WithCompletionID(result.MessageID),
+			list.WithCompletionShortcut(title),
+		)
+	}
+	return items
+}
+
+func (s *searchDialogCmp) View() string {
+	t := styles.CurrentTheme()
+	listView := s.results.View()
+	switch {
+	case strings.TrimSpace(s.query) == "":
+		listView = t.S().Muted.PaddingLeft(1).Render("Type to search the messages of every session")
+	case s.count == 0:
+		listView = t.S().Muted.PaddingLeft(1).Render("No matching messages")
+	}
+	content := lipgloss.JoinVertical(
+		lipgloss.Left,
+		t.S().Base.Padding(0, 1, 1, 1).Render(core.Title("Search Sessions", s.width-4)),
+		t.S().Base.PaddingLeft(1).PaddingBottom(1).Render(s.input.View()),
+		listView,
+		"",
+		t.S().Base.Width(s.width-2).PaddingLeft(1).AlignHorizontal(lipgloss.Left).Render(s.help.View(s.keyMap)),
+	)
+
+	return s.style().Render(content)
+}
+
+func (s *searchDialogCmp) Cursor() *tea.Cursor {
+	cursor := s.input.Cursor()
+	if cursor == nil {
+		return nil
+	}
+	row, col := s.Position()
+	cursor.Y += row + 3 // Border + title
+	cursor.X += col + 2
+	return cursor
+}
+
+func (s *searchDialogCmp) style() lipgloss.Style {
+	t := styles.CurrentTheme()
+	return t.S().Base.
+		Width(s.width).
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(t.BorderFocus)
+}
+
+func (s *searchDialogCmp) listHeight() int {
+	return s.wHeight/2 - 8 // 7 for the border, title, input and help
+}
+
+func (s *searchDialogCmp) listWidth() int {
+	return s.width - 2 // 2 for the border
+}
+
+func (s *searchDialogCmp) Position() (int, int) {
+	row := s.wHeight/4 - 2 // just a bit above the center
+	col := s.wWidth / 2
+	col -= s.width / 2
+	return row, col
+}
+
+// ID implements SearchDialog.
+func (s *searchDialogCmp) ID() dialogs.DialogID {
+	return SearchDialogID
+}
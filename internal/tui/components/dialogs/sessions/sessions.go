@@ -34,7 +34,9 @@ type sessionDialogCmp struct {
 	help              help.Model
 }
 
-// NewSessionDialogCmp creates a new session switching dialog
+// NewSessionDialogCmp creates a new session switching dialog. The attempts
+// kept when retrying a session are listed under it, and the other child
+// sessions are left out.
 func NewSessionDialogCmp(sessions []session.Session, selectedID string) SessionDialog {
 	t := styles.CurrentTheme()
 	listKeyMap := list.DefaultKeyMap()
@@ -44,10 +46,20 @@ func NewSessionDialogCmp(sessions []session.Session, selectedID string) SessionD
 	listKeyMap.DownOneItem = keyMap.Next
 	listKeyMap.UpOneItem = keyMap.Previous
 
-	items := make([]list.CompletionItem[session.Session], len(sessions))
-	if len(sessions) > 0 {
-		for i, session := range sessions {
-			items[i] = list.NewCompletionItem(session.Title, session, list.WithCompletionID(session.ID))
+	attempts := make(map[string][]session.Session)
+	for _, s := range sessions {
+		if s.IsAttempt() {
+			attempts[s.ParentSessionID] = append(attempts[s.ParentSessionID], s)
+		}
+	}
+	var items []list.CompletionItem[session.Session]
+	for _, s := range sessions {
+		if s.ParentSessionID != "" {
+			continue
+		}
+		items = append(items, list.NewCompletionItem(s.Title, s, list.WithCompletionID(s.ID)))
+		for _, attempt := range attempts[s.ID] {
+			items = append(items, list.NewCompletionItem("  ↳ "+attempt.Title, attempt, list.WithCompletionID(attempt.ID)))
 		}
 	}
 
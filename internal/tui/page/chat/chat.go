@@ -12,10 +12,13 @@ import (
 	"github.com/charmbracelet/crush/internal/app"
 	"github.com/charmbracelet/crush/internal/config"
 	"github.com/charmbracelet/crush/internal/history"
+	"github.com/charmbracelet/crush/internal/llm/agent"
 	"github.com/charmbracelet/crush/internal/message"
 	"github.com/charmbracelet/crush/internal/permission"
 	"github.com/charmbracelet/crush/internal/pubsub"
 	"github.com/charmbracelet/crush/internal/session"
+	"github.com/charmbracelet/crush/internal/shell"
+	"github.com/charmbracelet/crush/internal/todo"
 	"github.com/charmbracelet/crush/internal/tui/components/anim"
 	"github.com/charmbracelet/crush/internal/tui/components/chat"
 	"github.com/charmbracelet/crush/internal/tui/components/chat/editor"
@@ -26,6 +29,8 @@ import (
 	"github.com/charmbracelet/crush/internal/tui/components/completions"
 	"github.com/charmbracelet/crush/internal/tui/components/core"
 	"github.com/charmbracelet/crush/internal/tui/components/core/layout"
+	"github.com/charmbracelet/crush/internal/tui/components/dialogs"
+	"github.com/charmbracelet/crush/internal/tui/components/dialogs/agents"
 	"github.com/charmbracelet/crush/internal/tui/components/dialogs/commands"
 	"github.com/charmbracelet/crush/internal/tui/components/dialogs/filepicker"
 	"github.com/charmbracelet/crush/internal/tui/components/dialogs/models"
@@ -97,7 +102,11 @@ type chatPage struct {
 
 	// Session
 	session session.Session
-	keyMap  KeyMap
+	// The agent new sessions are run with, the coder when empty.
+	agentID string
+	// Whether new sessions start in plan mode.
+	planMode bool
+	keyMap   KeyMap
 
 	// Components
 	header  header.Header
@@ -119,7 +128,7 @@ func New(app *app.App) ChatPage {
 		app:         app,
 		keyMap:      DefaultKeyMap(),
 		header:      header.New(app.LSPClients),
-		sidebar:     sidebar.New(app.History, app.LSPClients, false),
+		sidebar:     sidebar.New(app.History, app.Todos, app.Jobs, app.LSPClients, false),
 		chat:        chat.New(app),
 		editor:      editor.New(app),
 		splash:      splash.New(),
@@ -237,6 +246,20 @@ func (p *chatPage) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return p, p.sendMessage(msg.Text, msg.Attachments)
 	case chat.SessionSelectedMsg:
 		return p, p.setSession(msg)
+	case chat.MessageSelectedMsg:
+		// The list only scrolls to the selected message when focused.
+		if p.focusedPane == PanelTypeEditor {
+			p.changeFocus()
+		}
+		u, cmd := p.chat.Update(msg)
+		p.chat = u.(chat.MessageListCmp)
+		return p, cmd
+	case chat.ReloadSessionMsg:
+		u, cmd := p.chat.Update(msg)
+		p.chat = u.(chat.MessageListCmp)
+		return p, cmd
+	case chat.EditPromptMsg:
+		return p, p.editPrompt(msg.Text)
 	case splash.SubmitAPIKeyMsg:
 		u, cmd := p.splash.Update(msg)
 		p.splash = u.(splash.Splash)
@@ -255,6 +278,18 @@ func (p *chatPage) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return p, tea.Batch(p.SetSize(p.width, p.height), cmd)
 	case commands.ToggleThinkingMsg:
 		return p, p.toggleThinking()
+	case commands.SwitchAgentMsg:
+		agentID := p.agentID
+		if p.session.ID != "" {
+			agentID = p.session.AgentID
+		}
+		return p, util.CmdHandler(dialogs.OpenDialogMsg{
+			Model: agents.NewAgentDialogCmp(config.Get().SessionAgent(agentID).ID),
+		})
+	case agents.AgentSelectedMsg:
+		return p, p.selectAgent(msg.Agent)
+	case commands.TogglePlanModeMsg:
+		return p, p.togglePlanMode()
 	case commands.OpenExternalEditorMsg:
 		u, cmd := p.editor.Update(msg)
 		p.editor = u.(editor.Editor)
@@ -312,12 +347,13 @@ func (p *chatPage) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		u, cmd := p.editor.Update(msg)
 		p.editor = u.(editor.Editor)
 		return p, cmd
-	case pubsub.Event[history.File], sidebar.SessionFilesMsg:
+	case pubsub.Event[history.File], sidebar.SessionFilesMsg,
+		pubsub.Event[todo.Todo], sidebar.SessionTodosMsg, pubsub.Event[shell.Job]:
 		u, cmd := p.sidebar.Update(msg)
 		p.sidebar = u.(sidebar.Sidebar)
 		cmds = append(cmds, cmd)
 		return p, tea.Batch(cmds...)
-	case pubsub.Event[permission.PermissionNotification]:
+	case pubsub.Event[permission.PermissionNotification], pubsub.Event[agent.AgentEvent]:
 		u, cmd := p.chat.Update(msg)
 		p.chat = u.(chat.MessageListCmp)
 		cmds = append(cmds, cmd)
@@ -364,7 +400,7 @@ func (p *chatPage) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 			return p, p.newSession()
 		case key.Matches(msg, p.keyMap.AddAttachment):
-			agentCfg := config.Get().Agents["coder"]
+			agentCfg := config.Get().SessionAgent(p.session.AgentID)
 			model := config.Get().GetModelByType(agentCfg.Model)
 			if model.SupportsImages {
 				return p, util.CmdHandler(commands.OpenFilePickerMsg{})
@@ -637,6 +673,68 @@ func (p *chatPage) setSession(session session.Session) tea.Cmd {
 	return tea.Sequence(cmds...)
 }
 
+// selectAgent runs the current session and the sessions created next with the
+// agent.
+func (p *chatPage) selectAgent(agentCfg config.Agent) tea.Cmd {
+	if p.session.ID == "" {
+		p.agentID = agentCfg.ID
+		return util.ReportInfo(fmt.Sprintf("New sessions will be run with the %s agent", agentCfg.Name))
+	}
+	if p.app.CoderAgent.IsSessionBusy(p.session.ID) {
+		return util.ReportWarn("Agent is busy, please wait...")
+	}
+	// The session in the page doesn't follow the token usage.
+	sess, err := p.app.Sessions.Get(context.Background(), p.session.ID)
+	if err != nil {
+		return util.ReportError(err)
+	}
+	sess.AgentID = agentCfg.ID
+	sess, err = p.app.Sessions.Save(context.Background(), sess)
+	if err != nil {
+		return util.ReportError(err)
+	}
+	p.session = sess
+	p.agentID = agentCfg.ID
+	return util.ReportInfo(fmt.Sprintf("Switched to the %s agent", agentCfg.Name))
+}
+
+// togglePlanMode switches the current session, or the sessions created next,
+// in or out of plan mode.
+func (p *chatPage) togglePlanMode() tea.Cmd {
+	if p.session.ID == "" {
+		p.planMode = !p.planMode
+		if p.planMode {
+			return util.ReportInfo("New sessions will start in plan mode")
+		}
+		return util.ReportInfo("New sessions will start out of plan mode")
+	}
+	// The session in the page doesn't follow plan approvals.
+	sess, err := p.app.Sessions.Get(context.Background(), p.session.ID)
+	if err != nil {
+		return util.ReportError(err)
+	}
+	sess, err = p.app.SetPlanMode(context.Background(), sess.ID, !sess.PlanMode)
+	if err != nil {
+		return util.ReportError(err)
+	}
+	p.session = sess
+	p.planMode = sess.PlanMode
+	if sess.PlanMode {
+		return util.ReportInfo("Plan mode on, the agent will plan the work for your approval")
+	}
+	return util.ReportInfo("Plan mode off")
+}
+
+// editPrompt focuses the editor with the prompt in it.
+func (p *chatPage) editPrompt(text string) tea.Cmd {
+	if p.focusedPane == PanelTypeChat {
+		p.changeFocus()
+	}
+	u, cmd := p.editor.Update(editor.OpenEditorMsg{Text: text})
+	p.editor = u.(editor.Editor)
+	return cmd
+}
+
 func (p *chatPage) changeFocus() {
 	if p.session.ID == "" {
 		return
@@ -693,6 +791,14 @@ func (p *chatPage) sendMessage(text string, attachments []message.Attachment) te
 		if err != nil {
 			return util.ReportError(err)
 		}
+		if p.agentID != "" || p.planMode {
+			newSession.AgentID = p.agentID
+			newSession.PlanMode = p.planMode
+			newSession, err = p.app.Sessions.Save(context.Background(), newSession)
+			if err != nil {
+				return util.ReportError(err)
+			}
+		}
 		session = newSession
 		cmds = append(cmds, util.CmdHandler(chat.SessionSelectedMsg(session)))
 	}
@@ -909,6 +1015,8 @@ func (p *chatPage) Help() help.KeyMap {
 					key.WithHelp("↑↓", "scroll"),
 				),
 				messages.CopyKey,
+				messages.RewindKey,
+				messages.ForkKey,
 			)
 			fullList = append(fullList,
 				[]key.Binding{
@@ -951,6 +1059,11 @@ func (p *chatPage) Help() help.KeyMap {
 					messages.CopyKey,
 					messages.ClearSelectionKey,
 				},
+				[]key.Binding{
+					messages.RewindKey,
+					messages.ForkKey,
+					messages.EditKey,
+				},
 			)
 		case PanelTypeEditor:
 			newLineBinding := key.NewBinding(
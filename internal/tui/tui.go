@@ -3,17 +3,24 @@ package tui
 import (
 	"context"
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/charmbracelet/bubbles/v2/key"
 	tea "github.com/charmbracelet/bubbletea/v2"
 	"github.com/charmbracelet/crush/internal/app"
+	"github.com/charmbracelet/crush/internal/checkpoint"
 	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/export"
 	"github.com/charmbracelet/crush/internal/llm/agent"
+	"github.com/charmbracelet/crush/internal/llm/tools"
 	"github.com/charmbracelet/crush/internal/permission"
 	"github.com/charmbracelet/crush/internal/pubsub"
+	"github.com/charmbracelet/crush/internal/session"
 	cmpChat "github.com/charmbracelet/crush/internal/tui/components/chat"
+	"github.com/charmbracelet/crush/internal/tui/components/chat/messages"
 	"github.com/charmbracelet/crush/internal/tui/components/chat/splash"
 	"github.com/charmbracelet/crush/internal/tui/components/completions"
 	"github.com/charmbracelet/crush/internal/tui/components/core"
@@ -23,9 +30,13 @@ import (
 	"github.com/charmbracelet/crush/internal/tui/components/dialogs/commands"
 	"github.com/charmbracelet/crush/internal/tui/components/dialogs/compact"
 	"github.com/charmbracelet/crush/internal/tui/components/dialogs/filepicker"
+	"github.com/charmbracelet/crush/internal/tui/components/dialogs/grants"
 	"github.com/charmbracelet/crush/internal/tui/components/dialogs/models"
 	"github.com/charmbracelet/crush/internal/tui/components/dialogs/permissions"
+	"github.com/charmbracelet/crush/internal/tui/components/dialogs/plan"
 	"github.com/charmbracelet/crush/internal/tui/components/dialogs/quit"
+	"github.com/charmbracelet/crush/internal/tui/components/dialogs/rewind"
+	"github.com/charmbracelet/crush/internal/tui/components/dialogs/search"
 	"github.com/charmbracelet/crush/internal/tui/components/dialogs/sessions"
 	"github.com/charmbracelet/crush/internal/tui/page"
 	"github.com/charmbracelet/crush/internal/tui/page/chat"
@@ -71,7 +82,9 @@ type appModel struct {
 	isConfigured bool
 
 	// Chat Page Specific
-	selectedSessionID string // The ID of the currently selected session
+	selectedSessionID string          // The ID of the currently selected session
+	initialSession    session.Session // The session to open on start, if any
+	retrySessionID    string          // The session to retry once a model is selected, if any
 }
 
 // Init initializes the application model and returns initial commands.
@@ -86,6 +99,10 @@ func (a appModel) Init() tea.Cmd {
 
 	cmds = append(cmds, tea.EnableMouseAllMotion)
 
+	if a.initialSession.ID != "" {
+		cmds = append(cmds, util.CmdHandler(cmpChat.SessionSelectedMsg(a.initialSession)))
+	}
+
 	return tea.Batch(cmds...)
 }
 
@@ -153,13 +170,19 @@ func (a *appModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	// Commands
 	case commands.SwitchSessionsMsg:
 		return a, func() tea.Msg {
-			allSessions, _ := a.app.Sessions.List(context.Background())
+			allSessions, _ := a.app.Sessions.ListAll(context.Background())
 			return dialogs.OpenDialogMsg{
 				Model: sessions.NewSessionDialogCmp(allSessions, a.selectedSessionID),
 			}
 		}
 
+	case commands.SearchSessionsMsg:
+		return a, util.CmdHandler(dialogs.OpenDialogMsg{
+			Model: search.NewSearchDialogCmp(a.app.Sessions, a.app.Messages),
+		})
+
 	case commands.SwitchModelMsg:
+		a.retrySessionID = ""
 		return a, util.CmdHandler(
 			dialogs.OpenDialogMsg{
 				Model: models.NewModelDialogCmp(),
@@ -170,12 +193,56 @@ func (a *appModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return a, util.CmdHandler(dialogs.OpenDialogMsg{
 			Model: compact.NewCompactDialogCmp(a.app.CoderAgent, msg.SessionID, true),
 		})
+	case commands.ExportSessionMsg:
+		return a, a.exportSession(msg.SessionID)
+	// Retry
+	case commands.RetryMsg:
+		return a, a.retry(msg.SessionID)
+	case commands.RetryWithModelMsg:
+		// The session is retried once another model is selected.
+		a.retrySessionID = msg.SessionID
+		return a, util.CmdHandler(
+			dialogs.OpenDialogMsg{
+				Model: models.NewModelDialogCmp(),
+			},
+		)
+	// Rewind
+	case messages.RewindMsg:
+		if a.app.CoderAgent.IsSessionBusy(msg.SessionID) {
+			return a, util.ReportWarn("Agent is working, please wait...")
+		}
+		return a, a.planRewind(msg.SessionID, msg.MessageID)
+	case rewind.RewindConfirmedMsg:
+		return a, a.rewind(msg.Checkpoint)
+	// Plan
+	case plan.PlanApprovedMsg:
+		return a, a.approvePlan(msg.SessionID, msg.Plan)
+	case plan.PlanEditMsg:
+		if _, err := a.app.SetPlanMode(context.Background(), msg.SessionID, false); err != nil {
+			return a, util.ReportError(err)
+		}
+		return a, util.CmdHandler(cmpChat.EditPromptMsg{Text: app.ExecutePlanPrompt(msg.Plan)})
+	case plan.PlanRejectedMsg:
+		return a, util.ReportInfo("Plan rejected, tell the agent what to change")
+	// Fork
+	case messages.ForkMsg:
+		return a, a.fork(msg.SessionID, msg.MessageID, msg.Edit)
 	case commands.QuitMsg:
 		return a, util.CmdHandler(dialogs.OpenDialogMsg{
 			Model: quit.NewQuitDialog(),
 		})
 	case commands.ToggleYoloModeMsg:
 		a.app.Permissions.SetSkipRequests(!a.app.Permissions.SkipRequests())
+	case commands.ManagePermissionsMsg:
+		return a, func() tea.Msg {
+			allGrants, err := a.app.Permissions.ListGrants()
+			if err != nil {
+				return util.InfoMsg{Type: util.InfoTypeError, Msg: err.Error()}
+			}
+			return dialogs.OpenDialogMsg{
+				Model: grants.NewGrantsDialogCmp(a.app.Permissions, allGrants),
+			}
+		}
 	case commands.ToggleHelpMsg:
 		a.status.ToggleFullHelp()
 		a.showingFullHelp = !a.showingFullHelp
@@ -196,6 +263,10 @@ func (a *appModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		if msg.ModelType == config.SelectedModelTypeSmall {
 			modelTypeName = "small"
 		}
+		if sessionID := a.retrySessionID; sessionID != "" {
+			a.retrySessionID = ""
+			return a, a.retry(sessionID)
+		}
 		return a, util.ReportInfo(fmt.Sprintf("%s model changed to %s", modelTypeName, msg.Model.Model))
 
 	// File Picker
@@ -229,7 +300,11 @@ func (a *appModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case permissions.PermissionAllow:
 			a.app.Permissions.Grant(msg.Permission)
 		case permissions.PermissionAllowForSession:
-			a.app.Permissions.GrantPersistent(msg.Permission)
+			a.app.Permissions.GrantPersistent(msg.Permission, permission.ScopeSession)
+		case permissions.PermissionAllowForProject:
+			a.app.Permissions.GrantPersistent(msg.Permission, permission.ScopeProject)
+		case permissions.PermissionAllowAlways:
+			a.app.Permissions.GrantPersistent(msg.Permission, permission.ScopeAlways)
 		case permissions.PermissionDeny:
 			a.app.Permissions.Deny(msg.Permission)
 		}
@@ -253,7 +328,8 @@ func (a *appModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				model := a.app.CoderAgent.Model()
 				contextWindow := model.ContextWindow
 				tokens := session.CompletionTokens + session.PromptTokens
-				if (tokens >= int64(float64(contextWindow)*0.95)) && !config.Get().Options.DisableAutoSummarize { // Show compact confirmation dialog
+				options := config.Get().Options
+				if (tokens >= int64(float64(contextWindow)*options.AutoSummarizeThreshold)) && !options.DisableAutoSummarize { // Show compact confirmation dialog
 					cmds = append(cmds, util.CmdHandler(dialogs.OpenDialogMsg{
 						Model: compact.NewCompactDialogCmp(a.app.CoderAgent, a.selectedSessionID, false),
 					}))
@@ -261,6 +337,29 @@ func (a *appModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 		}
 
+		// Summaries triggered automatically by the agent in the middle of a
+		// request are only reported in the status bar.
+		if payload.Type == agent.AgentEventTypeSummarize &&
+			payload.SessionID == a.selectedSessionID &&
+			a.dialog.ActiveDialogID() != compact.CompactDialogID {
+			cmds = append(cmds, util.ReportInfo("Summarizing conversation: "+payload.Progress))
+		}
+
+		// Plans submitted in plan mode wait for the approval of the user.
+		if payload.Type == agent.AgentEventTypeResponse &&
+			payload.Message.SessionID == a.selectedSessionID {
+			cmds = append(cmds, a.openPendingPlan(payload.Message.SessionID))
+		}
+
+		// The output of running tool calls is shown in the chat.
+		if payload.Type == agent.AgentEventTypeToolOutput {
+			if item, ok := a.pages[a.currentPage]; ok {
+				updated, pageCmd := item.Update(msg)
+				a.pages[a.currentPage] = updated.(util.Model)
+				cmds = append(cmds, pageCmd)
+			}
+		}
+
 		return a, tea.Batch(cmds...)
 	case splash.OnboardingCompleteMsg:
 		item, ok := a.pages[a.currentPage]
@@ -424,7 +523,7 @@ func (a *appModel) handleKeyPressMsg(msg tea.KeyPressMsg) tea.Cmd {
 		}
 		cmds = append(cmds,
 			func() tea.Msg {
-				allSessions, _ := a.app.Sessions.List(context.Background())
+				allSessions, _ := a.app.Sessions.ListAll(context.Background())
 				return dialogs.OpenDialogMsg{
 					Model: sessions.NewSessionDialogCmp(allSessions, a.selectedSessionID),
 				}
@@ -550,8 +649,134 @@ func (a *appModel) View() tea.View {
 	return view
 }
 
+// exportSession writes the session as a Markdown transcript to the working
+// directory.
+func (a *appModel) exportSession(sessionID string) tea.Cmd {
+	return func() tea.Msg {
+		services := export.Services{
+			Sessions: a.app.Sessions,
+			Messages: a.app.Messages,
+			History:  a.app.History,
+		}
+		bundle, err := export.Load(context.Background(), services, sessionID, a.app.Config().WorkingDir())
+		if err != nil {
+			return util.InfoMsg{Type: util.InfoTypeError, Msg: err.Error()}
+		}
+		name := "crush-session-" + sessionID[:min(8, len(sessionID))] + export.Markdown.Extension()
+		path := filepath.Join(a.app.Config().WorkingDir(), name)
+		f, err := os.Create(path)
+		if err != nil {
+			return util.InfoMsg{Type: util.InfoTypeError, Msg: err.Error()}
+		}
+		defer f.Close()
+		if err := bundle.Write(f, export.Markdown); err != nil {
+			return util.InfoMsg{Type: util.InfoTypeError, Msg: err.Error()}
+		}
+		return util.InfoMsg{Type: util.InfoTypeInfo, Msg: "Exported session to " + name}
+	}
+}
+
+// planRewind opens the dialog confirming the rewind of the session to before
+// the message.
+func (a *appModel) planRewind(sessionID, messageID string) tea.Cmd {
+	return func() tea.Msg {
+		services := checkpoint.Services{
+			Sessions: a.app.Sessions,
+			Messages: a.app.Messages,
+			History:  a.app.History,
+		}
+		c, err := checkpoint.Plan(context.Background(), services, sessionID, messageID)
+		if err != nil {
+			return util.InfoMsg{Type: util.InfoTypeError, Msg: err.Error()}
+		}
+		return dialogs.OpenDialogMsg{Model: rewind.NewRewindDialog(c)}
+	}
+}
+
+// rewind rewinds the session to the checkpoint, putting the prompt of the
+// removed message back in the editor. Conflicting files are overwritten, as
+// the dialog warned about them.
+func (a *appModel) rewind(c checkpoint.Checkpoint) tea.Cmd {
+	if err := a.app.Rewind(context.Background(), c, true); err != nil {
+		return util.ReportError(err)
+	}
+	return tea.Sequence(
+		util.CmdHandler(cmpChat.ReloadSessionMsg{SessionID: c.SessionID}),
+		util.CmdHandler(cmpChat.EditPromptMsg{Text: c.Message.Content().Text}),
+	)
+}
+
+// openPendingPlan opens the dialog approving the plan waiting for approval in
+// the session, if any.
+func (a *appModel) openPendingPlan(sessionID string) tea.Cmd {
+	return func() tea.Msg {
+		p, ok, err := a.app.PendingPlan(context.Background(), sessionID)
+		if err != nil {
+			return util.InfoMsg{Type: util.InfoTypeError, Msg: err.Error()}
+		}
+		if !ok {
+			return nil
+		}
+		return dialogs.OpenDialogMsg{Model: plan.NewPlanDialog(sessionID, p)}
+	}
+}
+
+// approvePlan takes the session out of plan mode and has the agent carry out
+// the plan.
+func (a *appModel) approvePlan(sessionID string, p tools.PlanParams) tea.Cmd {
+	if err := a.app.ApprovePlan(context.Background(), sessionID, p); err != nil {
+		return util.ReportError(err)
+	}
+	return util.ReportInfo("Plan approved, carrying it out")
+}
+
+// retry runs the last turn of the session again.
+func (a *appModel) retry(sessionID string) tea.Cmd {
+	attempt, err := a.app.Retry(context.Background(), sessionID)
+	if err != nil {
+		return util.ReportError(err)
+	}
+	return tea.Sequence(
+		util.CmdHandler(cmpChat.ReloadSessionMsg{SessionID: sessionID}),
+		util.ReportInfo("Retrying, the previous attempt is listed under this session as "+attempt.Title),
+	)
+}
+
+// fork forks the session from the message and switches to the fork. When
+// editing, the prompt of the message is put in the editor to be sent again.
+func (a *appModel) fork(sessionID, messageID string, edit bool) tea.Cmd {
+	services := checkpoint.Services{
+		Sessions: a.app.Sessions,
+		Messages: a.app.Messages,
+		History:  a.app.History,
+	}
+	if !edit {
+		forked, err := checkpoint.Fork(context.Background(), services, sessionID, messageID)
+		if err != nil {
+			return util.ReportError(err)
+		}
+		return tea.Sequence(
+			util.CmdHandler(cmpChat.SessionSelectedMsg(forked)),
+			util.ReportInfo("Forked session "+forked.Title),
+		)
+	}
+
+	msg, err := a.app.Messages.Get(context.Background(), messageID)
+	if err != nil {
+		return util.ReportError(err)
+	}
+	forked, err := checkpoint.ForkBefore(context.Background(), services, sessionID, messageID)
+	if err != nil {
+		return util.ReportError(err)
+	}
+	return tea.Sequence(
+		util.CmdHandler(cmpChat.SessionSelectedMsg(forked)),
+		util.CmdHandler(cmpChat.EditPromptMsg{Text: msg.Content().Text}),
+	)
+}
+
 // New creates and initializes a new TUI application model.
-func New(app *app.App) tea.Model {
+func New(app *app.App, initialSession session.Session) tea.Model {
 	chatPage := chat.New(app)
 	keyMap := DefaultKeyMap()
 	keyMap.pageBindings = chatPage.Bindings()
@@ -569,6 +794,8 @@ func New(app *app.App) tea.Model {
 
 		dialog:      dialogs.NewDialogCmp(),
 		completions: completions.New(),
+
+		initialSession: initialSession,
 	}
 
 	return model
@@ -11,9 +11,13 @@ import (
 
 	"github.com/charmbracelet/crush/internal/cmd"
 	"github.com/charmbracelet/crush/internal/log"
+	"github.com/charmbracelet/crush/internal/shell"
 )
 
 func main() {
+	// Commands run in the sandbox go through crush itself.
+	shell.RunSandboxHelper()
+
 	defer log.RecoverPanic("main", func() {
 		slog.Error("Application terminated due to unhandled panic")
 	})